@@ -0,0 +1,401 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/pkg/errors"
+)
+
+func nArgs(args []string, n int, usage string) error {
+	if len(args) != n {
+		return errors.Errorf("usage: spookyctl %s", usage)
+	}
+	return nil
+}
+
+func listUsers(ctx context.Context, c *client, args []string) error {
+	fs := flag.NewFlagSet("users list", flag.ContinueOnError)
+	limit := fs.Int("limit", 50, "maximum number of users to list")
+	offset := fs.Int("offset", 0, "number of users to skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	resp, err := c.svc.ListUsers(ctx, &pb.ListUsersRequest{Limit: int32(*limit), Offset: int32(*offset)})
+	if err != nil {
+		return errors.Wrap(err, "failed to list users")
+	}
+	return c.out.Print(resp, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tEMAIL\tCART ITEMS\tORDERS")
+		for _, u := range resp.GetUsers() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", u.GetID(), u.GetDisplayName(), u.GetEmail(),
+				len(u.GetCart().GetItems()), len(u.GetTransactions()))
+		}
+	})
+}
+
+// lookupUser fetches a user, turning a missing user into an error.
+func lookupUser(ctx context.Context, c *client, id string) (*pb.User, error) {
+	resp, err := c.svc.GetUser(ctx, &pb.UserRequest{ID: id})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up the user")
+	} else if !resp.GetFound() {
+		return nil, errors.Errorf("user %s not found", id)
+	}
+	return resp.GetUser(), nil
+}
+
+func getUser(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 1, "users get <user-id>"); err != nil {
+		return err
+	}
+	u, err := lookupUser(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	return c.out.Print(u, func(w io.Writer) {
+		fmt.Fprintf(w, "ID:\t%s\n", u.GetID())
+		fmt.Fprintf(w, "Name:\t%s\n", u.GetDisplayName())
+		fmt.Fprintf(w, "Email:\t%s\n", u.GetEmail())
+		fmt.Fprintf(w, "Google ID:\t%s\n", u.GetGoogleID())
+		fmt.Fprintf(w, "Roles:\t%s\n", strings.Join(u.GetRoles(), ", "))
+		fmt.Fprintf(w, "Cart items:\t%d\n", len(u.GetCart().GetItems()))
+		fmt.Fprintf(w, "Cart total:\t%.2f\n", u.GetCart().GetTotalCost())
+		fmt.Fprintf(w, "Orders:\t%d\n", len(u.GetTransactions()))
+	})
+}
+
+func setRoles(ctx context.Context, c *client, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: spookyctl users roles <user-id> <role>...")
+	}
+	u, err := c.svc.SetUserRoles(ctx, &pb.SetUserRolesRequest{UserID: args[0], Roles: args[1:]})
+	if err != nil {
+		return errors.Wrap(err, "failed to set roles")
+	}
+	return c.out.Print(u, func(w io.Writer) {
+		fmt.Fprintf(w, "user %s now has roles: %s\n", u.GetID(), strings.Join(u.GetRoles(), ", "))
+	})
+}
+
+func listProducts(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 0, "products list"); err != nil {
+		return err
+	}
+	resp, err := c.svc.GetAllProducts(ctx, &pb.GetAllProductsRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list products")
+	}
+	return c.out.Print(resp, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tCOST\tDESCRIPTION")
+		for _, p := range resp.GetProductList() {
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\n", p.GetID(), p.GetDisplayName(), p.GetCost(), p.GetDescription())
+		}
+	})
+}
+
+func getProduct(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 1, "products get <product-id>"); err != nil {
+		return err
+	}
+	p, err := c.svc.GetProduct(ctx, &pb.GetProductRequest{ID: args[0]})
+	if err != nil {
+		return errors.Wrap(err, "failed to look up the product")
+	}
+	return c.out.Print(p, func(w io.Writer) {
+		fmt.Fprintf(w, "ID:\t%s\n", p.GetID())
+		fmt.Fprintf(w, "Name:\t%s\n", p.GetDisplayName())
+		fmt.Fprintf(w, "Cost:\t%.2f\n", p.GetCost())
+		fmt.Fprintf(w, "Description:\t%s\n", p.GetDescription())
+		fmt.Fprintf(w, "Picture:\t%s\n", p.GetPictureURL())
+	})
+}
+
+func listOrders(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 1, "orders list <user-id>"); err != nil {
+		return err
+	}
+	u, err := lookupUser(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	orders := &pb.User{ID: u.GetID(), Transactions: u.GetTransactions()}
+	return c.out.Print(orders, func(w io.Writer) {
+		fmt.Fprintln(w, "COMPLETED\tITEMS\tTOTAL")
+		for _, t := range u.GetTransactions() {
+			completed := "-"
+			if ts, err := ptypes.Timestamp(t.GetCompletedTime()); err == nil {
+				completed = ts.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%d\t%.2f\n", completed, len(t.GetItems().GetItems()), t.GetItems().GetTotalCost())
+		}
+	})
+}
+
+func showCart(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 1, "cart show <user-id>"); err != nil {
+		return err
+	}
+	u, err := lookupUser(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	cart := u.GetCart()
+	if cart == nil {
+		cart = &pb.Cart{}
+	}
+	return c.out.Print(cart, func(w io.Writer) {
+		fmt.Fprintln(w, "PRODUCT ID\tNAME\tCOST\tQUANTITY")
+		for _, i := range cart.GetItems() {
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%d\n", i.GetID(), i.GetDisplayName(), i.GetCost(), i.GetQuantity())
+		}
+		fmt.Fprintf(w, "\t\tTOTAL\t%.2f\n", cart.GetTotalCost())
+	})
+}
+
+func addToCart(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 3, "cart add <user-id> <product-id> <quantity>"); err != nil {
+		return err
+	}
+	quantity, err := strconv.ParseInt(args[2], 10, 32)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse quantity")
+	}
+	resp, err := c.svc.AddProductToCart(ctx, &pb.AddProductRequest{
+		UserID:    args[0],
+		ProductID: args[1],
+		Quantity:  int32(quantity),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to add product to cart")
+	}
+	return c.out.Print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "added %d of product %s to the cart of user %s\n", quantity, args[1], args[0])
+	})
+}
+
+func clearCart(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 1, "cart clear <user-id>"); err != nil {
+		return err
+	}
+	resp, err := c.svc.ClearCart(ctx, &pb.UserRequest{ID: args[0]})
+	if err != nil {
+		return errors.Wrap(err, "failed to clear cart")
+	}
+	return c.out.Print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "cleared the cart of user %s\n", args[0])
+	})
+}
+
+// catalogEntry mirrors an entry of cmd/spookystore/inventory/products.json.
+type catalogEntry struct {
+	Description string
+	Cost        float32
+	PictureURL  string
+}
+
+func importCatalog(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 1, "catalog import <products.json>"); err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return errors.Wrap(err, "failed to read catalog")
+	}
+	var entries map[string]catalogEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return errors.Wrap(err, "failed to parse catalog")
+	}
+
+	req := &pb.ImportProductsRequest{}
+	for name, e := range entries {
+		req.Products = append(req.Products, &pb.Product{
+			DisplayName: name,
+			Description: e.Description,
+			Cost:        e.Cost,
+			PictureURL:  e.PictureURL,
+		})
+	}
+	resp, err := c.svc.ImportProducts(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "failed to import catalog")
+	}
+	return c.out.Print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "created %d products, %d already present\n", resp.GetCreated(), resp.GetExisting())
+	})
+}
+
+func listTokens(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 1, "tokens list <user-id>"); err != nil {
+		return err
+	}
+	resp, err := c.svc.ListAPITokens(ctx, &pb.UserRequest{ID: args[0]})
+	if err != nil {
+		return errors.Wrap(err, "failed to list tokens")
+	}
+	return c.out.Print(resp, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tSCOPES\tEXPIRES\tREVOKED")
+		for _, t := range resp.GetTokens() {
+			expires := "-"
+			if ts, err := ptypes.Timestamp(t.GetExpires()); err == nil {
+				expires = ts.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", t.GetID(), t.GetName(), strings.Join(t.GetScopes(), ","), expires, t.GetRevoked())
+		}
+	})
+}
+
+func createToken(ctx context.Context, c *client, args []string) error {
+	fs := flag.NewFlagSet("tokens create", flag.ContinueOnError)
+	days := fs.Int("days", 30, "number of days until the token expires")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 3 {
+		return errors.New("usage: spookyctl tokens create [-days N] <user-id> <name> <scope>...")
+	}
+	if *days <= 0 {
+		return errors.New("-days must be positive")
+	}
+	resp, err := c.svc.CreateAPIToken(ctx, &pb.CreateAPITokenRequest{
+		UserID:     fs.Arg(0),
+		Name:       fs.Arg(1),
+		Scopes:     fs.Args()[2:],
+		TTLSeconds: int64(*days) * 24 * 60 * 60,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create token")
+	}
+	return c.out.Print(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "created token %s, it will not be shown again:\n%s\n", resp.GetInfo().GetID(), resp.GetToken())
+	})
+}
+
+func revokeToken(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 2, "tokens revoke <user-id> <token-id>"); err != nil {
+		return err
+	}
+	t, err := c.svc.RevokeAPIToken(ctx, &pb.RevokeAPITokenRequest{UserID: args[0], TokenID: args[1]})
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke token")
+	}
+	return c.out.Print(t, func(w io.Writer) {
+		fmt.Fprintf(w, "revoked token %s of user %s\n", t.GetID(), args[0])
+	})
+}
+
+// faultEntry is a fault in a faults file, such as:
+//
+//	[{"kind": "User", "op": "put", "rate": 0.5, "error": "unavailable", "partial": true},
+//	 {"op": "get", "rate": 1, "latency": "200ms"}]
+type faultEntry struct {
+	Kind    string   `json:"kind"`
+	Op      string   `json:"op"`
+	Rate    float64  `json:"rate"`
+	Latency duration `json:"latency"`
+	Error   string   `json:"error"`
+	Partial bool     `json:"partial"`
+}
+
+func showFaults(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 0, "faults show"); err != nil {
+		return err
+	}
+	resp, err := c.svc.GetDatastoreFaults(ctx, &pb.GetDatastoreFaultsRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get faults")
+	}
+	return printFaults(c, resp)
+}
+
+func setFaults(ctx context.Context, c *client, args []string) error {
+	fs := flag.NewFlagSet("faults set", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed of the draws of which calls get faults, 0 keeps the current one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: spookyctl faults set [-seed N] <faults.json>")
+	}
+	b, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return errors.Wrap(err, "failed to read faults")
+	}
+	var entries []faultEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return errors.Wrap(err, "failed to parse faults")
+	}
+	req := &pb.SetDatastoreFaultsRequest{Seed: *seed}
+	for _, e := range entries {
+		req.Faults = append(req.Faults, &pb.DatastoreFault{
+			Kind:          e.Kind,
+			Op:            e.Op,
+			Rate:          e.Rate,
+			LatencyMillis: int64(time.Duration(e.Latency) / time.Millisecond),
+			Error:         e.Error,
+			Partial:       e.Partial,
+		})
+	}
+	resp, err := c.svc.SetDatastoreFaults(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "failed to set faults")
+	}
+	return printFaults(c, resp)
+}
+
+func clearFaults(ctx context.Context, c *client, args []string) error {
+	if err := nArgs(args, 0, "faults clear"); err != nil {
+		return err
+	}
+	resp, err := c.svc.SetDatastoreFaults(ctx, &pb.SetDatastoreFaultsRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to clear faults")
+	}
+	return printFaults(c, resp)
+}
+
+func printFaults(c *client, resp *pb.DatastoreFaults) error {
+	return c.out.Print(resp, func(w io.Writer) {
+		if !resp.GetEnabled() {
+			fmt.Fprintln(w, "fault injection is off")
+			return
+		}
+		fmt.Fprintf(w, "seed %d\n", resp.GetSeed())
+		fmt.Fprintln(w, "KIND\tOP\tRATE\tLATENCY\tERROR\tPARTIAL")
+		for _, f := range resp.GetFaults() {
+			fmt.Fprintf(w, "%s\t%s\t%g\t%s\t%s\t%t\n", orAny(f.GetKind()), orAny(f.GetOp()), f.GetRate(),
+				time.Duration(f.GetLatencyMillis())*time.Millisecond, orAny(f.GetError()), f.GetPartial())
+		}
+	})
+}
+
+func orAny(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// config holds connection settings, read from a JSON file such as:
+//
+//	{"addr": "localhost:8001", "user": "5629499534213120", "output": "table", "timeout": "10s"}
+//
+// Instead of a user, it may hold an API token to authenticate with:
+//
+//	{"addr": "localhost:8001", "token": "spk_..."}
+type config struct {
+	Addr    string   `json:"addr"`
+	User    string   `json:"user"`
+	Token   string   `json:"token"`
+	Output  string   `json:"output"`
+	Timeout duration `json:"timeout"`
+}
+
+// duration is a time.Duration that is written as a string ("10s") in JSON.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func defaultConfigPath() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".spookyctl.json")
+}
+
+// loadConfig reads the config file at path. A missing file is not an error,
+// since every setting can also be given as a flag.
+func loadConfig(path string) (*config, error) {
+	cfg := &config{
+		Addr:    "localhost:8001",
+		Output:  "table",
+		Timeout: duration(10 * time.Second),
+	}
+	if path == "" {
+		return cfg, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse config file %s", path)
+	}
+	return cfg, nil
+}
+
+func (c *config) validate() error {
+	if c.Addr == "" {
+		return errors.New("spookystore address is not set")
+	}
+	if c.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+	return nil
+}
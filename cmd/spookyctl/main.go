@@ -0,0 +1,188 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// spookyctl is a command-line tool for operators of the SpookyStore backend.
+// It talks gRPC directly to the spookystore service.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/m-okeefe/spookystore/internal/auth"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	configPath = flag.String("config", defaultConfigPath(), "path to spookyctl config file")
+	addr       = flag.String("addr", "", "address of spookystore backend (overrides config)")
+	output     = flag.String("o", "", "output format: table, json (overrides config)")
+	timeout    = flag.Duration("timeout", 0, "timeout for each call to the backend (overrides config)")
+	asUser     = flag.String("as", "", "ID of the user to act as; needs the support or admin role for most commands (overrides config)")
+)
+
+const usage = `spookyctl - administer a SpookyStore backend
+
+Usage:
+  spookyctl [flags] <command> [args]
+
+Commands:
+  users list [-limit N] [-offset N]      list users
+  users get <user-id>                     show a single user
+  users roles <user-id> <role>...         set a user's roles (customer, support, admin)
+  products list                           list all products
+  products get <product-id>               show a single product
+  orders list <user-id>                   list a user's past transactions
+  cart show <user-id>                     show a user's cart
+  cart add <user-id> <product-id> <qty>   add a product to a user's cart
+  cart clear <user-id>                    empty a user's cart
+  catalog import <products.json>          import a products inventory file
+  tokens list <user-id>                   list a user's API tokens
+  tokens create [-days N] <user-id> <name> <scope>...
+                                          mint an API token with scopes read, cart, checkout, admin
+  tokens revoke <user-id> <token-id>      revoke an API token
+  faults show                             show the faults injected into datastore calls
+  faults set [-seed N] <faults.json>      replace the faults injected into datastore calls
+  faults clear                            stop injecting faults
+
+An API token in $SPOOKYCTL_TOKEN or the config file is used instead of -as.
+-as is for operators only: the backend refuses it unless
+$SPOOKYCTL_SERVICE_SECRET holds the secret it shares with web.
+
+Flags:
+`
+
+type command func(ctx context.Context, c *client, args []string) error
+
+var commands = map[string]map[string]command{
+	"users": {
+		"list":  listUsers,
+		"get":   getUser,
+		"roles": setRoles,
+	},
+	"products": {
+		"list": listProducts,
+		"get":  getProduct,
+	},
+	"orders": {
+		"list": listOrders,
+	},
+	"cart": {
+		"show":  showCart,
+		"add":   addToCart,
+		"clear": clearCart,
+	},
+	"catalog": {
+		"import": importCatalog,
+	},
+	"tokens": {
+		"list":   listTokens,
+		"create": createToken,
+		"revoke": revokeToken,
+	},
+	"faults": {
+		"show":  showFaults,
+		"set":   setFaults,
+		"clear": clearFaults,
+	},
+}
+
+// client bundles the backend connection with the output printer.
+type client struct {
+	svc pb.SpookyStoreClient
+	out printer
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "spookyctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) < 2 {
+		flag.Usage()
+		return errors.New("missing command")
+	}
+	group, ok := commands[args[0]]
+	if !ok {
+		return errors.Errorf("unknown command %q", args[0])
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		return errors.Errorf("unknown command %q %q", args[0], args[1])
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *addr != "" {
+		cfg.Addr = *addr
+	}
+	if *output != "" {
+		cfg.Output = *output
+	}
+	if *timeout != 0 {
+		cfg.Timeout = duration(*timeout)
+	}
+	if *asUser != "" {
+		cfg.User = *asUser
+	}
+	if t := os.Getenv("SPOOKYCTL_TOKEN"); t != "" {
+		cfg.Token = t
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	serviceSecret := os.Getenv("SPOOKYCTL_SERVICE_SECRET")
+	if cfg.Token == "" && cfg.User != "" && serviceSecret == "" {
+		return errors.New("-as needs the service secret in $SPOOKYCTL_SERVICE_SECRET, use an API token instead")
+	}
+
+	out, err := newPrinter(cfg.Output, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	dialCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout))
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, cfg.Addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return errors.Wrapf(err, "cannot connect to spookystore at %s", cfg.Addr)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout))
+	defer cancel()
+	if cfg.Token != "" {
+		ctx = auth.WithToken(ctx, cfg.Token)
+	} else if cfg.User != "" {
+		ctx = metadata.AppendToOutgoingContext(auth.WithUserID(ctx, cfg.User), auth.ServiceSecretKey, serviceSecret)
+	}
+	return cmd(ctx, &client{svc: pb.NewSpookyStoreClient(conn), out: out}, args[2:])
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// printer writes a command's result either as a table or as JSON.
+type printer interface {
+	// Print writes msg. Table printers call table to render the rows.
+	Print(msg proto.Message, table func(w io.Writer)) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "table":
+		return &tablePrinter{w: w}, nil
+	case "json":
+		return &jsonPrinter{w: w}, nil
+	default:
+		return nil, errors.Errorf("unknown output format %q (want table or json)", format)
+	}
+}
+
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p *tablePrinter) Print(_ proto.Message, table func(w io.Writer)) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+type jsonPrinter struct {
+	w io.Writer
+}
+
+func (p *jsonPrinter) Print(msg proto.Message, _ func(w io.Writer)) error {
+	m := jsonpb.Marshaler{Indent: "  ", EmitDefaults: true}
+	if err := m.Marshal(p.w, msg); err != nil {
+		return errors.Wrap(err, "failed to encode json")
+	}
+	_, err := fmt.Fprintln(p.w)
+	return err
+}
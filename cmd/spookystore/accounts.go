@@ -0,0 +1,179 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/datastore"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+)
+
+// googleProvider is the provider name AuthorizeGoogle links accounts under
+const googleProvider = "google"
+
+// identityKey is how an external identity is stored in User.Identities
+func identityKey(provider, subject string) string {
+	return provider + ":" + subject
+}
+
+// LinkAccount logs in the user behind an external identity, creating them on
+// first login. Identities are matched by provider and subject. A new identity
+// is linked to the existing user with the same email only if the provider has
+// verified that email. Only trusted services holding the service secret may
+// call it.
+func (s *Server) LinkAccount(ctx context.Context, req *pb.LinkAccountRequest) (*pb.User, error) {
+	if err := s.requireService(ctx); err != nil {
+		return nil, err
+	}
+	span := tracing.FromContext(ctx).NewChild("usersvc/LinkAccount")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":       "LinkAccount",
+		"provider": req.GetProvider(),
+		"subject":  req.GetSubject()})
+
+	if req.GetProvider() == "" || req.GetSubject() == "" {
+		return nil, invalidArgument("Subject", "provider and subject are required")
+	}
+	identity := identityKey(req.GetProvider(), req.GetSubject())
+	email := normalizeEmail(req.GetEmail())
+
+	cs := span.NewChild("datastore/query/user/by_identity")
+	u, err := s.findUser(ctx, "Identities =", identity)
+	if err == nil && u == nil && req.GetProvider() == googleProvider {
+		// users created before LinkAccount only have a GoogleID
+		u, err = s.findUser(ctx, "GoogleID =", req.GetSubject())
+	}
+	if err == nil && u == nil && req.GetEmailVerified() && email != "" {
+		u, err = s.findUser(ctx, "Email =", email)
+	}
+	cs.Finish()
+	if err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to look up user")
+	}
+
+	var id string
+	if u == nil {
+		cs = span.NewChild("datastore/put/user")
+		u = &User{
+			Email:         email,
+			EmailVerified: req.GetEmailVerified(),
+			DisplayName:   req.GetDisplayName(),
+			Picture:       req.GetPicture(),
+			Identities:    []string{identity},
+		}
+		if req.GetProvider() == googleProvider {
+			u.GoogleID = req.GetSubject()
+		}
+		if id, err = s.createUser(ctx, u); err != nil {
+			log.WithField("error", err).Error("failed to save to datastore")
+			return nil, err
+		}
+		log.WithField("id", id).Info("created new user")
+		cs.Finish()
+	} else {
+		id = fmt.Sprintf("%d", u.K.ID)
+		log = log.WithField("id", id)
+		log.Debug("user exists")
+
+		var linked, granted bool
+		if !hasIdentity(u, identity) {
+			u.Identities = append(u.Identities, identity)
+			if len(u.PasswordHash) > 0 && !u.EmailVerified {
+				// whoever registered this email locally never proved they
+				// own it, so they must not keep a way into the account
+				u.PasswordHash = nil
+			}
+			linked = true
+		}
+		verified := u.EmailVerified || (req.GetEmailVerified() && email == normalizeEmail(u.Email))
+		if verified && s.isAdminEmail(u.Email) {
+			granted = addAdmin(u)
+		}
+		if linked || granted {
+			if _, err := s.ds.Put(ctx, u.K, u); err != nil {
+				log.WithField("error", err).Error("failed to save to datastore")
+				return nil, storeError(err, "failed to save user")
+			}
+		}
+		if linked {
+			log.Info("linked identity to existing user")
+		}
+		if granted {
+			log.Info("granted admin role")
+		}
+	}
+
+	// retrieve user again from backend
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if !user.GetFound() {
+		return nil, status.Error(codes.Internal, "cannot find user that is just created")
+	}
+	return user.GetUser(), nil
+}
+
+// createUser saves a new User, granting admin to verified admin emails, and
+// returns its ID
+func (s *Server) createUser(ctx context.Context, u *User) (string, error) {
+	if u.EmailVerified && s.isAdminEmail(u.Email) {
+		addAdmin(u)
+	}
+
+	k, err := s.ds.Put(ctx, datastore.IncompleteKey("User", nil), u)
+	if err != nil {
+		return "", storeError(err, "failed to save user")
+	}
+	u.ID = fmt.Sprintf("%d", k.ID)
+	ik := datastore.IDKey("User", k.ID, nil)
+	if _, err := s.ds.Put(ctx, ik, u); err != nil {
+		return "", storeError(err, "failed to save user with ID")
+	}
+	u.K = ik
+	return u.ID, nil
+}
+
+// findUser returns the first User matching filter, or nil if there is none
+func (s *Server) findUser(ctx context.Context, filter string, value interface{}) (*User, error) {
+	q := datastore.NewQuery("User").Filter(filter, value).Limit(1)
+	var v []User
+	if _, err := s.ds.GetAll(ctx, q, &v); err != nil {
+		return nil, err
+	}
+	if len(v) == 0 {
+		return nil, nil
+	}
+	return &v[0], nil
+}
+
+func hasIdentity(u *User, identity string) bool {
+	for _, v := range u.Identities {
+		if v == identity {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/m-okeefe/spookystore/internal/auth"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authorize checks that the caller holds permission p over the user ownerID.
+// The caller is the user of the call's API token, if it has one, which must
+// also be scoped for p. Otherwise it is the user named in the incoming
+// request metadata.
+func (s *Server) authorize(ctx context.Context, p auth.Permission, ownerID string) error {
+	callerID, ok := auth.UserIDFromContext(ctx)
+	if c := auth.CallerFromContext(ctx); c != nil {
+		if !auth.ScopesAllow(c.Scopes, p) {
+			return status.Error(codes.PermissionDenied, "API token is not scoped for this")
+		}
+		callerID, ok = c.UserID, true
+	}
+	if !ok {
+		return status.Error(codes.Unauthenticated, "caller is not identified")
+	}
+	// owners don't need their roles looked up
+	if auth.Allowed(&pb.User{ID: callerID}, p, ownerID) {
+		return nil
+	}
+
+	// roles come from the datastore, not a snapshot: a lookup racing a
+	// change of roles may cache the old ones after the change dropped them
+	resp, err := s.lookupUser(ctx, callerID, false)
+	if err != nil {
+		return err
+	} else if !resp.GetFound() {
+		return status.Error(codes.Unauthenticated, "caller is not a known user")
+	}
+	if !auth.Allowed(resp.GetUser(), p, ownerID) {
+		log.WithFields(logrus.Fields{
+			"caller": callerID,
+			"owner":  ownerID,
+			"roles":  strings.Join(resp.GetUser().GetRoles(), ","),
+		}).Warn("permission denied")
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	return nil
+}
+
+// requireService checks that the call comes from a trusted service, such as
+// web, that holds the service secret. Account methods that run before anyone
+// is logged in hand out tokens and so must not be open to any gRPC caller.
+func (s *Server) requireService(ctx context.Context) error {
+	if !auth.HasServiceSecret(ctx, s.serviceSecret) {
+		middleware.Logger(ctx, log).Warn("account call without the service secret")
+		return status.Error(codes.PermissionDenied, "only trusted services may call this")
+	}
+	return nil
+}
+
+// isAdminEmail reports whether email is listed in --admin-emails. Only ask
+// about verified emails: anyone can claim an unverified one.
+func (s *Server) isAdminEmail(email string) bool {
+	return s.adminEmails[normalizeEmail(email)]
+}
+
+// addAdmin adds the admin role to u, reporting whether it lacked it
+func addAdmin(u *User) bool {
+	roles := u.Roles
+	if len(roles) == 0 {
+		roles = []string{string(auth.RoleCustomer)}
+	}
+	for _, r := range roles {
+		if auth.Role(r) == auth.RoleAdmin {
+			return false
+		}
+	}
+	u.Roles = append(roles, string(auth.RoleAdmin))
+	return true
+}
+
+// SetUserRoles replaces the roles assigned to a User
+func (s *Server) SetUserRoles(ctx context.Context, req *pb.SetUserRolesRequest) (*pb.User, error) {
+	span := tracing.FromContext(ctx).NewChild("usersvc/SetUserRoles")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":    "SetUserRoles",
+		"id":    req.GetUserID(),
+		"roles": strings.Join(req.GetRoles(), ",")})
+
+	if err := s.authorize(ctx, auth.ManageRoles, ""); err != nil {
+		return nil, err
+	}
+	for _, r := range req.GetRoles() {
+		if !auth.ValidRole(r) {
+			return nil, invalidArgument("Roles", fmt.Sprintf("unknown role %q", r))
+		}
+	}
+
+	user, err := s.updateUser(ctx, req.GetUserID(), func(u *User) error {
+		u.Roles = req.GetRoles()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("updated user roles")
+	return user, nil
+}
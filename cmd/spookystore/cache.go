@@ -0,0 +1,132 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/protobuf/proto"
+	"github.com/m-okeefe/spookystore/internal/cache"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// The snapshot caches, as they are named in metrics
+const (
+	userCache     = "user"
+	productCache  = "product"
+	productsCache = "products"
+)
+
+// allProductsCacheKey is where the snapshot of the catalog is cached
+const allProductsCacheKey = "products"
+
+func userCacheKey(id int64) string    { return "user/" + strconv.FormatInt(id, 10) }
+func productCacheKey(id int64) string { return "product/" + strconv.FormatInt(id, 10) }
+
+// fromCache decodes the snapshot cached under key into m, and reports whether
+// there was one
+func (s *Server) fromCache(ctx context.Context, name, key string, m proto.Message) bool {
+	if s.cache == nil {
+		return false
+	}
+	b, ok, err := s.cache.Get(ctx, key)
+	if err == nil && ok {
+		if err = proto.Unmarshal(b, m); err == nil {
+			metrics.ObserveCache(name, "hit")
+			return true
+		}
+	}
+	if err != nil {
+		middleware.Logger(ctx, log).WithFields(logrus.Fields{"key": key, "error": err}).Warn("failed to read from the cache")
+		metrics.ObserveCache(name, "error")
+		return false
+	}
+	metrics.ObserveCache(name, "miss")
+	return false
+}
+
+// toCache caches a snapshot of m under key
+func (s *Server) toCache(ctx context.Context, key string, m proto.Message) {
+	if s.cache == nil {
+		return
+	}
+	b, err := proto.Marshal(m)
+	if err == nil {
+		err = s.cache.Set(ctx, key, b, s.cacheTTL)
+	}
+	if err != nil {
+		middleware.Logger(ctx, log).WithFields(logrus.Fields{"key": key, "error": err}).Warn("failed to write to the cache")
+	}
+}
+
+// invalidating drops the cached snapshots of the users and products written
+// through it. Every write goes through it, so the process that made a write
+// sees it at once, and so does every other one when the cache is shared.
+type invalidating struct {
+	D     dw.DatastoreWrapper
+	cache cache.Cache
+}
+
+func (i *invalidating) Get(ctx context.Context, k *datastore.Key, v interface{}) error {
+	return i.D.Get(ctx, k, v)
+}
+
+func (i *invalidating) GetAll(ctx context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	return i.D.GetAll(ctx, q, v)
+}
+
+func (i *invalidating) Put(ctx context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	key, err := i.D.Put(ctx, k, v)
+	// a failed put may still have been applied
+	i.invalidate(ctx, k, key)
+	return key, err
+}
+
+func (i *invalidating) Delete(ctx context.Context, k *datastore.Key) error {
+	err := i.D.Delete(ctx, k)
+	i.invalidate(ctx, k)
+	return err
+}
+
+func (i *invalidating) invalidate(ctx context.Context, keys ...*datastore.Key) {
+	var stale []string
+	for _, k := range keys {
+		if k == nil {
+			continue
+		}
+		switch k.Kind {
+		case "User":
+			if k.ID != 0 {
+				stale = append(stale, userCacheKey(k.ID))
+			}
+		case "Product":
+			stale = append(stale, allProductsCacheKey)
+			if k.ID != 0 {
+				stale = append(stale, productCacheKey(k.ID))
+			}
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+	if err := i.cache.Delete(ctx, stale...); err != nil {
+		middleware.Logger(ctx, log).WithFields(logrus.Fields{"keys": stale, "error": err}).Error("failed to invalidate cached snapshots, they stay stale until they expire")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	"github.com/m-okeefe/spookystore/internal/cache"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestProductCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	c := cache.NewMemory(10, clockwork.NewFakeClock())
+	ts := &Server{ds: &invalidating{D: m, cache: c}, cache: c, cacheTTL: time.Minute}
+	ctx := context.Background()
+	k := datastore.IDKey("Product", 3, nil)
+
+	m.EXPECT().Get(ctx, k, &Product{}).SetArg(2, Product{K: k, DisplayName: "Cauldron"}).Return(nil)
+	for i := 0; i < 2; i++ {
+		p, err := ts.GetProduct(ctx, &pb.GetProductRequest{ID: "3"})
+		if err != nil || p.GetDisplayName() != "Cauldron" {
+			t.Fatalf("expected the product, got %v, %v", p, err)
+		}
+	}
+
+	// writes drop the snapshot
+	m.EXPECT().Put(ctx, k, gomock.Any()).Return(k, nil)
+	ts.ds.Put(ctx, k, &Product{DisplayName: "Broom"})
+	m.EXPECT().Get(ctx, k, &Product{}).SetArg(2, Product{K: k, DisplayName: "Broom"}).Return(nil)
+	if p, _ := ts.GetProduct(ctx, &pb.GetProductRequest{ID: "3"}); p.GetDisplayName() != "Broom" {
+		t.Errorf("expected the product to be looked up again, got %v", p)
+	}
+}
+
+func TestUserCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	c := cache.NewMemory(10, clockwork.NewFakeClock())
+	ts := &Server{ds: &invalidating{D: m, cache: c}, cache: c, cacheTTL: time.Minute}
+	ctx := context.Background()
+
+	expectGetUser(m, ctx, "7", "")
+	ts.getUser(ctx, "7")
+	if resp, err := ts.getUser(ctx, "7"); err != nil || !resp.GetFound() {
+		t.Errorf("expected the cached user, got %v, %v", resp, err)
+	}
+
+	// updates always read the datastore
+	expectGetUser(m, ctx, "7", "")
+	ts.mustGetUser(ctx, "7")
+}
+
+func TestAuthorizeIgnoresCachedRoles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	c := cache.NewMemory(10, clockwork.NewFakeClock())
+	ts := &Server{ds: &invalidating{D: m, cache: c}, cache: c, cacheTTL: time.Minute}
+	ctx := asUser("7")
+
+	// a lookup that raced the admin role being taken away cached it stale
+	ts.toCache(ctx, userCacheKey(7), &pb.User{ID: "7", Roles: []string{string(auth.RoleAdmin)}})
+	expectCaller(m, ctx, "7", auth.RoleCustomer)
+	if err := ts.authorize(ctx, auth.ListUsers, ""); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected roles to be read from the datastore, got %v", err)
+	}
+}
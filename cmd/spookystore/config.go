@@ -0,0 +1,54 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/m-okeefe/spookystore/internal/config"
+	"github.com/pkg/errors"
+)
+
+// settings are the flags, also read from a config file and the environment
+var settings = config.New(flag.CommandLine).Secret("service-secret")
+
+// validateConfig returns every problem with the settings at once
+func validateConfig() error {
+	var errs config.Errors
+	if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" {
+		errs = append(errs, errors.New("GOOGLE_APPLICATION_CREDENTIALS environment variable is not set"))
+	}
+	errs.Required("google-project-id", *projectID)
+	errs.Required("addr", *addr)
+	errs.Required("service-secret", *serviceSecret)
+	errs.OneOf("log-level", *logLevel, "debug", "info", "warn", "error")
+	errs.OneOf("trace-exporter", *traceExporter, "none", "stdout", "otlp")
+	errs.OneOf("cache", *cacheName, "none", "memory", "redis")
+	errs.Check(*callTimeout > 0, "call-timeout", "must be positive")
+	errs.Check(*healthInterval > 0, "health-interval", "must be positive")
+	errs.Check(*shutdownDelay >= 0, "shutdown-delay", "must not be negative")
+	errs.Check(*shutdownTimeout >= 0, "shutdown-timeout", "must not be negative")
+	errs.Check(*datastoreTimeout > 0, "datastore-timeout", "must be positive")
+	errs.Check(*datastoreAttempts > 0, "datastore-attempts", "must be positive")
+	errs.Check(*datastoreBreakerFailures > 0, "datastore-breaker-failures", "must be positive")
+	errs.Check(*datastoreBreakerCooldown > 0, "datastore-breaker-cooldown", "must be positive")
+	errs.Check(*cacheTTL > 0, "cache-ttl", "must be positive")
+	errs.Check(*cacheSize > 0, "cache-size", "must be positive")
+	if _, err := os.Stat(*inventory); err != nil {
+		errs.Check(false, "inventory", "is not readable: "+err.Error())
+	}
+	return errs.Err()
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// withDetails returns an error with status code c and message msg, carrying
+// details for clients that want to act on the failure.
+func withDetails(c codes.Code, msg string, details ...proto.Message) error {
+	st, err := status.New(c, msg).WithDetails(details...)
+	if err != nil {
+		return status.Error(c, msg)
+	}
+	return st.Err()
+}
+
+// invalidArgument rejects a request whose field is invalid
+func invalidArgument(field, desc string) error {
+	return withDetails(codes.InvalidArgument, desc, &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: desc}},
+	})
+}
+
+// notFound reports that the entity of kind with the given name doesn't exist
+func notFound(kind, name string) error {
+	return withDetails(codes.NotFound, fmt.Sprintf("%s %s not found", kind, name), &errdetails.ResourceInfo{
+		ResourceType: kind,
+		ResourceName: name,
+	})
+}
+
+// failedPrecondition rejects a request because subject is not in the state
+// the call needs, e.g. a cart that is empty
+func failedPrecondition(typ, subject, desc string) error {
+	return withDetails(codes.FailedPrecondition, desc, &errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{{Type: typ, Subject: subject, Description: desc}},
+	})
+}
+
+// storeError converts an error returned by the datastore into a status error.
+// msg says what failed; the datastore's own message is not passed on.
+func storeError(err error, msg string) error {
+	switch err {
+	case datastore.ErrConcurrentTransaction:
+		return status.Error(codes.Aborted, msg+": concurrent modification, try again")
+	case context.Canceled:
+		return status.Error(codes.Canceled, msg)
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, msg)
+	}
+	switch c := status.Code(err); c {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return status.Error(c, msg+": datastore unavailable, try again")
+	case codes.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, msg)
+	case codes.Aborted:
+		return status.Error(codes.Aborted, msg+": concurrent modification, try again")
+	}
+	return status.Error(codes.Internal, msg)
+}
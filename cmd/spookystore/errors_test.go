@@ -0,0 +1,68 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStoreError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{datastore.ErrConcurrentTransaction, codes.Aborted},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{status.Error(codes.Unavailable, "connection refused"), codes.Unavailable},
+		{status.Error(codes.Aborted, "too much contention"), codes.Aborted},
+		{status.Error(codes.PermissionDenied, "missing IAM role"), codes.Internal},
+		{errors.New("datastore: invalid entity type"), codes.Internal},
+	}
+	for _, test := range tests {
+		err := storeError(test.err, "failed to save user")
+		if got := status.Code(err); got != test.want {
+			t.Errorf("storeError(%v) has code %v, want %v", test.err, got, test.want)
+		}
+		if msg := status.Convert(err).Message(); strings.Contains(msg, test.err.Error()) {
+			t.Errorf("storeError(%v) leaks the datastore error: %q", test.err, msg)
+		}
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	st := status.Convert(invalidArgument("Quantity", "quantity must be positive"))
+	if st.Code() != codes.InvalidArgument || st.Message() != "quantity must be positive" {
+		t.Errorf("unexpected status %v", st.Proto())
+	}
+	br, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok || br.GetFieldViolations()[0].GetField() != "Quantity" {
+		t.Errorf("expected a field violation on Quantity, got %v", st.Details())
+	}
+
+	st = status.Convert(failedPrecondition("EMPTY_CART", "User/555", "the cart is empty"))
+	pf, ok := st.Details()[0].(*errdetails.PreconditionFailure)
+	if st.Code() != codes.FailedPrecondition || !ok || pf.GetViolations()[0].GetSubject() != "User/555" {
+		t.Errorf("expected a precondition failure on User/555, got %v", st.Proto())
+	}
+}
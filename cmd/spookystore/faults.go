@@ -0,0 +1,91 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/m-okeefe/spookystore/internal/auth"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// maxFaultLatency caps the latency a fault can add to a datastore call
+const maxFaultLatency = time.Minute
+
+// GetDatastoreFaults returns the faults injected into datastore calls
+func (s *Server) GetDatastoreFaults(ctx context.Context, req *pb.GetDatastoreFaultsRequest) (*pb.DatastoreFaults, error) {
+	if err := s.authorize(ctx, auth.ManageFaults, ""); err != nil {
+		return nil, err
+	}
+	if s.faults == nil {
+		return &pb.DatastoreFaults{}, nil
+	}
+	return faultsToProto(s.faults.Rules()), nil
+}
+
+// SetDatastoreFaults replaces the faults injected into datastore calls
+func (s *Server) SetDatastoreFaults(ctx context.Context, req *pb.SetDatastoreFaultsRequest) (*pb.DatastoreFaults, error) {
+	span := tracing.FromContext(ctx).NewChild("adminsvc/SetDatastoreFaults")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":     "SetDatastoreFaults",
+		"faults": len(req.GetFaults())})
+
+	if err := s.authorize(ctx, auth.ManageFaults, ""); err != nil {
+		return nil, err
+	}
+	if s.faults == nil {
+		return nil, failedPrecondition("FAULTS_DISABLED", "datastore", "fault injection is off, run the backend with the faults datastore decorator")
+	}
+	var rules []dw.FaultRule
+	for _, f := range req.GetFaults() {
+		rules = append(rules, dw.FaultRule{
+			Kind:    f.GetKind(),
+			Op:      f.GetOp(),
+			Rate:    f.GetRate(),
+			Latency: time.Duration(f.GetLatencyMillis()) * time.Millisecond,
+			Error:   f.GetError(),
+			Partial: f.GetPartial(),
+		})
+	}
+	seed := req.GetSeed()
+	if seed == 0 {
+		_, seed = s.faults.Rules()
+	}
+	s.faults.SetRules(rules, seed)
+	log.WithField("seed", seed).Warn("changed the faults injected into datastore calls")
+	return faultsToProto(s.faults.Rules()), nil
+}
+
+func faultsToProto(rules []dw.FaultRule, seed int64) *pb.DatastoreFaults {
+	out := &pb.DatastoreFaults{Enabled: true, Seed: seed}
+	for _, r := range rules {
+		out.Faults = append(out.Faults, &pb.DatastoreFault{
+			Kind:          r.Kind,
+			Op:            r.Op,
+			Rate:          r.Rate,
+			LatencyMillis: int64(r.Latency / time.Millisecond),
+			Error:         r.Error,
+			Partial:       r.Partial,
+		})
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckoutWithFaults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	f := dw.NewFaulty(1, clockwork.NewFakeClock())
+	f.D = m
+	ts := &Server{ds: f, faults: f, clock: clockwork.NewFakeClock()}
+	ctx := asUser("555")
+	u := datastore.IDKey("User", 555, nil)
+	cart := &pb.Cart{Items: []*pb.CartItem{{ID: "123", Quantity: 1}}, TotalCost: 3}
+
+	// the order is not placed if it can't be saved
+	f.SetRules([]dw.FaultRule{{Kind: "User", Op: "put", Rate: 1, Error: dw.FaultUnavailable}}, 1)
+	m.EXPECT().Get(ctx, u, &User{}).SetArg(2, User{Cart: cart}).Return(nil)
+	if _, err := ts.Checkout(ctx, &pb.UserRequest{ID: "555"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+
+	// it is placed even if it can't be counted
+	f.SetRules([]dw.FaultRule{{Kind: "TransactionCounter", Op: "put", Rate: 1, Error: dw.FaultUnavailable}}, 1)
+	m.EXPECT().Get(ctx, u, &User{}).SetArg(2, User{Cart: cart}).Return(nil)
+	m.EXPECT().Put(ctx, u, gomock.Any()).Return(u, nil)
+	m.EXPECT().Get(ctx, transactionCounterKey, &TransactionCounter{}).Return(nil)
+	if resp, err := ts.Checkout(ctx, &pb.UserRequest{ID: "555"}); err != nil || resp.GetTransaction().GetItems().GetTotalCost() != 3 {
+		t.Errorf("expected the order to go through, got %v, %v", resp, err)
+	}
+}
+
+func TestSetDatastoreFaults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
+	ctx := asUser("1")
+	req := &pb.SetDatastoreFaultsRequest{Faults: []*pb.DatastoreFault{{Op: "get", Rate: 0.5, LatencyMillis: 200}}}
+
+	expectCaller(m, ctx, "1", auth.RoleAdmin)
+	if _, err := ts.SetDatastoreFaults(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition without fault injection, got %v", err)
+	}
+
+	ts.faults = dw.NewFaulty(7, clockwork.NewFakeClock())
+	ts.faults.D = m
+	expectCaller(m, ctx, "1", auth.RoleAdmin)
+	resp, err := ts.SetDatastoreFaults(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.GetEnabled() || resp.GetSeed() != 7 || len(resp.GetFaults()) != 1 || resp.GetFaults()[0].GetLatencyMillis() != 200 {
+		t.Errorf("expected the faults with the seed kept, got %v", resp)
+	}
+
+	expectCaller(m, ctx, "1", auth.RoleCustomer)
+	if _, err := ts.GetDatastoreFaults(ctx, &pb.GetDatastoreFaultsRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for customers, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// newGateway returns a handler that translates the HTTP bindings in
+// spookystore.proto to calls to the gRPC server at grpcAddr. gRPC status
+// codes become the matching HTTP status codes.
+func newGateway(ctx context.Context, grpcAddr string) (http.Handler, error) {
+	mux := runtime.NewServeMux(runtime.WithIncomingHeaderMatcher(gatewayHeader))
+	if err := pb.RegisterSpookyStoreHandlerFromEndpoint(ctx, mux, grpcAddr, []grpc.DialOption{grpc.WithInsecure()}); err != nil {
+		return nil, errors.Wrap(err, "failed to register gateway handlers")
+	}
+	return mux, nil
+}
+
+// gatewayHeader forwards no headers to the gRPC server besides the request ID,
+// the W3C traceparent and the Authorization header, which the gateway always
+// passes on. In particular, HTTP callers must not be able to set the user ID
+// metadata only the web tier is trusted to send, so they have to use an API
+// token.
+func gatewayHeader(h string) (string, bool) {
+	if strings.EqualFold(h, middleware.RequestIDKey) {
+		return middleware.RequestIDKey, true
+	}
+	if strings.EqualFold(h, tracing.TraceparentHeader) {
+		return tracing.TraceparentHeader, true
+	}
+	return "", false
+}
+
+// dialAddr returns the address to dial a server listening on addr from the
+// same host
+func dialAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
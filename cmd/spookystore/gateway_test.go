@@ -0,0 +1,110 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestGateway(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), serviceSecret: "s3cret"}
+	tokenStore(m)
+	resp, err := ts.CreateAPIToken(asUser("555"), &pb.CreateAPITokenRequest{UserID: "555", Name: "ci", Scopes: []string{string(auth.ScopeRead)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(middleware.ChainUnaryServer(ts.authenticate, requestRules.UnaryServerInterceptor())))
+	pb.RegisterSpookyStoreServer(srv, ts)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gw, err := newGateway(ctx, lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"user id metadata", map[string]string{"Grpc-Metadata-" + auth.MetadataKey: "555"}, http.StatusUnauthorized},
+		{"user id header", map[string]string{auth.MetadataKey: "555"}, http.StatusUnauthorized},
+		{"user id with service secret", map[string]string{
+			"Grpc-Metadata-" + auth.MetadataKey:      "555",
+			"Grpc-Metadata-" + auth.ServiceSecretKey: "s3cret",
+			auth.MetadataKey:                         "555",
+			auth.ServiceSecretKey:                    "s3cret",
+		}, http.StatusUnauthorized},
+		{"bad token", map[string]string{"Authorization": "Bearer " + auth.TokenPrefix + "0000000000000000_x"}, http.StatusUnauthorized},
+		{"token", map[string]string{"Authorization": "Bearer " + resp.GetToken()}, http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/555/tokens", nil)
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.want, w.Code, w.Body)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/casper/tokens", nil)
+	r.Header.Set("Authorization", "Bearer "+resp.GetToken())
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "field_violations") {
+		t.Errorf("expected a malformed user ID to be rejected with field violations, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestDialAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		":8001":         "localhost:8001",
+		"0.0.0.0:8001":  "localhost:8001",
+		"[::]:8001":     "localhost:8001",
+		"10.0.0.1:8001": "10.0.0.1:8001",
+		"spooky:8001":   "spooky:8001",
+	} {
+		if got := dialAddr(addr); got != want {
+			t.Errorf("dialAddr(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serviceName is the name the SpookyStore service's health is reported under.
+// Probes have to ask for it: the health server always reports the server as a
+// whole ("") as serving.
+const serviceName = "SpookyStore"
+
+var errShuttingDown = errors.New("shutting down")
+
+// readiness decides whether the server is ready for traffic: the products
+// have been populated, and the datastore answers
+type readiness struct {
+	s  *Server
+	hs *health.Server
+
+	// populate adds the products inventory; it is retried until it succeeds
+	populate  func(context.Context) error
+	populated bool
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+// check brings the health status up to date. Once stopped, it leaves the
+// status alone.
+func (r *readiness) check(ctx context.Context) error {
+	if r.isStopped() {
+		return errShuttingDown
+	}
+	err := r.ready(ctx)
+	st := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		st = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return errShuttingDown
+	}
+	r.hs.SetServingStatus(serviceName, st)
+	return err
+}
+
+func (r *readiness) isStopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopped
+}
+
+// stop reports the server as not serving for good, so load balancers stop
+// sending it calls while it drains
+func (r *readiness) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	r.hs.SetServingStatus(serviceName, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func (r *readiness) ready(ctx context.Context) error {
+	if !r.populated {
+		if err := r.populate(ctx); err != nil {
+			return errors.Wrap(err, "failed to populate products")
+		}
+		r.populated = true
+	}
+	q := datastore.NewQuery("Product").KeysOnly().Limit(1)
+	if _, err := r.s.ds.GetAll(ctx, q, nil); err != nil {
+		return errors.Wrap(err, "datastore is unreachable")
+	}
+	return nil
+}
+
+// run checks every interval until ctx is done, logging changes of status
+func (r *readiness) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var last error
+	for first := true; ; first = false {
+		cctx, cancel := context.WithTimeout(ctx, interval)
+		err := r.check(cctx)
+		cancel()
+		if err != nil && (first || last == nil) {
+			log.WithField("error", err).Warn("not serving")
+		} else if err == nil && (first || last != nil) {
+			log.Info("serving")
+		}
+		last = err
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
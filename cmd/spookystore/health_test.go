@@ -0,0 +1,148 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	"github.com/m-okeefe/spookystore/internal/hub"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestReadiness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
+	ctx := context.Background()
+
+	populateErr := errors.New("catalog missing")
+	populated := 0
+	r := &readiness{s: ts, hs: health.NewServer(), populate: func(context.Context) error {
+		populated++
+		return populateErr
+	}}
+	status := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := r.hs.Check(ctx, &healthpb.HealthCheckRequest{Service: serviceName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.GetStatus()
+	}
+	ping := datastore.NewQuery("Product").KeysOnly().Limit(1)
+
+	if err := r.check(ctx); err == nil || status() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected not to serve before the products are populated, got %v", err)
+	}
+
+	populateErr = nil
+	m.EXPECT().GetAll(ctx, ping, nil).Return(nil, nil)
+	if err := r.check(ctx); err != nil || status() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected to serve, got %v", err)
+	}
+
+	m.EXPECT().GetAll(ctx, ping, nil).Return(nil, errors.New("connection refused"))
+	if err := r.check(ctx); err == nil || status() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected not to serve without a datastore, got %v", err)
+	}
+	if populated != 2 {
+		t.Errorf("expected the products to be populated until it succeeds once, got %d tries", populated)
+	}
+}
+
+func TestReadinessStop(t *testing.T) {
+	ctx := context.Background()
+	r := &readiness{hs: health.NewServer(), populate: func(context.Context) error { return nil }}
+	r.hs.SetServingStatus(serviceName, healthpb.HealthCheckResponse_SERVING)
+
+	r.stop()
+	if err := r.check(ctx); err != errShuttingDown {
+		t.Errorf("expected checks to stop, got %v", err)
+	}
+	resp, err := r.hs.Check(ctx, &healthpb.HealthCheckRequest{Service: serviceName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected not to serve while shutting down, got %v", resp.GetStatus())
+	}
+}
+
+func TestShutdownDelay(t *testing.T) {
+	ctx := context.Background()
+	r := &readiness{hs: health.NewServer()}
+	r.hs.SetServingStatus(serviceName, healthpb.HealthCheckResponse_SERVING)
+	events := hub.New(1)
+	sub := events.Subscribe(transactionsTopic)
+	defer sub.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go srv.Serve(lis)
+	get := func() error {
+		resp, err := http.Get("http://" + lis.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	clock := clockwork.NewFakeClock()
+	done := make(chan struct{})
+	go func() {
+		shutdown(grpc.NewServer(), r, events, clock, 5*time.Second, time.Second, srv)
+		close(done)
+	}()
+
+	// readiness fails first, while everything keeps being served
+	clock.BlockUntil(1)
+	resp, err := r.hs.Check(ctx, &healthpb.HealthCheckRequest{Service: serviceName})
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected not to serve during the shutdown delay, got %v, %v", resp, err)
+	}
+	if err := get(); err != nil {
+		t.Errorf("expected requests to be served during the shutdown delay, got %v", err)
+	}
+	if events.Subscribers(transactionsTopic) != 1 {
+		t.Errorf("expected watch streams to go on during the shutdown delay")
+	}
+
+	clock.Advance(5 * time.Second)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected shutdown to finish after the delay")
+	}
+	if err := get(); err == nil {
+		t.Errorf("expected the listeners to be closed after the delay")
+	}
+	if sub.Err() != hub.ErrClosed {
+		t.Errorf("expected watch streams to end after the delay, got %v", sub.Err())
+	}
+}
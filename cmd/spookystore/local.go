@@ -0,0 +1,341 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+
+	// maxFailedLogins wrong passwords in a row lock the account for lockout
+	maxFailedLogins = 5
+	lockout         = 15 * time.Minute
+
+	resetTokenTTL = time.Hour
+)
+
+// errBadLogin is returned for both unknown emails and wrong passwords, so
+// LoginLocal doesn't reveal which emails have accounts
+var errBadLogin = status.Error(codes.Unauthenticated, "wrong email or password")
+
+// Register creates a local account with an unverified email, and returns the
+// token that verifies it. The caller is expected to email the token to the
+// user; the account cannot log in until VerifyEmail is called with it.
+func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	if err := s.requireService(ctx); err != nil {
+		return nil, err
+	}
+	span := tracing.FromContext(ctx).NewChild("usersvc/Register")
+	defer span.Finish()
+
+	email := normalizeEmail(req.GetEmail())
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":    "Register",
+		"email": email})
+
+	if !strings.Contains(email, "@") {
+		return nil, invalidArgument("Email", "invalid email")
+	}
+	if err := checkPassword(req.GetPassword()); err != nil {
+		return nil, err
+	}
+
+	if u, err := s.findUser(ctx, "Email =", email); err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to look up user")
+	} else if u != nil {
+		return nil, status.Error(codes.AlreadyExists, "an account with this email already exists")
+	}
+
+	hash, err := s.hashPassword(req.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	displayName := req.GetDisplayName()
+	if displayName == "" {
+		displayName = strings.SplitN(email, "@", 2)[0]
+	}
+	id, err := s.createUser(ctx, &User{
+		Email:        email,
+		DisplayName:  displayName,
+		PasswordHash: hash,
+		VerifyToken:  tokenHash(token),
+	})
+	if err != nil {
+		log.WithField("error", err).Error("failed to save to datastore")
+		return nil, err
+	}
+	log.WithField("id", id).Info("registered local user")
+	return &pb.RegisterResponse{UserID: id, VerificationToken: token}, nil
+}
+
+// VerifyEmail marks the email of the account the token was issued to as
+// verified.
+func (s *Server) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*pb.User, error) {
+	if err := s.requireService(ctx); err != nil {
+		return nil, err
+	}
+	span := tracing.FromContext(ctx).NewChild("usersvc/VerifyEmail")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithField("op", "VerifyEmail")
+	if req.GetToken() == "" {
+		return nil, invalidArgument("Token", "missing token")
+	}
+	u, err := s.findUser(ctx, "VerifyToken =", tokenHash(req.GetToken()))
+	if err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to look up user")
+	} else if u == nil {
+		return nil, status.Error(codes.NotFound, "invalid or used verification token")
+	}
+
+	u.EmailVerified = true
+	u.VerifyToken = ""
+	if s.isAdminEmail(u.Email) {
+		addAdmin(u)
+	}
+	if _, err := s.ds.Put(ctx, u.K, u); err != nil {
+		log.WithField("error", err).Error("failed to save to datastore")
+		return nil, storeError(err, "failed to save user")
+	}
+	log.WithField("id", u.ID).Info("verified email")
+	return userToProto(u.ID, u), nil
+}
+
+// LoginLocal checks an email and password. Accounts are locked for a while
+// after too many wrong passwords in a row.
+func (s *Server) LoginLocal(ctx context.Context, req *pb.LoginLocalRequest) (*pb.User, error) {
+	if err := s.requireService(ctx); err != nil {
+		return nil, err
+	}
+	span := tracing.FromContext(ctx).NewChild("usersvc/LoginLocal")
+	defer span.Finish()
+
+	email := normalizeEmail(req.GetEmail())
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":    "LoginLocal",
+		"email": email})
+
+	u, err := s.findUser(ctx, "Email =", email)
+	if err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to look up user")
+	}
+	if u == nil || len(u.PasswordHash) == 0 {
+		// spend as long as a real check would
+		bcrypt.CompareHashAndPassword(s.dummyHash(), []byte(req.GetPassword()))
+		return nil, errBadLogin
+	}
+
+	now := s.clock.Now()
+	if now.Before(u.LockedUntil) {
+		log.WithField("id", u.ID).Warn("login attempt on locked account")
+		return nil, status.Errorf(codes.ResourceExhausted, "too many failed logins, try again after %s", u.LockedUntil.Format(time.Kitchen))
+	}
+
+	if err := bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(req.GetPassword())); err != nil {
+		u.FailedLogins++
+		if u.FailedLogins >= maxFailedLogins {
+			u.FailedLogins = 0
+			u.LockedUntil = now.Add(lockout)
+			log.WithField("id", u.ID).Warn("locked account after failed logins")
+		}
+		if _, err := s.ds.Put(ctx, u.K, u); err != nil {
+			log.WithField("error", err).Error("failed to record failed login")
+			return nil, storeError(err, "failed to save user")
+		}
+		return nil, errBadLogin
+	}
+	if !u.EmailVerified {
+		return nil, status.Error(codes.FailedPrecondition, "email address is not verified yet")
+	}
+
+	if u.FailedLogins > 0 {
+		u.FailedLogins = 0
+		if _, err := s.ds.Put(ctx, u.K, u); err != nil {
+			log.WithField("error", err).Error("failed to reset failed logins")
+			return nil, storeError(err, "failed to save user")
+		}
+	}
+	log.WithField("id", u.ID).Info("local login")
+	return userToProto(u.ID, u), nil
+}
+
+// RequestPasswordReset issues a token that lets the holder set a new password
+// on the account with the given email, valid for an hour. The caller is
+// expected to email it to the user. The response has no token if there is no
+// such account; callers should not tell the user either way.
+func (s *Server) RequestPasswordReset(ctx context.Context, req *pb.PasswordResetRequest) (*pb.PasswordResetResponse, error) {
+	if err := s.requireService(ctx); err != nil {
+		return nil, err
+	}
+	span := tracing.FromContext(ctx).NewChild("usersvc/RequestPasswordReset")
+	defer span.Finish()
+
+	email := normalizeEmail(req.GetEmail())
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":    "RequestPasswordReset",
+		"email": email})
+
+	u, err := s.findUser(ctx, "Email =", email)
+	if err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to look up user")
+	} else if u == nil {
+		log.Debug("no account for password reset")
+		return &pb.PasswordResetResponse{}, nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	u.ResetToken = tokenHash(token)
+	u.ResetExpires = s.clock.Now().Add(resetTokenTTL)
+	if _, err := s.ds.Put(ctx, u.K, u); err != nil {
+		log.WithField("error", err).Error("failed to save to datastore")
+		return nil, storeError(err, "failed to save user")
+	}
+	log.WithField("id", u.ID).Info("issued password reset token")
+	return &pb.PasswordResetResponse{Token: token}, nil
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset.
+// As the token was sent to the user's email, this also verifies the email
+// and lifts any lockout.
+func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.User, error) {
+	if err := s.requireService(ctx); err != nil {
+		return nil, err
+	}
+	span := tracing.FromContext(ctx).NewChild("usersvc/ResetPassword")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithField("op", "ResetPassword")
+	if req.GetToken() == "" {
+		return nil, invalidArgument("Token", "missing token")
+	}
+	if err := checkPassword(req.GetPassword()); err != nil {
+		return nil, err
+	}
+	u, err := s.findUser(ctx, "ResetToken =", tokenHash(req.GetToken()))
+	if err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to look up user")
+	} else if u == nil || s.clock.Now().After(u.ResetExpires) {
+		return nil, status.Error(codes.NotFound, "invalid or expired reset token")
+	}
+
+	hash, err := s.hashPassword(req.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+	u.PasswordHash = hash
+	u.ResetToken = ""
+	u.ResetExpires = time.Time{}
+	u.EmailVerified = true
+	u.VerifyToken = ""
+	if s.isAdminEmail(u.Email) {
+		addAdmin(u)
+	}
+	u.FailedLogins = 0
+	u.LockedUntil = time.Time{}
+	if _, err := s.ds.Put(ctx, u.K, u); err != nil {
+		log.WithField("error", err).Error("failed to save to datastore")
+		return nil, storeError(err, "failed to save user")
+	}
+	log.WithField("id", u.ID).Info("reset password")
+	return userToProto(u.ID, u), nil
+}
+
+func checkPassword(p string) error {
+	if len(p) < minPasswordLength {
+		return invalidArgument("Password", fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+	if len(p) > maxPasswordLength {
+		return invalidArgument("Password", fmt.Sprintf("password must be at most %d bytes", maxPasswordLength))
+	}
+	return nil
+}
+
+func (s *Server) hashPassword(p string) ([]byte, error) {
+	cost := s.passwordCost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(p), cost)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to hash password")
+	}
+	return h, nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyHash is compared against when there is no account, so logins take
+// about as long whether or not the email exists
+func (s *Server) dummyHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = s.hashPassword("not a real password")
+	})
+	return dummyHash
+}
+
+func normalizeEmail(e string) string {
+	return strings.ToLower(strings.TrimSpace(e))
+}
+
+// newToken returns a random single-use token to email to a user
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", status.Error(codes.Internal, "failed to generate token")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// tokenHash is what is stored for a token, so a leaked datastore does not
+// leak usable tokens
+func tokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,264 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// oneUserStore makes m behave like a datastore holding at most one User,
+// which every query matches, and returns a pointer to that user
+func oneUserStore(m *dwmock.MockDatastoreWrapper) **User {
+	var cur *User
+	m.EXPECT().GetAll(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, _ *datastore.Query, dst interface{}) ([]*datastore.Key, error) {
+			if cur != nil {
+				*dst.(*[]User) = []User{*cur}
+			}
+			return nil, nil
+		})
+	m.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, k *datastore.Key, src interface{}) (*datastore.Key, error) {
+			u := *src.(*User)
+			if k.Incomplete() {
+				k = datastore.IDKey("User", 1, nil)
+			}
+			u.K = k
+			cur = &u
+			return k, nil
+		})
+	return &cur
+}
+
+func TestLocalAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	clock := clockwork.NewFakeClock()
+	ts := &Server{ds: m, clock: clock, passwordCost: bcrypt.MinCost, serviceSecret: testServiceSecret}
+	ctx := asService()
+	user := oneUserStore(m)
+
+	if _, err := ts.Register(ctx, &pb.RegisterRequest{Email: "sam@example.com", Password: "short"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected short password to be rejected, got %v", err)
+	}
+	reg, err := ts.Register(ctx, &pb.RegisterRequest{Email: " Sam@Example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*user).Email != "sam@example.com" || string((*user).PasswordHash) == "correct horse" {
+		t.Errorf("expected normalized email and hashed password, got %+v", *user)
+	}
+	if _, err := ts.Register(ctx, &pb.RegisterRequest{Email: "sam@example.com", Password: "correct horse"}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected duplicate email to be rejected, got %v", err)
+	}
+
+	login := &pb.LoginLocalRequest{Email: "sam@example.com", Password: "correct horse"}
+	if _, err := ts.LoginLocal(ctx, login); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected unverified login to fail, got %v", err)
+	}
+	if _, err := ts.VerifyEmail(ctx, &pb.VerifyEmailRequest{Token: reg.GetVerificationToken()}); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := ts.LoginLocal(ctx, login); err != nil {
+		t.Fatal(err)
+	} else if u.GetID() != reg.GetUserID() {
+		t.Errorf("logged in as %q, want %q", u.GetID(), reg.GetUserID())
+	}
+
+	// too many wrong passwords lock the account, even for the right one
+	wrong := &pb.LoginLocalRequest{Email: "sam@example.com", Password: "wrong password"}
+	for i := 0; i < maxFailedLogins; i++ {
+		if _, err := ts.LoginLocal(ctx, wrong); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("attempt %d: expected Unauthenticated, got %v", i, err)
+		}
+	}
+	if _, err := ts.LoginLocal(ctx, login); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected locked account, got %v", err)
+	}
+	clock.Advance(lockout + time.Second)
+	if _, err := ts.LoginLocal(ctx, login); err != nil {
+		t.Errorf("expected lockout to end, got %v", err)
+	}
+}
+
+// TestLocalAccountKeptByUpdates checks that changing the cart or roles of a
+// local user leaves their credentials alone
+func TestLocalAccountKeptByUpdates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), passwordCost: bcrypt.MinCost, serviceSecret: testServiceSecret}
+	ctx := asService()
+	user := oneUserStore(m)
+	m.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, k *datastore.Key, dst interface{}) error {
+			switch dst := dst.(type) {
+			case *Product:
+				*dst = Product{ID: "123", DisplayName: "Ghost", Cost: 3}
+			case *User:
+				if k.ID != 1 {
+					*dst = User{Roles: []string{string(auth.RoleAdmin)}}
+				} else if *user != nil {
+					*dst = **user
+				} else {
+					return datastore.ErrNoSuchEntity
+				}
+			}
+			return nil
+		})
+
+	reg, err := ts.Register(ctx, &pb.RegisterRequest{Email: "sam@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.VerifyEmail(ctx, &pb.VerifyEmailRequest{Token: reg.GetVerificationToken()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.AddProductToCart(asUser(reg.GetUserID()), &pb.AddProductRequest{UserID: reg.GetUserID(), ProductID: "123", Quantity: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.SetUserRoles(asUser("2"), &pb.SetUserRolesRequest{UserID: reg.GetUserID(), Roles: []string{string(auth.RoleSupport)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := ts.LoginLocal(ctx, &pb.LoginLocalRequest{Email: "sam@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("expected login to still work, got %v", err)
+	}
+	if len(u.GetCart().GetItems()) != 1 || len(u.GetRoles()) != 1 || u.GetRoles()[0] != string(auth.RoleSupport) {
+		t.Errorf("expected the cart and roles to be saved, got %v", u)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	clock := clockwork.NewFakeClock()
+	ts := &Server{ds: m, clock: clock, passwordCost: bcrypt.MinCost, serviceSecret: testServiceSecret}
+	ctx := asService()
+	user := oneUserStore(m)
+
+	if _, err := ts.Register(ctx, &pb.RegisterRequest{Email: "sam@example.com", Password: "forgotten pw"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := ts.RequestPasswordReset(ctx, &pb.PasswordResetRequest{Email: "sam@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(resetTokenTTL + time.Second)
+	if _, err := ts.ResetPassword(ctx, &pb.ResetPasswordRequest{Token: expired.GetToken(), Password: "new password"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected expired token to be rejected, got %v", err)
+	}
+
+	reset, err := ts.RequestPasswordReset(ctx, &pb.PasswordResetRequest{Email: "sam@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*user).ResetToken == reset.GetToken() {
+		t.Errorf("expected reset token to be stored hashed")
+	}
+	if _, err := ts.ResetPassword(ctx, &pb.ResetPasswordRequest{Token: reset.GetToken(), Password: "new password"}); err != nil {
+		t.Fatal(err)
+	}
+	// the reset proves the user owns the email, so they can log in at once
+	if _, err := ts.LoginLocal(ctx, &pb.LoginLocalRequest{Email: "sam@example.com", Password: "new password"}); err != nil {
+		t.Error(err)
+	}
+	if _, err := ts.ResetPassword(ctx, &pb.ResetPasswordRequest{Token: reset.GetToken(), Password: "another password"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected used token to be rejected, got %v", err)
+	}
+}
+
+func TestLocalAccountNeedsServiceSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl) // no datastore calls expected
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), passwordCost: bcrypt.MinCost, serviceSecret: testServiceSecret}
+
+	for name, ctx := range map[string]context.Context{
+		"anonymous":    context.Background(),
+		"user":         asUser("1"),
+		"wrong secret": metadata.NewIncomingContext(context.Background(), metadata.Pairs(auth.ServiceSecretKey, "guess")),
+	} {
+		calls := map[string]func() error{
+			"Register": func() error {
+				_, err := ts.Register(ctx, &pb.RegisterRequest{Email: "sam@example.com", Password: "correct horse"})
+				return err
+			},
+			"VerifyEmail": func() error {
+				_, err := ts.VerifyEmail(ctx, &pb.VerifyEmailRequest{Token: "abc"})
+				return err
+			},
+			"LoginLocal": func() error {
+				_, err := ts.LoginLocal(ctx, &pb.LoginLocalRequest{Email: "sam@example.com", Password: "correct horse"})
+				return err
+			},
+			"RequestPasswordReset": func() error {
+				_, err := ts.RequestPasswordReset(ctx, &pb.PasswordResetRequest{Email: "sam@example.com"})
+				return err
+			},
+			"ResetPassword": func() error {
+				_, err := ts.ResetPassword(ctx, &pb.ResetPasswordRequest{Token: "abc", Password: "correct horse"})
+				return err
+			},
+		}
+		for method, call := range calls {
+			if err := call(); status.Code(err) != codes.PermissionDenied {
+				t.Errorf("%s from %s caller: expected PermissionDenied, got %v", method, name, err)
+			}
+		}
+	}
+}
+
+func TestLocalAdminNeedsVerifiedEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), passwordCost: bcrypt.MinCost, serviceSecret: testServiceSecret,
+		adminEmails: map[string]bool{"sam@example.com": true}}
+	ctx := asService()
+	user := oneUserStore(m)
+
+	reg, err := ts.Register(ctx, &pb.RegisterRequest{Email: "Sam@Example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*user).Email != "sam@example.com" || len((*user).Roles) != 0 {
+		t.Errorf("expected an unverified customer sam@example.com, got %q with roles %v", (*user).Email, (*user).Roles)
+	}
+	u, err := ts.VerifyEmail(ctx, &pb.VerifyEmailRequest{Token: reg.GetVerificationToken()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !auth.HasRole(u, auth.RoleAdmin) {
+		t.Errorf("expected admin role once the email is verified, got %v", u.GetRoles())
+	}
+}
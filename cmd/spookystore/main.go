@@ -18,50 +18,77 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
-	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/jonboulle/clockwork"
 
-	"cloud.google.com/go/datastore"
-	"cloud.google.com/go/trace"
 	"github.com/m-okeefe/spookystore/cmd/version"
+	"github.com/m-okeefe/spookystore/internal/cache"
 	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	"github.com/m-okeefe/spookystore/internal/hub"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+	"github.com/m-okeefe/spookystore/internal/middleware"
 	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/tracing"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
+// traceQPS is how many new traces a second are recorded
+const traceQPS = 10
+
 var (
-	projectID = flag.String("google-project-id", "", "google cloud project id")
-	addr      = flag.String("addr", ":8001", "[host]:port to listen")
-	logLevel  = flag.String("log-level", "info", "info, debug, warn, error")
+	projectID   = flag.String("google-project-id", "", "google cloud project id")
+	addr        = flag.String("addr", ":8001", "[host]:port to listen")
+	httpAddr    = flag.String("http-addr", "", "[host]:port to serve the REST gateway on, disabled if empty")
+	metricsAddr = flag.String("metrics-addr", ":9090", "[host]:port to serve prometheus metrics on, disabled if empty")
+	logLevel    = flag.String("log-level", "info", "info, debug, warn, error")
+	inventory   = flag.String("inventory", "./inventory/products.json", "JSON file of products to add to the datastore at startup")
+
+	callTimeout     = flag.Duration("call-timeout", 10*time.Second, "deadline of calls that arrive without one")
+	healthInterval  = flag.Duration("health-interval", 10*time.Second, "how often to check that the datastore is reachable")
+	shutdownDelay   = flag.Duration("shutdown-delay", 5*time.Second, "how long to keep serving after failing readiness checks when shutting down, so load balancers stop sending calls first")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 20*time.Second, "how long to wait for calls in flight when shutting down")
+
+	traceExporter = flag.String("trace-exporter", "none", "where to send traces: none, stdout, otlp")
+	otlpEndpoint  = flag.String("otlp-endpoint", tracing.DefaultOTLPEndpoint, "url of the collector to post traces to with otlp over http")
+
+	datastoreDecorators      = flag.String("datastore-decorators", dw.DefaultDecorators, "comma-separated decorators to wrap datastore calls in, outermost first: metrics, tracing, breaker, retry, timeout")
+	datastoreTimeout         = flag.Duration("datastore-timeout", 5*time.Second, "deadline of each datastore call, or attempt when retrying")
+	datastoreAttempts        = flag.Int("datastore-attempts", 3, "how many times to try datastore calls that fail with transient errors")
+	datastoreBreakerFailures = flag.Int("datastore-breaker-failures", 5, "transient datastore errors in a row that open the circuit breaker")
+	datastoreBreakerCooldown = flag.Duration("datastore-breaker-cooldown", 30*time.Second, "how long the circuit breaker fails datastore calls before trying again")
+	faultSeed                = flag.Int64("fault-seed", 1, "seed of the draws of which datastore calls get faults, with the faults decorator")
+	recordDatastore          = flag.String("record-datastore", "", "file to record datastore calls to, for replaying in tests; disabled if empty")
+
+	cacheName = flag.String("cache", "memory", "where to cache snapshots of users and products: none, memory, redis")
+	cacheTTL  = flag.Duration("cache-ttl", 30*time.Second, "how long snapshots of users and products are cached")
+	cacheSize = flag.Int("cache-size", 10000, "how many snapshots the memory cache keeps")
+	redisAddr = flag.String("redis-addr", cache.DefaultRedisAddr, "host:port of the redis server to cache snapshots in")
+
+	adminEmails   = flag.String("admin-emails", "", "comma-separated emails of users granted the admin role on login")
+	serviceSecret = flag.String("service-secret", "", "secret shared with the services trusted to name the calling user, such as web; best set in the environment or config file")
 
 	log *logrus.Entry
 )
 
 func init() {
-	flag.Parse()
 	host, err := os.Hostname()
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "cannot get hostname"))
+		logrus.Fatal(errors.Wrap(err, "cannot get hostname"))
 	}
-	switch *logLevel {
-	case "error":
-		logrus.SetLevel(logrus.ErrorLevel)
-	case "warn":
-		logrus.SetLevel(logrus.WarnLevel)
-	case "debug":
-		logrus.SetLevel(logrus.DebugLevel)
-	default:
-		logrus.SetLevel(logrus.InfoLevel)
-	}
-
 	logrus.SetFormatter(&logrus.JSONFormatter{FieldMap: logrus.FieldMap{logrus.FieldKeyLevel: "severity"}})
 	log = logrus.WithFields(logrus.Fields{
 		"service": "spookystore",
@@ -72,95 +99,223 @@ func init() {
 }
 
 func main() {
-	if env := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); env == "" {
-		log.Fatal("GOOGLE_APPLICATION_CREDENTIALS environment variable is not set")
+	if err := settings.Load(os.Args[1:], os.LookupEnv); err != nil {
+		log.Fatal(err)
+	}
+	if settings.PrintRequested() {
+		if err := settings.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := validateConfig(); err != nil {
+		log.Fatal(err)
+	}
+	switch *logLevel {
+	case "error":
+		logrus.SetLevel(logrus.ErrorLevel)
+	case "warn":
+		logrus.SetLevel(logrus.WarnLevel)
+	case "debug":
+		logrus.SetLevel(logrus.DebugLevel)
+	default:
+		logrus.SetLevel(logrus.InfoLevel)
 	}
 
-	if *projectID == "" {
-		log.Fatal("google cloud project id is not set")
+	if err := run(); err != nil {
+		log.Fatal(err)
 	}
+	log.Info("shut down")
+}
 
+// run serves until the process is told to stop, and returns once everything
+// is shut down and closed
+func run() error {
 	// Initialize server
 	ds, ctx, err := dw.NewCloudDatastore(*projectID)
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "failed to initialize cloud datastore wrapper"))
+		return errors.Wrap(err, "failed to initialize cloud datastore wrapper")
 	}
 	defer ds.D.Close()
-
-	tc, err := trace.NewClient(ctx, *projectID)
+	var inner dw.DatastoreWrapper = ds
+	if *recordDatastore != "" {
+		f, err := os.Create(*recordDatastore)
+		if err != nil {
+			return errors.Wrap(err, "failed to create datastore recording")
+		}
+		defer f.Close()
+		rec := dw.NewRecorder(ds, f)
+		defer func() {
+			if err := rec.Err(); err != nil {
+				log.WithField("error", err).Error("datastore recording is incomplete")
+			}
+		}()
+		inner = rec
+		log.WithField("file", *recordDatastore).Warn("recording datastore calls")
+	}
+	dc := dw.DefaultConfig()
+	dc.Decorators = strings.Split(*datastoreDecorators, ",")
+	dc.Timeout = *datastoreTimeout
+	dc.Attempts = *datastoreAttempts
+	dc.BreakerFailures = *datastoreBreakerFailures
+	dc.BreakerCooldown = *datastoreBreakerCooldown
+	for _, d := range dc.Decorators {
+		if strings.TrimSpace(d) == "faults" {
+			dc.Faults = dw.NewFaulty(*faultSeed, clockwork.NewRealClock())
+			log.Warn("datastore fault injection is on")
+		}
+	}
+	store, err := dw.Decorate(inner, dc)
+	if err != nil {
+		return err
+	}
+	c, err := cache.New(*cacheName, *cacheSize, *redisAddr)
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "failed to initialize tracing client"))
+		return err
 	}
-	ts, err := trace.NewLimitedSampler(1.0, 10)
+	if r, ok := c.(*cache.Redis); ok {
+		defer r.Close()
+	}
+	if c != nil {
+		store = &invalidating{D: store, cache: c}
+	}
+
+	exp, err := tracing.NewExporter(*traceExporter, *otlpEndpoint)
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "failed to initialize sampling policy"))
+		return err
 	}
-	tc.SetSamplingPolicy(ts)
+	tracer := tracing.NewTracer("spookystore", exp, tracing.LimitedSampler(traceQPS), log)
+	defer flushTraces(tracer)
 
 	lis, err := net.Listen("tcp", *addr)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(tc.GRPCServerInterceptor()))
-
 	// Initialize new backend server
 	s := &Server{
-		ds:    ds,
-		clock: clockwork.NewRealClock(),
+		ds:            store,
+		cache:         c,
+		cacheTTL:      *cacheTTL,
+		faults:        dc.Faults,
+		clock:         clockwork.NewRealClock(),
+		adminEmails:   map[string]bool{},
+		serviceSecret: *serviceSecret,
+		events:        hub.New(watchBuffer),
 	}
+	for _, e := range strings.Split(*adminEmails, ",") {
+		if e = normalizeEmail(e); e != "" {
+			s.adminEmails[e] = true
+		}
+	}
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(middleware.ChainUnaryServer(
+		tracer.GRPCServerInterceptor(),
+		metrics.UnaryServerInterceptor(),
+		middleware.Recovery(log),
+		middleware.AccessLog(log),
+		middleware.Deadline(*callTimeout),
+		s.authenticate,
+		requestRules.UnaryServerInterceptor(),
+	)), grpc.StreamInterceptor(middleware.ChainStreamServer(
+		tracer.GRPCStreamServerInterceptor(),
+		metrics.StreamServerInterceptor(),
+		middleware.StreamRecovery(log),
+		middleware.StreamAccessLog(log),
+		s.authenticateStream,
+		requestRules.StreamServerInterceptor(),
+	)))
 	pb.RegisterSpookyStoreServer(grpcServer, s)
+	reflection.Register(grpcServer)
 
-	// add products
-	populateProducts(ctx, ds)
+	// not serving until the products are added and the datastore answers
+	hs := health.NewServer()
+	hs.SetServingStatus(serviceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, hs)
+	ready := &readiness{s: s, hs: hs, populate: func(ctx context.Context) error {
+		return populateProducts(ctx, s, *inventory)
+	}}
+	readyCtx, stopChecks := context.WithCancel(ctx)
+	defer stopChecks()
+	go ready.run(readyCtx, *healthInterval)
 
-	log.WithField("addr", *addr).Info("starting to listen on grpc")
-	log.Fatal(grpcServer.Serve(lis))
-}
+	errc := make(chan error, 3)
+	var gw, ms *http.Server
+	if *httpAddr != "" {
+		// cancelling gwCtx closes the gateway's connection to the gRPC server
+		gwCtx, closeGateway := context.WithCancel(ctx)
+		defer closeGateway()
+		h, err := newGateway(gwCtx, dialAddr(*addr))
+		if err != nil {
+			return err
+		}
+		gw = &http.Server{Addr: *httpAddr, Handler: h}
+		go func() {
+			log.WithField("addr", *httpAddr).Info("starting to listen on http")
+			if err := gw.ListenAndServe(); err != http.ErrServerClosed {
+				errc <- errors.Wrap(err, "failed to serve the gateway")
+			}
+		}()
+	}
+	if *metricsAddr != "" {
+		ms = &http.Server{Addr: *metricsAddr, Handler: metrics.Handler()}
+		go func() {
+			log.WithField("addr", *metricsAddr).Info("starting to serve metrics")
+			if err := ms.ListenAndServe(); err != http.ErrServerClosed {
+				errc <- errors.Wrap(err, "failed to serve metrics")
+			}
+		}()
+	}
+	go func() {
+		log.WithField("addr", *addr).Info("starting to listen on grpc")
+		if err := grpcServer.Serve(lis); err != nil {
+			errc <- errors.Wrap(err, "failed to serve grpc")
+		}
+	}()
 
-// add products from JSON file to Cloud Datastore. return list of productk eys
-func populateProducts(ctx context.Context, ds dw.DatastoreWrapper) ([]string, error) {
-	pKeys := []string{}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	select {
+	case sig := <-sigs:
+		log.WithField("signal", sig.String()).Info("shutting down")
+	case err = <-errc:
+	}
+	shutdown(grpcServer, ready, s.events, s.clock, *shutdownDelay, *shutdownTimeout, gw, ms)
+	return err
+}
 
-	// add products only if not already present
-	file, e := ioutil.ReadFile("./inventory/products.json")
-	if e != nil {
-		fmt.Println(e)
-		return nil, e
+// readCatalog parses a JSON products inventory, keyed by DisplayName
+func readCatalog(path string) ([]*pb.Product, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 	var i map[string]Product
-	json.Unmarshal(file, &i)
-	for DispName, v := range i {
-		q := datastore.NewQuery("Product").Filter("DisplayName =", DispName)
-		var result []*Product
-		k, err := ds.GetAll(ctx, q, &result)
-		if err != nil {
-			return nil, err
-		}
-		if len(result) > 0 {
-			pKeys = append(pKeys, k[0].String())
-			continue
-		}
-		key := datastore.IncompleteKey("Product", nil)
-		p := &pb.Product{
-			DisplayName: DispName,
+	if err := json.Unmarshal(file, &i); err != nil {
+		return nil, errors.Wrap(err, "failed to parse catalog")
+	}
+	out := []*pb.Product{}
+	for dispName, v := range i {
+		out = append(out, &pb.Product{
+			DisplayName: dispName,
 			Cost:        v.Cost,
 			PictureURL:  v.PictureURL,
 			Description: v.Description,
-		}
-		newK, err := ds.Put(ctx, key, p)
-		if err != nil {
-			return nil, err
-		}
-		spl := strings.Split(newK.String(), ",")
-		if len(spl) < 2 {
-			return nil, fmt.Errorf("Bad ID: %s", newK.String())
-		}
-		p.ID = spl[1]
-		_, err = ds.Put(ctx, newK, p)
-		if err != nil {
-			return nil, err
-		}
-		pKeys = append(pKeys, newK.String())
+		})
+	}
+	return out, nil
+}
+
+// add products from JSON file to Cloud Datastore, if not already present
+func populateProducts(ctx context.Context, s *Server, path string) error {
+	products, err := readCatalog(path)
+	if err != nil {
+		return err
+	}
+	resp, err := s.importProducts(ctx, products)
+	if err != nil {
+		return err
 	}
-	return nil, nil
+	log.WithFields(logrus.Fields{
+		"created":  resp.GetCreated(),
+		"existing": resp.GetExisting()}).Info("populated products")
+	return nil
 }
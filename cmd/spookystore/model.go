@@ -15,6 +15,8 @@
 package main
 
 import (
+	"time"
+
 	"cloud.google.com/go/datastore"
 	pb "github.com/m-okeefe/spookystore/internal/proto"
 )
@@ -29,9 +31,20 @@ type User struct {
 	Cart                 *pb.Cart          `datastore:"Cart"`
 	Transactions         []*pb.Transaction `datastore:"Transactions"`
 	Email                string            `datastore:"Email"`
+	Roles                []string          `datastore:"Roles"`
+	Identities           []string          `datastore:"Identities"`
 	XXX_NoUnkeyedLiteral struct{}          `datastore:"XXX_NoUnkeyedLiteral"`
 	XXX_unrecognized     []byte            `datastore:"XXX_unrecognized"`
 	XXX_sizecache        int32             `datastore:"XXX_sizecache"`
+
+	// local account credentials, never sent to clients
+	PasswordHash  []byte    `datastore:"PasswordHash,noindex"`
+	EmailVerified bool      `datastore:"EmailVerified"`
+	VerifyToken   string    `datastore:"VerifyToken"` // hashed, see tokenHash
+	ResetToken    string    `datastore:"ResetToken"`  // hashed, see tokenHash
+	ResetExpires  time.Time `datastore:"ResetExpires"`
+	FailedLogins  int       `datastore:"FailedLogins"`
+	LockedUntil   time.Time `datastore:"LockedUntil"`
 }
 
 type Product struct {
@@ -49,3 +62,17 @@ type Product struct {
 type TransactionCounter struct {
 	NumTransactions int32 `datastore:"NumTransactions"`
 }
+
+// APIToken is a personal API token, keyed by its ID. Only a hash of the
+// token's secret is stored.
+type APIToken struct {
+	K *datastore.Key `datastore:"__key__"`
+
+	UserID  string    `datastore:"UserID"`
+	Name    string    `datastore:"Name,noindex"`
+	Scopes  []string  `datastore:"Scopes,noindex"`
+	Hash    string    `datastore:"Hash,noindex"`
+	Created time.Time `datastore:"Created"`
+	Expires time.Time `datastore:"Expires"`
+	Revoked bool      `datastore:"Revoked"`
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/jonboulle/clockwork"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+)
+
+// TestCheckoutReplay checks out against the datastore calls of a recorded
+// checkout; run the backend with --record-datastore to record a new one.
+func TestCheckoutReplay(t *testing.T) {
+	r, err := dw.LoadReplayer("testdata/checkout.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &Server{ds: r, clock: clockwork.NewFakeClock()}
+
+	resp, err := ts.Checkout(asUser("555"), &pb.UserRequest{ID: "555"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetTransaction().GetItems().GetTotalCost(); got != 6 {
+		t.Errorf("expected a transaction of 6, got %v", got)
+	}
+	if u := r.Unused(); len(u) > 0 {
+		t.Errorf("expected every recorded call to be made, %d were not: %v", len(u), u)
+	}
+}
@@ -17,17 +17,22 @@ package main
 import (
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	tspb "github.com/golang/protobuf/ptypes/timestamp"
-	"github.com/pkg/errors"
 
+	"github.com/m-okeefe/spookystore/internal/auth"
+	"github.com/m-okeefe/spookystore/internal/cache"
 	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	"github.com/m-okeefe/spookystore/internal/hub"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+	"github.com/m-okeefe/spookystore/internal/middleware"
 	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/tracing"
 
 	"cloud.google.com/go/datastore"
-	"cloud.google.com/go/trace"
 
 	"github.com/jonboulle/clockwork"
 
@@ -35,96 +40,91 @@ import (
 	"golang.org/x/net/context"
 )
 
+// maxListUsers caps the page size returned by ListUsers
+const maxListUsers = 100
+
 type Server struct {
 	ds    dw.DatastoreWrapper
 	clock clockwork.Clock
-}
-
-// AuthorizeGoogle generates an OAuth2 client token for this Google user
-func (s *Server) AuthorizeGoogle(ctx context.Context, goog *pb.User) (*pb.User, error) {
-	span := trace.FromContext(ctx).NewChild("usersvc/AuthorizeGoogle")
-	defer span.Finish()
 
-	gid := goog.GetGoogleID()
+	// users with these normalized emails are granted the admin role once
+	// their email is verified
+	adminEmails map[string]bool
 
-	log := log.WithFields(logrus.Fields{
-		"op":        "AuthorizeGoogle",
-		"google.id": gid})
-	log.Debug("received request")
+	// serviceSecret must come with calls naming their user in metadata,
+	// see withCaller
+	serviceSecret string
 
-	cs := span.NewChild("datastore/query/user/by_ID")
+	// passwordCost is the bcrypt cost of local account passwords, or 0 for
+	// bcrypt.DefaultCost
+	passwordCost int
 
-	q := datastore.NewQuery("User").Filter("GoogleID =", goog.GoogleID).Limit(1)
-	var v []User
+	// cache keeps snapshots of users and products for cacheTTL, if not nil.
+	// Writes through ds must drop them, see invalidating.
+	cache    cache.Cache
+	cacheTTL time.Duration
 
-	if _, err := s.ds.GetAll(ctx, q, &v); err != nil {
-		log.WithField("error", err).Error("failed to query the datastore")
-		return nil, errors.Wrap(err, "failed to query")
-	}
-	cs.Finish()
+	// faults injects faults into calls to ds, if fault injection is on
+	faults *dw.Faulty
 
-	var id string
-	if len(v) == 0 {
-		cs = span.NewChild("datastore/put/user")
+	// events passes changes on to the watchers of carts, the catalog and
+	// the transaction counter
+	events *hub.Hub
 
-		u := &User{
-			Email:       goog.Email,
-			DisplayName: goog.DisplayName,
-			GoogleID:    gid,
-			Picture:     goog.Picture,
-		}
-
-		ik := datastore.IncompleteKey("User", nil)
-
-		k, err := s.ds.Put(ctx, ik, u)
-		if err != nil {
-			log.WithField("error", err).Error("failed to save to datastore")
-			return nil, errors.New("failed to save")
-		}
-		id = fmt.Sprintf("%d", k.ID)
+	// counterMu serializes updates of the transaction counter
+	counterMu sync.Mutex
+}
 
-		u.ID = id
-		_, err = s.ds.Put(ctx, datastore.IDKey("User", k.ID, nil), u)
-		if err != nil {
-			log.WithField("error", err).Error("failed to save with ID to datastore")
-			return nil, errors.New("failed to save with ID")
-		}
+// AuthorizeGoogle logs in a Google user, creating them on first login.
+//
+// Deprecated: use LinkAccount, which works with any identity provider.
+func (s *Server) AuthorizeGoogle(ctx context.Context, goog *pb.User) (*pb.User, error) {
+	return s.LinkAccount(ctx, &pb.LinkAccountRequest{
+		Provider:    googleProvider,
+		Subject:     goog.GetGoogleID(),
+		Email:       goog.GetEmail(),
+		DisplayName: goog.GetDisplayName(),
+		Picture:     goog.GetPicture(),
+	})
+}
 
-		log.WithField("id", id).Info("created new user")
-		cs.Finish()
-	} else {
-		// return existing user
-		id = fmt.Sprintf("%d", v[0].K.ID)
-		log.WithField("id", id).Debug("user exists")
+// GetUser fetches this User from Cloud Datastore
+func (s *Server) GetUser(ctx context.Context, req *pb.UserRequest) (*pb.UserResponse, error) {
+	if err := s.authorize(ctx, auth.ViewUser, req.GetID()); err != nil {
+		return nil, err
 	}
+	return s.getUser(ctx, req.GetID())
+}
 
-	// retrieve user again from backend
-	user, err := s.GetUser(ctx, &pb.UserRequest{ID: id})
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to retrieve user")
-	} else if !user.GetFound() {
-		return nil, errors.New("cannot find user that is just created")
-	}
-	return user.GetUser(), nil
+// getUser fetches a User without checking the caller's permissions, from
+// the cache if it has a snapshot
+func (s *Server) getUser(ctx context.Context, reqID string) (*pb.UserResponse, error) {
+	return s.lookupUser(ctx, reqID, true)
 }
 
-// GetUser fetches this User from Cloud Datastore
-func (s *Server) GetUser(ctx context.Context, req *pb.UserRequest) (*pb.UserResponse, error) {
-	span := trace.FromContext(ctx).NewChild("usersvc/GetUser")
+// getUserForUpdate fetches a User like getUser, but always from the
+// datastore, for callers that change and save it
+func (s *Server) getUserForUpdate(ctx context.Context, reqID string) (*pb.UserResponse, error) {
+	return s.lookupUser(ctx, reqID, false)
+}
+
+func (s *Server) lookupUser(ctx context.Context, reqID string, cached bool) (*pb.UserResponse, error) {
+	span := tracing.FromContext(ctx).NewChild("usersvc/GetUser")
 	defer span.Finish()
 
-	log := log.WithFields(logrus.Fields{
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
 		"op": "GetUser",
-		"id": req.GetID()})
-	start := time.Now()
-	defer func() {
-		log.WithField("elapsed", time.Since(start).String()).Debug("completed request")
-	}()
-	log.Debug("received request")
-
-	id, err := strconv.ParseInt(req.ID, 10, 64)
+		"id": reqID})
+
+	id, err := strconv.ParseInt(reqID, 10, 64)
 	if err != nil {
-		return nil, errors.New("cannot parse ID")
+		return nil, invalidArgument("ID", "user ID must be a number")
+	}
+	if cached {
+		var u pb.User
+		if s.fromCache(ctx, userCache, userCacheKey(id), &u) {
+			return &pb.UserResponse{Found: true, User: &u}, nil
+		}
 	}
 
 	cs := span.NewChild("datastore/query/user/by_id")
@@ -137,47 +137,165 @@ func (s *Server) GetUser(ctx context.Context, req *pb.UserRequest) (*pb.UserResp
 		return &pb.UserResponse{Found: false}, nil
 	} else if err != nil {
 		log.WithField("error", err).Error("failed to query the datastore")
-		return nil, errors.Wrap(err, "failed to query")
+		return nil, storeError(err, "failed to look up user")
 	}
 
+	user := userToProto(reqID, &v)
+	s.toCache(ctx, userCacheKey(id), user)
 	return &pb.UserResponse{
 		Found: true,
-		User: &pb.User{
-			ID:           req.ID,
-			GoogleID:     v.GoogleID,
-			DisplayName:  v.DisplayName,
-			Picture:      v.Picture,
-			Cart:         v.Cart,
-			Transactions: v.Transactions,
-		}}, nil
+		User:  user}, nil
+}
+
+// ListUsers returns a page of Users from Cloud Datastore, ordered by key
+func (s *Server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	span := tracing.FromContext(ctx).NewChild("usersvc/ListUsers")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":     "ListUsers",
+		"limit":  req.GetLimit(),
+		"offset": req.GetOffset()})
+
+	if err := s.authorize(ctx, auth.ListUsers, ""); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.GetLimit())
+	if limit <= 0 || limit > maxListUsers {
+		limit = maxListUsers
+	}
+	q := datastore.NewQuery("User").Order("__key__").Offset(int(req.GetOffset())).Limit(limit)
+
+	cs := span.NewChild("datastore/query/users")
+	defer cs.Finish()
+
+	var v []User
+	keys, err := s.ds.GetAll(ctx, q, &v)
+	if err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to list users")
+	}
+
+	users := []*pb.User{}
+	for i := range v {
+		id := v[i].ID
+		if i < len(keys) && keys[i] != nil {
+			id = fmt.Sprintf("%d", keys[i].ID)
+		}
+		users = append(users, userToProto(id, &v[i]))
+	}
+	return &pb.ListUsersResponse{Users: users}, nil
+}
+
+// mustGetUser fetches a User from the datastore without checking the
+// caller's permissions, and fails with NotFound if there is none
+func (s *Server) mustGetUser(ctx context.Context, id string) (*pb.User, error) {
+	resp, err := s.getUserForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if !resp.GetFound() {
+		return nil, notFound("User", id)
+	}
+	return resp.GetUser(), nil
+}
+
+// updateUser applies update to the User entity id and saves it back whole,
+// keeping the fields the protos don't carry, such as local account
+// credentials. It returns the updated User.
+func (s *Server) updateUser(ctx context.Context, id string, update func(u *User) error) (*pb.User, error) {
+	log := middleware.Logger(ctx, log).WithField("id", id)
+	parsed, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, invalidArgument("ID", "user ID must be a number")
+	}
+	k := datastore.IDKey("User", parsed, nil)
+	var u User
+	if err := s.ds.Get(ctx, k, &u); err == datastore.ErrNoSuchEntity {
+		return nil, notFound("User", id)
+	} else if err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to look up user")
+	}
+	if err := update(&u); err != nil {
+		return nil, err
+	}
+	if _, err := s.ds.Put(ctx, k, &u); err != nil {
+		log.WithField("error", err).Error("failed to save to datastore")
+		return nil, storeError(err, "failed to save user")
+	}
+	return userToProto(id, &u), nil
 }
 
+// userToProto converts a User entity into its wire representation
+func userToProto(id string, v *User) *pb.User {
+	return &pb.User{
+		ID:           id,
+		GoogleID:     v.GoogleID,
+		Email:        v.Email,
+		DisplayName:  v.DisplayName,
+		Picture:      v.Picture,
+		Cart:         v.Cart,
+		Transactions: v.Transactions,
+		Roles:        v.Roles,
+		Identities:   v.Identities,
+	}
+}
+
+// transactionCounterKey is the key of the counter of all checkouts
+var transactionCounterKey = datastore.NameKey("TransactionCounter", "AllPurchases", nil)
+
 // GetNumTransactions fetches the Number of total SpookyStore transactions from Cloud datastore
 func (s *Server) GetNumTransactions(ctx context.Context, req *pb.GetNumTransactionsRequest) (*pb.NumTransactionsResponse, error) {
-	var t TransactionCounter
-	k := datastore.NameKey("TransactionCounter", "AllPurchases", nil)
-	err := s.ds.Get(ctx, k, &t)
+	n, err := s.numTransactions(ctx)
 	if err != nil {
 		log.WithField("error", err).Error("failed to get num transactions")
-		return nil, errors.Wrap(err, "failed to query")
+		return nil, storeError(err, "failed to count transactions")
 	}
 	return &pb.NumTransactionsResponse{
-		NumTransactions: t.NumTransactions,
+		NumTransactions: n,
 	}, nil
 }
 
+// numTransactions reads the transaction counter, which is missing until the
+// first checkout
+func (s *Server) numTransactions(ctx context.Context) (int32, error) {
+	var t TransactionCounter
+	if err := s.ds.Get(ctx, transactionCounterKey, &t); err != nil && err != datastore.ErrNoSuchEntity {
+		return 0, err
+	}
+	return t.NumTransactions, nil
+}
+
+// countTransaction adds one to the transaction counter and tells watchers the
+// new total. Increments are only serialized within this process.
+func (s *Server) countTransaction(ctx context.Context) error {
+	s.counterMu.Lock()
+	defer s.counterMu.Unlock()
+	n, err := s.numTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	n++
+	if _, err := s.ds.Put(ctx, transactionCounterKey, &TransactionCounter{NumTransactions: n}); err != nil {
+		return err
+	}
+	s.publish(transactionsTopic, &pb.NumTransactionsResponse{NumTransactions: n})
+	return nil
+}
+
 // GetAllProducts returns a list of all Products in the datastore
 func (s *Server) GetAllProducts(ctx context.Context, req *pb.GetAllProductsRequest) (*pb.GetAllProductsResponse, error) {
-	span := trace.FromContext(ctx).NewChild("spookystoresvc/GetAllProducts")
+	span := tracing.FromContext(ctx).NewChild("spookystoresvc/GetAllProducts")
 	defer span.Finish()
 
-	log := log.WithFields(logrus.Fields{
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
 		"op": "GetAllProducts"})
-	start := time.Now()
-	defer func() {
-		log.WithField("elapsed", time.Since(start).String()).Debug("completed request")
-	}()
-	log.Debug("received request")
+
+	var cached pb.GetAllProductsResponse
+	if s.fromCache(ctx, productsCache, allProductsCacheKey, &cached) {
+		return &cached, nil
+	}
 
 	cs := span.NewChild("datastore/query/products")
 	defer cs.Finish()
@@ -186,7 +304,7 @@ func (s *Server) GetAllProducts(ctx context.Context, req *pb.GetAllProductsReque
 	_, err := s.ds.GetAll(ctx, datastore.NewQuery("Product"), &result)
 	if err != nil {
 		log.WithField("error", err).Error("failed to query the datastore")
-		return nil, errors.Wrap(err, "failed to getAll")
+		return nil, storeError(err, "failed to list products")
 	}
 
 	output := []*pb.Product{}
@@ -201,7 +319,9 @@ func (s *Server) GetAllProducts(ctx context.Context, req *pb.GetAllProductsReque
 		output = append(output, temp)
 	}
 
-	return &pb.GetAllProductsResponse{ProductList: output}, nil
+	resp := &pb.GetAllProductsResponse{ProductList: output}
+	s.toCache(ctx, allProductsCacheKey, resp)
+	return resp, nil
 }
 
 // GetProduct fetches a specific product from Datastore
@@ -209,29 +329,89 @@ func (s *Server) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb
 	var v Product
 	parsed, err := strconv.ParseInt(req.ID, 10, 64)
 	if err != nil {
-		return &pb.Product{}, nil
+		return nil, invalidArgument("ID", "product ID must be a number")
+	}
+	var cached pb.Product
+	if s.fromCache(ctx, productCache, productCacheKey(parsed), &cached) {
+		return &cached, nil
 	}
 
 	err = s.ds.Get(ctx, datastore.IDKey("Product", parsed, nil), &v)
 	if err == datastore.ErrNoSuchEntity {
 		log.Debug("product not found")
-		return &pb.Product{}, nil
+		return nil, notFound("Product", req.ID)
 	} else if err != nil {
 		log.WithField("error", err).Error("failed to query the datastore")
-		return nil, errors.Wrap(err, "failed to query")
+		return nil, storeError(err, "failed to look up product")
 	}
 
 	finalID := ""
 	if v.K != nil {
 		finalID = fmt.Sprintf("%d", v.K.ID)
 	}
-	return &pb.Product{
+	p := &pb.Product{
 		ID:          finalID,
 		DisplayName: v.DisplayName,
 		PictureURL:  v.PictureURL,
 		Cost:        v.Cost,
 		Description: v.Description,
-	}, nil
+	}
+	s.toCache(ctx, productCacheKey(parsed), p)
+	return p, nil
+}
+
+// ImportProducts adds each Product to Cloud Datastore, skipping products whose
+// DisplayName is already present
+func (s *Server) ImportProducts(ctx context.Context, req *pb.ImportProductsRequest) (*pb.ImportProductsResponse, error) {
+	if err := s.authorize(ctx, auth.ManageCatalog, ""); err != nil {
+		return nil, err
+	}
+	return s.importProducts(ctx, req.GetProducts())
+}
+
+// importProducts adds products without checking the caller's permissions
+func (s *Server) importProducts(ctx context.Context, products []*pb.Product) (*pb.ImportProductsResponse, error) {
+	span := tracing.FromContext(ctx).NewChild("spookystoresvc/ImportProducts")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":       "ImportProducts",
+		"products": len(products)})
+
+	resp := &pb.ImportProductsResponse{}
+	for _, p := range products {
+		q := datastore.NewQuery("Product").Filter("DisplayName =", p.GetDisplayName())
+		var result []*Product
+		if _, err := s.ds.GetAll(ctx, q, &result); err != nil {
+			log.WithField("error", err).Error("failed to query the datastore")
+			return nil, storeError(err, "failed to look up product")
+		}
+		if len(result) > 0 {
+			resp.Existing++
+			continue
+		}
+
+		np := &pb.Product{
+			DisplayName: p.GetDisplayName(),
+			Cost:        p.GetCost(),
+			PictureURL:  p.GetPictureURL(),
+			Description: p.GetDescription(),
+		}
+		k, err := s.ds.Put(ctx, datastore.IncompleteKey("Product", nil), np)
+		if err != nil {
+			log.WithField("error", err).Error("failed to save to datastore")
+			return nil, storeError(err, "failed to save product")
+		}
+		np.ID = fmt.Sprintf("%d", k.ID)
+		if _, err := s.ds.Put(ctx, k, np); err != nil {
+			log.WithField("error", err).Error("failed to save with ID to datastore")
+			return nil, storeError(err, "failed to save product with ID")
+		}
+		log.WithField("id", np.ID).Info("created new product")
+		s.publish(productsTopic, &pb.ProductEvent{Type: pb.ProductEvent_CREATED, Product: np})
+		resp.Created++
+	}
+	return resp, nil
 }
 
 func findProductInCart(items []*pb.CartItem, id string) int {
@@ -246,108 +426,117 @@ func findProductInCart(items []*pb.CartItem, id string) int {
 // AddProductToCart adds one or more Quantity of this Product to a User's Cart
 // Note - Cart works like a set, and only stores one CartItem per Product (but supports 1+ quantity of that product)
 func (s *Server) AddProductToCart(ctx context.Context, req *pb.AddProductRequest) (*pb.AddProductResponse, error) {
-	// get user
-	userResp, err := s.GetUser(ctx, &pb.UserRequest{ID: req.UserID})
-	if err != nil {
-		return &pb.AddProductResponse{Success: false}, err
-	}
-	user := userResp.GetUser()
-	if user.Cart == nil {
-		user.Cart = &pb.Cart{
-			Items:     []*pb.CartItem{},
-			TotalCost: 0.0,
-		}
+	if err := s.authorize(ctx, auth.EditCart, req.GetUserID()); err != nil {
+		return nil, err
 	}
 
-	items := user.Cart.Items
-	var addToCost float32
-	addToCost = 0.0
+	if req.GetQuantity() <= 0 {
+		return nil, invalidArgument("Quantity", "quantity must be positive")
+	}
 
-	// add to set
-	if i := findProductInCart(items, req.ProductID); i > 0 {
-		temp := items[i]
-		temp.Quantity = temp.Quantity + req.Quantity
-		addToCost = temp.Cost * float32(req.Quantity)
-	} else {
-		prod, err := s.GetProduct(ctx, &pb.GetProductRequest{ID: req.ProductID})
-		if err != nil {
-			return &pb.AddProductResponse{Success: false}, err
+	var newCart bool
+	user, err := s.updateUser(ctx, req.GetUserID(), func(u *User) error {
+		if u.Cart == nil {
+			u.Cart = &pb.Cart{
+				Items:     []*pb.CartItem{},
+				TotalCost: 0.0,
+			}
 		}
-		temp := &pb.CartItem{
-			ID:          req.ProductID,
-			DisplayName: prod.DisplayName,
-			Cost:        prod.Cost,
-			Quantity:    req.Quantity,
-		}
-		addToCost = prod.Cost * float32(req.Quantity)
-		items = append(items, temp)
-	}
 
-	// update user with cart
-	user.Cart.Items = items
-	user.Cart.TotalCost += addToCost
+		items := u.Cart.Items
+		newCart = len(items) == 0
+		var addToCost float32
+		addToCost = 0.0
+
+		// add to set
+		if i := findProductInCart(items, req.ProductID); i > 0 {
+			temp := items[i]
+			temp.Quantity = temp.Quantity + req.Quantity
+			addToCost = temp.Cost * float32(req.Quantity)
+		} else {
+			prod, err := s.GetProduct(ctx, &pb.GetProductRequest{ID: req.ProductID})
+			if err != nil {
+				return err
+			}
+			temp := &pb.CartItem{
+				ID:          req.ProductID,
+				DisplayName: prod.DisplayName,
+				Cost:        prod.Cost,
+				Quantity:    req.Quantity,
+			}
+			addToCost = prod.Cost * float32(req.Quantity)
+			items = append(items, temp)
+		}
 
-	// put user
-	parsed, err := strconv.ParseInt(req.UserID, 10, 64)
+		// update user with cart
+		u.Cart.Items = items
+		u.Cart.TotalCost += addToCost
+		return nil
+	})
 	if err != nil {
-		return &pb.AddProductResponse{Success: false}, err
+		return nil, err
 	}
-	u := datastore.IDKey("User", parsed, nil)
-	if _, err := s.ds.Put(ctx, u, user); err != nil {
-		return &pb.AddProductResponse{Success: false}, err
+	if newCart {
+		metrics.CartsCreated.Inc()
 	}
-	return &pb.AddProductResponse{Success: true}, nil
+	metrics.ItemsAdded.Add(float64(req.GetQuantity()))
+	s.publish(cartTopic(user.ID), user.Cart)
+	return &pb.AddProductResponse{Cart: user.Cart}, nil
 }
 
 // ClearCart zeroes out a User's Cart, and writes the empty Cart back to Datastore
 func (s *Server) ClearCart(ctx context.Context, req *pb.UserRequest) (*pb.ClearCartResponse, error) {
-	userResp, err := s.GetUser(ctx, &pb.UserRequest{ID: req.ID})
-	if err != nil {
-		return &pb.ClearCartResponse{Success: false}, err
+	if err := s.authorize(ctx, auth.EditCart, req.GetID()); err != nil {
+		return nil, err
 	}
-	user := userResp.GetUser()
-	user.Cart = &pb.Cart{}
+	return s.clearCart(ctx, req.GetID())
+}
 
-	// put user
-	parsed, err := strconv.ParseInt(user.ID, 10, 64)
-	u := datastore.IDKey("User", parsed, nil)
-	if _, err := s.ds.Put(ctx, u, user); err != nil {
-		return &pb.ClearCartResponse{Success: false}, err
+// clearCart empties a User's Cart without checking the caller's permissions
+func (s *Server) clearCart(ctx context.Context, id string) (*pb.ClearCartResponse, error) {
+	user, err := s.updateUser(ctx, id, func(u *User) error {
+		u.Cart = &pb.Cart{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &pb.ClearCartResponse{Success: true}, nil
+	s.publish(cartTopic(user.ID), user.Cart)
+	return &pb.ClearCartResponse{}, nil
 }
 
 // CHeckout gets a user's Cart, clears it, then adds a new Transaction for that user with a Timestamp
 func (s *Server) Checkout(ctx context.Context, req *pb.UserRequest) (*pb.CheckoutResponse, error) {
-	userResp, err := s.GetUser(ctx, req)
-	if err != nil {
-		return &pb.CheckoutResponse{Success: false}, err
+	if err := s.authorize(ctx, auth.Checkout, req.GetID()); err != nil {
+		return nil, err
 	}
-	user := userResp.User
 
-	t := &pb.Transaction{
-		CompletedTime: ClockworkNow(s),
-		Items:         user.Cart,
-	}
-	if user.Transactions == nil {
-		user.Transactions = []*pb.Transaction{}
-	}
-	user.Transactions = append(user.Transactions, t)
-
-	// update user
-	parsed, err := strconv.ParseInt(user.ID, 10, 64)
-	u := datastore.IDKey("User", parsed, nil)
-	if _, err := s.ds.Put(ctx, u, user); err != nil {
-		return &pb.CheckoutResponse{Success: false}, err
-	}
-
-	// zero out their cart
-	_, err = s.ClearCart(ctx, req)
+	// the order and the emptied cart are saved together
+	var t *pb.Transaction
+	user, err := s.updateUser(ctx, req.GetID(), func(u *User) error {
+		if len(u.Cart.GetItems()) == 0 {
+			return failedPrecondition("EMPTY_CART", "User/"+req.GetID(), "the cart is empty")
+		}
+		t = &pb.Transaction{
+			CompletedTime: ClockworkNow(s),
+			Items:         u.Cart,
+		}
+		u.Transactions = append(u.Transactions, t)
+		u.Cart = &pb.Cart{}
+		return nil
+	})
 	if err != nil {
-		return &pb.CheckoutResponse{Success: false}, err
+		return nil, err
 	}
-	return &pb.CheckoutResponse{Success: true}, nil
+	s.publish(cartTopic(user.ID), user.Cart)
+	metrics.Checkouts.Inc()
+	metrics.Revenue.Add(float64(t.GetItems().GetTotalCost()))
 
+	// the order went through even if it isn't counted
+	if err := s.countTransaction(ctx); err != nil {
+		middleware.Logger(ctx, log).WithField("error", err).Error("failed to count transaction")
+	}
+	return &pb.CheckoutResponse{Transaction: t}, nil
 }
 
 // ClockworkNow stubs time.Now to avoid unit testing field equality problems
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 
@@ -22,18 +23,24 @@ import (
 
 	"cloud.google.com/go/datastore"
 	"github.com/golang/mock/gomock"
+	"github.com/m-okeefe/spookystore/internal/auth"
 	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	"github.com/m-okeefe/spookystore/internal/hub"
 	pb "github.com/m-okeefe/spookystore/internal/proto"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestAuthorizeGoogle(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := dwmock.NewMockDatastoreWrapper(ctrl)
-	ts := &Server{m, clockwork.NewFakeClock()}
-	ctx := context.Background()
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), serviceSecret: testServiceSecret}
+	ctx := asService()
 
 	tests := []struct {
 		u          *pb.User
@@ -48,9 +55,10 @@ func TestAuthorizeGoogle(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		q := datastore.NewQuery("User").Filter("GoogleID =", test.u.GoogleID).Limit(1)
 		var output []User
-
+		q := datastore.NewQuery("User").Filter("Identities =", "google:"+test.u.GoogleID).Limit(1)
+		m.EXPECT().GetAll(ctx, q, &output).Return([]*datastore.Key{}, nil)
+		q = datastore.NewQuery("User").Filter("GoogleID =", test.u.GoogleID).Limit(1)
 		m.EXPECT().GetAll(ctx, q, &output).Return([]*datastore.Key{}, nil)
 
 		if test.shouldPass {
@@ -59,6 +67,7 @@ func TestAuthorizeGoogle(t *testing.T) {
 				DisplayName: test.u.DisplayName,
 				GoogleID:    test.u.GetGoogleID(),
 				Picture:     test.u.Picture,
+				Identities:  []string{"google:" + test.u.GoogleID},
 			}
 
 			m.EXPECT().Put(ctx, datastore.IncompleteKey("User", nil), modelUser).Return(&datastore.Key{}, nil)
@@ -68,6 +77,7 @@ func TestAuthorizeGoogle(t *testing.T) {
 				DisplayName: test.u.DisplayName,
 				GoogleID:    test.u.GetGoogleID(),
 				Picture:     test.u.Picture,
+				Identities:  []string{"google:" + test.u.GoogleID},
 			}
 
 			m.EXPECT().Put(ctx, datastore.IDKey("User", 0, nil), temp).Return(&datastore.Key{}, nil)
@@ -87,12 +97,141 @@ func TestAuthorizeGoogle(t *testing.T) {
 	}
 }
 
+func TestLinkAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), serviceSecret: testServiceSecret}
+	ctx := asService()
+
+	byIdentity := datastore.NewQuery("User").Filter("Identities =", "github:42").Limit(1)
+	byEmail := datastore.NewQuery("User").Filter("Email =", "sam@example.com").Limit(1)
+	existing := User{
+		K:          datastore.IDKey("User", 7, nil),
+		ID:         "7",
+		Email:      "sam@example.com",
+		Identities: []string{"google:12345"},
+	}
+	var output []User
+
+	// known identity logs in without changes
+	known := existing
+	known.Identities = []string{"google:12345", "github:42"}
+	m.EXPECT().GetAll(ctx, byIdentity, &output).SetArg(2, []User{known}).Return(nil, nil)
+	expectGetUser(m, ctx, "7", "")
+	if _, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github", Subject: "42", Email: "sam@example.com"}); err != nil {
+		t.Error(err)
+	}
+
+	// a verified email links the new identity to the existing user
+	linked := existing
+	linked.Identities = []string{"google:12345", "github:42"}
+	m.EXPECT().GetAll(ctx, byIdentity, &output).Return(nil, nil)
+	m.EXPECT().GetAll(ctx, byEmail, &output).SetArg(2, []User{existing}).Return(nil, nil)
+	m.EXPECT().Put(ctx, existing.K, &linked).Return(existing.K, nil)
+	expectGetUser(m, ctx, "7", "")
+	if _, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github", Subject: "42", Email: "sam@example.com", EmailVerified: true}); err != nil {
+		t.Error(err)
+	}
+
+	// an unverified email creates a separate user
+	m.EXPECT().GetAll(ctx, byIdentity, &output).Return(nil, nil)
+	m.EXPECT().Put(ctx, datastore.IncompleteKey("User", nil), gomock.Any()).Return(datastore.IDKey("User", 8, nil), nil)
+	m.EXPECT().Put(ctx, datastore.IDKey("User", 8, nil), gomock.Any()).Return(nil, nil)
+	expectGetUser(m, ctx, "8", "")
+	if _, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github", Subject: "42", Email: "sam@example.com"}); err != nil {
+		t.Error(err)
+	}
+
+	_, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument without a subject, got %v", err)
+	}
+
+	// only trusted services may link accounts
+	for _, ctx := range []context.Context{context.Background(), asUser("7")} {
+		if _, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github", Subject: "42"}); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("expected PermissionDenied without the service secret, got %v", err)
+		}
+		if _, err := ts.AuthorizeGoogle(ctx, &pb.User{GoogleID: "12345"}); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("expected PermissionDenied without the service secret, got %v", err)
+		}
+	}
+}
+
+func TestLinkAccountAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), serviceSecret: testServiceSecret,
+		adminEmails: map[string]bool{"sam@example.com": true}}
+	ctx := asService()
+
+	byIdentity := datastore.NewQuery("User").Filter("Identities =", "github:42").Limit(1)
+	byEmail := datastore.NewQuery("User").Filter("Email =", "sam@example.com").Limit(1)
+	var output []User
+	var created *User
+	expectCreate := func(id int64) {
+		m.EXPECT().Put(ctx, datastore.IncompleteKey("User", nil), gomock.Any()).Return(datastore.IDKey("User", id, nil), nil)
+		m.EXPECT().Put(ctx, datastore.IDKey("User", id, nil), gomock.Any()).
+			DoAndReturn(func(_ context.Context, k *datastore.Key, src interface{}) (*datastore.Key, error) {
+				created = src.(*User)
+				return k, nil
+			})
+		expectGetUser(m, ctx, strconv.FormatInt(id, 10), "")
+	}
+
+	// an unverified admin email is not trusted
+	m.EXPECT().GetAll(ctx, byIdentity, &output).Return(nil, nil)
+	expectCreate(8)
+	if _, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github", Subject: "42", Email: "sam@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(created.Roles) != 0 {
+		t.Errorf("expected no roles for an unverified admin email, got %v", created.Roles)
+	}
+
+	// a verified admin email is matched regardless of case
+	m.EXPECT().GetAll(ctx, byIdentity, &output).Return(nil, nil)
+	m.EXPECT().GetAll(ctx, byEmail, &output).Return(nil, nil)
+	expectCreate(9)
+	if _, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github", Subject: "42", Email: " Sam@Example.COM", EmailVerified: true}); err != nil {
+		t.Fatal(err)
+	}
+	if created.Email != "sam@example.com" {
+		t.Errorf("expected the email to be stored normalized, got %q", created.Email)
+	}
+	if want := []string{string(auth.RoleCustomer), string(auth.RoleAdmin)}; !reflect.DeepEqual(created.Roles, want) {
+		t.Errorf("expected roles %v for a verified admin email, got %v", want, created.Roles)
+	}
+
+	// an existing user is granted admin once their email is verified
+	existing := User{
+		K:          datastore.IDKey("User", 7, nil),
+		ID:         "7",
+		Email:      "Sam@Example.com",
+		Identities: []string{"github:42"},
+	}
+	m.EXPECT().GetAll(ctx, byIdentity, &output).SetArg(2, []User{existing}).Return(nil, nil)
+	expectGetUser(m, ctx, "7", "")
+	if _, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github", Subject: "42", Email: "sam@example.com"}); err != nil {
+		t.Error(err)
+	}
+	promoted := existing
+	promoted.Roles = []string{string(auth.RoleCustomer), string(auth.RoleAdmin)}
+	m.EXPECT().GetAll(ctx, byIdentity, &output).SetArg(2, []User{existing}).Return(nil, nil)
+	m.EXPECT().Put(ctx, existing.K, &promoted).Return(existing.K, nil)
+	expectGetUser(m, ctx, "7", "")
+	if _, err := ts.LinkAccount(ctx, &pb.LinkAccountRequest{Provider: "github", Subject: "42", Email: "SAM@example.com", EmailVerified: true}); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestGetUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := dwmock.NewMockDatastoreWrapper(ctrl)
-	ts := &Server{m, clockwork.NewFakeClock()}
-	ctx := context.Background()
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
 
 	tests := []struct {
 		u          *pb.User
@@ -117,6 +256,7 @@ func TestGetUser(t *testing.T) {
 	}
 
 	for _, test := range tests {
+		ctx := asUser(test.u.ID)
 		if test.shouldPass {
 			expectGetUser(m, ctx, test.u.ID, "")
 		}
@@ -133,11 +273,42 @@ func TestGetUser(t *testing.T) {
 	}
 }
 
+func TestListUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
+	ctx := asUser("1")
+
+	tests := []struct {
+		limit, offset int32
+		wantLimit     int
+	}{
+		{limit: 10, offset: 0, wantLimit: 10},
+		{limit: 0, offset: 20, wantLimit: maxListUsers},
+		{limit: 1000, offset: 0, wantLimit: maxListUsers},
+	}
+
+	for _, test := range tests {
+		q := datastore.NewQuery("User").Order("__key__").Offset(int(test.offset)).Limit(test.wantLimit)
+		var output []User
+		expectCaller(m, ctx, "1", auth.RoleSupport)
+		m.EXPECT().GetAll(ctx, q, &output).Return([]*datastore.Key{}, nil)
+
+		resp, err := ts.ListUsers(ctx, &pb.ListUsersRequest{Limit: test.limit, Offset: test.offset})
+		if err != nil {
+			t.Error(err)
+		} else if resp.GetUsers() == nil {
+			t.Errorf("expected an empty user list, got nil")
+		}
+	}
+}
+
 func TestGetNumTransactions(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := dwmock.NewMockDatastoreWrapper(ctrl)
-	ts := &Server{m, clockwork.NewFakeClock()}
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
 	ctx := context.Background()
 
 	var tc TransactionCounter
@@ -148,13 +319,20 @@ func TestGetNumTransactions(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+
+	// the counter is missing until the first checkout
+	m.EXPECT().Get(ctx, k, &tc).Return(datastore.ErrNoSuchEntity)
+	resp, err := ts.GetNumTransactions(ctx, &pb.GetNumTransactionsRequest{})
+	if err != nil || resp.GetNumTransactions() != 0 {
+		t.Errorf("expected 0 transactions, got %v, %v", resp, err)
+	}
 }
 
 func TestGetAllProducts(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := dwmock.NewMockDatastoreWrapper(ctrl)
-	ts := &Server{m, clockwork.NewFakeClock()}
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
 	ctx := context.Background()
 
 	var result []Product
@@ -170,7 +348,7 @@ func TestGetProduct(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := dwmock.NewMockDatastoreWrapper(ctrl)
-	ts := &Server{m, clockwork.NewFakeClock()}
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
 	ctx := context.Background()
 
 	tests := []struct {
@@ -196,14 +374,66 @@ func TestGetProduct(t *testing.T) {
 			t.Error(err)
 		}
 	}
+
+	_, err := ts.GetProduct(ctx, &pb.GetProductRequest{ID: "candle"})
+	if st := status.Convert(err); st.Code() != codes.InvalidArgument || len(st.Details()) != 1 {
+		t.Errorf("expected InvalidArgument with a field violation, got %v", err)
+	}
+	var v Product
+	m.EXPECT().Get(ctx, datastore.IDKey("Product", 404, nil), &v).Return(datastore.ErrNoSuchEntity)
+	_, err = ts.GetProduct(ctx, &pb.GetProductRequest{ID: "404"})
+	if st := status.Convert(err); st.Code() != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	} else if ri, ok := st.Details()[0].(*errdetails.ResourceInfo); !ok || ri.GetResourceName() != "404" {
+		t.Errorf("expected the missing product in the details, got %v", st.Details())
+	}
+}
+
+func TestImportProducts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
+	ctx := asUser("1")
+
+	p := &pb.Product{
+		DisplayName: "candle",
+		Cost:        12.00,
+		PictureURL:  "candle.jpg",
+		Description: "hand-poured soy candle",
+	}
+
+	expectCaller(m, ctx, "1", auth.RoleAdmin)
+
+	var result []*Product
+	q := datastore.NewQuery("Product").Filter("DisplayName =", p.DisplayName)
+	m.EXPECT().GetAll(ctx, q, &result).Return(nil, nil)
+
+	newKey := datastore.IDKey("Product", 601, nil)
+	m.EXPECT().Put(ctx, datastore.IncompleteKey("Product", nil), gomock.Any()).Return(newKey, nil)
+	withID := &pb.Product{
+		ID:          "601",
+		DisplayName: p.DisplayName,
+		Cost:        p.Cost,
+		PictureURL:  p.PictureURL,
+		Description: p.Description,
+	}
+	m.EXPECT().Put(ctx, newKey, withID).Return(newKey, nil)
+
+	resp, err := ts.ImportProducts(ctx, &pb.ImportProductsRequest{Products: []*pb.Product{p}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetCreated() != 1 || resp.GetExisting() != 0 {
+		t.Errorf("expected 1 created and 0 existing, got %d and %d", resp.GetCreated(), resp.GetExisting())
+	}
 }
 
 func TestAddProductToCart(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := dwmock.NewMockDatastoreWrapper(ctrl)
-	ts := &Server{m, clockwork.NewFakeClock()}
-	ctx := context.Background()
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
 
 	user := pb.User{
 		ID:          "555",
@@ -212,6 +442,7 @@ func TestAddProductToCart(t *testing.T) {
 		DisplayName: "Foo Bar",
 		Picture:     "bar.jpg",
 	}
+	ctx := asUser(user.ID)
 
 	expectGetUser(m, ctx, user.ID, "")
 	var v Product
@@ -220,15 +451,31 @@ func TestAddProductToCart(t *testing.T) {
 	parsed, _ := strconv.ParseInt(user.ID, 10, 64)
 	u := datastore.IDKey("User", parsed, nil)
 
-	finalUser := &pb.User{
-		ID:   "555",
-		Cart: &pb.Cart{Items: []*pb.CartItem{&pb.CartItem{ID: "123"}}},
+	finalUser := &User{
+		Cart: &pb.Cart{Items: []*pb.CartItem{&pb.CartItem{ID: "123", Quantity: 2}}},
 	}
 	m.EXPECT().Put(ctx, u, finalUser)
 
-	_, err := ts.AddProductToCart(ctx, &pb.AddProductRequest{UserID: user.ID, ProductID: "123"})
+	resp, err := ts.AddProductToCart(ctx, &pb.AddProductRequest{UserID: user.ID, ProductID: "123", Quantity: 2})
 	if err != nil {
 		t.Error(err)
+	} else if len(resp.GetCart().GetItems()) != 1 {
+		t.Errorf("expected the updated cart, got %v", resp.GetCart())
+	}
+
+	if _, err := ts.AddProductToCart(ctx, &pb.AddProductRequest{UserID: user.ID, ProductID: "123"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a zero quantity, got %v", err)
+	}
+
+	expectGetUser(m, ctx, user.ID, "")
+	m.EXPECT().Get(ctx, datastore.IDKey("Product", 404, nil), &v).Return(datastore.ErrNoSuchEntity)
+	if _, err := ts.AddProductToCart(ctx, &pb.AddProductRequest{UserID: user.ID, ProductID: "404", Quantity: 1}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown product, got %v", err)
+	}
+
+	m.EXPECT().Get(ctx, u, &User{}).Return(datastore.ErrNoSuchEntity)
+	if _, err := ts.AddProductToCart(ctx, &pb.AddProductRequest{UserID: user.ID, ProductID: "123", Quantity: 1}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown user, got %v", err)
 	}
 }
 
@@ -236,8 +483,7 @@ func TestClearCart(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := dwmock.NewMockDatastoreWrapper(ctrl)
-	ts := &Server{m, clockwork.NewFakeClock()}
-	ctx := context.Background()
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
 
 	user := pb.User{
 		ID:          "555",
@@ -246,30 +492,40 @@ func TestClearCart(t *testing.T) {
 		DisplayName: "Foo Bar",
 		Picture:     "bar.jpg",
 	}
+	ctx := asUser(user.ID)
 
 	expectGetUser(m, ctx, user.ID, "")
 
 	parsed, _ := strconv.ParseInt(user.ID, 10, 64)
 	u := datastore.IDKey("User", parsed, nil)
 
-	finalUser := &pb.User{
-		ID:   "555",
+	finalUser := &User{
 		Cart: &pb.Cart{},
 	}
 	m.EXPECT().Put(ctx, u, finalUser)
 
+	ts.events = hub.New(1)
+	sub := ts.events.Subscribe(cartTopic(user.ID))
+	defer sub.Close()
 	_, err := ts.ClearCart(ctx, &pb.UserRequest{ID: user.ID})
 	if err != nil {
 		t.Error(err)
 	}
+	select {
+	case c := <-sub.C():
+		if len(c.(*pb.Cart).GetItems()) != 0 {
+			t.Errorf("expected the empty cart to be published, got %v", c)
+		}
+	default:
+		t.Error("expected the empty cart to be published")
+	}
 }
 
 func TestCheckout(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := dwmock.NewMockDatastoreWrapper(ctrl)
-	ts := &Server{m, clockwork.NewFakeClock()}
-	ctx := context.Background()
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
 
 	user := pb.User{
 		ID:          "555",
@@ -278,29 +534,134 @@ func TestCheckout(t *testing.T) {
 		DisplayName: "Foo Bar",
 		Picture:     "bar.jpg",
 	}
-
-	expectGetUser(m, ctx, user.ID, "")
+	ctx := asUser(user.ID)
 
 	parsed, _ := strconv.ParseInt(user.ID, 10, 64)
 	u := datastore.IDKey("User", parsed, nil)
 
-	putUser := &pb.User{
-		ID:           "555",
-		Transactions: []*pb.Transaction{&pb.Transaction{CompletedTime: ClockworkNow(ts)}},
-	}
-	m.EXPECT().Put(ctx, u, putUser)
+	// an empty cart can't be checked out
 	expectGetUser(m, ctx, user.ID, "")
+	_, err := ts.Checkout(ctx, &pb.UserRequest{ID: user.ID})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition for an empty cart, got %v", err)
+	}
 
-	finalUser := &pb.User{
-		ID:   "555",
-		Cart: &pb.Cart{},
+	cart := &pb.Cart{Items: []*pb.CartItem{{ID: "123", Quantity: 1}}, TotalCost: 3}
+	m.EXPECT().Get(ctx, u, &User{}).SetArg(2, User{Cart: cart}).Return(nil)
+	// the order and the emptied cart are saved at once
+	finalUser := &User{
+		Cart:         &pb.Cart{},
+		Transactions: []*pb.Transaction{&pb.Transaction{CompletedTime: ClockworkNow(ts), Items: cart}},
 	}
 	m.EXPECT().Put(ctx, u, finalUser)
+	m.EXPECT().Get(ctx, transactionCounterKey, &TransactionCounter{}).SetArg(2, TransactionCounter{NumTransactions: 41}).Return(nil)
+	m.EXPECT().Put(ctx, transactionCounterKey, &TransactionCounter{NumTransactions: 42})
 
-	_, err := ts.Checkout(ctx, &pb.UserRequest{ID: user.ID})
+	ts.events = hub.New(1)
+	sub := ts.events.Subscribe(transactionsTopic)
+	defer sub.Close()
+	resp, err := ts.Checkout(ctx, &pb.UserRequest{ID: user.ID})
 	if err != nil {
 		t.Error(err)
+	} else if resp.GetTransaction().GetItems().GetTotalCost() != 3 {
+		t.Errorf("expected the new transaction, got %v", resp.GetTransaction())
+	}
+	select {
+	case n := <-sub.C():
+		if n.(*pb.NumTransactionsResponse).GetNumTransactions() != 42 {
+			t.Errorf("expected the new total to be published, got %v", n)
+		}
+	default:
+		t.Error("expected the new total to be published")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
+
+	tests := []struct {
+		name     string
+		caller   string
+		roles    []auth.Role
+		perm     auth.Permission
+		owner    string
+		wantCode codes.Code
+	}{
+		{name: "owner views self", caller: "555", perm: auth.ViewUser, owner: "555", wantCode: codes.OK},
+		{name: "owner checks out", caller: "555", perm: auth.Checkout, owner: "555", wantCode: codes.OK},
+		{name: "customer views other", caller: "555", perm: auth.ViewUser, owner: "777", wantCode: codes.PermissionDenied},
+		{name: "customer edits other cart", caller: "555", perm: auth.EditCart, owner: "777", wantCode: codes.PermissionDenied},
+		{name: "customer lists users", caller: "555", perm: auth.ListUsers, wantCode: codes.PermissionDenied},
+		{name: "support views other", caller: "555", roles: []auth.Role{auth.RoleSupport}, perm: auth.ViewUser, owner: "777", wantCode: codes.OK},
+		{name: "support edits other cart", caller: "555", roles: []auth.Role{auth.RoleSupport}, perm: auth.EditCart, owner: "777", wantCode: codes.OK},
+		{name: "support checks out other", caller: "555", roles: []auth.Role{auth.RoleSupport}, perm: auth.Checkout, owner: "777", wantCode: codes.PermissionDenied},
+		{name: "support manages catalog", caller: "555", roles: []auth.Role{auth.RoleSupport}, perm: auth.ManageCatalog, wantCode: codes.PermissionDenied},
+		{name: "admin checks out other", caller: "555", roles: []auth.Role{auth.RoleAdmin}, perm: auth.Checkout, owner: "777", wantCode: codes.OK},
+		{name: "admin manages roles", caller: "555", roles: []auth.Role{auth.RoleAdmin}, perm: auth.ManageRoles, wantCode: codes.OK},
+		{name: "anonymous caller", perm: auth.ViewUser, owner: "555", wantCode: codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.caller != "" {
+			ctx = asUser(test.caller)
+			if test.caller != test.owner {
+				expectCaller(m, ctx, test.caller, test.roles...)
+			}
+		}
+		err := ts.authorize(ctx, test.perm, test.owner)
+		if got := status.Code(err); got != test.wantCode {
+			t.Errorf("%s: got code %v, want %v (err=%v)", test.name, got, test.wantCode, err)
+		}
+	}
+}
+
+func TestSetUserRoles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock()}
+	ctx := asUser("1")
+
+	expectCaller(m, ctx, "1", auth.RoleAdmin)
+	expectGetUser(m, ctx, "555", "")
+	m.EXPECT().Put(ctx, datastore.IDKey("User", 555, nil), &User{Roles: []string{"support"}})
+
+	if _, err := ts.SetUserRoles(ctx, &pb.SetUserRolesRequest{UserID: "555", Roles: []string{"support"}}); err != nil {
+		t.Error(err)
+	}
+
+	expectCaller(m, ctx, "1", auth.RoleAdmin)
+	_, err := ts.SetUserRoles(ctx, &pb.SetUserRolesRequest{UserID: "555", Roles: []string{"wizard"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for unknown role, got %v", err)
+	}
+}
+
+// asUser returns a context carrying the caller's user ID, as the web tier sends it
+func asUser(id string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(auth.MetadataKey, id))
+}
+
+// testServiceSecret is the service secret of test servers
+const testServiceSecret = "s3cret"
+
+// asService returns a context carrying the service secret, as the web tier sends it
+func asService() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(auth.ServiceSecretKey, testServiceSecret))
+}
+
+// expectCaller expects the caller's User entity to be looked up for their roles
+func expectCaller(m *dwmock.MockDatastoreWrapper, ctx context.Context, id string, roles ...auth.Role) {
+	parsed, _ := strconv.ParseInt(id, 10, 64)
+	u := User{}
+	for _, r := range roles {
+		u.Roles = append(u.Roles, string(r))
 	}
+	m.EXPECT().Get(ctx, datastore.IDKey("User", parsed, nil), &User{}).SetArg(2, u).Return(nil)
 }
 
 func expectGetUser(m *dwmock.MockDatastoreWrapper, ctx context.Context, id string, errMsg string) {
@@ -0,0 +1,76 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/hub"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// traceFlushTimeout bounds the export of the last spans on the way out
+const traceFlushTimeout = 5 * time.Second
+
+// shutdown drains the server: it reports not serving, and keeps serving for
+// delay, until load balancers have noticed. It then ends the watch streams so
+// their callers reconnect elsewhere, stops the HTTP servers that are running,
+// and lets the calls in flight finish within timeout. Calls still running
+// after timeout are cancelled.
+func shutdown(grpcServer *grpc.Server, ready *readiness, events *hub.Hub, clock clockwork.Clock, delay, timeout time.Duration, servers ...*http.Server) {
+	ready.stop()
+	if delay > 0 {
+		log.WithField("delay", delay).Info("not ready, waiting for load balancers before draining")
+		<-clock.After(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	events.Close()
+	for _, srv := range servers {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			log.WithFields(logrus.Fields{"addr": srv.Addr, "error": err}).Warn("failed to drain http server")
+		}
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.WithField("timeout", timeout).Warn("calls still running at the shutdown timeout, cancelling them")
+		grpcServer.Stop()
+	}
+}
+
+// flushTraces exports the spans tracer still holds
+func flushTraces(tracer *tracing.Tracer) {
+	ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		log.WithField("error", err).Warn("failed to flush traces")
+	}
+}
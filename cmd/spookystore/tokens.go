@@ -0,0 +1,257 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/m-okeefe/spookystore/internal/auth"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+)
+
+const (
+	tokenKind = "APIToken"
+
+	defaultTokenTTL = 30 * 24 * time.Hour
+	maxTokenTTL     = 365 * 24 * time.Hour
+)
+
+var errBadToken = status.Error(codes.Unauthenticated, "invalid, expired or revoked API token")
+
+var errUntrustedUserID = status.Error(codes.Unauthenticated, "only trusted services may name the calling user, use an API token")
+
+// CreateAPIToken mints an API token for a user, limited to the requested
+// scopes. The token itself is only returned here.
+func (s *Server) CreateAPIToken(ctx context.Context, req *pb.CreateAPITokenRequest) (*pb.CreateAPITokenResponse, error) {
+	span := tracing.FromContext(ctx).NewChild("usersvc/CreateAPIToken")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":     "CreateAPIToken",
+		"id":     req.GetUserID(),
+		"scopes": strings.Join(req.GetScopes(), ",")})
+
+	if err := s.authorize(ctx, auth.ManageTokens, req.GetUserID()); err != nil {
+		return nil, err
+	}
+	if req.GetName() == "" {
+		return nil, invalidArgument("Name", "token name is required")
+	}
+	if len(req.GetScopes()) == 0 {
+		return nil, invalidArgument("Scopes", "at least one scope is required")
+	}
+	for _, sc := range req.GetScopes() {
+		if !auth.ValidScope(sc) {
+			return nil, invalidArgument("Scopes", fmt.Sprintf("unknown scope %q", sc))
+		}
+	}
+	ttl := time.Duration(req.GetTTLSeconds()) * time.Second
+	if ttl == 0 {
+		ttl = defaultTokenTTL
+	} else if ttl < 0 || ttl > maxTokenTTL {
+		return nil, invalidArgument("TTLSeconds", fmt.Sprintf("token lifetime must be between 1s and %s", maxTokenTTL))
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	t := &APIToken{
+		UserID:  req.GetUserID(),
+		Name:    req.GetName(),
+		Scopes:  req.GetScopes(),
+		Hash:    tokenHash(secret),
+		Created: now,
+		Expires: now.Add(ttl),
+	}
+	k := datastore.NameKey(tokenKind, id, nil)
+	if _, err := s.ds.Put(ctx, k, t); err != nil {
+		log.WithField("error", err).Error("failed to save to datastore")
+		return nil, storeError(err, "failed to save token")
+	}
+	t.K = k
+	log.WithField("token", id).Info("created api token")
+	return &pb.CreateAPITokenResponse{
+		Token: auth.TokenPrefix + id + "_" + secret,
+		Info:  tokenToProto(t),
+	}, nil
+}
+
+// ListAPITokens lists a user's API tokens, newest first, including expired
+// and revoked ones.
+func (s *Server) ListAPITokens(ctx context.Context, req *pb.UserRequest) (*pb.ListAPITokensResponse, error) {
+	span := tracing.FromContext(ctx).NewChild("usersvc/ListAPITokens")
+	defer span.Finish()
+
+	if err := s.authorize(ctx, auth.ManageTokens, req.GetID()); err != nil {
+		return nil, err
+	}
+	var v []APIToken
+	q := datastore.NewQuery(tokenKind).Filter("UserID =", req.GetID())
+	if _, err := s.ds.GetAll(ctx, q, &v); err != nil {
+		log.WithField("error", err).Error("failed to query the datastore")
+		return nil, storeError(err, "failed to list tokens")
+	}
+	sort.Slice(v, func(i, j int) bool { return v[i].Created.After(v[j].Created) })
+
+	resp := &pb.ListAPITokensResponse{}
+	for i := range v {
+		resp.Tokens = append(resp.Tokens, tokenToProto(&v[i]))
+	}
+	return resp, nil
+}
+
+// RevokeAPIToken stops a token from being accepted. It is kept, so it still
+// shows up in ListAPITokens.
+func (s *Server) RevokeAPIToken(ctx context.Context, req *pb.RevokeAPITokenRequest) (*pb.APIToken, error) {
+	span := tracing.FromContext(ctx).NewChild("usersvc/RevokeAPIToken")
+	defer span.Finish()
+
+	log := middleware.Logger(ctx, log).WithFields(logrus.Fields{
+		"op":    "RevokeAPIToken",
+		"id":    req.GetUserID(),
+		"token": req.GetTokenID()})
+
+	if err := s.authorize(ctx, auth.ManageTokens, req.GetUserID()); err != nil {
+		return nil, err
+	}
+	var t APIToken
+	k := datastore.NameKey(tokenKind, req.GetTokenID(), nil)
+	if err := s.ds.Get(ctx, k, &t); err == datastore.ErrNoSuchEntity || (err == nil && t.UserID != req.GetUserID()) {
+		return nil, notFound("APIToken", req.GetTokenID())
+	} else if err != nil {
+		log.WithField("error", err).Error("failed to get token")
+		return nil, storeError(err, "failed to get token")
+	}
+	t.Revoked = true
+	if _, err := s.ds.Put(ctx, k, &t); err != nil {
+		log.WithField("error", err).Error("failed to save to datastore")
+		return nil, storeError(err, "failed to save token")
+	}
+	t.K = k
+	log.Info("revoked api token")
+	return tokenToProto(&t), nil
+}
+
+// authenticate is a gRPC interceptor that authenticates calls carrying an API
+// token, and rejects calls with a bad one. Calls without a token are passed
+// on unchanged.
+func (s *Server) authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.withCaller(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticateStream is authenticate for streaming calls.
+func (s *Server) authenticateStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.withCaller(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, middleware.WithStreamContext(ss, ctx))
+}
+
+// withCaller adds the caller of the call's API token, if it has one, to ctx.
+// Calls naming their user in metadata instead must carry the service secret.
+func (s *Server) withCaller(ctx context.Context, method string) (context.Context, error) {
+	if _, named := auth.UserIDFromContext(ctx); named && !auth.HasServiceSecret(ctx, s.serviceSecret) {
+		log.WithField("method", method).Warn("rejected user id metadata without the service secret")
+		return nil, errUntrustedUserID
+	}
+	token, ok := auth.TokenFromContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	c, err := s.checkToken(ctx, token)
+	if err != nil {
+		log.WithField("method", method).WithField("error", err).Warn("rejected api token")
+		return nil, errBadToken
+	}
+	return auth.WithCaller(ctx, c), nil
+}
+
+// checkToken returns the caller a valid API token authenticates
+func (s *Server) checkToken(ctx context.Context, token string) (*auth.Caller, error) {
+	parts := strings.SplitN(strings.TrimPrefix(token, auth.TokenPrefix), "_", 2)
+	if !strings.HasPrefix(token, auth.TokenPrefix) || len(parts) != 2 {
+		return nil, errors.New("malformed token")
+	}
+	id, secret := parts[0], parts[1]
+
+	var t APIToken
+	if err := s.ds.Get(ctx, datastore.NameKey(tokenKind, id, nil), &t); err != nil {
+		return nil, errors.Wrap(err, "failed to get token")
+	}
+	if subtle.ConstantTimeCompare([]byte(tokenHash(secret)), []byte(t.Hash)) != 1 {
+		return nil, errors.New("wrong secret")
+	}
+	if t.Revoked {
+		return nil, errors.New("revoked")
+	}
+	if s.clock.Now().After(t.Expires) {
+		return nil, errors.New("expired")
+	}
+	return &auth.Caller{UserID: t.UserID, TokenID: id, Scopes: t.Scopes}, nil
+}
+
+func tokenToProto(t *APIToken) *pb.APIToken {
+	created, _ := ptypes.TimestampProto(t.Created)
+	expires, _ := ptypes.TimestampProto(t.Expires)
+	var id string
+	if t.K != nil {
+		id = t.K.Name
+	}
+	return &pb.APIToken{
+		ID:      id,
+		UserID:  t.UserID,
+		Name:    t.Name,
+		Scopes:  t.Scopes,
+		Created: created,
+		Expires: expires,
+		Revoked: t.Revoked,
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", status.Error(codes.Internal, "failed to generate id")
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// typeMatcher matches arguments of one type
+type typeMatcher struct{ t reflect.Type }
+
+func ofType(v interface{}) gomock.Matcher { return typeMatcher{reflect.TypeOf(v)} }
+
+func (m typeMatcher) Matches(x interface{}) bool { return reflect.TypeOf(x) == m.t }
+func (m typeMatcher) String() string             { return "is a " + m.t.String() }
+
+// tokenStore makes m keep APIToken entities in memory
+func tokenStore(m *dwmock.MockDatastoreWrapper) {
+	tokens := map[string]APIToken{}
+	m.EXPECT().Put(gomock.Any(), gomock.Any(), ofType(&APIToken{})).AnyTimes().
+		DoAndReturn(func(_ context.Context, k *datastore.Key, src interface{}) (*datastore.Key, error) {
+			tokens[k.Name] = *src.(*APIToken)
+			return k, nil
+		})
+	m.EXPECT().Get(gomock.Any(), gomock.Any(), ofType(&APIToken{})).AnyTimes().
+		DoAndReturn(func(_ context.Context, k *datastore.Key, dst interface{}) error {
+			t, ok := tokens[k.Name]
+			if !ok {
+				return datastore.ErrNoSuchEntity
+			}
+			*dst.(*APIToken) = t
+			return nil
+		})
+}
+
+// withToken returns an incoming context carrying an API token
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// call runs handler behind the authenticate interceptor and returns its error
+func call(ts *Server, ctx context.Context, handler func(ctx context.Context) error) error {
+	_, err := ts.authenticate(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/SpookyStore/Test"},
+		func(ctx context.Context, _ interface{}) (interface{}, error) { return nil, handler(ctx) })
+	return err
+}
+
+func TestAPITokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	clock := clockwork.NewFakeClock()
+	ts := &Server{ds: m, clock: clock}
+	tokenStore(m)
+
+	if _, err := ts.CreateAPIToken(asUser("555"), &pb.CreateAPITokenRequest{UserID: "555", Name: "ci", Scopes: []string{"everything"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected unknown scope to be rejected, got %v", err)
+	}
+	resp, err := ts.CreateAPIToken(asUser("555"), &pb.CreateAPITokenRequest{
+		UserID:     "555",
+		Name:       "ci",
+		Scopes:     []string{string(auth.ScopeCart)},
+		TTLSeconds: int64(time.Hour.Seconds()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := resp.GetToken()
+
+	ctx := withToken(token)
+	if err := call(ts, ctx, func(ctx context.Context) error { return ts.authorize(ctx, auth.EditCart, "555") }); err != nil {
+		t.Errorf("expected token to allow editing its user's cart: %v", err)
+	}
+	if err := call(ts, ctx, func(ctx context.Context) error { return ts.authorize(ctx, auth.Checkout, "555") }); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected token without checkout scope to be denied, got %v", err)
+	}
+	create := func(ctx context.Context) error {
+		_, err := ts.CreateAPIToken(ctx, &pb.CreateAPITokenRequest{UserID: "555", Name: "more", Scopes: []string{"cart"}})
+		return err
+	}
+	if err := call(ts, ctx, create); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected token to be unable to mint tokens, got %v", err)
+	}
+	// the scope does not extend to other users
+	m.EXPECT().Get(gomock.Any(), datastore.IDKey("User", 555, nil), &User{}).Return(nil)
+	if err := call(ts, ctx, func(ctx context.Context) error { return ts.authorize(ctx, auth.EditCart, "777") }); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected token to be denied another user's cart, got %v", err)
+	}
+
+	for name, bad := range map[string]string{
+		"wrong secret": token[:len(token)-2] + "xx",
+		"malformed":    "not-a-token",
+		"unknown id":   auth.TokenPrefix + "0000000000000000_secret",
+	} {
+		if err := call(ts, withToken(bad), func(context.Context) error { return nil }); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: expected Unauthenticated, got %v", name, err)
+		}
+	}
+
+	if _, err := ts.RevokeAPIToken(asUser("777"), &pb.RevokeAPITokenRequest{UserID: "777", TokenID: resp.GetInfo().GetID()}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected revoking another user's token to fail, got %v", err)
+	}
+	if _, err := ts.RevokeAPIToken(asUser("555"), &pb.RevokeAPITokenRequest{UserID: "555", TokenID: resp.GetInfo().GetID()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(ts, ctx, func(context.Context) error { return nil }); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected revoked token to be rejected, got %v", err)
+	}
+
+	resp, err = ts.CreateAPIToken(asUser("555"), &pb.CreateAPITokenRequest{UserID: "555", Name: "short", Scopes: []string{"read"}, TTLSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Minute)
+	if err := call(ts, withToken(resp.GetToken()), func(context.Context) error { return nil }); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected expired token to be rejected, got %v", err)
+	}
+}
+
+func TestServiceSecret(t *testing.T) {
+	ts := &Server{clock: clockwork.NewFakeClock(), serviceSecret: "s3cret"}
+	named := func(ctx context.Context) error {
+		if id, _ := auth.UserIDFromContext(ctx); id != "555" {
+			t.Errorf("expected the named user to reach the handler, got %q", id)
+		}
+		return nil
+	}
+	for _, tc := range []struct {
+		name   string
+		secret string
+		want   codes.Code
+	}{
+		{"no secret", "", codes.Unauthenticated},
+		{"wrong secret", "guess", codes.Unauthenticated},
+		{"secret", "s3cret", codes.OK},
+	} {
+		md := metadata.Pairs(auth.MetadataKey, "555")
+		if tc.secret != "" {
+			md.Set(auth.ServiceSecretKey, tc.secret)
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		if err := call(ts, ctx, named); status.Code(err) != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	ts.serviceSecret = ""
+	md := metadata.Pairs(auth.MetadataKey, "555", auth.ServiceSecretKey, "")
+	if err := call(ts, metadata.NewIncomingContext(context.Background(), md), named); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected an unset service secret to trust no one, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/m-okeefe/spookystore/internal/auth"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	v "github.com/m-okeefe/spookystore/internal/validate"
+)
+
+const (
+	maxNameLength  = 100
+	maxEmailLength = 254
+)
+
+// requestRules are enforced on every call before it reaches its handler. Each
+// request message of the SpookyStore service has an entry, even if empty.
+var requestRules = v.Rules{
+	"User": {
+		v.F("GoogleID", v.Required),
+		v.F("Email", v.Email, v.MaxLen(maxEmailLength)),
+		v.F("DisplayName", v.MaxLen(maxNameLength)),
+	},
+	"UserRequest": {
+		v.F("ID", v.Required, v.ID),
+	},
+	"GetProductRequest": {
+		v.F("ID", v.Required, v.ID),
+	},
+	"GetAllProductsRequest":     {},
+	"GetNumTransactionsRequest": {},
+	"AddProductRequest": {
+		v.F("UserID", v.Required, v.ID),
+		v.F("ProductID", v.Required, v.ID),
+		v.F("Quantity", v.Positive),
+	},
+	"ListUsersRequest": {
+		v.F("Limit", v.Min(0)),
+		v.F("Offset", v.Min(0)),
+	},
+	"ImportProductsRequest": {
+		v.F("Products", v.Required),
+	},
+	"Product": {
+		v.F("DisplayName", v.Required, v.MaxLen(maxNameLength)),
+		v.F("Cost", v.Min(0)),
+	},
+	"SetUserRolesRequest": {
+		v.F("UserID", v.Required, v.ID),
+		v.F("Roles").Each(v.Is(auth.ValidRole, "a known role")),
+	},
+	"LinkAccountRequest": {
+		v.F("Provider", v.Required),
+		v.F("Subject", v.Required),
+		v.F("Email", v.Email, v.MaxLen(maxEmailLength)),
+		v.F("DisplayName", v.MaxLen(maxNameLength)),
+	},
+	"RegisterRequest": {
+		v.F("Email", v.Required, v.Email, v.MaxLen(maxEmailLength)),
+		v.F("Password", v.Required),
+		v.F("DisplayName", v.MaxLen(maxNameLength)),
+	},
+	"VerifyEmailRequest": {
+		v.F("Token", v.Required),
+	},
+	"LoginLocalRequest": {
+		v.F("Email", v.Required),
+		v.F("Password", v.Required),
+	},
+	"PasswordResetRequest": {
+		v.F("Email", v.Required),
+	},
+	"ResetPasswordRequest": {
+		v.F("Token", v.Required),
+		v.F("Password", v.Required),
+	},
+	"CreateAPITokenRequest": {
+		v.F("UserID", v.Required, v.ID),
+		v.F("Name", v.Required, v.MaxLen(maxNameLength)),
+		v.F("Scopes", v.Required).Each(v.Is(auth.ValidScope, "a known scope")),
+		v.F("TTLSeconds", v.Min(0), v.Max(maxTokenTTL.Seconds())),
+	},
+	"RevokeAPITokenRequest": {
+		v.F("UserID", v.Required, v.ID),
+		v.F("TokenID", v.Required),
+	},
+	"WatchProductsRequest":      {},
+	"GetDatastoreFaultsRequest": {},
+	"SetDatastoreFaultsRequest": {},
+	"DatastoreFault": {
+		v.F("Op", v.Is(dw.ValidOp, "get, get_all, put or delete")),
+		v.F("Rate", v.Min(0), v.Max(1)),
+		v.F("LatencyMillis", v.Min(0), v.Max(maxFaultLatency.Seconds()*1000)),
+		v.F("Error", v.Is(dw.ValidFaultError, "unavailable, deadline_exceeded, not_found or contention")),
+	},
+}
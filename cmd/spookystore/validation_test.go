@@ -0,0 +1,116 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+)
+
+// TestRequestRulesCoverService checks that every request message of the
+// service has rules, and that there are no rules for unknown messages.
+func TestRequestRulesCoverService(t *testing.T) {
+	known := map[string]bool{"Product": true, "DatastoreFault": true}
+	svc := reflect.TypeOf((*pb.SpookyStoreServer)(nil)).Elem()
+	for i := 0; i < svc.NumMethod(); i++ {
+		// unary methods take a context first, streaming ones the request
+		in := svc.Method(i).Type.In(1)
+		if in.Kind() == reflect.Interface {
+			in = svc.Method(i).Type.In(0)
+		}
+		req := reflect.New(in.Elem()).Interface().(proto.Message)
+		name := proto.MessageName(req)
+		known[name] = true
+		if _, ok := requestRules[name]; !ok {
+			t.Errorf("%s: request %s has no validation rules", svc.Method(i).Name, name)
+		}
+		requestRules.Violations(req) // panics on rules for missing fields
+	}
+	for name := range requestRules {
+		if !known[name] {
+			t.Errorf("rules for %s, which is not a request of the service", name)
+		}
+	}
+}
+
+func TestRequestRules(t *testing.T) {
+	tests := []struct {
+		req  proto.Message
+		want []string
+	}{
+		{&pb.User{GoogleID: "12345", Email: "casper@example.com"}, nil},
+		{&pb.User{Email: "casper", DisplayName: strings.Repeat("boo", 50)}, []string{"DisplayName", "Email", "GoogleID"}},
+		{&pb.UserRequest{ID: "555"}, nil},
+		{&pb.UserRequest{}, []string{"ID"}},
+		{&pb.UserRequest{ID: "casper"}, []string{"ID"}},
+		{&pb.GetProductRequest{ID: "601"}, nil},
+		{&pb.GetProductRequest{ID: "-601"}, []string{"ID"}},
+		{&pb.GetAllProductsRequest{}, nil},
+		{&pb.GetNumTransactionsRequest{}, nil},
+		{&pb.AddProductRequest{UserID: "555", ProductID: "601", Quantity: 2}, nil},
+		{&pb.AddProductRequest{UserID: "casper", ProductID: "", Quantity: -1}, []string{"ProductID", "Quantity", "UserID"}},
+		{&pb.AddProductRequest{UserID: "555", ProductID: "601"}, []string{"Quantity"}},
+		{&pb.ListUsersRequest{Limit: 10, Offset: 20}, nil},
+		{&pb.ListUsersRequest{Limit: -1, Offset: -1}, []string{"Limit", "Offset"}},
+		{&pb.ImportProductsRequest{Products: []*pb.Product{{DisplayName: "candle", Cost: 3}}}, nil},
+		{&pb.ImportProductsRequest{}, []string{"Products"}},
+		{&pb.ImportProductsRequest{Products: []*pb.Product{{DisplayName: "candle"}, {Cost: -3}}}, []string{"Products[1].Cost", "Products[1].DisplayName"}},
+		{&pb.SetUserRolesRequest{UserID: "555", Roles: []string{"support"}}, nil},
+		{&pb.SetUserRolesRequest{Roles: []string{"support", "ghost"}}, []string{"Roles[1]", "UserID"}},
+		{&pb.LinkAccountRequest{Provider: "github", Subject: "42", Email: "sam@example.com"}, nil},
+		{&pb.LinkAccountRequest{Email: "sam", DisplayName: strings.Repeat("x", 101)}, []string{"DisplayName", "Email", "Provider", "Subject"}},
+		{&pb.RegisterRequest{Email: "sam@example.com", Password: "correct horse"}, nil},
+		{&pb.RegisterRequest{Email: "sam"}, []string{"Email", "Password"}},
+		{&pb.RegisterRequest{Email: strings.Repeat("s", 250) + "@example.com", Password: "x"}, []string{"Email"}},
+		{&pb.VerifyEmailRequest{Token: "abc"}, nil},
+		{&pb.VerifyEmailRequest{}, []string{"Token"}},
+		{&pb.LoginLocalRequest{Email: "sam@example.com", Password: "x"}, nil},
+		{&pb.LoginLocalRequest{}, []string{"Email", "Password"}},
+		{&pb.PasswordResetRequest{Email: "sam@example.com"}, nil},
+		{&pb.PasswordResetRequest{}, []string{"Email"}},
+		{&pb.ResetPasswordRequest{Token: "abc", Password: "x"}, nil},
+		{&pb.ResetPasswordRequest{}, []string{"Password", "Token"}},
+		{&pb.CreateAPITokenRequest{UserID: "555", Name: "ci", Scopes: []string{"read"}, TTLSeconds: 3600}, nil},
+		{&pb.CreateAPITokenRequest{}, []string{"Name", "Scopes", "UserID"}},
+		{&pb.CreateAPITokenRequest{UserID: "555", Name: "ci", Scopes: []string{"everything"}, TTLSeconds: -1}, []string{"Scopes[0]", "TTLSeconds"}},
+		{&pb.CreateAPITokenRequest{UserID: "555", Name: "ci", Scopes: []string{"read"}, TTLSeconds: int64(maxTokenTTL.Seconds()) + 1}, []string{"TTLSeconds"}},
+		{&pb.RevokeAPITokenRequest{UserID: "555", TokenID: "9e7f581629aaeec6"}, nil},
+		{&pb.RevokeAPITokenRequest{}, []string{"TokenID", "UserID"}},
+		{&pb.GetDatastoreFaultsRequest{}, nil},
+		{&pb.SetDatastoreFaultsRequest{}, nil},
+		{&pb.SetDatastoreFaultsRequest{Seed: 7, Faults: []*pb.DatastoreFault{{Op: "get", Rate: 0.5}}}, nil},
+		{&pb.SetDatastoreFaultsRequest{Faults: []*pb.DatastoreFault{{Rate: 1}, {Op: "scan", Rate: 2}}}, []string{"Faults[1].Op", "Faults[1].Rate"}},
+		{&pb.DatastoreFault{}, nil},
+		{&pb.DatastoreFault{Kind: "User", Op: "get_all", Rate: 1, LatencyMillis: 200, Error: "unavailable", Partial: true}, nil},
+		{&pb.DatastoreFault{Op: "getall", Rate: -0.1}, []string{"Op", "Rate"}},
+		{&pb.DatastoreFault{Rate: 1.5, LatencyMillis: -1}, []string{"LatencyMillis", "Rate"}},
+		{&pb.DatastoreFault{LatencyMillis: int64(maxFaultLatency.Seconds()*1000) + 1, Error: "boom"}, []string{"Error", "LatencyMillis"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, fv := range requestRules.Violations(test.req) {
+			got = append(got, fv.GetField())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s %v: got violations %v, want %v", proto.MessageName(test.req), test.req, got, test.want)
+		}
+	}
+}
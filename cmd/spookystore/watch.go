@@ -0,0 +1,117 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/m-okeefe/spookystore/internal/auth"
+	"github.com/m-okeefe/spookystore/internal/hub"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchBuffer is how many changes a watcher may fall behind by before it is
+// disconnected
+const watchBuffer = 16
+
+// productsTopic carries a *pb.ProductEvent for every change to the catalog
+const productsTopic = "products"
+
+// transactionsTopic carries a *pb.NumTransactionsResponse after every checkout
+const transactionsTopic = "transactions"
+
+// cartTopic carries a *pb.Cart every time the cart of user id changes
+func cartTopic(id string) string { return "cart/" + id }
+
+// publish sends msg to the watchers of topic
+func (s *Server) publish(topic string, msg interface{}) {
+	if s.events != nil {
+		s.events.Publish(topic, msg)
+	}
+}
+
+// WatchCart sends a User's Cart, then the Cart again every time it changes
+func (s *Server) WatchCart(req *pb.UserRequest, stream pb.SpookyStore_WatchCartServer) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx, auth.ViewUser, req.GetID()); err != nil {
+		return err
+	}
+
+	// subscribe first, so no change between the lookup and the subscription
+	// is missed
+	sub := s.events.Subscribe(cartTopic(req.GetID()))
+	defer sub.Close()
+	user, err := s.mustGetUser(ctx, req.GetID())
+	if err != nil {
+		return err
+	}
+	cart := user.GetCart()
+	if cart == nil {
+		cart = &pb.Cart{}
+	}
+	if err := stream.Send(cart); err != nil {
+		return err
+	}
+	return s.relay(ctx, sub, func(m interface{}) error { return stream.Send(m.(*pb.Cart)) })
+}
+
+// WatchProducts sends an event for every change to the catalog
+func (s *Server) WatchProducts(req *pb.WatchProductsRequest, stream pb.SpookyStore_WatchProductsServer) error {
+	sub := s.events.Subscribe(productsTopic)
+	defer sub.Close()
+	return s.relay(stream.Context(), sub, func(m interface{}) error { return stream.Send(m.(*pb.ProductEvent)) })
+}
+
+// WatchNumTransactions sends the number of transactions, then the new number
+// after every checkout
+func (s *Server) WatchNumTransactions(req *pb.GetNumTransactionsRequest, stream pb.SpookyStore_WatchNumTransactionsServer) error {
+	ctx := stream.Context()
+	sub := s.events.Subscribe(transactionsTopic)
+	defer sub.Close()
+	n, err := s.numTransactions(ctx)
+	if err != nil {
+		middleware.Logger(ctx, log).WithField("error", err).Error("failed to get num transactions")
+		return storeError(err, "failed to count transactions")
+	}
+	if err := stream.Send(&pb.NumTransactionsResponse{NumTransactions: n}); err != nil {
+		return err
+	}
+	return s.relay(ctx, sub, func(m interface{}) error { return stream.Send(m.(*pb.NumTransactionsResponse)) })
+}
+
+// relay sends the messages of sub until the caller goes away. Watchers that
+// fall behind are disconnected with ResourceExhausted, and have to watch
+// again; when the server shuts down they get Unavailable.
+func (s *Server) relay(ctx context.Context, sub *hub.Subscription, send func(interface{}) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-sub.C():
+			if !ok && sub.Err() == hub.ErrClosed {
+				return status.Error(codes.Unavailable, "server is shutting down, watch again")
+			} else if !ok {
+				middleware.Logger(ctx, log).WithField("error", sub.Err()).Warn("dropped watcher")
+				return status.Error(codes.ResourceExhausted, "watcher fell behind, watch again")
+			}
+			if err := send(m); err != nil {
+				middleware.Logger(ctx, log).WithField("error", err).Debug("failed to send change")
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	"github.com/m-okeefe/spookystore/internal/hub"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStream hands whatever the server sends to the test
+type fakeStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan interface{}
+}
+
+func (f *fakeStream) Context() context.Context { return f.ctx }
+
+type cartStream struct{ *fakeStream }
+
+func (s cartStream) Send(c *pb.Cart) error { s.sent <- c; return nil }
+
+type productStream struct{ *fakeStream }
+
+func (s productStream) Send(e *pb.ProductEvent) error { s.sent <- e; return nil }
+
+// receive returns the next message sent, or fails the test
+func receive(t *testing.T, f *fakeStream) interface{} {
+	select {
+	case m := <-f.sent:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+func TestWatchCart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), events: hub.New(watchBuffer)}
+
+	ctx, cancel := context.WithCancel(asUser("555"))
+	defer cancel()
+	cart := &pb.Cart{Items: []*pb.CartItem{{ID: "601", Quantity: 1, Cost: 3}}, TotalCost: 3}
+	m.EXPECT().Get(ctx, datastore.IDKey("User", 555, nil), &User{}).SetArg(2, User{Cart: cart}).Return(nil)
+
+	f := &fakeStream{ctx: ctx, sent: make(chan interface{})}
+	done := make(chan error)
+	go func() { done <- ts.WatchCart(&pb.UserRequest{ID: "555"}, cartStream{f}) }()
+
+	if got := receive(t, f).(*pb.Cart); got.GetTotalCost() != 3 {
+		t.Errorf("expected the current cart first, got %v", got)
+	}
+	ts.publish(cartTopic("556"), &pb.Cart{TotalCost: 1})
+	ts.publish(cartTopic("555"), &pb.Cart{})
+	if got := receive(t, f).(*pb.Cart); len(got.GetItems()) != 0 {
+		t.Errorf("expected the cleared cart, got %v", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the watch to end cleanly, got %v", err)
+	}
+	if n := ts.events.Subscribers(cartTopic("555")); n != 0 {
+		t.Errorf("expected the watcher to unsubscribe, got %d subscribers", n)
+	}
+}
+
+func TestWatchCartOfOtherUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), events: hub.New(watchBuffer)}
+
+	ctx := asUser("556")
+	expectCaller(m, ctx, "556")
+	err := ts.WatchCart(&pb.UserRequest{ID: "555"}, cartStream{&fakeStream{ctx: ctx}})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied, got %v", err)
+	}
+}
+
+type counterStream struct{ *fakeStream }
+
+func (s counterStream) Send(n *pb.NumTransactionsResponse) error { s.sent <- n; return nil }
+
+func TestWatchNumTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	ts := &Server{ds: m, clock: clockwork.NewFakeClock(), events: hub.New(watchBuffer)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.EXPECT().Get(ctx, transactionCounterKey, &TransactionCounter{}).Return(datastore.ErrNoSuchEntity)
+
+	f := &fakeStream{ctx: ctx, sent: make(chan interface{})}
+	done := make(chan error)
+	go func() { done <- ts.WatchNumTransactions(&pb.GetNumTransactionsRequest{}, counterStream{f}) }()
+	if got := receive(t, f).(*pb.NumTransactionsResponse); got.GetNumTransactions() != 0 {
+		t.Errorf("expected 0 transactions before the first checkout, got %v", got)
+	}
+
+	m.EXPECT().Get(ctx, transactionCounterKey, &TransactionCounter{}).Return(datastore.ErrNoSuchEntity)
+	m.EXPECT().Put(ctx, transactionCounterKey, &TransactionCounter{NumTransactions: 1})
+	if err := ts.countTransaction(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, f).(*pb.NumTransactionsResponse); got.GetNumTransactions() != 1 {
+		t.Errorf("expected the new total, got %v", got)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the watch to end cleanly, got %v", err)
+	}
+}
+
+func TestWatchProducts(t *testing.T) {
+	ts := &Server{clock: clockwork.NewFakeClock(), events: hub.New(1)}
+	f := &fakeStream{ctx: context.Background(), sent: make(chan interface{})}
+	done := make(chan error, 1)
+	go func() { done <- ts.WatchProducts(&pb.WatchProductsRequest{}, productStream{f}) }()
+	for ts.events.Subscribers(productsTopic) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// with one event being sent and one buffered, the third overflows
+	for i := 0; i < 3; i++ {
+		ts.publish(productsTopic, &pb.ProductEvent{Type: pb.ProductEvent_CREATED, Product: &pb.Product{ID: strconv.Itoa(i)}})
+	}
+	if got := receive(t, f).(*pb.ProductEvent); got.GetProduct().GetID() != "0" {
+		t.Errorf("expected the first event, got %v", got)
+	}
+	for {
+		select {
+		case <-f.sent:
+			continue
+		case err := <-done:
+			if status.Code(err) != codes.ResourceExhausted {
+				t.Errorf("expected a slow watcher to be dropped, got %v", err)
+			}
+			return
+		}
+	}
+}
+
+func TestWatchProductsShutdown(t *testing.T) {
+	ts := &Server{clock: clockwork.NewFakeClock(), events: hub.New(watchBuffer)}
+	ts.events.Close()
+	err := ts.WatchProducts(&pb.WatchProductsRequest{}, productStream{&fakeStream{ctx: context.Background()}})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected watchers to be told to come back, got %v", err)
+	}
+}
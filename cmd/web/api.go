@@ -0,0 +1,319 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/gorilla/mux"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The JSON API is for scripts and apps, so it only accepts API tokens and
+// never the session cookie; browsers cannot be tricked into calling it.
+// static/openapi.json documents it, and api_test.go checks the two agree.
+
+// apiRoutes registers the JSON API on r
+func (s *server) apiRoutes(r *mux.Router) {
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiError(w, status.Errorf(codes.NotFound, "no such endpoint %s", r.URL.Path))
+	})
+	var paths []string
+	handle := func(path, method string, h http.HandlerFunc) {
+		if len(paths) == 0 || paths[len(paths)-1] != path {
+			paths = append(paths, path)
+		}
+		api.Handle(path, s.traceHandler(logHandler(h))).Methods(method)
+	}
+	handle("/openapi.json", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join(*staticDir, "openapi.json"))
+	})
+	handle("/products", http.MethodGet, s.api(s.apiListProducts, false))
+	handle("/products/{pid:[0-9]+}", http.MethodGet, s.api(s.apiGetProduct, false))
+	handle("/users/{id:[0-9]+}", http.MethodGet, s.api(s.apiGetUser, true))
+	handle("/users/{id:[0-9]+}/cart", http.MethodGet, s.api(s.apiGetCart, true))
+	handle("/users/{id:[0-9]+}/cart", http.MethodPost, s.api(s.apiAddToCart, true))
+	handle("/users/{id:[0-9]+}/cart", http.MethodDelete, s.api(s.apiClearCart, true))
+	handle("/users/{id:[0-9]+}/checkout", http.MethodPost, s.api(s.apiCheckout, true))
+	handle("/users/{id:[0-9]+}/orders", http.MethodGet, s.api(s.apiListOrders, true))
+
+	// the vendored mux loses MethodNotAllowedHandler in subrouters, so each
+	// path gets a route for the methods it does not handle
+	for _, path := range paths {
+		api.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			apiErrorCode(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method+" is not allowed here")
+		})
+	}
+}
+
+// API resources. They are kept apart from the protos so the API can stay
+// stable while the backend changes.
+
+type apiProduct struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PictureURL  string  `json:"pictureUrl"`
+	Cost        float32 `json:"cost"`
+}
+
+type apiProductList struct {
+	Products []apiProduct `json:"products"`
+}
+
+type apiCartItem struct {
+	ProductID string  `json:"productId"`
+	Name      string  `json:"name"`
+	Cost      float32 `json:"cost"`
+	Quantity  int32   `json:"quantity"`
+}
+
+type apiCart struct {
+	Items     []apiCartItem `json:"items"`
+	TotalCost float32       `json:"totalCost"`
+}
+
+type apiOrder struct {
+	CompletedTime time.Time     `json:"completedTime"`
+	Items         []apiCartItem `json:"items"`
+	TotalCost     float32       `json:"totalCost"`
+}
+
+type apiOrderList struct {
+	Orders []apiOrder `json:"orders"`
+}
+
+type apiUser struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Email   string   `json:"email"`
+	Picture string   `json:"picture"`
+	Roles   []string `json:"roles"`
+}
+
+type apiAddToCartRequest struct {
+	ProductID string `json:"productId"`
+	Quantity  int32  `json:"quantity"`
+}
+
+type apiErrorBody struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiHandler handles an API call and returns the HTTP status and resource to
+// respond with. If the call needs a token, ctx carries it.
+type apiHandler func(ctx context.Context, r *http.Request) (code int, v interface{}, err error)
+
+// api writes the result of handle as JSON. If needToken is set, the request
+// must carry an API token, which is passed on to the backend.
+func (s *server) api(handle apiHandler, needToken bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if needToken {
+			h := r.Header.Get("Authorization")
+			token := strings.TrimPrefix(h, "Bearer ")
+			if token == h || !strings.HasPrefix(token, auth.TokenPrefix) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				apiError(w, status.Error(codes.Unauthenticated, "an API token is required"))
+				return
+			}
+			ctx = auth.WithToken(ctx, token)
+		}
+		code, v, err := handle(ctx, r)
+		if err != nil {
+			apiError(w, err)
+			return
+		}
+		writeJSON(w, code, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithField("error", err).Error("failed to write api response")
+	}
+}
+
+// apiError writes err as an error body, with the HTTP status matching its
+// gRPC code. Internal errors are not passed on to the caller.
+func apiError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code, msg := httpStatus(st.Code()), st.Message()
+	if code == http.StatusInternalServerError {
+		log.WithField("error", err).Error("api call failed")
+		msg = "internal error"
+	} else {
+		log.WithField("http.status", code).WithField("error", err).Warn("api call rejected")
+	}
+	apiErrorCode(w, code, st.Code().String(), msg)
+}
+
+func apiErrorCode(w http.ResponseWriter, code int, errCode, msg string) {
+	writeJSON(w, code, apiErrorBody{Error: apiErrorDetail{Code: errCode, Message: msg}})
+}
+
+func (s *server) apiListProducts(ctx context.Context, r *http.Request) (int, interface{}, error) {
+	resp, err := s.spookySvc.GetAllProducts(ctx, &pb.GetAllProductsRequest{})
+	if err != nil {
+		return 0, nil, err
+	}
+	v := apiProductList{Products: []apiProduct{}}
+	for _, p := range resp.GetProductList() {
+		v.Products = append(v.Products, toAPIProduct(p))
+	}
+	return http.StatusOK, v, nil
+}
+
+func (s *server) apiGetProduct(ctx context.Context, r *http.Request) (int, interface{}, error) {
+	p, err := s.spookySvc.GetProduct(ctx, &pb.GetProductRequest{ID: mux.Vars(r)["pid"]})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, toAPIProduct(p), nil
+}
+
+// apiLookupUser looks up the user in the {id} route variable
+func (s *server) apiLookupUser(ctx context.Context, r *http.Request) (*pb.User, error) {
+	resp, err := s.getUser(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return nil, err
+	} else if !resp.GetFound() {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return resp.GetUser(), nil
+}
+
+func (s *server) apiGetUser(ctx context.Context, r *http.Request) (int, interface{}, error) {
+	u, err := s.apiLookupUser(ctx, r)
+	if err != nil {
+		return 0, nil, err
+	}
+	roles := u.GetRoles()
+	if roles == nil {
+		roles = []string{}
+	}
+	return http.StatusOK, apiUser{
+		ID:      u.GetID(),
+		Name:    u.GetDisplayName(),
+		Email:   u.GetEmail(),
+		Picture: u.GetPicture(),
+		Roles:   roles,
+	}, nil
+}
+
+func (s *server) apiGetCart(ctx context.Context, r *http.Request) (int, interface{}, error) {
+	u, err := s.apiLookupUser(ctx, r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, toAPICart(u.GetCart()), nil
+}
+
+func (s *server) apiAddToCart(ctx context.Context, r *http.Request) (int, interface{}, error) {
+	var req apiAddToCartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return 0, nil, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	if req.ProductID == "" {
+		return 0, nil, status.Error(codes.InvalidArgument, "productId is required")
+	} else if req.Quantity <= 0 {
+		return 0, nil, status.Error(codes.InvalidArgument, "quantity must be positive")
+	}
+	resp, err := s.spookySvc.AddProductToCart(ctx, &pb.AddProductRequest{
+		UserID:    mux.Vars(r)["id"],
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, toAPICart(resp.GetCart()), nil
+}
+
+func (s *server) apiClearCart(ctx context.Context, r *http.Request) (int, interface{}, error) {
+	if _, err := s.spookySvc.ClearCart(ctx, &pb.UserRequest{ID: mux.Vars(r)["id"]}); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, toAPICart(nil), nil
+}
+
+// apiCheckout purchases the cart and returns the new order
+func (s *server) apiCheckout(ctx context.Context, r *http.Request) (int, interface{}, error) {
+	resp, err := s.spookySvc.Checkout(ctx, &pb.UserRequest{ID: mux.Vars(r)["id"]})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, toAPIOrder(resp.GetTransaction()), nil
+}
+
+func (s *server) apiListOrders(ctx context.Context, r *http.Request) (int, interface{}, error) {
+	u, err := s.apiLookupUser(ctx, r)
+	if err != nil {
+		return 0, nil, err
+	}
+	v := apiOrderList{Orders: []apiOrder{}}
+	for _, t := range u.GetTransactions() {
+		v.Orders = append(v.Orders, toAPIOrder(t))
+	}
+	return http.StatusOK, v, nil
+}
+
+func toAPIProduct(p *pb.Product) apiProduct {
+	return apiProduct{
+		ID:          p.GetID(),
+		Name:        p.GetDisplayName(),
+		Description: p.GetDescription(),
+		PictureURL:  p.GetPictureURL(),
+		Cost:        p.GetCost(),
+	}
+}
+
+func toAPICart(c *pb.Cart) apiCart {
+	return apiCart{Items: toAPICartItems(c.GetItems()), TotalCost: c.GetTotalCost()}
+}
+
+func toAPICartItems(items []*pb.CartItem) []apiCartItem {
+	v := []apiCartItem{}
+	for _, i := range items {
+		v = append(v, apiCartItem{ProductID: i.GetID(), Name: i.GetDisplayName(), Cost: i.GetCost(), Quantity: i.GetQuantity()})
+	}
+	return v
+}
+
+func toAPIOrder(t *pb.Transaction) apiOrder {
+	completed, _ := ptypes.Timestamp(t.GetCompletedTime())
+	return apiOrder{
+		CompletedTime: completed,
+		Items:         toAPICartItems(t.GetItems().GetItems()),
+		TotalCost:     t.GetItems().GetTotalCost(),
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/gorilla/mux"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBackend serves one user with the token "spk_good"
+type fakeBackend struct {
+	pb.SpookyStoreClient
+	user *pb.User
+}
+
+func (f *fakeBackend) check(ctx context.Context) error {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if v := md.Get("authorization"); len(v) != 1 || v[0] != "Bearer spk_good" {
+		return status.Error(codes.Unauthenticated, "bad token")
+	}
+	return nil
+}
+
+func (f *fakeBackend) GetAllProducts(ctx context.Context, _ *pb.GetAllProductsRequest, _ ...grpc.CallOption) (*pb.GetAllProductsResponse, error) {
+	return &pb.GetAllProductsResponse{ProductList: []*pb.Product{{ID: "7", DisplayName: "Ghost", Cost: 2}}}, nil
+}
+
+func (f *fakeBackend) GetUser(ctx context.Context, req *pb.UserRequest, _ ...grpc.CallOption) (*pb.UserResponse, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetID() != f.user.GetID() {
+		return &pb.UserResponse{}, nil
+	}
+	return &pb.UserResponse{Found: true, User: f.user}, nil
+}
+
+func (f *fakeBackend) AddProductToCart(ctx context.Context, req *pb.AddProductRequest, _ ...grpc.CallOption) (*pb.AddProductResponse, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+	f.user.Cart = &pb.Cart{
+		Items:     []*pb.CartItem{{ID: req.GetProductID(), DisplayName: "Ghost", Cost: 2, Quantity: req.GetQuantity()}},
+		TotalCost: 2 * float32(req.GetQuantity()),
+	}
+	return &pb.AddProductResponse{Cart: f.user.Cart}, nil
+}
+
+func (f *fakeBackend) Checkout(ctx context.Context, req *pb.UserRequest, _ ...grpc.CallOption) (*pb.CheckoutResponse, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+	if len(f.user.GetCart().GetItems()) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "the cart is empty")
+	}
+	t := &pb.Transaction{CompletedTime: &timestamp.Timestamp{Seconds: 1500000000}, Items: f.user.Cart}
+	f.user.Transactions = append(f.user.Transactions, t)
+	f.user.Cart = nil
+	return &pb.CheckoutResponse{Transaction: t}, nil
+}
+
+func TestAPI(t *testing.T) {
+	log = logrus.NewEntry(logrus.New())
+	s := &server{spookySvc: &fakeBackend{user: &pb.User{ID: "1", DisplayName: "Casper"}}}
+	r := mux.NewRouter()
+	s.apiRoutes(r)
+
+	call := func(method, path, token, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var v map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+			t.Fatalf("%s %s: invalid json body %q", method, path, w.Body)
+		}
+		return w.Code, v
+	}
+	errCode := func(v map[string]interface{}) interface{} {
+		e, _ := v["error"].(map[string]interface{})
+		return e["code"]
+	}
+
+	for name, token := range map[string]string{
+		"no token":    "",
+		"not a token": "Bearer abc",
+		"no scheme":   "spk_good",
+		"basic auth":  "Basic dXNlcjpwYXNz",
+	} {
+		if code, v := call(http.MethodGet, "/api/v1/users/1/cart", token, ""); code != http.StatusUnauthorized || errCode(v) != "Unauthenticated" {
+			t.Errorf("%s: expected 401 Unauthenticated, got %d %v", name, code, v)
+		}
+	}
+	if code, v := call(http.MethodGet, "/api/v1/users/1", "Bearer spk_bad", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected the backend's rejection to be passed on, got %d %v", code, v)
+	}
+
+	const good = "Bearer spk_good"
+	if code, v := call(http.MethodGet, "/api/v1/products", "", ""); code != http.StatusOK || len(v["products"].([]interface{})) != 1 {
+		t.Errorf("expected products without a token, got %d %v", code, v)
+	}
+	if code, v := call(http.MethodGet, "/api/v1/users/2", good, ""); code != http.StatusNotFound || errCode(v) != "NotFound" {
+		t.Errorf("expected 404 for an unknown user, got %d %v", code, v)
+	}
+	if code, v := call(http.MethodGet, "/api/v1/nothing", good, ""); code != http.StatusNotFound || errCode(v) != "NotFound" {
+		t.Errorf("expected a json 404 for an unknown endpoint, got %d %v", code, v)
+	}
+	if code, v := call(http.MethodPut, "/api/v1/users/1/cart", good, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected a json 405, got %d %v", code, v)
+	}
+	if code, v := call(http.MethodPost, "/api/v1/users/1/checkout", good, ""); code != http.StatusBadRequest || errCode(v) != "FailedPrecondition" {
+		t.Errorf("expected checking out an empty cart to fail, got %d %v", code, v)
+	}
+	if code, v := call(http.MethodPost, "/api/v1/users/1/cart", good, `{"productId": "7", "quantity": 0}`); code != http.StatusBadRequest || errCode(v) != "InvalidArgument" {
+		t.Errorf("expected a zero quantity to be rejected, got %d %v", code, v)
+	}
+	if code, v := call(http.MethodPost, "/api/v1/users/1/cart", good, `{"productId": "7", "quantity": 3}`); code != http.StatusOK || v["totalCost"] != 6.0 {
+		t.Errorf("expected the updated cart, got %d %v", code, v)
+	}
+	if code, v := call(http.MethodPost, "/api/v1/users/1/checkout", good, ""); code != http.StatusCreated || v["completedTime"] != "2017-07-14T02:40:00Z" {
+		t.Errorf("expected the new order, got %d %v", code, v)
+	}
+	if code, v := call(http.MethodGet, "/api/v1/users/1/orders", good, ""); code != http.StatusOK || len(v["orders"].([]interface{})) != 1 {
+		t.Errorf("expected one order, got %d %v", code, v)
+	}
+}
+
+func TestRPCError(t *testing.T) {
+	log = logrus.NewEntry(logrus.New())
+	for err, want := range map[error]int{
+		status.Error(codes.NotFound, "user 1 not found"):                         http.StatusNotFound,
+		errors.Wrap(status.Error(codes.FailedPrecondition, "empty"), "checkout"): http.StatusBadRequest,
+		errors.Wrap(status.Error(codes.Unavailable, "down"), "checkout"):         http.StatusServiceUnavailable,
+		errors.New("connection reset"):                                           http.StatusInternalServerError,
+	} {
+		w := httptest.NewRecorder()
+		rpcError(w, err)
+		if w.Code != want {
+			t.Errorf("rpcError(%v) wrote %d, want %d", err, w.Code, want)
+		}
+	}
+}
+
+// apiSchemas are the Go types of the schemas in static/openapi.json
+var apiSchemas = map[string]interface{}{
+	"Product":          apiProduct{},
+	"ProductList":      apiProductList{},
+	"CartItem":         apiCartItem{},
+	"Cart":             apiCart{},
+	"Order":            apiOrder{},
+	"OrderList":        apiOrderList{},
+	"User":             apiUser{},
+	"AddToCartRequest": apiAddToCartRequest{},
+	"Error":            apiErrorBody{},
+	"ErrorDetail":      apiErrorDetail{},
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       string                    `json:"type"`
+	Format     string                    `json:"format"`
+	Items      *openAPISchema            `json:"items"`
+	Properties map[string]*openAPISchema `json:"properties"`
+}
+
+// TestOpenAPI checks that static/openapi.json documents every route and
+// resource of the API, and nothing else.
+func TestOpenAPI(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("static", "openapi.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage
+		Components struct{ Schemas map[string]*openAPISchema }
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("invalid openapi.json: %v", err)
+	}
+
+	var documented, routed []string
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			documented = append(documented, strings.ToUpper(method)+" /api/v1"+path)
+		}
+	}
+	r := mux.NewRouter()
+	(&server{}).apiRoutes(r)
+	pattern := regexp.MustCompile(`{(\w+):[^}]*}`)
+	r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, m := range methods {
+			routed = append(routed, m+" "+pattern.ReplaceAllString(path, "{$1}"))
+		}
+		return nil
+	})
+	sort.Strings(documented)
+	sort.Strings(routed)
+	if !reflect.DeepEqual(documented, routed) {
+		t.Errorf("documented routes\n%s\ndo not match the handlers\n%s", strings.Join(documented, "\n"), strings.Join(routed, "\n"))
+	}
+
+	for name := range doc.Components.Schemas {
+		if _, ok := apiSchemas[name]; !ok {
+			t.Errorf("schema %s has no Go type", name)
+		}
+	}
+	for name, v := range apiSchemas {
+		s, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("%T is not documented as schema %s", v, name)
+			continue
+		}
+		checkSchema(t, name, s, reflect.TypeOf(v))
+	}
+}
+
+// checkSchema checks that the properties of schema s match the fields of
+// struct type typ
+func checkSchema(t *testing.T, name string, s *openAPISchema, typ reflect.Type) {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		fields[strings.Split(f.Tag.Get("json"), ",")[0]] = f.Type
+	}
+	for prop := range s.Properties {
+		if _, ok := fields[prop]; !ok {
+			t.Errorf("%s.%s is documented but not in %s", name, prop, typ)
+		}
+	}
+	for field, ft := range fields {
+		p, ok := s.Properties[field]
+		if !ok {
+			t.Errorf("%s.%s is not documented", name, field)
+			continue
+		}
+		if want, got := schemaOf(ft), describe(p); want != got {
+			t.Errorf("%s.%s is documented as %s, but is %s", name, field, got, want)
+		}
+	}
+}
+
+// schemaOf describes the JSON encoding of typ in the same terms as describe
+func schemaOf(typ reflect.Type) string {
+	if typ == reflect.TypeOf(time.Time{}) {
+		return "string/date-time"
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array of " + schemaOf(typ.Elem())
+	case reflect.Struct:
+		for name, v := range apiSchemas {
+			if reflect.TypeOf(v) == typ {
+				return "#/components/schemas/" + name
+			}
+		}
+	}
+	return "unknown " + typ.String()
+}
+
+func describe(s *openAPISchema) string {
+	switch {
+	case s.Ref != "":
+		return s.Ref
+	case s.Type == "array" && s.Items != nil:
+		return "array of " + describe(s.Items)
+	case s.Format == "date-time":
+		return s.Type + "/" + s.Format
+	}
+	return s.Type
+}
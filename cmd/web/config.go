@@ -0,0 +1,65 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/m-okeefe/spookystore/internal/config"
+	"github.com/m-okeefe/spookystore/internal/session"
+	"github.com/pkg/errors"
+)
+
+// settings are the flags, also read from a config file and the environment
+var settings = config.New(flag.CommandLine).Secret("session-keys", "smtp-password", "service-secret")
+
+// validateConfig returns every problem with the settings at once
+func validateConfig() error {
+	var errs config.Errors
+	errs.Required("addr", *addr)
+	errs.Required("spooky-store-addr", *spookyStoreBackend)
+	errs.Required("service-secret", *serviceSecret)
+	errs.Check(*oauthConfig != "" || *identityProviders != "" || *localAccounts, "google-oauth2-config",
+		"is required unless --identity-providers or --local-accounts are given, or no one can log in")
+	errs.OneOf("log-level", *logLevel, "debug", "info", "warn", "error")
+	errs.OneOf("trace-exporter", *traceExporter, "none", "stdout", "otlp")
+	errs.OneOf("session-store", *sessionStore, "memory", "datastore")
+	if *sessionStore == "datastore" {
+		errs.Required("google-project-id", *projectID)
+		if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" {
+			errs = append(errs, errors.New("GOOGLE_APPLICATION_CREDENTIALS environment variable is not set, but the datastore session store needs it"))
+		}
+	}
+	if *sessionKeys != "" {
+		if _, err := session.ParseKeys(*sessionKeys); err != nil {
+			errs.Check(false, "session-keys", "are invalid: "+err.Error())
+		}
+	}
+	if _, err := os.Stat(*staticDir); err != nil {
+		errs.Check(false, "static-dir", "is not readable: "+err.Error())
+	}
+	errs.OneOf("templates", *templateSource, "disk", "embedded")
+	errs.Check(!*reloadTemplates || *templateSource == "disk", "reload-templates", "needs --templates=disk")
+	errs.Check(*dialAttempts > 0, "backend-dial-attempts", "must be positive")
+	errs.Check(*dialTimeout > 0, "backend-dial-timeout", "must be positive")
+	errs.Check(*backendTimeout > 0, "backend-timeout", "must be positive")
+	errs.Check(*shutdownDelay >= 0, "shutdown-delay", "must not be negative")
+	errs.Check(*shutdownTimeout >= 0, "shutdown-timeout", "must not be negative")
+	errs.Check(*sessionMaxAge > 0, "session-max-age", "must be positive")
+	errs.Check(*sessionIdleTimeout > 0, "session-idle-timeout", "must be positive")
+	errs.Check(*smtpUser == "" || *smtpAddr != "", "smtp-user", "needs --smtp-addr")
+	return errs.Err()
+}
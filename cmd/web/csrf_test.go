@@ -0,0 +1,124 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/session"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// cartBackend serves one user to web's session-authenticated pages and
+// counts the products added to their cart
+type cartBackend struct {
+	pb.SpookyStoreClient
+	user  *pb.User
+	added int32
+}
+
+func (f *cartBackend) GetUser(ctx context.Context, req *pb.UserRequest, _ ...grpc.CallOption) (*pb.UserResponse, error) {
+	return &pb.UserResponse{Found: req.GetID() == f.user.GetID(), User: f.user}, nil
+}
+
+func (f *cartBackend) AddProductToCart(ctx context.Context, req *pb.AddProductRequest, _ ...grpc.CallOption) (*pb.AddProductResponse, error) {
+	f.added += req.GetQuantity()
+	return &pb.AddProductResponse{}, nil
+}
+
+func TestCSRF(t *testing.T) {
+	log = logrus.NewEntry(logrus.New())
+	backend := &cartBackend{user: &pb.User{ID: "1", Roles: []string{string(auth.RoleCustomer)}}}
+	store := session.NewMemoryStore()
+	s := &server{
+		spookySvc: backend,
+		sessions:  session.NewManager(store, session.RandomKeys(), clockwork.NewFakeClock(), session.Options{}),
+	}
+	w := httptest.NewRecorder()
+	sess, err := s.sessions.Login(context.Background(), w, httptest.NewRequest(http.MethodGet, "/", nil), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+
+	request := func(method, path string, form url.Values, header string) *http.Request {
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set(csrfHeader, header)
+		}
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		return r
+	}
+	add := func(form url.Values, header string) int {
+		r := request(http.MethodPost, "/addproduct/1/7/2", form, header)
+		r = mux.SetURLVars(r, map[string]string{"id": "1", "pid": "7", "quantity": "2"})
+		w := httptest.NewRecorder()
+		s.addProduct(w, r)
+		return w.Code
+	}
+
+	for name, tc := range map[string]struct {
+		form   url.Values
+		header string
+	}{
+		"no token":     {},
+		"wrong token":  {form: url.Values{csrfField: {"guess"}}},
+		"wrong header": {header: "guess"},
+		"session id":   {form: url.Values{csrfField: {sess.ID}}},
+	} {
+		if code := add(tc.form, tc.header); code != http.StatusForbidden {
+			t.Errorf("%s: expected %d, got %d", name, http.StatusForbidden, code)
+		}
+	}
+	if backend.added != 0 {
+		t.Fatalf("expected no product added without the csrf token, got %d", backend.added)
+	}
+	if code := add(nil, sess.CSRFToken); code != http.StatusOK {
+		t.Errorf("header: expected %d, got %d", http.StatusOK, code)
+	}
+	if code := add(url.Values{csrfField: {sess.CSRFToken}}, ""); code != http.StatusOK {
+		t.Errorf("form: expected %d, got %d", http.StatusOK, code)
+	}
+	if backend.added != 4 {
+		t.Errorf("expected 4 products added with the csrf token, got %d", backend.added)
+	}
+
+	w = httptest.NewRecorder()
+	s.logoutAll(w, request(http.MethodPost, "/logout/all", nil, ""))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected logging out everywhere without the csrf token to be forbidden, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.logoutAll(w, request(http.MethodPost, "/logout/all", url.Values{csrfField: {sess.CSRFToken}}, ""))
+	if w.Code != http.StatusFound {
+		t.Errorf("expected logging out everywhere with the csrf token to redirect, got %d", w.Code)
+	}
+	if _, err := store.Get(context.Background(), sess.ID); err != session.ErrNotFound {
+		t.Errorf("expected the session to be ended, got %v", err)
+	}
+}
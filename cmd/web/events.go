@@ -0,0 +1,247 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/pkg/errors"
+)
+
+// Pages keep up with carts, the catalog and the purchase counter by listening to Server-Sent Events
+// relayed from the backend's watch streams. The events carry the same JSON as
+// the API.
+
+// eventsHeartbeat is how often an idle event stream gets a comment, so
+// proxies don't time it out
+const eventsHeartbeat = 15 * time.Second
+
+// eventsRetry is how long browsers wait before reconnecting a dropped stream
+const eventsRetry = 3 * time.Second
+
+// event is one Server-Sent Event. Its ID, if set, comes back in the
+// Last-Event-ID header when the browser reconnects.
+type event struct {
+	id   string
+	name string
+	data interface{}
+}
+
+type apiProductEvent struct {
+	Type    string     `json:"type"`
+	Product apiProduct `json:"product"`
+}
+
+type apiTransactionCount struct {
+	NumTransactions int32 `json:"numTransactions"`
+}
+
+// cartEvents streams the cart of the user in the {id} route variable as
+// "cart" events, starting with its current contents
+func (s *server) cartEvents(w http.ResponseWriter, r *http.Request) {
+	_, ctx, ok := s.authorize(w, r, auth.ViewUser)
+	if !ok {
+		return
+	}
+	stream, err := s.spookySvc.WatchCart(ctx, &pb.UserRequest{ID: mux.Vars(r)["id"]})
+	if err != nil {
+		rpcError(w, errors.Wrap(err, "failed to watch cart"))
+		return
+	}
+	// the first cart comes right away, so failures still get an error status
+	cart, err := stream.Recv()
+	if err != nil {
+		rpcError(w, errors.Wrap(err, "failed to watch cart"))
+		return
+	}
+	es, err := startEvents(w)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if err := es.send(&event{name: "cart", data: toAPICart(cart)}); err != nil {
+		return
+	}
+	relayEvents(ctx, s.stopping, es, func() (*event, error) {
+		c, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return &event{name: "cart", data: toAPICart(c)}, nil
+	})
+}
+
+// productEvents streams changes to the catalog as "product" events
+func (s *server) productEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	stream, err := s.spookySvc.WatchProducts(ctx, &pb.WatchProductsRequest{})
+	if err != nil {
+		rpcError(w, errors.Wrap(err, "failed to watch products"))
+		return
+	}
+	es, err := startEvents(w)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	relayEvents(ctx, s.stopping, es, func() (*event, error) {
+		e, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return &event{name: "product", data: apiProductEvent{
+			Type:    strings.ToLower(e.GetType().String()),
+			Product: toAPIProduct(e.GetProduct()),
+		}}, nil
+	})
+}
+
+// transactionEvents streams the number of transactions as "transactions"
+// events, whose ID is the number. Browsers that reconnect with the number
+// they have already seen only get an event once it changes.
+func (s *server) transactionEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	stream, err := s.spookySvc.WatchNumTransactions(ctx, &pb.GetNumTransactionsRequest{})
+	if err != nil {
+		rpcError(w, errors.Wrap(err, "failed to watch transactions"))
+		return
+	}
+	next := func() (*event, error) {
+		n, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return &event{
+			id:   strconv.Itoa(int(n.GetNumTransactions())),
+			name: "transactions",
+			data: apiTransactionCount{NumTransactions: n.GetNumTransactions()},
+		}, nil
+	}
+	// the current number comes right away
+	first, err := next()
+	if err != nil {
+		rpcError(w, errors.Wrap(err, "failed to watch transactions"))
+		return
+	}
+	es, err := startEvents(w)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if first.id != r.Header.Get("Last-Event-ID") {
+		if err := es.send(first); err != nil {
+			return
+		}
+	}
+	relayEvents(ctx, s.stopping, es, next)
+}
+
+// eventStream writes Server-Sent Events
+type eventStream struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+// startEvents writes the headers of an event stream
+func startEvents(w http.ResponseWriter) (*eventStream, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("response writer can't stream")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	es := &eventStream{w: w, f: f}
+	return es, es.write(fmt.Sprintf("retry: %d\n\n", eventsRetry/time.Millisecond))
+}
+
+// send writes e, with its data as JSON
+func (es *eventStream) send(e *event) error {
+	b, err := json.Marshal(e.data)
+	if err != nil {
+		return err
+	}
+	var id string
+	if e.id != "" {
+		id = fmt.Sprintf("id: %s\n", e.id)
+	}
+	return es.write(fmt.Sprintf("%sevent: %s\ndata: %s\n\n", id, e.name, b))
+}
+
+func (es *eventStream) write(s string) error {
+	if _, err := io.WriteString(es.w, s); err != nil {
+		return err
+	}
+	es.f.Flush()
+	return nil
+}
+
+// relayEvents sends what recv returns as events until recv fails, the browser
+// goes away or stop is closed. The browser reconnects when the stream ends.
+func relayEvents(ctx context.Context, stop <-chan struct{}, es *eventStream, recv func() (*event, error)) {
+	type result struct {
+		e   *event
+		err error
+	}
+	results := make(chan result)
+	go func() {
+		for {
+			v, err := recv()
+			select {
+			case results <- result{v, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-heartbeat.C:
+			if err := es.write(": keep-alive\n\n"); err != nil {
+				return
+			}
+		case res := <-results:
+			if res.err != nil {
+				if res.err != io.EOF {
+					log.WithField("error", res.err).Warn("event stream from backend ended")
+				}
+				return
+			}
+			if err := es.send(res.e); err != nil {
+				return
+			}
+		}
+	}
+}
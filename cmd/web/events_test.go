@@ -0,0 +1,127 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeWatcher streams fixed lists of product events and transaction counts
+type fakeWatcher struct {
+	pb.SpookyStoreClient
+	events []*pb.ProductEvent
+	counts []int32
+	err    error
+}
+
+func (f *fakeWatcher) WatchProducts(ctx context.Context, _ *pb.WatchProductsRequest, _ ...grpc.CallOption) (pb.SpookyStore_WatchProductsClient, error) {
+	return &fakeProductStream{events: f.events}, f.err
+}
+
+type fakeProductStream struct {
+	grpc.ClientStream
+	events []*pb.ProductEvent
+}
+
+func (f *fakeProductStream) Recv() (*pb.ProductEvent, error) {
+	if len(f.events) == 0 {
+		return nil, io.EOF
+	}
+	e := f.events[0]
+	f.events = f.events[1:]
+	return e, nil
+}
+
+func (f *fakeWatcher) WatchNumTransactions(ctx context.Context, _ *pb.GetNumTransactionsRequest, _ ...grpc.CallOption) (pb.SpookyStore_WatchNumTransactionsClient, error) {
+	return &fakeCounterStream{counts: f.counts}, f.err
+}
+
+type fakeCounterStream struct {
+	grpc.ClientStream
+	counts []int32
+}
+
+func (f *fakeCounterStream) Recv() (*pb.NumTransactionsResponse, error) {
+	if len(f.counts) == 0 {
+		return nil, io.EOF
+	}
+	n := f.counts[0]
+	f.counts = f.counts[1:]
+	return &pb.NumTransactionsResponse{NumTransactions: n}, nil
+}
+
+func TestProductEvents(t *testing.T) {
+	log = logrus.NewEntry(logrus.New())
+	s := &server{spookySvc: &fakeWatcher{events: []*pb.ProductEvent{{
+		Type:    pb.ProductEvent_CREATED,
+		Product: &pb.Product{ID: "601", DisplayName: "Pumpkin", Cost: 3},
+	}}}}
+	w := httptest.NewRecorder()
+	s.productEvents(w, httptest.NewRequest(http.MethodGet, "/events/products", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, got %q", ct)
+	}
+	want := "retry: 3000\n\n" +
+		"event: product\n" +
+		`data: {"type":"created","product":{"id":"601","name":"Pumpkin","description":"","pictureUrl":"","cost":3}}` + "\n\n"
+	if w.Body.String() != want {
+		t.Errorf("got body %q, want %q", w.Body, want)
+	}
+
+	s = &server{spookySvc: &fakeWatcher{err: status.Error(codes.Unavailable, "backend down")}}
+	w = httptest.NewRecorder()
+	s.productEvents(w, httptest.NewRequest(http.MethodGet, "/events/products", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
+
+func TestTransactionEvents(t *testing.T) {
+	log = logrus.NewEntry(logrus.New())
+	serve := func(lastEventID string) string {
+		s := &server{spookySvc: &fakeWatcher{counts: []int32{41, 42}}}
+		req := httptest.NewRequest(http.MethodGet, "/events/transactions", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		w := httptest.NewRecorder()
+		s.transactionEvents(w, req)
+		return w.Body.String()
+	}
+	e41 := "id: 41\nevent: transactions\ndata: {\"numTransactions\":41}\n\n"
+	e42 := "id: 42\nevent: transactions\ndata: {\"numTransactions\":42}\n\n"
+
+	if got, want := serve(""), "retry: 3000\n\n"+e41+e42; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// a browser that saw 41 before reconnecting isn't sent it again
+	if got, want := serve("41"), "retry: 3000\n\n"+e42; got != want {
+		t.Errorf("after reconnecting, got %q, want %q", got, want)
+	}
+	if got, want := serve("40"), "retry: 3000\n\n"+e41+e42; got != want {
+		t.Errorf("after missing a change, got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build ignore
+// +build ignore
+
+// gen_templates writes templates_embedded.go, holding the templates in
+// static/template, for web --templates=embedded. Run it with go generate.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+)
+
+func main() {
+	paths, err := filepath.Glob(filepath.Join("static", "template", "*.html"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(paths)
+	var b bytes.Buffer
+	fmt.Fprint(&b, "// Code generated by gen_templates.go; DO NOT EDIT.\n\npackage main\n\n")
+	fmt.Fprint(&b, "// embeddedTemplates are the files in static/template, by name\n")
+	fmt.Fprint(&b, "var embeddedTemplates = map[string]string{\n")
+	for _, p := range paths {
+		src, err := ioutil.ReadFile(p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&b, "%q: %q,\n", filepath.Base(p), src)
+	}
+	fmt.Fprint(&b, "}\n")
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("templates_embedded.go", out, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
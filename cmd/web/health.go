@@ -0,0 +1,63 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// backendServiceName is the service the backend reports its health under
+const backendServiceName = "SpookyStore"
+
+// readyTimeout bounds the backend health check of a readiness probe
+const readyTimeout = 2 * time.Second
+
+// healthz answers liveness probes. It doesn't depend on the backend, so a
+// backend outage doesn't get web restarted.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz answers readiness probes, and is only ok while the backend reports
+// that it is serving, so web gets no traffic it can't serve. It fails once
+// web is shutting down.
+func (s *server) readyz(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-s.draining:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "shutting down")
+		return
+	default:
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	resp, err := s.health.Check(ctx, &healthpb.HealthCheckRequest{Service: backendServiceName})
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "backend unreachable: %s\n", status.Code(err))
+		return
+	} else if st := resp.GetStatus(); st != healthpb.HealthCheckResponse_SERVING {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "backend %s\n", st)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
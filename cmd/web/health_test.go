@@ -0,0 +1,127 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// fakeHealth reports a fixed status for the backend
+type fakeHealth struct {
+	healthpb.HealthClient
+	status healthpb.HealthCheckResponse_ServingStatus
+	err    error
+}
+
+func (f *fakeHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest, _ ...grpc.CallOption) (*healthpb.HealthCheckResponse, error) {
+	if req.GetService() != backendServiceName {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: f.status}, f.err
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		backend *fakeHealth
+		want    int
+	}{
+		{&fakeHealth{status: healthpb.HealthCheckResponse_SERVING}, http.StatusOK},
+		{&fakeHealth{status: healthpb.HealthCheckResponse_NOT_SERVING}, http.StatusServiceUnavailable},
+		{&fakeHealth{err: status.Error(codes.Unavailable, "connection refused")}, http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		s := &server{health: test.backend}
+		w := httptest.NewRecorder()
+		s.readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if w.Code != test.want {
+			t.Errorf("backend %v, %v: got %d %q, want %d", test.backend.status, test.backend.err, w.Code, w.Body, test.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected healthz to be ok, got %d", w.Code)
+	}
+}
+
+func TestReadyzShuttingDown(t *testing.T) {
+	s := &server{
+		health:   &fakeHealth{status: healthpb.HealthCheckResponse_SERVING},
+		draining: make(chan struct{}),
+		stopping: make(chan struct{}),
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(s.readyz)}
+	go srv.Serve(lis)
+	get := func() (int, error) {
+		resp, err := http.Get("http://" + lis.Addr().String() + "/readyz")
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	if code, err := get(); err != nil || code != http.StatusOK {
+		t.Fatalf("expected to be ready, got %d, %v", code, err)
+	}
+
+	clock := clockwork.NewFakeClock()
+	done := make(chan struct{})
+	go func() {
+		s.shutdown(clock, 5*time.Second, time.Second, srv)
+		close(done)
+	}()
+
+	// readiness fails first, while requests and event streams are still served
+	clock.BlockUntil(1)
+	if code, err := get(); err != nil || code != http.StatusServiceUnavailable {
+		t.Errorf("expected requests to be served but not to be ready during the shutdown delay, got %d, %v", code, err)
+	}
+	select {
+	case <-s.stopping:
+		t.Errorf("expected event streams to go on during the shutdown delay")
+	default:
+	}
+
+	clock.Advance(5 * time.Second)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected shutdown to finish after the delay")
+	}
+	if _, err := get(); err == nil {
+		t.Errorf("expected the listener to be closed after the delay")
+	}
+	select {
+	case <-s.stopping:
+	default:
+		t.Errorf("expected event streams to end after the delay")
+	}
+}
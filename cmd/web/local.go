@@ -0,0 +1,196 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userError returns the message of errors the user can fix, and false for
+// errors that are our fault
+func userError(err error) (string, bool) {
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.InvalidArgument, codes.AlreadyExists, codes.Unauthenticated,
+		codes.ResourceExhausted, codes.FailedPrecondition, codes.NotFound:
+		return st.Message(), true
+	}
+	return "", false
+}
+
+func (s *server) loginPage(w http.ResponseWriter, errMsg, notice string) {
+	names := make([]string, len(s.providers))
+	for i, p := range s.providers {
+		names[i] = p.Name()
+	}
+	code := http.StatusOK
+	if errMsg != "" {
+		code = http.StatusBadRequest
+	}
+	s.templates.render(w, code, "login.html", map[string]interface{}{
+		"providers": names,
+		"local":     s.localAccounts,
+		"error":     errMsg,
+		"notice":    notice})
+}
+
+func (s *server) loginLocal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, err := s.spookySvc.LoginLocal(ctx, &pb.LoginLocalRequest{
+		Email:    r.PostFormValue("email"),
+		Password: r.PostFormValue("password"),
+	})
+	if err != nil {
+		if msg, ok := userError(err); ok {
+			s.loginPage(w, msg, "")
+			return
+		}
+		rpcError(w, errors.Wrap(err, "failed to log in"))
+		return
+	}
+	if _, err := s.sessions.Login(ctx, w, r, user.GetID()); err != nil {
+		serverError(w, errors.Wrap(err, "failed to start session"))
+		return
+	}
+	log.WithField("user.id", user.GetID()).Info("authenticated local user")
+	w.Header().Set("Location", "/")
+	w.WriteHeader(http.StatusFound)
+}
+
+func (s *server) registerPage(w http.ResponseWriter, r *http.Request) {
+	s.templates.render(w, http.StatusOK, "register.html", nil)
+}
+
+func (s *server) register(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	email := r.PostFormValue("email")
+	if r.PostFormValue("password") != r.PostFormValue("confirm") {
+		s.templates.render(w, http.StatusBadRequest, "register.html", map[string]interface{}{"error": "passwords do not match", "email": email})
+		return
+	}
+	resp, err := s.spookySvc.Register(ctx, &pb.RegisterRequest{
+		Email:       email,
+		Password:    r.PostFormValue("password"),
+		DisplayName: r.PostFormValue("name"),
+	})
+	if err != nil {
+		if msg, ok := userError(err); ok {
+			s.templates.render(w, http.StatusBadRequest, "register.html", map[string]interface{}{"error": msg, "email": email})
+			return
+		}
+		rpcError(w, errors.Wrap(err, "failed to register"))
+		return
+	}
+	link := s.publicURL + "/verify?token=" + url.QueryEscape(resp.GetVerificationToken())
+	if err := s.mail.Send(email, "Verify your SpookyStore account",
+		"Welcome to SpookyStore! Open this link to verify your email:\n\n"+link); err != nil {
+		serverError(w, errors.Wrap(err, "failed to send verification email"))
+		return
+	}
+	log.WithField("user.id", resp.GetUserID()).Info("registered local user")
+	s.loginPage(w, "", "Check your email for a link to verify your account.")
+}
+
+// verifyEmail verifies the email of a new account and logs its user in
+func (s *server) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, err := s.spookySvc.VerifyEmail(ctx, &pb.VerifyEmailRequest{Token: r.URL.Query().Get("token")})
+	if err != nil {
+		if msg, ok := userError(err); ok {
+			s.loginPage(w, msg, "")
+			return
+		}
+		rpcError(w, errors.Wrap(err, "failed to verify email"))
+		return
+	}
+	if _, err := s.sessions.Login(ctx, w, r, user.GetID()); err != nil {
+		serverError(w, errors.Wrap(err, "failed to start session"))
+		return
+	}
+	w.Header().Set("Location", "/")
+	w.WriteHeader(http.StatusFound)
+}
+
+func (s *server) forgotPasswordPage(w http.ResponseWriter, r *http.Request) {
+	s.templates.render(w, http.StatusOK, "password_forgot.html", nil)
+}
+
+func (s *server) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	email := r.PostFormValue("email")
+	resp, err := s.spookySvc.RequestPasswordReset(ctx, &pb.PasswordResetRequest{Email: email})
+	if err != nil {
+		rpcError(w, errors.Wrap(err, "failed to request password reset"))
+		return
+	}
+	if resp.GetToken() != "" {
+		link := s.publicURL + "/password/reset?token=" + url.QueryEscape(resp.GetToken())
+		// sending takes a while, so it happens after responding: waiting for
+		// it would tell which emails have accounts
+		go func() {
+			if err := s.mail.Send(email, "Reset your SpookyStore password",
+				"Open this link within an hour to choose a new password:\n\n"+link+
+					"\n\nIf you did not ask to reset your password, you can ignore this email."); err != nil {
+				log.WithField("error", err).Error("failed to send password reset email")
+			}
+		}()
+	}
+	// say the same, as quickly, whether or not the account exists
+	s.loginPage(w, "", "If there is an account with that email, we sent it a link to reset the password.")
+}
+
+func (s *server) resetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	s.templates.render(w, http.StatusOK, "password_reset.html", map[string]interface{}{"token": r.URL.Query().Get("token")})
+}
+
+// resetPassword sets a new password, ends every existing session of the user
+// and logs them in
+func (s *server) resetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := r.PostFormValue("token")
+	if r.PostFormValue("password") != r.PostFormValue("confirm") {
+		s.templates.render(w, http.StatusBadRequest, "password_reset.html", map[string]interface{}{"error": "passwords do not match", "token": token})
+		return
+	}
+	user, err := s.spookySvc.ResetPassword(ctx, &pb.ResetPasswordRequest{
+		Token:    token,
+		Password: r.PostFormValue("password"),
+	})
+	if err != nil {
+		if msg, ok := userError(err); ok {
+			s.templates.render(w, http.StatusBadRequest, "password_reset.html", map[string]interface{}{"error": msg, "token": token})
+			return
+		}
+		rpcError(w, errors.Wrap(err, "failed to reset password"))
+		return
+	}
+	if err := s.sessions.LogoutAll(ctx, w, user.GetID()); err != nil {
+		serverError(w, errors.Wrap(err, "failed to end sessions"))
+		return
+	}
+	if _, err := s.sessions.Login(ctx, w, r, user.GetID()); err != nil {
+		serverError(w, errors.Wrap(err, "failed to start session"))
+		return
+	}
+	log.WithField("user.id", user.GetID()).Info("reset password")
+	w.Header().Set("Location", "/")
+	w.WriteHeader(http.StatusFound)
+}
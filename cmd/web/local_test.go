@@ -0,0 +1,82 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// resetBackend has an account for sam@example.com only
+type resetBackend struct {
+	pb.SpookyStoreClient
+}
+
+func (resetBackend) RequestPasswordReset(_ context.Context, req *pb.PasswordResetRequest, _ ...grpc.CallOption) (*pb.PasswordResetResponse, error) {
+	if req.GetEmail() != "sam@example.com" {
+		return &pb.PasswordResetResponse{}, nil
+	}
+	return &pb.PasswordResetResponse{Token: "reset-token"}, nil
+}
+
+// slowMailer sends emails once released
+type slowMailer struct {
+	release chan struct{}
+	sent    chan string
+}
+
+func (m slowMailer) Send(to, subject, body string) error {
+	<-m.release
+	m.sent <- to
+	return nil
+}
+
+func TestForgotPassword(t *testing.T) {
+	tmpls, err := newTemplates(embeddedLoader, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mail := slowMailer{release: make(chan struct{}), sent: make(chan string, 1)}
+	s := &server{spookySvc: resetBackend{}, templates: tmpls, mail: mail}
+
+	// the response doesn't wait for the email, for accounts or not
+	for _, email := range []string{"nobody@example.com", "sam@example.com"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(url.Values{"email": {email}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		s.forgotPassword(w, r)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "If there is an account") {
+			t.Errorf("expected the same notice for %s, got %d %s", email, w.Code, w.Body)
+		}
+	}
+
+	close(mail.release)
+	select {
+	case to := <-mail.sent:
+		if to != "sam@example.com" {
+			t.Errorf("expected the reset link to go to the account, went to %s", to)
+		}
+	case <-time.After(10 * time.Second):
+		t.Error("expected the reset link to be sent")
+	}
+}
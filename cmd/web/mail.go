@@ -0,0 +1,66 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// mailer sends emails to users, such as verification and reset links
+type mailer interface {
+	Send(to, subject, body string) error
+}
+
+// logMailer only logs emails, for development and staging environments
+// without an SMTP server
+type logMailer struct{}
+
+func (logMailer) Send(to, subject, body string) error {
+	log.WithField("to", to).WithField("subject", subject).Info("not sending email: " + body)
+	return nil
+}
+
+// smtpMailer sends emails through an SMTP server
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func newSMTPMailer(addr, from, user, password string) (*smtpMailer, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid smtp address %q", addr)
+	}
+	m := &smtpMailer{addr: addr, from: from}
+	if user != "" {
+		m.auth = smtp.PlainAuth("", user, password, host)
+	}
+	return m, nil
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return errors.New("invalid email header")
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		m.from, to, subject, body)
+	return errors.Wrap(smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg)), "failed to send email")
+}
@@ -20,66 +20,105 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"html/template"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
-	"cloud.google.com/go/trace"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/gorilla/mux"
-	"github.com/gorilla/securecookie"
+	"github.com/jonboulle/clockwork"
 	"github.com/m-okeefe/spookystore/cmd/version"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	"github.com/m-okeefe/spookystore/internal/identity"
+	"github.com/m-okeefe/spookystore/internal/metrics"
 	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/m-okeefe/spookystore/internal/session"
+	"github.com/m-okeefe/spookystore/internal/tracing"
 	"github.com/pkg/errors"
 	logrus "github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	plus "google.golang.org/api/plus/v1"
-	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/grpclog"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 type server struct {
-	cfg       *oauth2.Config
+	providers []identity.Provider
 	spookySvc pb.SpookyStoreClient
-	tc        *trace.Client
+	health    healthpb.HealthClient
+	tracer    *tracing.Tracer
+	sessions  *session.Manager
+
+	// redirectURLs are the oauth2 callback URLs users may be sent back to
+	redirectURLs []string
+
+	// localAccounts enables email and password login next to the providers
+	localAccounts bool
+	mail          mailer
+	// publicURL is where links in emails point to
+	publicURL string
+
+	// draining is closed when web starts shutting down, to fail readiness
+	// checks, and stopping once it stops taking requests, to end event streams
+	draining chan struct{}
+	stopping chan struct{}
+
+	templates *templates
 }
 
+// traceQPS is how many new traces a second are recorded
+const traceQPS = 5
+
 var (
 	projectID          = flag.String("google-project-id", "", "google cloud project id")
 	addr               = flag.String("addr", ":8000", "[host]:port to listen")
-	oauthConfig        = flag.String("google-oauth2-config", "", "path to oauth2 config json")
+	oauthConfig        = flag.String("google-oauth2-config", "", "path to google oauth2 client json, to log in with google")
+	identityProviders  = flag.String("identity-providers", "", "path to json list of identity providers to log in with")
+	oauthRedirectURLs  = flag.String("oauth2-redirect-urls", "", "comma-separated oauth2 callback urls to allow, defaults to the first redirect_uris entry of the google oauth2 config")
 	spookyStoreBackend = flag.String("spooky-store-addr", "", "address of spookystore backend")
+	serviceSecret      = flag.String("service-secret", "", "secret shared with the backend, proving calls naming the logged in user come from web; best set in the environment or config file")
+	dialAttempts       = flag.Int("backend-dial-attempts", 5, "how many times to try connecting to the backend at startup")
+	dialTimeout        = flag.Duration("backend-dial-timeout", 5*time.Second, "how long each attempt to connect to the backend at startup may take")
+	check              = flag.Bool("check", false, "check the configuration and that every dependency is usable, then exit")
 	logLevel           = flag.String("log-level", "info", "info, debug, warn, error")
-
-	hashKey  = []byte("very-secret")      // TODO extract to env
-	blockKey = []byte("a-lot-secret-key") // TODO extract to env
-	sc       = securecookie.New(hashKey, blockKey)
+	staticDir          = flag.String("static-dir", "static", "directory of the templates and static files")
+	templateSource     = flag.String("templates", "disk", "where to load page templates from: disk, from the template directory of --static-dir, or embedded in the binary")
+	reloadTemplates    = flag.Bool("reload-templates", false, "parse templates from disk on every request, to see edits without a restart")
+	metricsAddr        = flag.String("metrics-addr", ":9090", "[host]:port to serve prometheus metrics on, disabled if empty")
+	backendTimeout     = flag.Duration("backend-timeout", 5*time.Second, "deadline of calls to the spookystore backend")
+	traceExporter      = flag.String("trace-exporter", "none", "where to send traces: none, stdout, otlp")
+	otlpEndpoint       = flag.String("otlp-endpoint", tracing.DefaultOTLPEndpoint, "url of the collector to post traces to with otlp over http")
+	shutdownDelay      = flag.Duration("shutdown-delay", 5*time.Second, "how long to keep serving after failing readiness checks when shutting down, so load balancers stop sending requests first")
+	shutdownTimeout    = flag.Duration("shutdown-timeout", 20*time.Second, "how long to wait for requests in flight when shutting down")
+
+	sessionStore        = flag.String("session-store", "memory", "where to keep login sessions: memory, datastore")
+	datastoreDecorators = flag.String("datastore-decorators", dw.DefaultDecorators, "comma-separated decorators to wrap datastore calls of the session store in, outermost first: metrics, tracing, breaker, retry, timeout")
+	sessionMaxAge       = flag.Duration("session-max-age", 7*24*time.Hour, "log users out this long after they log in")
+	sessionIdleTimeout  = flag.Duration("session-idle-timeout", 2*time.Hour, "log users out after this long without a request")
+	secureCookies       = flag.Bool("secure-cookies", false, "only send cookies over HTTPS")
+	sessionKeys         = flag.String("session-keys", "", "comma-separated base64 hashKey:blockKey pairs protecting cookies, newest first; best set in the environment or config file")
+
+	localAccounts = flag.Bool("local-accounts", false, "let users register and log in with an email and password")
+	publicURL     = flag.String("public-url", "", "base url of this site for links in emails, defaults to the origin of the first oauth2 redirect url")
+	smtpAddr      = flag.String("smtp-addr", "", "host:port of the smtp server to send emails with, emails are only logged if empty")
+	smtpUser      = flag.String("smtp-user", "", "smtp username")
+	smtpPassword  = flag.String("smtp-password", "", "smtp password, best set in the environment or config file")
+	mailFrom      = flag.String("mail-from", "SpookyStore <noreply@spookystore.example.com>", "sender of emails")
 )
 
 var log *logrus.Entry
 
-func main() {
-	flag.Parse()
+func init() {
 	host, err := os.Hostname()
 	if err != nil {
-		log.Error(errors.Wrap(err, "cannot get hostname"))
-	}
-	switch *logLevel {
-	case "error":
-		logrus.SetLevel(logrus.ErrorLevel)
-	case "warn":
-		logrus.SetLevel(logrus.WarnLevel)
-	case "debug":
-		logrus.SetLevel(logrus.DebugLevel)
-	default:
-		logrus.SetLevel(logrus.InfoLevel)
+		logrus.Fatal(errors.Wrap(err, "cannot get hostname"))
 	}
-
 	logrus.SetFormatter(&logrus.JSONFormatter{FieldMap: logrus.FieldMap{logrus.FieldKeyLevel: "severity"}})
 	log = logrus.WithFields(logrus.Fields{
 		"service": "web",
@@ -87,80 +126,145 @@ func main() {
 		"v":       version.Version(),
 	})
 	grpclog.SetLogger(log.WithField("facility", "grpc"))
-	sc.SetSerializer(securecookie.JSONEncoder{})
+}
 
-	if env := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); env == "" {
-		log.Error("GOOGLE_APPLICATION_CREDENTIALS environment variable is not set")
+func main() {
+	if err := settings.Load(os.Args[1:], os.LookupEnv); err != nil {
+		log.Fatal(err)
 	}
-	if *projectID == "" {
-		log.Error("google cloud project id flag not specified")
+	if settings.PrintRequested() {
+		if err := settings.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	if *spookyStoreBackend == "" {
-		log.Error("spookystorebackend address flag not specified")
+	if err := validateConfig(); err != nil {
+		log.Fatal(err)
 	}
-	if *oauthConfig == "" {
-		log.Error("google oauth2 config flag not specified")
+	switch *logLevel {
+	case "error":
+		logrus.SetLevel(logrus.ErrorLevel)
+	case "warn":
+		logrus.SetLevel(logrus.WarnLevel)
+	case "debug":
+		logrus.SetLevel(logrus.DebugLevel)
+	default:
+		logrus.SetLevel(logrus.InfoLevel)
 	}
 
-	b, err := ioutil.ReadFile(*oauthConfig)
-	if err != nil {
-		log.Error(errors.Wrap(err, "failed to parse config file"))
-	}
-	authConf, err := google.ConfigFromJSON(b)
-	if err != nil {
-		log.Error(errors.Wrap(err, "failed to parse config file"))
+	if err := run(); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	tc, err := trace.NewClient(context.Background(), *projectID)
+// run sets up every dependency, then serves until the process is told to
+// stop, or with --check only reports whether they are all usable
+func run() error {
+	ctx := context.Background()
+	deps, err := startup(ctx)
 	if err != nil {
-		log.Error(errors.Wrap(err, "failed to initialize trace client"))
+		return errors.Wrap(err, "startup failed")
 	}
-	spookySvcConn, err := grpc.Dial(*spookyStoreBackend,
-		grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(tc.GRPCClientInterceptor()))
-	if err != nil {
-		log.Error(errors.Wrap(err, "cannot connect to backend spookystore service"))
-	}
-	defer func() {
-		log.Info("closing connection to spookystore backend")
-		spookySvcConn.Close()
-	}()
-	sp, err := trace.NewLimitedSampler(1.0, 5)
-	if err != nil {
-		log.Error(errors.Wrap(err, "failed to create sampling policy"))
+	defer deps.close()
+	if *check {
+		if err := checkBackend(ctx, healthpb.NewHealthClient(deps.conn)); err != nil {
+			return err
+		}
+		log.Info("all startup checks passed")
+		return nil
 	}
-	tc.SetSamplingPolicy(sp)
 
 	s := &server{
-		tc:        tc,
-		cfg:       authConf,
-		spookySvc: pb.NewSpookyStoreClient(spookySvcConn),
+		tracer:    deps.tracer,
+		providers: deps.providers,
+		spookySvc: pb.NewSpookyStoreClient(deps.conn),
+		health:    healthpb.NewHealthClient(deps.conn),
+		sessions: session.NewManager(deps.sessions, deps.keys, clockwork.NewRealClock(), session.Options{
+			MaxAge:      *sessionMaxAge,
+			IdleTimeout: *sessionIdleTimeout,
+			Secure:      *secureCookies,
+		}),
+		redirectURLs:  deps.redirectURLs,
+		localAccounts: *localAccounts,
+		mail:          deps.mail,
+		publicURL:     deps.publicURL,
+		draining:      make(chan struct{}),
+		stopping:      make(chan struct{}),
+		templates:     deps.templates,
 	}
 
 	// set up server
 	r := mux.NewRouter()
-	r.PathPrefix("/static/").HandlerFunc(http.StripPrefix("/static/", http.FileServer(http.Dir("static"))).ServeHTTP)
+	// probes are neither traced nor logged
+	r.HandleFunc("/healthz", healthz).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", s.readyz).Methods(http.MethodGet)
+	r.PathPrefix("/static/").HandlerFunc(http.StripPrefix("/static/", http.FileServer(http.Dir(*staticDir))).ServeHTTP)
 	r.Handle("/", s.traceHandler(logHandler(s.home))).Methods(http.MethodGet)
 	r.Handle("/login", s.traceHandler(logHandler(s.login))).Methods(http.MethodGet)
+	r.Handle("/login/{provider}", s.traceHandler(logHandler(s.loginWith))).Methods(http.MethodGet)
+	if s.localAccounts {
+		r.Handle("/login/local", s.traceHandler(logHandler(s.loginLocal))).Methods(http.MethodPost)
+		r.Handle("/register", s.traceHandler(logHandler(s.registerPage))).Methods(http.MethodGet)
+		r.Handle("/register", s.traceHandler(logHandler(s.register))).Methods(http.MethodPost)
+		r.Handle("/verify", s.traceHandler(logHandler(s.verifyEmail))).Methods(http.MethodGet)
+		r.Handle("/password/forgot", s.traceHandler(logHandler(s.forgotPasswordPage))).Methods(http.MethodGet)
+		r.Handle("/password/forgot", s.traceHandler(logHandler(s.forgotPassword))).Methods(http.MethodPost)
+		r.Handle("/password/reset", s.traceHandler(logHandler(s.resetPasswordPage))).Methods(http.MethodGet)
+		r.Handle("/password/reset", s.traceHandler(logHandler(s.resetPassword))).Methods(http.MethodPost)
+	}
 	r.Handle("/logout", s.traceHandler(logHandler(s.logout))).Methods(http.MethodGet)
+	r.Handle("/logout/all", s.traceHandler(logHandler(s.logoutAll))).Methods(http.MethodPost)
 	r.Handle("/oauth2callback", s.traceHandler(logHandler(s.oauth2Callback))).Methods(http.MethodGet)
 	r.Handle("/u/{id:[0-9]+}", s.traceHandler(logHandler(s.userProfile))).Methods(http.MethodGet)
+	r.Handle("/u/{id:[0-9]+}/tokens", s.traceHandler(logHandler(s.tokensPage))).Methods(http.MethodGet)
+	r.Handle("/u/{id:[0-9]+}/tokens", s.traceHandler(logHandler(s.createToken))).Methods(http.MethodPost)
+	r.Handle("/u/{id:[0-9]+}/tokens/{tid:[0-9a-f]+}/revoke", s.traceHandler(logHandler(s.revokeToken))).Methods(http.MethodPost)
 	r.Handle("/cart/u/{id:[0-9]+}", s.traceHandler(logHandler(s.cart)))
-	r.Handle("/clearcart/u/{id:[0-9]+}", s.traceHandler(logHandler(s.clearCart)))
-	r.Handle("/checkout/u/{id:[0-9]+}", s.traceHandler(logHandler(s.checkout)))
-	r.Handle("/addproduct/{id:[0-9]+}/{pid:[0-9]+}/{quantity:[0-9]+}", s.traceHandler(logHandler(s.addProduct)))
-	srv := http.Server{
-		Addr:    *addr, // TODO make configurable
+	r.Handle("/clearcart/u/{id:[0-9]+}", s.traceHandler(logHandler(s.clearCart))).Methods(http.MethodPost)
+	r.Handle("/checkout/u/{id:[0-9]+}", s.traceHandler(logHandler(s.checkout))).Methods(http.MethodPost)
+	r.Handle("/addproduct/{id:[0-9]+}/{pid:[0-9]+}/{quantity:[0-9]+}", s.traceHandler(logHandler(s.addProduct))).Methods(http.MethodPost)
+	r.Handle("/events/cart/u/{id:[0-9]+}", s.traceHandler(logHandler(s.cartEvents))).Methods(http.MethodGet)
+	r.Handle("/events/products", s.traceHandler(logHandler(s.productEvents))).Methods(http.MethodGet)
+	r.Handle("/events/transactions", s.traceHandler(logHandler(s.transactionEvents))).Methods(http.MethodGet)
+	s.apiRoutes(r)
+	r.Use(routeMetrics)
+	srv := &http.Server{
+		Addr:    *addr,
 		Handler: r}
-	log.WithFields(logrus.Fields{"addr": *addr,
-		"spookyStore": *spookyStoreBackend}).Info("starting to listen on http")
-	log.Error(errors.Wrap(srv.ListenAndServe(), "failed to listen/serve"))
+
+	errc := make(chan error, 2)
+	var ms *http.Server
+	if *metricsAddr != "" {
+		ms = &http.Server{Addr: *metricsAddr, Handler: metrics.Handler()}
+		go func() {
+			log.WithField("addr", *metricsAddr).Info("starting to serve metrics")
+			if err := ms.ListenAndServe(); err != http.ErrServerClosed {
+				errc <- errors.Wrap(err, "failed to serve metrics")
+			}
+		}()
+	}
+	go func() {
+		log.WithFields(logrus.Fields{"addr": *addr,
+			"spookyStore": *spookyStoreBackend}).Info("starting to listen on http")
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			errc <- errors.Wrap(err, "failed to listen/serve")
+		}
+	}()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	select {
+	case sig := <-sigs:
+		log.WithField("signal", sig.String()).Info("shutting down")
+	case err = <-errc:
+	}
+	s.shutdown(clockwork.NewRealClock(), *shutdownDelay, *shutdownTimeout, srv, ms)
+	return err
 }
 
 type httpErrorWriter func(http.ResponseWriter, error)
 
 func (s *server) getUser(ctx context.Context, id string) (*pb.UserResponse, error) {
-	span := trace.FromContext(ctx).NewChild("get_user")
+	span := tracing.FromContext(ctx).NewChild("get_user")
 	defer span.Finish()
 	span.SetLabel("user/id", id)
 
@@ -170,36 +274,68 @@ func (s *server) getUser(ctx context.Context, id string) (*pb.UserResponse, erro
 	return userResp, err
 }
 
-func (s *server) authUser(ctx context.Context, r *http.Request) (user *pb.User, errFunc httpErrorWriter, err error) {
-	span := trace.FromContext(ctx).NewChild("authorize_user")
+// authUser returns the logged-in user of the request and their session, or
+// nils if no one is logged in.
+func (s *server) authUser(ctx context.Context, r *http.Request) (user *pb.User, sess *session.Session, errFunc httpErrorWriter, err error) {
+	span := tracing.FromContext(ctx).NewChild("authorize_user")
 	defer span.Finish()
 
-	c, err := r.Cookie("user")
-	if err == http.ErrNoCookie {
-		return nil, nil, nil
-	}
-	var userID string
-	if err := sc.Decode("user", c.Value, &userID); err != nil {
-		return nil, badRequest, errors.Wrap(err, "failed to decode cookie")
+	sess, err = s.sessions.Load(ctx, r)
+	if err != nil {
+		return nil, nil, serverError, errors.Wrap(err, "failed to load session")
+	} else if sess == nil {
+		return nil, nil, nil, nil
 	}
+	userID := sess.UserID
 
-	userResp, err := s.getUser(ctx, userID)
+	userResp, err := s.getUser(auth.WithUserID(ctx, userID), userID)
 	if err != nil {
-		return nil, serverError, errors.Wrap(err, "failed to look up the user")
+		return nil, nil, rpcError, errors.Wrap(err, "failed to look up the user")
 	} else if !userResp.GetFound() {
-		return nil, badRequest, errors.New("unrecognized user")
+		return nil, nil, badRequest, errors.New("unrecognized user")
 	}
-	return userResp.GetUser(), nil, nil
+	return userResp.GetUser(), sess, nil, nil
+}
+
+// authorize authenticates the request and checks that the logged-in user holds
+// permission p over the user in the {id} route variable, and that requests
+// changing anything carry the session's CSRF token. The returned context
+// identifies the caller to the backend and carries the CSRF token for pages.
+// If ok is false, an error response has already been written.
+func (s *server) authorize(w http.ResponseWriter, r *http.Request, p auth.Permission) (me *pb.User, ctx context.Context, ok bool) {
+	ctx = r.Context()
+	me, sess, ef, err := s.authUser(ctx, r)
+	if err != nil {
+		ef(w, err)
+		return nil, ctx, false
+	} else if me == nil {
+		unauthorized(w, errors.New("not logged in"))
+		return nil, ctx, false
+	}
+	if !checkCSRF(r, sess) {
+		forbidden(w, errors.Errorf("%s %s without a valid csrf token", r.Method, r.URL.Path))
+		return nil, ctx, false
+	}
+	if !auth.Allowed(me, p, mux.Vars(r)["id"]) {
+		forbidden(w, errors.Errorf("user %s may not access user %s", me.GetID(), mux.Vars(r)["id"]))
+		return nil, ctx, false
+	}
+	return me, withCSRF(auth.WithUserID(ctx, me.GetID()), sess.CSRFToken), true
 }
 
 func (s *server) home(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	user, errF, err := s.authUser(ctx, r)
+	user, sess, errF, err := s.authUser(ctx, r)
 	if err != nil {
 		errF(w, err)
 		return
 	}
+	var csrf string
+	if user != nil {
+		ctx = auth.WithUserID(ctx, user.GetID())
+		csrf = sess.CSRFToken
+	}
 	resp, err := s.spookySvc.GetAllProducts(ctx, &pb.GetAllProductsRequest{})
 	if err != nil {
 		log.Error(err)
@@ -214,28 +350,47 @@ func (s *server) home(w http.ResponseWriter, r *http.Request) {
 		log.Warn(err)
 	}
 	var numTransactions int32
-	if tResp == nil {
+	if tResp != nil {
 		numTransactions = tResp.GetNumTransactions()
 	}
 
 	log.WithField("logged_in", user != nil).Debug("serving home page")
-	tmpl := template.Must(template.ParseFiles(
-		filepath.Join("static", "template", "layout.html"),
-		filepath.Join("static", "template", "home.html")))
-
-	if err := tmpl.Execute(w, map[string]interface{}{
+	s.templates.render(w, http.StatusOK, "home.html", map[string]interface{}{
 		"me":              user,
+		"csrf":            csrf,
 		"numTransactions": numTransactions,
-		"products":        pl}); err != nil {
-		log.Error(err)
-	}
+		"products":        pl,
+	})
 }
 
+// login sends the user to the identity provider, or lets them pick one if
+// there are several or local accounts are enabled
 func (s *server) login(w http.ResponseWriter, r *http.Request) {
-	s.cfg.RedirectURL = "http://" + r.Host + "/oauth2callback" // TODO this is hacky
-	s.cfg.Scopes = []string{"profile", "email"}
-	url := s.cfg.AuthCodeURL("todo_rand_state",
-		oauth2.SetAuthURLParam("access_type", "offline"))
+	if len(s.providers) == 0 && !s.localAccounts {
+		serverError(w, errors.New("no identity providers configured"))
+		return
+	} else if len(s.providers) == 1 && !s.localAccounts {
+		s.startLogin(w, r, s.providers[0])
+		return
+	}
+	s.loginPage(w, "", "")
+}
+
+func (s *server) loginWith(w http.ResponseWriter, r *http.Request) {
+	p := s.provider(mux.Vars(r)["provider"])
+	if p == nil {
+		errorCode(w, http.StatusNotFound, "not found", errors.Errorf("unknown identity provider %q", mux.Vars(r)["provider"]))
+		return
+	}
+	s.startLogin(w, r, p)
+}
+
+func (s *server) startLogin(w http.ResponseWriter, r *http.Request, p identity.Provider) {
+	url, err := s.startOAuth(w, r, p)
+	if err != nil {
+		serverError(w, errors.Wrap(err, "failed to start login"))
+		return
+	}
 	log.Debug("redirecting user to oauth2 consent page")
 	w.Header().Set("Location", url)
 	w.WriteHeader(http.StatusFound)
@@ -243,111 +398,123 @@ func (s *server) login(w http.ResponseWriter, r *http.Request) {
 
 func (s *server) logout(w http.ResponseWriter, r *http.Request) {
 	log.Debug("logout requested")
-	for _, c := range r.Cookies() {
-		c.Expires = time.Unix(1, 0)
-		http.SetCookie(w, c)
-		log.WithField("key", c.Name).Debug("cleared cookie")
+	if err := s.sessions.Logout(r.Context(), w, r); err != nil {
+		serverError(w, errors.Wrap(err, "failed to end session"))
+		return
 	}
 	w.Header().Set("Location", "/")
 	w.WriteHeader(http.StatusFound)
 }
 
+// logoutAll ends every session of the logged-in user, on every device
+func (s *server) logoutAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	me, sess, ef, err := s.authUser(ctx, r)
+	if err != nil {
+		ef(w, err)
+		return
+	} else if me == nil {
+		unauthorized(w, errors.New("not logged in"))
+		return
+	} else if !checkCSRF(r, sess) {
+		forbidden(w, errors.New("logout everywhere without a valid csrf token"))
+		return
+	}
+	if err := s.sessions.LogoutAll(ctx, w, me.GetID()); err != nil {
+		serverError(w, errors.Wrap(err, "failed to end sessions"))
+		return
+	}
+	log.WithField("user.id", me.GetID()).Info("logged out everywhere")
+	w.Header().Set("Location", "/")
+	w.WriteHeader(http.StatusFound)
+}
+
 func (s *server) oauth2Callback(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	span := trace.FromContext(ctx)
-	if state := r.URL.Query().Get("state"); state != "todo_rand_state" {
-		badRequest(w, errors.New("wrong oauth2 state"))
+	span := tracing.FromContext(ctx)
+	q := r.URL.Query()
+	attempt, err := s.finishOAuth(w, r)
+	if err != nil {
+		badRequest(w, errors.Wrap(err, "invalid oauth2 callback"))
+		return
+	}
+	switch e := q.Get("error"); e {
+	case "":
+	case "access_denied":
+		log.Debug("user declined oauth2 consent")
+		w.Header().Set("Location", "/")
+		w.WriteHeader(http.StatusFound)
+		return
+	default:
+		badRequest(w, errors.Errorf("oauth2 provider returned %s: %s", e, q.Get("error_description")))
 		return
 	}
 
-	code := r.URL.Query().Get("code")
+	code := q.Get("code")
 	if code == "" {
 		badRequest(w, errors.New("missing oauth2 grant code"))
 		return
 	}
 
+	p := s.provider(attempt.Provider)
+	if p == nil {
+		badRequest(w, errors.Errorf("unknown identity provider %q", attempt.Provider))
+		return
+	}
+
 	cs := span.NewChild("oauth2/exchange_token")
-	tok, err := s.cfg.Exchange(ctx, code)
+	cfg := s.oauthConfig(p, attempt.Redirect)
+	tok, err := cfg.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", attempt.Verifier))
 	if err != nil {
 		serverError(w, errors.Wrap(err, "oauth2 token exchange failed"))
 		return
 	}
 	cs.Finish()
 
-	cs = span.NewChild("gplus/get/me")
-	svc, err := plus.New(oauth2.NewClient(ctx, s.cfg.TokenSource(ctx, tok)))
-	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to construct g+ client"))
-		return
-	}
-	me, err := plus.NewPeopleService(svc).Get("me").Do()
+	cs = span.NewChild("identity/" + p.Name())
+	id, err := p.Identity(ctx, tok, attempt.Nonce)
 	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to query user g+ profile"))
+		serverError(w, errors.Wrap(err, "failed to identify the user"))
 		return
 	}
 	cs.Finish()
-	log.WithField("google.id", me.Id).Debug("retrieved google user")
-
-	fmt.Printf("\n\nABOUT TO AUTHORIZE_GOOGLE, what are eMAILs? %#v, what is google id? %s\n\n", me.Emails[0], me.Id)
-
-	cs = span.NewChild("authorize_google")
-	user, err := s.spookySvc.AuthorizeGoogle(ctx,
-		&pb.User{
-			GoogleID:    me.Id,
-			ID:          me.Id,
-			Email:       me.Emails[0].Value,
-			DisplayName: me.DisplayName,
-			Picture:     me.Image.Url,
-		})
+	log.WithFields(logrus.Fields{"provider": id.Provider, "subject": id.Subject}).Debug("retrieved identity")
+
+	cs = span.NewChild("link_account")
+	user, err := s.spookySvc.LinkAccount(ctx, &pb.LinkAccountRequest{
+		Provider:      id.Provider,
+		Subject:       id.Subject,
+		Email:         id.Email,
+		EmailVerified: id.EmailVerified,
+		DisplayName:   id.Name,
+		Picture:       id.Picture,
+	})
 	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to log in the user"))
+		rpcError(w, errors.Wrap(err, "failed to log in the user"))
 		return
 	}
-	log.WithField("id", user.ID).Info("authenticated user with google")
 	cs.Finish()
 
-	// save the user id to cookies
-	// TODO implement as sessions
-	co, err := sc.Encode("user", user.ID)
-	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to encode the token"))
+	if _, err := s.sessions.Login(ctx, w, r, user.ID); err != nil {
+		serverError(w, errors.Wrap(err, "failed to start session"))
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:  "user",
-		Path:  "/",
-		Value: co,
-	})
-
-	log.WithField("user.id", me.Id).Info("authenticated user")
+	log.WithFields(logrus.Fields{"user.id": user.ID, "provider": id.Provider}).Info("authenticated user")
 	w.Header().Set("Location", "/")
 	w.WriteHeader(http.StatusFound)
 }
 
 func (s *server) checkout(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	id := mux.Vars(r)["id"]
 
-	_, ef, err := s.authUser(ctx, r)
-	if err != nil {
-		ef(w, err)
+	_, ctx, ok := s.authorize(w, r, auth.Checkout)
+	if !ok {
 		return
 	}
 
-	userResp, err := s.getUser(ctx, id)
-	if err != nil {
-		serverError(w, errors.Wrap(err, "checkout: failed to look up the user"))
-		return
-	} else if !userResp.GetFound() {
-		errorCode(w, http.StatusNotFound, "not found", errors.New("user not found"))
-		return
-	}
-
-	_, err = s.spookySvc.Checkout(ctx, &pb.UserRequest{ID: id})
-	if err != nil {
-		serverError(w, errors.Wrap(err, "checkout failed"))
+	if _, err := s.spookySvc.Checkout(ctx, &pb.UserRequest{ID: id}); err != nil {
+		rpcError(w, errors.Wrap(err, "checkout failed"))
 		return
 	}
 	// take user to their transactions page
@@ -356,27 +523,15 @@ func (s *server) checkout(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) clearCart(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	id := mux.Vars(r)["id"]
 
-	_, ef, err := s.authUser(ctx, r)
-	if err != nil {
-		ef(w, err)
+	_, ctx, ok := s.authorize(w, r, auth.EditCart)
+	if !ok {
 		return
 	}
 
-	userResp, err := s.getUser(ctx, id)
-	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to look up the user"))
-		return
-	} else if !userResp.GetFound() {
-		errorCode(w, http.StatusNotFound, "not found", errors.New("user not found"))
-		return
-	}
-	_, err = s.spookySvc.ClearCart(ctx, &pb.UserRequest{ID: id})
-	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to clear cart"))
+	if _, err := s.spookySvc.ClearCart(ctx, &pb.UserRequest{ID: id}); err != nil {
+		rpcError(w, errors.Wrap(err, "failed to clear cart"))
 		return
 	}
 	w.Header().Set("Location", "/") //take me home
@@ -384,64 +539,55 @@ func (s *server) clearCart(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) cart(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	id := mux.Vars(r)["id"]
 
-	me, ef, err := s.authUser(ctx, r)
-	if err != nil {
-		ef(w, err)
+	me, ctx, ok := s.authorize(w, r, auth.ViewUser)
+	if !ok {
 		return
 	}
 
 	userResp, err := s.getUser(ctx, id)
 	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to look up the user"))
+		rpcError(w, errors.Wrap(err, "failed to look up the user"))
 		return
 	} else if !userResp.GetFound() {
 		errorCode(w, http.StatusNotFound, "not found", errors.New("user not found"))
 		return
 	}
 
-	tmpl := template.Must(template.ParseFiles(
-		filepath.Join("static", "template", "layout.html"),
-		filepath.Join("static", "template", "cart.html")))
-	if err := tmpl.Execute(w, map[string]interface{}{
+	s.templates.render(w, http.StatusOK, "cart.html", map[string]interface{}{
 		"me":        me,
+		"csrf":      csrfToken(ctx),
 		"cart":      userResp.GetUser().Cart,
 		"user":      userResp.GetUser(),
 		"CartItems": userResp.GetUser().Cart.GetItems(),
-	}); err != nil {
-		log.Error(err)
-	}
-	w.Header().Set("Location", "/") //take me home
-	w.WriteHeader(http.StatusOK)
+	})
 }
 
 func (s *server) addProduct(w http.ResponseWriter, r *http.Request) {
 
-	ctx := r.Context()
-	span := trace.FromContext(ctx)
+	span := tracing.FromContext(r.Context())
 
 	userID := mux.Vars(r)["id"]
 	productID := mux.Vars(r)["pid"]
 	quantity := mux.Vars(r)["quantity"]
 	span.SetLabel("user/id", userID)
 
-	_, ef, err := s.authUser(ctx, r)
-	if err != nil {
-		ef(w, err)
+	_, ctx, ok := s.authorize(w, r, auth.EditCart)
+	if !ok {
 		return
 	}
 
 	parsedQuantity, err := strconv.ParseInt(quantity, 10, 32)
 	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to parse quantity"))
+		badRequest(w, errors.Wrap(err, "failed to parse quantity"))
+		return
 	}
 
 	_, err = s.spookySvc.AddProductToCart(ctx, &pb.AddProductRequest{UserID: userID, ProductID: productID, Quantity: int32(parsedQuantity)})
 	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to add product to cart"))
+		rpcError(w, errors.Wrap(err, "failed to add product to cart"))
+		return
 	}
 	w.Header().Set("Location", "/")
 	w.WriteHeader(http.StatusOK)
@@ -473,21 +619,19 @@ func FormatTransactions(input []*pb.Transaction) ([]FormattedTransaction, error)
 }
 
 func (s *server) userProfile(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	span := trace.FromContext(ctx)
+	span := tracing.FromContext(r.Context())
 
 	userID := mux.Vars(r)["id"]
 	span.SetLabel("user/id", userID)
 
-	me, ef, err := s.authUser(ctx, r)
-	if err != nil {
-		ef(w, err)
+	me, ctx, ok := s.authorize(w, r, auth.ViewUser)
+	if !ok {
 		return
 	}
 
 	userResp, err := s.getUser(ctx, userID)
 	if err != nil {
-		serverError(w, errors.Wrap(err, "failed to look up the user"))
+		rpcError(w, errors.Wrap(err, "failed to look up the user"))
 		return
 	} else if !userResp.GetFound() {
 		errorCode(w, http.StatusNotFound, "not found", errors.New("user not found"))
@@ -502,18 +646,12 @@ func (s *server) userProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl := template.Must(template.ParseFiles(
-		filepath.Join("static", "template", "layout.html"),
-		filepath.Join("static", "template", "profile.html")))
-	if err := tmpl.Execute(w, map[string]interface{}{
+	s.templates.render(w, http.StatusOK, "profile.html", map[string]interface{}{
 		"me":           me,
+		"csrf":         csrfToken(ctx),
 		"user":         u,
 		"Transactions": fTransactions,
-	}); err != nil {
-		log.Error(err)
-	}
-	w.Header().Set("Location", "/") //take me home
-	w.WriteHeader(http.StatusOK)
+	})
 }
 
 func errorCode(w http.ResponseWriter, code int, msg string, err error) {
@@ -526,6 +664,10 @@ func unauthorized(w http.ResponseWriter, err error) {
 	errorCode(w, http.StatusUnauthorized, "unauthorized", err)
 }
 
+func forbidden(w http.ResponseWriter, err error) {
+	errorCode(w, http.StatusForbidden, "forbidden", err)
+}
+
 func badRequest(w http.ResponseWriter, err error) {
 	errorCode(w, http.StatusBadRequest, "bad request", err)
 }
@@ -533,3 +675,33 @@ func badRequest(w http.ResponseWriter, err error) {
 func serverError(w http.ResponseWriter, err error) {
 	errorCode(w, http.StatusInternalServerError, "server error", err)
 }
+
+// rpcError writes the failure of a backend call, with the HTTP status that
+// matches its gRPC code
+func rpcError(w http.ResponseWriter, err error) {
+	code := httpStatus(status.Code(errors.Cause(err)))
+	errorCode(w, code, strings.ToLower(http.StatusText(code)), err)
+}
+
+// httpStatus returns the HTTP status matching gRPC code c
+func httpStatus(c codes.Code) int {
+	switch c {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
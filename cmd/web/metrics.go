@@ -0,0 +1,55 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+)
+
+// routeMetrics records the rate, errors and duration of requests by the
+// template of the route they matched, so IDs in paths don't make new series
+func routeMetrics(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "other"
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if t, err := cr.GetPathTemplate(); err == nil {
+				route = t
+			}
+		}
+		ww := &proxyResponseWriter{w: w}
+		start := time.Now()
+		h.ServeHTTP(ww, r)
+		code := ww.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		metrics.ObserveHTTP(route, methodLabel(r.Method), code, time.Since(start))
+	})
+}
+
+// methodLabel returns the standard HTTP method m, or "other", so clients
+// making up methods don't make new series
+func methodLabel(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	}
+	return "other"
+}
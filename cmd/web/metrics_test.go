@@ -0,0 +1,50 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+)
+
+func TestRouteMetrics(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/metricstest/u/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r.Use(routeMetrics)
+	for _, path := range []string{"/metricstest/u/1", "/metricstest/u/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for _, method := range []string{"BREW", "WHEN"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/metricstest/u/1", nil))
+	}
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `http_requests_total{code="418",method="GET",route="/metricstest/u/{id:[0-9]+}"} 2`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected requests to be counted by route template, got:\n%s", w.Body)
+	}
+	want = `http_requests_total{code="418",method="other",route="/metricstest/u/{id:[0-9]+}"} 2`
+	if !strings.Contains(w.Body.String(), want) || strings.Contains(w.Body.String(), `method="BREW"`) {
+		t.Errorf("expected non-standard methods to be counted as other, got:\n%s", w.Body)
+	}
+}
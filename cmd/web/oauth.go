@@ -0,0 +1,234 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/m-okeefe/spookystore/internal/identity"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// oauthCookie carries the login attempt from /login to /oauth2callback
+	oauthCookie = "oauth2"
+
+	// oauthTimeout is how long the user has to get through the consent page
+	oauthTimeout = 10 * time.Minute
+)
+
+// oauthAttempt is what we remember about a login between sending the user to
+// the provider and the provider sending them back.
+type oauthAttempt struct {
+	Provider string `json:"p"`
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"` // PKCE code verifier
+	Redirect string `json:"r"`
+	Expires  int64  `json:"e"`
+}
+
+// loadProviders sets up the identity providers users can log in with: Google,
+// if googleConfig names a Google oauth2 client json, and those listed in the
+// providersConfig file. It also returns the first redirect URL registered in
+// the Google client, if any.
+func loadProviders(ctx context.Context, googleConfig, providersConfig string) ([]identity.Provider, string, error) {
+	var configs []identity.Config
+	var redirect string
+	if googleConfig != "" {
+		b, err := ioutil.ReadFile(googleConfig)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to read google oauth2 config")
+		}
+		c, err := google.ConfigFromJSON(b)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to parse google oauth2 config")
+		}
+		configs = append(configs, identity.Config{
+			Name:         "google",
+			Issuer:       "https://accounts.google.com",
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+		})
+		redirect = c.RedirectURL
+	}
+	if providersConfig != "" {
+		c, err := identity.LoadConfig(providersConfig)
+		if err != nil {
+			return nil, "", err
+		}
+		configs = append(configs, c...)
+	}
+
+	var providers []identity.Provider
+	seen := map[string]bool{}
+	for _, c := range configs {
+		p, err := identity.New(ctx, c)
+		if err != nil {
+			return nil, "", err
+		}
+		if seen[p.Name()] {
+			return nil, "", errors.Errorf("identity provider %q configured twice", p.Name())
+		}
+		seen[p.Name()] = true
+		providers = append(providers, p)
+	}
+	return providers, redirect, nil
+}
+
+// provider returns the configured identity provider with the given name
+func (s *server) provider(name string) identity.Provider {
+	for _, p := range s.providers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// parseRedirectURLs parses a comma-separated list of absolute callback URLs.
+func parseRedirectURLs(s string) ([]string, error) {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redirect url %q", v)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.Errorf("redirect url %q is not an absolute http(s) url", v)
+		}
+		out = append(out, v)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no oauth2 redirect urls configured")
+	}
+	return out, nil
+}
+
+// redirectURL picks the allowed callback URL on the host the user is
+// browsing, or the first allowed URL if none matches. The Host header is
+// only used for matching, never to build the URL.
+func (s *server) redirectURL(r *http.Request) string {
+	for _, v := range s.redirectURLs {
+		if u, err := url.Parse(v); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return v
+		}
+	}
+	return s.redirectURLs[0]
+}
+
+// oauthConfig returns the provider's oauth2 config for a single login, so
+// concurrent logins don't race on RedirectURL.
+func (s *server) oauthConfig(p identity.Provider, redirect string) *oauth2.Config {
+	cfg := p.OAuth2Config()
+	cfg.RedirectURL = redirect
+	return cfg
+}
+
+// startOAuth records a new login attempt in a cookie and returns the provider
+// URL to send the user to.
+func (s *server) startOAuth(w http.ResponseWriter, r *http.Request, p identity.Provider) (string, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate oauth2 state")
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate oidc nonce")
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate pkce verifier")
+	}
+	a := oauthAttempt{
+		Provider: p.Name(),
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		Redirect: s.redirectURL(r),
+		Expires:  time.Now().Add(oauthTimeout).Unix(),
+	}
+	v, err := s.sessions.Encode(oauthCookie, a)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode oauth2 cookie")
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthCookie,
+		Value:    v,
+		Path:     "/oauth2callback",
+		MaxAge:   int(oauthTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   *secureCookies,
+	})
+
+	challenge := sha256.Sum256([]byte(verifier))
+	return s.oauthConfig(p, a.Redirect).AuthCodeURL(state,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256")), nil
+}
+
+// finishOAuth checks the callback against the login attempt in the cookie,
+// which it clears, and returns the attempt.
+func (s *server) finishOAuth(w http.ResponseWriter, r *http.Request) (*oauthAttempt, error) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthCookie,
+		Value:    "",
+		Path:     "/oauth2callback",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   *secureCookies,
+	})
+
+	c, err := r.Cookie(oauthCookie)
+	if err == http.ErrNoCookie {
+		return nil, errors.New("no login in progress")
+	}
+	var a oauthAttempt
+	if err := s.sessions.Decode(oauthCookie, c.Value, &a); err != nil {
+		return nil, errors.Wrap(err, "failed to decode oauth2 cookie")
+	}
+	if time.Now().Unix() > a.Expires {
+		return nil, errors.New("login attempt expired")
+	}
+	state := r.URL.Query().Get("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(a.State)) != 1 {
+		return nil, errors.New("wrong oauth2 state")
+	}
+	return &a, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/identity"
+	"github.com/m-okeefe/spookystore/internal/session"
+)
+
+func testServer(t *testing.T) *server {
+	redirects, err := parseRedirectURLs("https://spooky.example.com/oauth2callback, http://localhost:8000/oauth2callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &server{
+		providers:    []identity.Provider{identity.NewGitHub(identity.Config{ClientID: "client"})},
+		sessions:     session.NewManager(session.NewMemoryStore(), session.RandomKeys(), clockwork.NewRealClock(), session.Options{}),
+		redirectURLs: redirects,
+	}
+}
+
+// startLogin runs startOAuth and returns the provider URL and the cookie set
+func startLogin(t *testing.T, s *server, host string) (*url.URL, *http.Cookie) {
+	r := httptest.NewRequest(http.MethodGet, "http://"+host+"/login", nil)
+	w := httptest.NewRecorder()
+	v, err := s.startOAuth(w, r, s.providers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u, w.Result().Cookies()[0]
+}
+
+func callback(state string, c *http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/oauth2callback?code=abc&state="+url.QueryEscape(state), nil)
+	if c != nil {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestStartOAuth(t *testing.T) {
+	s := testServer(t)
+
+	u, _ := startLogin(t, s, "localhost:8000")
+	if got := u.Query().Get("redirect_uri"); got != "http://localhost:8000/oauth2callback" {
+		t.Errorf("expected redirect on the request host, got %q", got)
+	}
+	u, _ = startLogin(t, s, "evil.example.com")
+	if got := u.Query().Get("redirect_uri"); got != "https://spooky.example.com/oauth2callback" {
+		t.Errorf("expected unknown host to get the first allowed redirect, got %q", got)
+	}
+
+	other, _ := startLogin(t, s, "localhost:8000")
+	if u.Query().Get("state") == other.Query().Get("state") {
+		t.Errorf("expected a new state per login")
+	}
+	if u.Query().Get("code_challenge_method") != "S256" || u.Query().Get("code_challenge") == "" {
+		t.Errorf("expected a pkce challenge, got %v", u.Query())
+	}
+	if u.Query().Get("nonce") == "" {
+		t.Errorf("expected a nonce, got %v", u.Query())
+	}
+	if got := s.providers[0].OAuth2Config().RedirectURL; got != "" {
+		t.Errorf("shared config was modified: %q", got)
+	}
+}
+
+func TestFinishOAuth(t *testing.T) {
+	s := testServer(t)
+	u, c := startLogin(t, s, "localhost:8000")
+	state := u.Query().Get("state")
+
+	a, err := s.finishOAuth(httptest.NewRecorder(), callback(state, c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Provider != "github" || a.Nonce != u.Query().Get("nonce") {
+		t.Errorf("expected attempt to record provider and nonce, got %+v", a)
+	}
+	challenge := sha256.Sum256([]byte(a.Verifier))
+	if got := base64.RawURLEncoding.EncodeToString(challenge[:]); got != u.Query().Get("code_challenge") {
+		t.Errorf("verifier does not match the challenge sent")
+	}
+
+	if _, err := s.finishOAuth(httptest.NewRecorder(), callback("forged", c)); err == nil {
+		t.Errorf("expected wrong state to be rejected")
+	}
+	if _, err := s.finishOAuth(httptest.NewRecorder(), callback(state, nil)); err == nil {
+		t.Errorf("expected callback without a cookie to be rejected")
+	}
+
+	_, stranger := startLogin(t, testServer(t), "localhost:8000")
+	if _, err := s.finishOAuth(httptest.NewRecorder(), callback(state, stranger)); err == nil {
+		t.Errorf("expected cookie from other keys to be rejected")
+	}
+
+	expired, err := s.sessions.Encode(oauthCookie, oauthAttempt{State: state, Expires: time.Now().Add(-time.Second).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.finishOAuth(httptest.NewRecorder(), callback(state, &http.Cookie{Name: oauthCookie, Value: expired})); err == nil {
+		t.Errorf("expected expired login to be rejected")
+	}
+}
+
+func TestParseRedirectURLs(t *testing.T) {
+	for _, in := range []string{"", "/oauth2callback", "ftp://example.com/cb", "localhost:8000"} {
+		if _, err := parseRedirectURLs(in); err == nil {
+			t.Errorf("parseRedirectURLs(%q): expected to fail", in)
+		}
+	}
+}
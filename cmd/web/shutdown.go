@@ -0,0 +1,61 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+)
+
+// traceFlushTimeout bounds the export of the last spans on the way out
+const traceFlushTimeout = 5 * time.Second
+
+// shutdown drains servers: readiness probes start failing, and requests are
+// still served for delay, until load balancers have noticed. Then event
+// streams end so browsers reconnect elsewhere, and the requests in flight
+// finish within timeout. Connections still open after timeout are closed.
+func (s *server) shutdown(clock clockwork.Clock, delay, timeout time.Duration, servers ...*http.Server) {
+	close(s.draining)
+	if delay > 0 {
+		log.WithField("delay", delay).Info("not ready, waiting for load balancers before draining")
+		<-clock.After(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	close(s.stopping)
+	for _, srv := range servers {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			log.WithField("error", err).Warn("requests still running at the shutdown timeout, closing them")
+			srv.Close()
+		}
+	}
+}
+
+// flushTraces exports the spans tracer still holds
+func flushTraces(tracer *tracing.Tracer) {
+	ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		log.WithField("error", err).Warn("failed to flush traces")
+	}
+}
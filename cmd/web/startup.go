@@ -0,0 +1,214 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/m-okeefe/spookystore/internal/auth"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	"github.com/m-okeefe/spookystore/internal/identity"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	"github.com/m-okeefe/spookystore/internal/session"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// dial backoff between attempts to connect to the backend at startup
+const (
+	dialBackoff    = 500 * time.Millisecond
+	dialMaxBackoff = 8 * time.Second
+)
+
+// dependencies are what web serves with, all checked to be usable
+type dependencies struct {
+	providers    []identity.Provider
+	redirectURLs []string
+	publicURL    string
+	mail         mailer
+	tracer       *tracing.Tracer
+	conn         *grpc.ClientConn
+	keys         *session.Keys
+	sessions     session.Store
+	templates    *templates
+
+	closers []func()
+}
+
+// close releases the dependencies, last set up first
+func (d *dependencies) close() {
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		d.closers[i]()
+	}
+}
+
+// startup sets up the dependencies in turn, and fails on the first that
+// isn't usable, saying which. The ones set up so far are released.
+func startup(ctx context.Context) (_ *dependencies, err error) {
+	d := &dependencies{}
+	defer func() {
+		if err != nil {
+			d.close()
+		}
+	}()
+
+	var defaultRedirect string
+	d.providers, defaultRedirect, err = loadProviders(ctx, *oauthConfig, *identityProviders)
+	if err != nil {
+		return nil, errors.Wrap(err, "identity providers")
+	}
+	redirects := *oauthRedirectURLs
+	if redirects == "" {
+		redirects = defaultRedirect
+	}
+	if len(d.providers) > 0 {
+		if d.redirectURLs, err = parseRedirectURLs(redirects); err != nil {
+			return nil, errors.Wrap(err, "invalid --oauth2-redirect-urls")
+		}
+	}
+
+	d.publicURL = *publicURL
+	if d.publicURL == "" && len(d.redirectURLs) > 0 {
+		u, _ := url.Parse(d.redirectURLs[0])
+		d.publicURL = u.Scheme + "://" + u.Host
+	}
+	d.publicURL = strings.TrimSuffix(d.publicURL, "/")
+	if *localAccounts && d.publicURL == "" {
+		return nil, errors.New("local accounts need --public-url for the links in emails")
+	}
+	load := diskTemplates(filepath.Join(*staticDir, "template"))
+	if *templateSource == "embedded" {
+		load = embeddedLoader
+	}
+	if d.templates, err = newTemplates(load, *reloadTemplates); err != nil {
+		return nil, errors.Wrap(err, "templates")
+	}
+
+	d.mail = logMailer{}
+	if *smtpAddr != "" {
+		if d.mail, err = newSMTPMailer(*smtpAddr, *mailFrom, *smtpUser, *smtpPassword); err != nil {
+			return nil, errors.Wrap(err, "smtp")
+		}
+	}
+
+	exp, err := tracing.NewExporter(*traceExporter, *otlpEndpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "tracing")
+	}
+	d.tracer = tracing.NewTracer("web", exp, tracing.LimitedSampler(traceQPS), log)
+	d.closers = append(d.closers, func() { flushTraces(d.tracer) })
+
+	if *sessionKeys == "" {
+		log.Warn("no session keys set, sessions will not survive a restart")
+		d.keys = session.RandomKeys()
+	} else if d.keys, err = session.ParseKeys(*sessionKeys); err != nil {
+		return nil, errors.Wrap(err, "invalid --session-keys")
+	}
+	switch *sessionStore {
+	case "datastore":
+		ds, _, err := dw.NewCloudDatastore(*projectID)
+		if err != nil {
+			return nil, errors.Wrap(err, "session store: failed to initialize cloud datastore wrapper")
+		}
+		d.closers = append(d.closers, func() { ds.D.Close() })
+		dc := dw.DefaultConfig()
+		dc.Decorators = strings.Split(*datastoreDecorators, ",")
+		store, err := dw.Decorate(ds, dc)
+		if err != nil {
+			return nil, errors.Wrap(err, "session store")
+		}
+		d.sessions = session.NewDatastoreStore(store)
+	default:
+		d.sessions = session.NewMemoryStore()
+	}
+
+	d.conn, err = dialBackend(ctx, *spookyStoreBackend, *dialAttempts, *dialTimeout, dialBackoff,
+		grpc.WithInsecure(),
+		grpc.WithPerRPCCredentials(auth.ServiceCredentials(*serviceSecret)),
+		grpc.WithUnaryInterceptor(middleware.ChainUnaryClient(
+			d.tracer.GRPCClientInterceptor(),
+			metrics.UnaryClientInterceptor(),
+			middleware.ClientRequestID(),
+			middleware.ClientLog(log),
+			middleware.ClientDeadline(*backendTimeout),
+		)),
+		grpc.WithStreamInterceptor(middleware.ChainStreamClient(
+			d.tracer.GRPCStreamClientInterceptor(),
+			middleware.ClientStreamRequestID(),
+		)))
+	if err != nil {
+		return nil, err
+	}
+	d.closers = append(d.closers, func() {
+		log.Info("closing connection to spookystore backend")
+		d.conn.Close()
+	})
+	return d, nil
+}
+
+// dialBackend connects to the backend at addr, trying up to attempts times,
+// each for up to timeout, with a doubling backoff in between
+func dialBackend(ctx context.Context, addr string, attempts int, timeout, backoff time.Duration, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append(opts, grpc.WithBlock())
+	var err error
+	for i := 1; ; i++ {
+		dctx, cancel := context.WithTimeout(ctx, timeout)
+		var conn *grpc.ClientConn
+		conn, err = grpc.DialContext(dctx, addr, opts...)
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		if i >= attempts {
+			break
+		}
+		log.WithFields(logrus.Fields{
+			"addr":    addr,
+			"attempt": i,
+			"error":   err,
+		}).Warn("backend not reachable yet, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "backend %s", addr)
+		}
+		if backoff *= 2; backoff > dialMaxBackoff {
+			backoff = dialMaxBackoff
+		}
+	}
+	return nil, errors.Wrapf(err, "backend %s not reachable after %d attempts", addr, attempts)
+}
+
+// checkBackend fails unless the backend reports that it is serving
+func checkBackend(ctx context.Context, hc healthpb.HealthClient) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	resp, err := hc.Check(ctx, &healthpb.HealthCheckRequest{Service: backendServiceName})
+	if err != nil {
+		return errors.Wrap(err, "backend health check failed")
+	}
+	if st := resp.GetStatus(); st != healthpb.HealthCheckResponse_SERVING {
+		return errors.Errorf("backend is %s", st)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+func TestDialBackend(t *testing.T) {
+	ctx := context.Background()
+	addr := freeAddr(t)
+	if _, err := dialBackend(ctx, addr, 2, 50*time.Millisecond, time.Millisecond, grpc.WithInsecure()); err == nil ||
+		!strings.Contains(err.Error(), "not reachable after 2 attempts") {
+		t.Errorf("expected the backend not to be reachable, got %v", err)
+	}
+
+	// the backend comes up while web is retrying
+	srv := grpc.NewServer()
+	defer srv.Stop()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		srv.Serve(lis)
+	}()
+	conn, err := dialBackend(ctx, addr, 10, 50*time.Millisecond, 10*time.Millisecond, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestCheckBackend(t *testing.T) {
+	ctx := context.Background()
+	if err := checkBackend(ctx, &fakeHealth{status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+		t.Error(err)
+	}
+	if err := checkBackend(ctx, &fakeHealth{status: healthpb.HealthCheckResponse_NOT_SERVING}); err == nil {
+		t.Error("expected a backend that isn't serving to fail the check")
+	}
+	if err := checkBackend(ctx, &fakeHealth{err: status.Error(codes.Unavailable, "connection refused")}); err == nil {
+		t.Error("expected an unreachable backend to fail the check")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+//go:generate go run gen_templates.go
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/pkg/errors"
+)
+
+// layoutTemplate is the template every page is rendered inside
+const layoutTemplate = "layout.html"
+
+// dateLayout is how dates are shown on pages
+const dateLayout = "2 January 2006"
+
+// templateFuncs can be called from every template
+var templateFuncs = template.FuncMap{
+	"money":  money,
+	"date":   date,
+	"plural": plural,
+}
+
+// money formats an amount in dollars, such as $3.50
+func money(v interface{}) string {
+	switch v := v.(type) {
+	case float32:
+		return fmt.Sprintf("$%.2f", v)
+	case float64:
+		return fmt.Sprintf("$%.2f", v)
+	}
+	return fmt.Sprintf("$%v", v)
+}
+
+// date formats a time.Time or a timestamp as a day, or nothing if unset
+func date(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		if !v.IsZero() {
+			return v.Format(dateLayout)
+		}
+	case *tspb.Timestamp:
+		if t, err := ptypes.Timestamp(v); err == nil {
+			return t.Format(dateLayout)
+		}
+	}
+	return ""
+}
+
+// plural counts n things, such as 1 order or 3 orders
+func plural(n interface{}, singular, plural string) string {
+	var c int64
+	switch n := n.(type) {
+	case int:
+		c = int64(n)
+	case int32:
+		c = int64(n)
+	case int64:
+		c = n
+	}
+	if c == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", c, plural)
+}
+
+// templates are the page templates, each parsed with the layout once and
+// for all, or on every render when reloading to see edits without a
+// restart
+type templates struct {
+	// load returns the template files by name
+	load   func() (map[string]string, error)
+	reload bool
+
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+}
+
+// newTemplates parses the templates load returns, and fails if any of them
+// don't
+func newTemplates(load func() (map[string]string, error), reload bool) (*templates, error) {
+	t := &templates{load: load, reload: reload}
+	pages, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.pages = pages
+	return t, nil
+}
+
+// diskTemplates loads the templates in dir
+func diskTemplates(dir string) func() (map[string]string, error) {
+	return func() (map[string]string, error) {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.html"))
+		if err != nil {
+			return nil, err
+		}
+		files := map[string]string{}
+		for _, p := range paths {
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read template")
+			}
+			files[filepath.Base(p)] = string(b)
+		}
+		return files, nil
+	}
+}
+
+// embeddedLoader loads the templates built into the binary
+func embeddedLoader() (map[string]string, error) { return embeddedTemplates, nil }
+
+func (t *templates) parse() (map[string]*template.Template, error) {
+	files, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	layout, ok := files[layoutTemplate]
+	if !ok {
+		return nil, errors.Errorf("no %s template", layoutTemplate)
+	}
+	pages := map[string]*template.Template{}
+	for name, src := range files {
+		if name == layoutTemplate {
+			continue
+		}
+		p, err := template.New(layoutTemplate).Funcs(templateFuncs).Parse(layout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", layoutTemplate)
+		}
+		if _, err := p.New(name).Parse(src); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", name)
+		}
+		pages[name] = p
+	}
+	return pages, nil
+}
+
+func (t *templates) page(name string) (*template.Template, error) {
+	if t.reload {
+		pages, err := t.parse()
+		if err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		t.pages = pages
+		t.mu.Unlock()
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	p, ok := t.pages[name]
+	if !ok {
+		return nil, errors.Errorf("no %s template", name)
+	}
+	return p, nil
+}
+
+// render writes page with data and status code, or a server error if it
+// can't be rendered
+func (t *templates) render(w http.ResponseWriter, code int, page string, data interface{}) {
+	p, err := t.page(page)
+	var buf bytes.Buffer
+	if err == nil {
+		err = p.Execute(&buf, data)
+	}
+	if err != nil {
+		serverError(w, errors.Wrapf(err, "failed to render %s", page))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,16 @@
+// Code generated by gen_templates.go; DO NOT EDIT.
+
+package main
+
+// embeddedTemplates are the files in static/template, by name
+var embeddedTemplates = map[string]string{
+	"cart.html":            "{{define \"title\"}}\n    Checkout - SpookyStore\n{{- end}}\n\n{{define \"body\"}}\n<script>\n  window.onCartChange = function(cart) { window.location.reload(); };\n</script>\n\n\n<div class=\"transaction-div\">\n\n\n    {{ $length := len .CartItems }} {{ if eq $length 0 }}\n      <h6>Your cart is empty! Browse <a href=\"/\">our products</a> to add to your cart.</h6>\n    {{ end }} \n\n\n    {{ $length := len .CartItems }} {{ if ge $length 1 }}\n    <div>\n\n    </div>\n\n    <div class=\"transaction-card mdl-card mdl-shadow--2dp\">\n        <div class=\"mdl-card__title\">\n          <h2 class=\"mdl-card__title-text\">My Cart</h2>\n        </div>\n        <div class=\"mdl-card__supporting-text\">\n\n        {{range $i, $t := .CartItems}}\n              <h6><b>{{ $t.DisplayName }}</b>: {{money $t.Cost}} ({{ $t.Quantity}}) </h6>\n        {{end }}\n      </div>\n      \n        <div class=\"mdl-card__actions mdl-card--border\">\n\n            <h5>Total: {{money .cart.TotalCost}}</h5>\n\n\n            <div class=\"mdl-grid\">\n                <div class=\"mdl-cell mdl-cell--6-col mdl-textfield mdl-js-textfield\">\n\n              <button class=\"mdl-button mdl-js-button mdl-button--raised\" onclick=\"httpPost('/clearcart/u/{{.me.ID}}', function() { window.location.reload(); });\">\n                Clear Cart\n              </button>\n              </div>\n              <div class=\"mdl-cell mdl-cell--6-col mdl-textfield mdl-js-textfield\">\n\n\n          <button class=\"mdl-button mdl-js-button mdl-button--raised mdl-js-ripple-effect mdl-button--accent\"onclick=\"httpPost('/checkout/u/{{$.me.ID}}', checkoutSuccess)\">\n            Place Order\n          </div>\n\n\n          </div>\n        </div>\n      </div>\n    </div>\n    {{ end }}\n\n    \n{{- end}}",
+	"home.html":            "{{define \"title\"}}SpookyStore{{end}}\n\n{{define \"body\"}}\n<div id=\"new-products\" class=\"product-grid\" style=\"display: none\">\n    <p class=\"mdl-card__supporting-text\">New products have arrived! <a href=\"/\">Take a look</a>.</p>\n</div>\n<script>\n  new EventSource(\"/events/products\").addEventListener(\"product\", function(e) {\n    document.getElementById(\"new-products\").style.display = \"block\";\n  });\n</script>\n<div class=\"product-grid\">\n    {{range $i, $p := .products}}\n    <div class=\"mdl-card mdl-shadow--2dp demo-card-square\">\n            <div class=\"mdl-card__title mdl-card__accent mdl-card--expand\" style=\" background: url('{{$p.PictureURL}}') center / cover;\">\n            </div>\n\n            <div class=\"mdl-card__supporting-text\">\n                    <h5> {{ $p.DisplayName }}</h5>\n                    <h6><b>{{money $p.Cost}}</b></h6>\n                    <span>{{ $p.Description }}</span>\n                      {{ if $.me }}\n                      <div class=\"mdl-grid product-add\">\n                        <div class=\"mdl-cell mdl-cell--6-col mdl-textfield mdl-js-textfield\">\n                            <input class=\"mdl-textfield__input quantity-input\" type=\"text\" pattern=\"-?[0-9]*(\\.[0-9]+)?\" id=\"q-{{ $p.ID }}\">\n                            <label class=\"mdl-textfield__label\" for=\"q-{{ $p.ID }}\">quantity</label>\n                            <span class=\"mdl-textfield__error\">enter a number</span>\n                        </div>\n                        <div class=\"mdl-cell mdl-cell--6-col add-button\">\n                            <button class=\"mdl-button mdl-js-button mdl-button--icon mdl-button--colored\" onclick=\"addToCart('{{$.me.ID}}', '{{ $p.ID }}')\">\n                                    <i id=\"{{ $p.ID }}\"  class=\"material-icons\">\n                                    add\n                                  </i> \n                                </button>\n                        </div>\n                     </div>\n                        {{ end }}\n              </div>  \n         </div>\n    {{end}}\n</div>\n    <div class=\"product-grid\"> \n     <p id=\"transactions-note\" class=\"mdl-card__supporting-text\" {{ if not .numTransactions }}style=\"display: none\"{{ end }}>Thank you for visiting the Spooky Store! Since our founding in 2018, we have processed over <b id=\"transactions\">{{ .numTransactions }}</b> orders. \n       We are happy to serve all of your Autumn needs! Check back often for new products.\n     </p>\n    </div>\n<script>\n  // the count ticks up with every order; reconnects only resend it if it changed\n  new EventSource(\"/events/transactions\").addEventListener(\"transactions\", function(e) {\n    var n = JSON.parse(e.data).numTransactions;\n    document.getElementById(\"transactions\").textContent = n;\n    document.getElementById(\"transactions-note\").style.display = n > 0 ? \"block\" : \"none\";\n  });\n</script>\n{{end}}",
+	"layout.html":          "<!DOCTYPE html>\n<html>\n<head>\n  <title>{{template \"title\" .}}</title>\n  <meta charset=\"utf-8\">\n  <meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"/>\n  {{if .csrf}}<meta name=\"csrf-token\" content=\"{{.csrf}}\">{{end}}\n  <link rel=\"shortcut icon\" href=\"http://icons-for-free.com/icon/download-ghost_halloween_icon-316897.ico\" />\n  <link rel=\"stylesheet\" href=\"https://code.getmdl.io/1.3.0/material.deep_orange-blue.min.css\" />\n  <link rel=\"stylesheet\" href=\"https://fonts.googleapis.com/icon?family=Material+Icons\">\n  <script defer src=\"https://code.getmdl.io/1.3.0/material.min.js\"></script>\n  <script>\n\n\n\nfunction addToCart(userID, productID) {\n  var quantity = document.getElementById(\"q-\" + productID).value;\n    if (quantity > 0) {\n      console.log(\"addding to cart\");\n      httpPost('/addproduct/' + userID + \"/\" + productID + \"/\" + quantity);\n      markDone(productID); //TODO - only mark done if I get a 200 back \n    } else {\n      console.log(\"quantity is zero, not adding to cart\");\n    }\n}\n\n   // httpPost posts to url with the page's CSRF token, then calls done, if\n   // given, once it succeeds\n   function httpPost(url, done) {\n      var xmlHttp = new XMLHttpRequest();\n      xmlHttp.onreadystatechange = function() { \n          if (xmlHttp.readyState == 4 && xmlHttp.status == 200) {\n            console.log(\"posted to \" + url);\n            if (done) done();\n          }\n      }\n      xmlHttp.open(\"POST\", url, true); // true for asynchronous \n      var csrf = document.querySelector('meta[name=\"csrf-token\"]');\n      if (csrf) xmlHttp.setRequestHeader(\"X-CSRF-Token\", csrf.content);\n      xmlHttp.send(null);\n  }\n\n  // changes a button from \"add\" to \"done\" after adding to cart \n  function markDone(productID) {\n    document.getElementById(productID).innerHTML = 'check';\n    document.getElementById(\"q-\" + productID).value = \"quantity\";\n  }\n\n  function checkoutSuccess(name) {\n      // the backend counts the transaction\n      window.location = \"/\";\n  }\n\n\n  </script>\n  {{if .me}}\n  <script>\n  // keeps the cart badge up to date, and tells pages that show the cart\n  // (through onCartChange) when it changes in another tab or device\n  var cartSeen = false;\n  var cartEvents = new EventSource(\"/events/cart/u/{{.me.ID}}\");\n  cartEvents.addEventListener(\"cart\", function(e) {\n    var cart = JSON.parse(e.data);\n    var count = 0;\n    cart.items.forEach(function(i) { count += i.quantity; });\n    var badge = document.getElementById(\"cart-badge\");\n    if (count > 0) {\n      badge.setAttribute(\"data-badge\", count);\n    } else {\n      badge.removeAttribute(\"data-badge\");\n    }\n    if (cartSeen && window.onCartChange) {\n      window.onCartChange(cart);\n    }\n    cartSeen = true;\n  });\n  </script>\n  {{end}}\n  <style>\n\n    .lg {\n      font-size: 20px;\n    }\n\n    .product-add {\n      padding: 0px 0px 0px 0px;\n      margin: 0rem;\n    }\n\n    .quantity-input {\n      width: 100px; \n    }\n\n    .add-button {\n      display: flex;\n      align-items: center;\n    }\n\n    .product-grid {\n      float: left; \n      margin-left: 60px;\n      padding-top: 15px;\n    }\n\n.demo-card-square.mdl-card {\n  width: 350px;\n  height: 400px;\n  float: left;\n  margin: 1rem;\n  position: relative;\n}\n\n\n.demo-card-square.mdl-card:hover {\n  box-shadow: 0 8px 10px 1px rgba(0, 0, 0, .14), 0 3px 14px 2px rgba(0, 0, 0, .12), 0 5px 5px -3px rgba(0, 0, 0, .2);\n}\n\n.demo-card-square > .mdl-card__title {\n  color: #fff;\n  background: #03a9f4;\n}\n\n.demo-card-square > .mdl-card__accent {\n  background: #ff9800;\n}\n\n.transaction-div {\n  padding: 25px 50px 75px 85px;\n}\n\n.transaction-card {\n  width: 600px;\n}\n\nbody {\n  background: #fafafa;\n  position: relative;\n}\n  </style>\n</head>\n<body></body>\n<!-- Always shows a header, even in smaller screens. -->\n<div class=\"mdl-layout mdl-js-layout mdl-layout--fixed-header\">\n    <header class=\"mdl-layout__header\">\n      <div class=\"mdl-layout__header-row\">\n        <!-- Title -->\n        <button class=\"mdl-button mdl-js-button mdl-button--icon\" onclick=\"location.href='/'\">\n            <i class=\"material-icons\">store</i>\n          </button>\n        \n        <h1 class=\"mdl-layout-title\">spooky store</h1>\n        <!-- Add spacer, to align navigation to the right -->\n        <div class=\"mdl-layout-spacer\"></div>\n        <!-- Navigation. We hide it in small screens. -->\n        <nav class=\"mdl-navigation\">\n            {{if .me}} \n\n            <button class=\"mdl-button mdl-js-button mdl-button--icon\" onclick=\"location.href='/cart/u/{{.me.ID}}'\">\n                <i id=\"cart-badge\" class=\"material-icons mdl-badge mdl-badge--overlap\">shopping_cart</i>\n              </button>\n\n            <a class=\"mdl-navigation__link\" href=\"/logout\">Logout</a>\n            <form action=\"/logout/all\" method=\"post\" style=\"display: inline\">\n              <input type=\"hidden\" name=\"csrf\" value=\"{{.csrf}}\">\n              <button class=\"mdl-navigation__link mdl-button mdl-js-button\" type=\"submit\">Log out everywhere</button>\n            </form>\n            <a href=\"/u/{{.me.ID}}\"><div class=\"valign-wrapper\"><img src=\"{{.me.Picture}}\" alt=\"\"/></div></a>\n          {{else}}\n          <a class=\"mdl-navigation__link\" href=\"/login\">Login</a>\n          {{end}}\n        </nav>\n      </div>\n    </header>\n    <main class=\"mdl-layout__content\">\n      <div class=\"page-content\"> {{template \"body\" .}}</div>\n    </main>\n  </div>\n</body> \n</html>\n\n",
+	"login.html":           "{{define \"title\"}}\n    Log in - SpookyStore\n{{- end}}\n\n{{define \"body\"}}\n\n<div class=\"transaction-div\">\n          {{ if .notice }}<h6>{{ .notice }}</h6>{{ end }}\n          {{ if .error }}<h6 style=\"color: #d50000\">{{ .error }}</h6>{{ end }}\n\n          {{ if .local }}\n          <div class=\"mdl-card__title\">\n            <h2 class=\"mdl-card__title-text\">Log in</h2>\n          </div>\n          <form action=\"/login/local\" method=\"post\">\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"email\" name=\"email\" id=\"email\" required>\n                  <label class=\"mdl-textfield__label\" for=\"email\">email</label>\n              </div>\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"password\" name=\"password\" id=\"password\" required>\n                  <label class=\"mdl-textfield__label\" for=\"password\">password</label>\n              </div>\n              <button class=\"mdl-button mdl-js-button mdl-button--raised mdl-button--colored\" type=\"submit\">Log in</button>\n          </form>\n          <h6><a href=\"/register\">Create an account</a> &middot; <a href=\"/password/forgot\">Forgot your password?</a></h6>\n          {{ end }}\n\n          {{ if .providers }}\n          <div class=\"mdl-card__title\">\n            <h2 class=\"mdl-card__title-text\">Log in with</h2>\n          </div>\n          {{range .providers}}\n              <a class=\"mdl-button mdl-js-button mdl-button--raised\" href=\"/login/{{.}}\">{{.}}</a>\n          {{end}}\n          {{ end }}\n    </div>\n\n{{- end}}\n",
+	"password_forgot.html": "{{define \"title\"}}\n    Forgot your password - SpookyStore\n{{- end}}\n\n{{define \"body\"}}\n\n<div class=\"transaction-div\">\n          <div class=\"mdl-card__title\">\n            <h2 class=\"mdl-card__title-text\">Forgot your password?</h2>\n          </div>\n          <h6>We will email you a link to choose a new one.</h6>\n          <form action=\"/password/forgot\" method=\"post\">\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"email\" name=\"email\" id=\"email\" required>\n                  <label class=\"mdl-textfield__label\" for=\"email\">email</label>\n              </div>\n              <button class=\"mdl-button mdl-js-button mdl-button--raised mdl-button--colored\" type=\"submit\">Send link</button>\n          </form>\n    </div>\n\n{{- end}}\n",
+	"password_reset.html":  "{{define \"title\"}}\n    Reset your password - SpookyStore\n{{- end}}\n\n{{define \"body\"}}\n\n<div class=\"transaction-div\">\n          <div class=\"mdl-card__title\">\n            <h2 class=\"mdl-card__title-text\">Choose a new password</h2>\n          </div>\n          {{ if .error }}<h6 style=\"color: #d50000\">{{ .error }}</h6>{{ end }}\n          <form action=\"/password/reset\" method=\"post\">\n              <input type=\"hidden\" name=\"token\" value=\"{{ .token }}\">\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"password\" name=\"password\" id=\"password\" minlength=\"8\" required>\n                  <label class=\"mdl-textfield__label\" for=\"password\">new password (at least 8 characters)</label>\n              </div>\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"password\" name=\"confirm\" id=\"confirm\" minlength=\"8\" required>\n                  <label class=\"mdl-textfield__label\" for=\"confirm\">new password again</label>\n              </div>\n              <button class=\"mdl-button mdl-js-button mdl-button--raised mdl-button--colored\" type=\"submit\">Set password</button>\n          </form>\n    </div>\n\n{{- end}}\n",
+	"profile.html":         "{{define \"title\"}}\n    SpookyStore\n{{- end}}\n\n{{define \"body\"}}\n\n<div class=\"transaction-div\">\n          <div class=\"mdl-card__title\">\n            <h2 class=\"mdl-card__title-text\">My Transaction History</h2>\n          </div>\n          {{ if .Transactions }}\n              <table class=\"mdl-data-table mdl-js-data-table mdl-shadow--2dp\">\n                      <thead>\n                        <tr>\n                          <th class=\"mdl-data-table__cell--non-numeric\"><h6>Date</h6></th>\n                          <th><h6>Total</h6></th>\n                        </tr>\n                      </thead>\n                      <tbody>\n                          {{range $i, $t := .Transactions}}\n                                <tr>\n                                  <td class=\"mdl-data-table__cell--non-numeric\"><h6> {{ $t.CompletedTime }}</h6></td>\n                                  <td><h6>{{money $t.TotalCost}}</h6></td>\n                                </tr>\n                        {{end}}\n                      </tbody>\n                </table>\n          {{ end }}\n          <h6><a href=\"/u/{{ .user.ID }}/tokens\">API tokens</a></h6>\n    </div>\n\n{{- end}}",
+	"register.html":        "{{define \"title\"}}\n    Create an account - SpookyStore\n{{- end}}\n\n{{define \"body\"}}\n\n<div class=\"transaction-div\">\n          <div class=\"mdl-card__title\">\n            <h2 class=\"mdl-card__title-text\">Create an account</h2>\n          </div>\n          {{ if .error }}<h6 style=\"color: #d50000\">{{ .error }}</h6>{{ end }}\n          <form action=\"/register\" method=\"post\">\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"text\" name=\"name\" id=\"name\">\n                  <label class=\"mdl-textfield__label\" for=\"name\">name</label>\n              </div>\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"email\" name=\"email\" id=\"email\" value=\"{{ .email }}\" required>\n                  <label class=\"mdl-textfield__label\" for=\"email\">email</label>\n              </div>\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"password\" name=\"password\" id=\"password\" minlength=\"8\" required>\n                  <label class=\"mdl-textfield__label\" for=\"password\">password (at least 8 characters)</label>\n              </div>\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"password\" name=\"confirm\" id=\"confirm\" minlength=\"8\" required>\n                  <label class=\"mdl-textfield__label\" for=\"confirm\">password again</label>\n              </div>\n              <button class=\"mdl-button mdl-js-button mdl-button--raised mdl-button--colored\" type=\"submit\">Create account</button>\n          </form>\n    </div>\n\n{{- end}}\n",
+	"tokens.html":          "{{define \"title\"}}\n    API tokens - SpookyStore\n{{- end}}\n\n{{define \"body\"}}\n\n<div class=\"transaction-div\">\n          {{ if .error }}<h6 style=\"color: #d50000\">{{ .error }}</h6>{{ end }}\n          {{ if .token }}\n          <h6>Copy your new token now, it will not be shown again:</h6>\n          <pre>{{ .token }}</pre>\n          {{ end }}\n\n          <div class=\"mdl-card__title\">\n            <h2 class=\"mdl-card__title-text\">API tokens</h2>\n          </div>\n          {{ if .tokens }}\n              <table class=\"mdl-data-table mdl-js-data-table mdl-shadow--2dp\">\n                      <thead>\n                        <tr>\n                          <th class=\"mdl-data-table__cell--non-numeric\"><h6>Name</h6></th>\n                          <th class=\"mdl-data-table__cell--non-numeric\"><h6>Scopes</h6></th>\n                          <th class=\"mdl-data-table__cell--non-numeric\"><h6>Created</h6></th>\n                          <th class=\"mdl-data-table__cell--non-numeric\"><h6>Expires</h6></th>\n                          <th></th>\n                        </tr>\n                      </thead>\n                      <tbody>\n                          {{range .tokens}}\n                                <tr>\n                                  <td class=\"mdl-data-table__cell--non-numeric\"><h6>{{ .Name }}</h6></td>\n                                  <td class=\"mdl-data-table__cell--non-numeric\"><h6>{{ range .Scopes }}{{ . }} {{ end }}</h6></td>\n                                  <td class=\"mdl-data-table__cell--non-numeric\"><h6>{{ date .Created }}</h6></td>\n                                  <td class=\"mdl-data-table__cell--non-numeric\"><h6>{{ date .Expires }}</h6></td>\n                                  <td>\n                                    {{ if .Active }}\n                                    <form action=\"/u/{{ $.userID }}/tokens/{{ .ID }}/revoke\" method=\"post\">\n                                        <input type=\"hidden\" name=\"csrf\" value=\"{{ $.csrf }}\">\n                                        <button class=\"mdl-button mdl-js-button\" type=\"submit\">Revoke</button>\n                                    </form>\n                                    {{ else }}<h6>inactive</h6>{{ end }}\n                                  </td>\n                                </tr>\n                        {{end}}\n                      </tbody>\n                </table>\n          {{ end }}\n\n          <div class=\"mdl-card__title\">\n            <h2 class=\"mdl-card__title-text\">New token</h2>\n          </div>\n          <form action=\"/u/{{ .userID }}/tokens\" method=\"post\">\n              <input type=\"hidden\" name=\"csrf\" value=\"{{ .csrf }}\">\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"text\" name=\"name\" id=\"name\" required>\n                  <label class=\"mdl-textfield__label\" for=\"name\">name</label>\n              </div>\n              <div class=\"mdl-textfield mdl-js-textfield\">\n                  <input class=\"mdl-textfield__input\" type=\"number\" name=\"days\" id=\"days\" min=\"1\" max=\"365\" value=\"30\" required>\n                  <label class=\"mdl-textfield__label\" for=\"days\">days until it expires</label>\n              </div>\n              {{range .scopes}}\n              <label><input type=\"checkbox\" name=\"scope\" value=\"{{ . }}\"> {{ . }}</label>\n              {{end}}\n              <button class=\"mdl-button mdl-js-button mdl-button--raised mdl-button--colored\" type=\"submit\">Create token</button>\n          </form>\n    </div>\n\n{{- end}}\n",
+}
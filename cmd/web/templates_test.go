@@ -0,0 +1,109 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+)
+
+func TestEmbeddedTemplates(t *testing.T) {
+	files, err := diskTemplates(filepath.Join("static", "template"))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, embeddedTemplates) {
+		t.Error("embedded templates are out of date, run go generate")
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpls, err := newTemplates(embeddedLoader, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	tmpls.render(w, http.StatusOK, "home.html", map[string]interface{}{
+		"products": []*pb.Product{{ID: "1", DisplayName: "Cauldron", Cost: 3.5}},
+	})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "$3.50") {
+		t.Errorf("expected the home page, got %d %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	tmpls.render(w, http.StatusOK, "nope.html", nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected a server error for a missing template, got %d", w.Code)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	files := map[string]string{
+		layoutTemplate: `<title>{{template "title" .}}</title>{{template "body" .}}`,
+		"page.html":    `{{define "title"}}Page{{end}}{{define "body"}}{{.Name}}{{end}}`,
+	}
+	load := func() (map[string]string, error) { return files, nil }
+	tmpls, err := newTemplates(load, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a page that fails half way is not sent
+	w := httptest.NewRecorder()
+	tmpls.render(w, http.StatusOK, "page.html", map[int]int{})
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "<title>") {
+		t.Errorf("expected only a server error, got %d %s", w.Code, w.Body)
+	}
+
+	// edits are picked up when reloading
+	files["page.html"] = `{{define "title"}}Edited{{end}}{{define "body"}}{{.Name}}{{end}}`
+	w = httptest.NewRecorder()
+	tmpls.render(w, http.StatusBadRequest, "page.html", struct{ Name string }{"Casper"})
+	if w.Code != http.StatusBadRequest || w.Body.String() != "<title>Edited</title>Casper" {
+		t.Errorf("expected the edited page, got %d %s", w.Code, w.Body)
+	}
+
+	files["page.html"] = `{{define "body"}}{{if}}{{end}}`
+	w = httptest.NewRecorder()
+	tmpls.render(w, http.StatusOK, "page.html", nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected a server error for a broken template, got %d", w.Code)
+	}
+	if _, err := newTemplates(load, false); err == nil {
+		t.Error("expected broken templates to fail at startup")
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	for _, c := range []struct{ got, want string }{
+		{money(float32(3)), "$3.00"},
+		{money(12.345), "$12.35"},
+		{plural(int32(1), "order", "orders"), "1 order"},
+		{plural(0, "order", "orders"), "0 orders"},
+		{date(time.Date(2018, 10, 31, 12, 0, 0, 0, time.UTC)), "31 October 2018"},
+		{date(time.Time{}), ""},
+	} {
+		if c.got != c.want {
+			t.Errorf("expected %q, got %q", c.want, c.got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/gorilla/mux"
+	"github.com/m-okeefe/spookystore/internal/auth"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"github.com/pkg/errors"
+)
+
+// tokenScopes are offered on the token page
+var tokenScopes = []auth.Scope{auth.ScopeRead, auth.ScopeCart, auth.ScopeCheckout, auth.ScopeAdmin}
+
+// formattedToken is an API token as shown on the token page
+type formattedToken struct {
+	ID      string
+	Name    string
+	Scopes  []string
+	Created time.Time
+	Expires time.Time
+	Active  bool
+}
+
+func (s *server) tokensPage(w http.ResponseWriter, r *http.Request) {
+	me, ctx, ok := s.authorize(w, r, auth.ManageTokens)
+	if !ok {
+		return
+	}
+	s.renderTokens(ctx, w, me, mux.Vars(r)["id"], map[string]interface{}{})
+}
+
+// createToken mints a token and shows it, the only time it can be seen
+func (s *server) createToken(w http.ResponseWriter, r *http.Request) {
+	me, ctx, ok := s.authorize(w, r, auth.ManageTokens)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if err := r.ParseForm(); err != nil {
+		badRequest(w, errors.Wrap(err, "failed to parse form"))
+		return
+	}
+	days, err := strconv.Atoi(r.PostForm.Get("days"))
+	if err != nil || days <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		s.renderTokens(ctx, w, me, id, map[string]interface{}{"error": "lifetime must be a positive number of days"})
+		return
+	}
+	resp, err := s.spookySvc.CreateAPIToken(ctx, &pb.CreateAPITokenRequest{
+		UserID:     id,
+		Name:       r.PostForm.Get("name"),
+		Scopes:     r.PostForm["scope"],
+		TTLSeconds: int64((time.Duration(days) * 24 * time.Hour).Seconds()),
+	})
+	if err != nil {
+		if msg, ok := userError(err); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			s.renderTokens(ctx, w, me, id, map[string]interface{}{"error": msg})
+			return
+		}
+		rpcError(w, errors.Wrap(err, "failed to create token"))
+		return
+	}
+	log.WithField("user.id", id).WithField("token", resp.GetInfo().GetID()).Info("created api token")
+	s.renderTokens(ctx, w, me, id, map[string]interface{}{"token": resp.GetToken()})
+}
+
+func (s *server) revokeToken(w http.ResponseWriter, r *http.Request) {
+	_, ctx, ok := s.authorize(w, r, auth.ManageTokens)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if _, err := s.spookySvc.RevokeAPIToken(ctx, &pb.RevokeAPITokenRequest{UserID: id, TokenID: mux.Vars(r)["tid"]}); err != nil {
+		rpcError(w, errors.Wrap(err, "failed to revoke token"))
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/u/%s/tokens", id))
+	w.WriteHeader(http.StatusFound)
+}
+
+// renderTokens renders the token page of user id, adding the tokens and
+// scopes to data
+func (s *server) renderTokens(ctx context.Context, w http.ResponseWriter, me *pb.User, id string, data map[string]interface{}) {
+	resp, err := s.spookySvc.ListAPITokens(ctx, &pb.UserRequest{ID: id})
+	if err != nil {
+		rpcError(w, errors.Wrap(err, "failed to list tokens"))
+		return
+	}
+	var tokens []formattedToken
+	for _, t := range resp.GetTokens() {
+		created, _ := ptypes.Timestamp(t.GetCreated())
+		expires, _ := ptypes.Timestamp(t.GetExpires())
+		tokens = append(tokens, formattedToken{
+			ID:      t.GetID(),
+			Name:    t.GetName(),
+			Scopes:  t.GetScopes(),
+			Created: created,
+			Expires: expires,
+			Active:  !t.GetRevoked() && time.Now().Before(expires),
+		})
+	}
+	data["me"] = me
+	data["csrf"] = csrfToken(ctx)
+	data["userID"] = id
+	data["tokens"] = tokens
+	data["scopes"] = tokenScopes
+	s.templates.render(w, http.StatusOK, "tokens.html", data)
+}
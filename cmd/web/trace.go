@@ -5,8 +5,9 @@ import (
 	"net/http"
 	"time"
 
-	"cloud.google.com/go/trace"
 	"github.com/m-okeefe/spookystore/cmd/version"
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	"github.com/m-okeefe/spookystore/internal/tracing"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,13 +27,21 @@ func (p *proxyResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush sends buffered data to the client, so event streams work through the
+// proxy.
+func (p *proxyResponseWriter) Flush() {
+	if f, ok := p.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // traceHandler wraps the HTTP handler with tracing that automatically finishes
 // the span. It adds additional fields to the trace span about the response and
 // adds correlation header to the headers.
 func (s *server) traceHandler(h func(http.ResponseWriter, *http.Request)) http.Handler {
-	return s.tc.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return s.tracer.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ww := &proxyResponseWriter{w: w}
-		span := trace.FromContext(r.Context())
+		span := tracing.FromContext(r.Context())
 		defer func() {
 			code := ww.code
 			if code == 0 {
@@ -44,19 +53,28 @@ func (s *server) traceHandler(h func(http.ResponseWriter, *http.Request)) http.H
 			span.SetLabel("app/version", version.Version())
 			span.Finish()
 		}()
-		ww.Header().Set("X-Cloud-Trace-Context", span.TraceID())
+		if id := span.TraceID(); id != "" {
+			ww.Header().Set("X-Trace-Id", id)
+		}
 		ww.Header().Set("X-App-Version", version.Version())
 		h(ww, r)
 	}))
 }
 
-// logHandler wraps the HTTP handler with structured logging.
+// logHandler wraps the HTTP handler with structured logging. The request ID
+// is passed on to the backend with every call made for the request.
 func logHandler(h func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		id := tracing.FromContext(r.Context()).TraceID()
+		if id == "" {
+			id = middleware.NewRequestID()
+		}
+		r = r.WithContext(middleware.WithRequestID(r.Context(), id))
+		w.Header().Set("X-Request-Id", id)
 		e := log.WithFields(logrus.Fields{
 			"method":     r.Method,
 			"path":       r.URL.Path,
-			"request.id": trace.FromContext(r.Context()).TraceID(),
+			"request.id": id,
 		})
 		e.Debug("request accepted")
 		start := time.Now()
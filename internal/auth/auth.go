@@ -0,0 +1,171 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth decides what a SpookyStore user is allowed to do, and carries
+// the calling user's identity from the web tier to the spookystore service.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// Role is a named set of permissions stored on the User entity.
+type Role string
+
+const (
+	// RoleCustomer can view and change only their own cart and orders.
+	RoleCustomer Role = "customer"
+	// RoleSupport can view any user and fix up any user's cart.
+	RoleSupport Role = "support"
+	// RoleAdmin can do everything, including catalog and role management.
+	RoleAdmin Role = "admin"
+)
+
+// ValidRole reports whether r names a known role.
+func ValidRole(r string) bool {
+	switch Role(r) {
+	case RoleCustomer, RoleSupport, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// HasRole reports whether u has been assigned role r. Users with no stored
+// roles are customers.
+func HasRole(u *pb.User, r Role) bool {
+	if u == nil {
+		return false
+	}
+	if len(u.GetRoles()) == 0 {
+		return r == RoleCustomer
+	}
+	for _, v := range u.GetRoles() {
+		if Role(v) == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Permission is an operation that is subject to authorization.
+type Permission int
+
+const (
+	// ViewUser covers reading a user's profile, cart and orders.
+	ViewUser Permission = iota
+	// EditCart covers adding to and clearing a user's cart.
+	EditCart
+	// Checkout covers purchasing the contents of a user's cart.
+	Checkout
+	// ListUsers covers enumerating all users.
+	ListUsers
+	// ManageCatalog covers adding products.
+	ManageCatalog
+	// ManageRoles covers assigning roles to users.
+	ManageRoles
+	// ManageTokens covers creating, listing and revoking a user's API tokens.
+	ManageTokens
+	// ManageFaults covers injecting faults into the backend's datastore calls.
+	ManageFaults
+)
+
+// roles that hold each permission over users other than themselves
+var grants = map[Permission][]Role{
+	ViewUser:      {RoleSupport, RoleAdmin},
+	EditCart:      {RoleSupport, RoleAdmin},
+	Checkout:      {RoleAdmin},
+	ListUsers:     {RoleSupport, RoleAdmin},
+	ManageCatalog: {RoleAdmin},
+	ManageRoles:   {RoleAdmin},
+	ManageTokens:  {RoleAdmin},
+	ManageFaults:  {RoleAdmin},
+}
+
+// ownerPermissions are held by every user over their own account.
+var ownerPermissions = map[Permission]bool{
+	ViewUser:     true,
+	EditCart:     true,
+	Checkout:     true,
+	ManageTokens: true,
+}
+
+// Allowed reports whether caller holds permission p over the user identified
+// by ownerID. ownerID is empty for operations that are not user-scoped.
+func Allowed(caller *pb.User, p Permission, ownerID string) bool {
+	if caller == nil || caller.GetID() == "" {
+		return false
+	}
+	if ownerID != "" && caller.GetID() == ownerID && ownerPermissions[p] {
+		return true
+	}
+	for _, r := range grants[p] {
+		if HasRole(caller, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// MetadataKey is the gRPC metadata key carrying the calling user's ID. The
+// backend only believes it from callers holding the service secret.
+const MetadataKey = "x-spookystore-user-id"
+
+// ServiceSecretKey is the gRPC metadata key carrying the secret the backend
+// shares with the services trusted to name the calling user, such as web.
+const ServiceSecretKey = "x-spookystore-service-secret"
+
+// ServiceCredentials send the service secret with every call.
+type ServiceCredentials string
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (c ServiceCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{ServiceSecretKey: string(c)}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (c ServiceCredentials) RequireTransportSecurity() bool { return false }
+
+// HasServiceSecret reports whether the incoming call carries secret, which
+// must not be empty.
+func HasServiceSecret(ctx context.Context, secret string) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || secret == "" {
+		return false
+	}
+	v := md.Get(ServiceSecretKey)
+	return len(v) > 0 && subtle.ConstantTimeCompare([]byte(v[0]), []byte(secret)) == 1
+}
+
+// WithUserID returns a context whose outgoing gRPC calls identify the caller
+// as the user with this ID.
+func WithUserID(ctx context.Context, id string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+}
+
+// UserIDFromContext returns the calling user's ID from incoming gRPC metadata.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	v := md.Get(MetadataKey)
+	if len(v) == 0 || v[0] == "" {
+		return "", false
+	}
+	return v[0], true
+}
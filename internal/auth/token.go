@@ -0,0 +1,111 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Scope limits what an API token may do on its user's behalf. A token never
+// allows more than its user's roles do.
+type Scope string
+
+const (
+	// ScopeRead allows viewing the user, their cart and orders.
+	ScopeRead Scope = "read"
+	// ScopeCart allows changing carts.
+	ScopeCart Scope = "cart"
+	// ScopeCheckout allows purchasing carts.
+	ScopeCheckout Scope = "checkout"
+	// ScopeAdmin allows the support and admin operations the user's roles allow.
+	ScopeAdmin Scope = "admin"
+)
+
+// permissions each scope allows. No scope allows ManageTokens, so tokens
+// cannot mint more tokens.
+var scopePermissions = map[Scope][]Permission{
+	ScopeRead:     {ViewUser},
+	ScopeCart:     {EditCart},
+	ScopeCheckout: {Checkout},
+	ScopeAdmin:    {ListUsers, ManageCatalog, ManageRoles, ManageFaults},
+}
+
+// ValidScope reports whether s names a known scope.
+func ValidScope(s string) bool {
+	_, ok := scopePermissions[Scope(s)]
+	return ok
+}
+
+// ScopesAllow reports whether any of scopes allows permission p.
+func ScopesAllow(scopes []string, p Permission) bool {
+	for _, s := range scopes {
+		for _, v := range scopePermissions[Scope(s)] {
+			if v == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TokenPrefix starts every API token, so they are easy to recognize, e.g. by
+// secret scanners.
+const TokenPrefix = "spk_"
+
+// authorizationKey is the gRPC metadata key carrying API tokens
+const authorizationKey = "authorization"
+
+// WithToken returns a context whose outgoing gRPC calls authenticate with
+// the API token.
+func WithToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, authorizationKey, "Bearer "+token)
+}
+
+// TokenFromContext returns the API token from incoming gRPC metadata.
+func TokenFromContext(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	v := md.Get(authorizationKey)
+	if len(v) == 0 || !strings.HasPrefix(v[0], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimPrefix(v[0], "Bearer "), true
+}
+
+// Caller is a caller authenticated with an API token.
+type Caller struct {
+	UserID  string
+	TokenID string
+	Scopes  []string
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context carrying the authenticated caller.
+func WithCaller(ctx context.Context, c *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// CallerFromContext returns the caller authenticated with an API token, or
+// nil if the call did not carry one.
+func CallerFromContext(ctx context.Context) *Caller {
+	c, _ := ctx.Value(callerKey{}).(*Caller)
+	return c
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache keeps short-lived copies of values that are expensive to
+// look up, in process or in a Redis server shared by every replica.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/pkg/errors"
+)
+
+// Cache maps keys to values that expire after a while. Values may be dropped
+// early, so callers must always be able to look them up elsewhere.
+type Cache interface {
+	// Get returns the value of key, and whether there is one.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value of key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete drops the values of keys.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// New returns the cache called name: "memory", which keeps at most size
+// values in process, or "redis", which keeps them in the Redis server at
+// redisAddr. It returns nil for "none".
+func New(name string, size int, redisAddr string) (Cache, error) {
+	switch name {
+	case "none", "":
+		return nil, nil
+	case "memory":
+		return NewMemory(size, clockwork.NewRealClock()), nil
+	case "redis":
+		return NewRedis(redisAddr), nil
+	default:
+		return nil, errors.Errorf("unknown cache %q, want none, memory or redis", name)
+	}
+}
+
+// Memory is a Cache in process, which drops the least recently used values
+// once it is full.
+type Memory struct {
+	size  int
+	clock clockwork.Clock
+
+	mu      sync.Mutex
+	lru     *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory returns a cache that keeps at most size values.
+func NewMemory(size int, clock clockwork.Clock) *Memory {
+	return &Memory{size: size, clock: clock, lru: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if !m.clock.Now().Before(e.expires) {
+		m.remove(el)
+		return nil, false, nil
+	}
+	m.lru.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if m.size <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &entry{key: key, value: value, expires: m.clock.Now().Add(ttl)}
+	if el, ok := m.entries[key]; ok {
+		el.Value = e
+		m.lru.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.lru.PushFront(e)
+	for m.lru.Len() > m.size {
+		m.remove(m.lru.Back())
+	}
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		if el, ok := m.entries[k]; ok {
+			m.remove(el)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) remove(el *list.Element) {
+	m.lru.Remove(el)
+	delete(m.entries, el.Value.(*entry).key)
+}
@@ -0,0 +1,61 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+func TestMemory(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	m := NewMemory(2, clock)
+	ctx := context.Background()
+
+	m.Set(ctx, "a", []byte("1"), time.Minute)
+	m.Set(ctx, "b", []byte("2"), time.Second)
+	if v, ok, _ := m.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Errorf("expected a, got %q", v)
+	}
+
+	// b is the least recently used
+	m.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Error("expected b to be evicted")
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Error("expected a to expire")
+	}
+
+	m.Set(ctx, "d", []byte("4"), time.Minute)
+	m.Delete(ctx, "d")
+	if _, ok, _ := m.Get(ctx, "d"); ok {
+		t.Error("expected d to be deleted")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if c, err := New("none", 0, ""); c != nil || err != nil {
+		t.Errorf("expected no cache, got %v, %v", c, err)
+	}
+	if _, err := New("memcached", 0, ""); err == nil {
+		t.Error("expected unknown caches to be rejected")
+	}
+}
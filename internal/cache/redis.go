@@ -0,0 +1,112 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRedisAddr is where a Redis server running next to the process
+	// listens.
+	DefaultRedisAddr = "localhost:6379"
+
+	// redisTimeout bounds every command, so a slow server can't hold up
+	// the lookups it is meant to speed up
+	redisTimeout = 500 * time.Millisecond
+	// redisPrefix namespaces the keys of spookystore in a shared server
+	redisPrefix = "spookystore/"
+)
+
+// Redis is a Cache kept in a Redis server, or anything that speaks its
+// protocol, shared by every process using it.
+type Redis struct {
+	pool *redis.Pool
+}
+
+// NewRedis returns a cache kept in the Redis server at addr. It connects on
+// first use.
+func NewRedis(addr string) *Redis {
+	return &Redis{pool: &redis.Pool{
+		MaxIdle:     8,
+		IdleTimeout: time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr,
+				redis.DialConnectTimeout(redisTimeout),
+				redis.DialReadTimeout(redisTimeout),
+				redis.DialWriteTimeout(redisTimeout))
+		},
+	}}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var v []byte
+	err := r.do(ctx, func(c redis.Conn) error {
+		var err error
+		v, err = redis.Bytes(c.Do("GET", redisPrefix+key))
+		return err
+	})
+	if err == redis.ErrNil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get from redis")
+	}
+	return v, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := int64(ttl / time.Millisecond)
+	if ms <= 0 {
+		return nil
+	}
+	err := r.do(ctx, func(c redis.Conn) error {
+		_, err := c.Do("SET", redisPrefix+key, value, "PX", ms)
+		return err
+	})
+	return errors.Wrap(err, "failed to set in redis")
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(keys))
+	for i, k := range keys {
+		args[i] = redisPrefix + k
+	}
+	err := r.do(ctx, func(c redis.Conn) error {
+		_, err := c.Do("DEL", args...)
+		return err
+	})
+	return errors.Wrap(err, "failed to delete from redis")
+}
+
+// Close closes the connections to the server.
+func (r *Redis) Close() error {
+	return r.pool.Close()
+}
+
+func (r *Redis) do(ctx context.Context, f func(redis.Conn) error) error {
+	c, err := r.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return f(c)
+}
@@ -0,0 +1,247 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config loads the settings of a command from, in increasing order of
+// precedence, their defaults, a YAML file, SPOOKY_* environment variables and
+// the command line. Settings are the command's flags, so they stay typed and
+// documented in one place, and are named the same everywhere: the flag
+// --cache-ttl is cache-ttl in the file and SPOOKY_CACHE_TTL in the
+// environment.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EnvPrefix starts the names of environment variables holding settings.
+const EnvPrefix = "SPOOKY_"
+
+// redacted replaces the values of secrets when printing settings
+const redacted = "REDACTED"
+
+// Sources of settings, as reported by Source.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Errors are all the problems found with a configuration, so they can be
+// fixed in one go.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid configuration:\n  " + strings.Join(msgs, "\n  ")
+}
+
+// Err returns e, or nil if it is empty.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Check records a problem with setting name unless ok.
+func (e *Errors) Check(ok bool, name, problem string) {
+	if !ok {
+		*e = append(*e, errors.Errorf("--%s (%s) %s", name, EnvName(name), problem))
+	}
+}
+
+// Required records a problem if setting name is empty.
+func (e *Errors) Required(name, v string) {
+	e.Check(v != "", name, "is required")
+}
+
+// OneOf records a problem if setting name isn't one of allowed.
+func (e *Errors) OneOf(name, v string, allowed ...string) {
+	for _, a := range allowed {
+		if v == a {
+			return
+		}
+	}
+	e.Check(false, name, fmt.Sprintf("is %q, want one of %s", v, strings.Join(allowed, ", ")))
+}
+
+// Settings are the flags of a command, loaded from all sources.
+type Settings struct {
+	fs      *flag.FlagSet
+	file    *string
+	print   *bool
+	secrets map[string]bool
+	sources map[string]string
+}
+
+// New adds --config and --print-config to fs, and returns its settings.
+func New(fs *flag.FlagSet) *Settings {
+	return &Settings{
+		fs:      fs,
+		file:    fs.String("config", "", "YAML file of settings, keyed by flag name"),
+		print:   fs.Bool("print-config", false, "print the settings in effect, with secrets redacted, and exit"),
+		secrets: map[string]bool{},
+		sources: map[string]string{},
+	}
+}
+
+// Secret marks settings whose values are not to be printed.
+func (s *Settings) Secret(names ...string) *Settings {
+	for _, n := range names {
+		s.secrets[n] = true
+	}
+	return s
+}
+
+// EnvName returns the environment variable of setting name.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// Load parses args, then sets the settings missing from them from the
+// environment, looked up with env, and then from the config file.
+func (s *Settings) Load(args []string, env func(string) (string, bool)) error {
+	if err := s.fs.Parse(args); err != nil {
+		return err
+	}
+	s.fs.Visit(func(f *flag.Flag) { s.sources[f.Name] = SourceFlag })
+
+	var errs Errors
+	s.fs.VisitAll(func(f *flag.Flag) {
+		if s.sources[f.Name] != "" {
+			return
+		}
+		v, ok := env(EnvName(f.Name))
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(v); err != nil {
+			errs = append(errs, errors.Errorf("%s: %v", EnvName(f.Name), err))
+			return
+		}
+		s.sources[f.Name] = SourceEnv
+	})
+	if *s.file != "" {
+		errs = append(errs, s.loadFile(*s.file)...)
+	}
+	return errs.Err()
+}
+
+// loadFile sets the settings in the YAML file path that aren't set yet
+func (s *Settings) loadFile(path string) Errors {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Errors{errors.Wrap(err, "failed to read config file")}
+	}
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return Errors{errors.Wrapf(err, "%s: invalid YAML", path)}
+	}
+	var errs Errors
+	for _, name := range sortedKeys(m) {
+		f := s.fs.Lookup(name)
+		switch {
+		case f == nil || name == "config" || name == "print-config":
+			errs = append(errs, errors.Errorf("%s: unknown setting %q", path, name))
+			continue
+		case s.sources[name] != "":
+			continue
+		}
+		v, err := scalar(m[name])
+		if err == nil {
+			err = f.Value.Set(v)
+		}
+		if err != nil {
+			errs = append(errs, errors.Errorf("%s: %s: %v", path, name, err))
+			continue
+		}
+		s.sources[name] = SourceFile
+	}
+	return errs
+}
+
+// scalar returns the flag value of a YAML value; lists are comma-separated
+func scalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			s, err := scalar(e)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = s
+		}
+		return strings.Join(elems, ","), nil
+	case map[interface{}]interface{}:
+		return "", errors.New("expected a value or a list, got a mapping")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Source returns where setting name came from.
+func (s *Settings) Source(name string) string {
+	if src := s.sources[name]; src != "" {
+		return src
+	}
+	return SourceDefault
+}
+
+// PrintRequested reports whether --print-config was given.
+func (s *Settings) PrintRequested() bool { return *s.print }
+
+// Print writes the settings in effect to w as a config file, noting where
+// each came from, with secrets redacted.
+func (s *Settings) Print(w io.Writer) error {
+	var err error
+	s.fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		v := f.Value.String()
+		if s.secrets[f.Name] && v != "" {
+			v = redacted
+		}
+		var b []byte
+		if b, err = yaml.Marshal(map[string]string{f.Name: v}); err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, "%s # %s\n", strings.TrimSuffix(string(b), "\n"), s.Source(f.Name))
+	})
+	return err
+}
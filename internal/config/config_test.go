@@ -0,0 +1,127 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(m map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := m[name]
+		return v, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(path, []byte("addr: :8001\ncache-ttl: 1m\nadmin-emails: [a@example.com, b@example.com]\nlog-level: warn\n"), 0644)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addr := fs.String("addr", ":80", "")
+	ttl := fs.Duration("cache-ttl", time.Second, "")
+	admins := fs.String("admin-emails", "", "")
+	level := fs.String("log-level", "info", "")
+	password := fs.String("smtp-password", "", "")
+	size := fs.Int("cache-size", 10, "")
+	s := New(fs).Secret("smtp-password")
+
+	err = s.Load([]string{"--config", path, "--log-level=debug"}, env(map[string]string{
+		"SPOOKY_CACHE_TTL":     "2m",
+		"SPOOKY_SMTP_PASSWORD": "hunter2",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name, got, want, source string
+	}{
+		{"addr", *addr, ":8001", SourceFile},
+		{"cache-ttl", ttl.String(), "2m0s", SourceEnv},
+		{"admin-emails", *admins, "a@example.com,b@example.com", SourceFile},
+		{"log-level", *level, "debug", SourceFlag},
+		{"smtp-password", *password, "hunter2", SourceEnv},
+		{"cache-size", fmt.Sprint(*size), "10", SourceDefault},
+	} {
+		if c.got != c.want || s.Source(c.name) != c.source {
+			t.Errorf("%s: expected %q from %s, got %q from %s", c.name, c.want, c.source, c.got, s.Source(c.name))
+		}
+	}
+
+	var out bytes.Buffer
+	if err := s.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), "smtp-password: REDACTED # env") {
+		t.Errorf("expected the password to be redacted, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "cache-ttl: 2m0s # env") {
+		t.Errorf("expected the settings with their sources, got:\n%s", out.String())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(path, []byte("cache-size: lots\ncolour: orange\n"), 0644)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("cache-size", 10, "")
+	fs.Duration("cache-ttl", time.Second, "")
+	err = New(fs).Load([]string{"--config", path}, env(map[string]string{"SPOOKY_CACHE_TTL": "soon"}))
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected all three problems, got %v", err)
+	}
+	for _, want := range []string{"SPOOKY_CACHE_TTL", "cache-size", `unknown setting "colour"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	errs.Required("google-project-id", "")
+	errs.OneOf("cache", "disk", "none", "memory")
+	errs.OneOf("cache", "memory", "none", "memory")
+	errs.Check(true, "cache-ttl", "must be positive")
+	if len(errs) != 2 {
+		t.Fatalf("expected two problems, got %v", errs)
+	}
+	if want := `--cache (SPOOKY_CACHE) is "disk", want one of none, memory`; errs[1].Error() != want {
+		t.Errorf("expected %q, got %q", want, errs[1])
+	}
+	if (Errors{}).Err() != nil {
+		t.Error("expected no error without problems")
+	}
+}
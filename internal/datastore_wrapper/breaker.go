@@ -0,0 +1,139 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned instead of calling the datastore while the
+// breaker is open.
+var ErrCircuitOpen = status.Error(codes.Unavailable, "datastore circuit breaker is open")
+
+// Breaker stops calling the wrapped datastore after it fails with transient
+// errors too many times in a row, so callers fail fast instead of piling up
+// while it is down. Once open, it fails calls with ErrCircuitOpen until the
+// cooldown is over, then lets a single call through: the breaker closes if
+// it succeeds, and stays open for another cooldown if it fails.
+type Breaker struct {
+	D        DatastoreWrapper
+	failures int
+	cooldown time.Duration
+	clock    clockwork.Clock
+
+	mu       sync.Mutex
+	failed   int
+	open     bool
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker returns d behind a breaker that opens after failures transient
+// errors in a row.
+func NewBreaker(d DatastoreWrapper, failures int, cooldown time.Duration, clock clockwork.Clock) *Breaker {
+	return &Breaker{D: d, failures: failures, cooldown: cooldown, clock: clock}
+}
+
+func (b *Breaker) Get(ctx context.Context, k *datastore.Key, v interface{}) error {
+	ok, probe := b.allow()
+	if !ok {
+		return ErrCircuitOpen
+	}
+	err := b.D.Get(ctx, k, v)
+	b.record(ctx, probe, err)
+	return err
+}
+
+func (b *Breaker) GetAll(ctx context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	ok, probe := b.allow()
+	if !ok {
+		return nil, ErrCircuitOpen
+	}
+	keys, err := b.D.GetAll(ctx, q, v)
+	b.record(ctx, probe, err)
+	return keys, err
+}
+
+func (b *Breaker) Put(ctx context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	ok, probe := b.allow()
+	if !ok {
+		return nil, ErrCircuitOpen
+	}
+	key, err := b.D.Put(ctx, k, v)
+	b.record(ctx, probe, err)
+	return key, err
+}
+
+func (b *Breaker) Delete(ctx context.Context, k *datastore.Key) error {
+	ok, probe := b.allow()
+	if !ok {
+		return ErrCircuitOpen
+	}
+	err := b.D.Delete(ctx, k)
+	b.record(ctx, probe, err)
+	return err
+}
+
+// allow reports whether a call may go through to the datastore, and whether
+// it is the probe of an open breaker
+func (b *Breaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true, false
+	}
+	if b.probing || b.clock.Now().Sub(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// record updates the breaker with the outcome of a call it allowed. Calls
+// let through before the breaker opened don't count while it is open: only
+// the probe decides whether it closes.
+func (b *Breaker) record(ctx context.Context, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	} else if b.open {
+		return
+	}
+	switch {
+	case transient(err) && ctx.Err() == nil:
+		b.failed++
+		if b.open || b.failed >= b.failures {
+			if !b.open {
+				metrics.DatastoreBreakerOpened()
+			}
+			b.open = true
+			b.openedAt = b.clock.Now()
+		}
+	case err != nil && ctx.Err() != nil:
+		// the caller gave up, which says nothing about the datastore
+	default:
+		b.failed = 0
+		b.open = false
+	}
+}
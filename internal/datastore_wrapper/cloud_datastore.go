@@ -49,3 +49,7 @@ func (c *CloudDatastore) Put(ctx context.Context, k *datastore.Key, i interface{
 func (c *CloudDatastore) GetAll(ctx context.Context, q *datastore.Query, i interface{}) ([]*datastore.Key, error) {
 	return c.D.GetAll(ctx, q, i)
 }
+
+func (c *CloudDatastore) Delete(ctx context.Context, k *datastore.Key) error {
+	return c.D.Delete(ctx, k)
+}
@@ -27,4 +27,5 @@ type DatastoreWrapper interface {
 	Get(context.Context, *datastore.Key, interface{}) error
 	GetAll(context.Context, *datastore.Query, interface{}) ([]*datastore.Key, error)
 	Put(context.Context, *datastore.Key, interface{}) (*datastore.Key, error)
+	Delete(context.Context, *datastore.Key) error
 }
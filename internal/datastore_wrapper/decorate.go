@@ -0,0 +1,232 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/jonboulle/clockwork"
+	"github.com/m-okeefe/spookystore/internal/tracing"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultDecorators is the stack of decorators Decorate puts around the
+// datastore unless configured otherwise, outermost first. Metrics and spans
+// cover a call with all its retries, the breaker counts calls that failed
+// despite them, and the timeout bounds each attempt.
+const DefaultDecorators = "metrics,tracing,breaker,retry,timeout"
+
+// Config picks the decorators Decorate stacks around a datastore, and sets
+// them up.
+type Config struct {
+	// Decorators names the decorators to stack, outermost first: metrics,
+	// tracing, breaker, retry, timeout and faults.
+	Decorators []string
+
+	// Timeout bounds each call, or attempt when retrying.
+	Timeout time.Duration
+	// Attempts is how many times retry tries a call in all.
+	Attempts int
+	// Backoff is the longest wait before the first retry. It doubles with
+	// every retry, up to MaxBackoff.
+	Backoff, MaxBackoff time.Duration
+	// BreakerFailures is how many transient failures in a row open the
+	// breaker, which then fails calls for BreakerCooldown before letting one
+	// through to try the datastore again.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	// Faults is the fault injector to stack, if Decorators lists faults.
+	Faults *Faulty
+
+	Clock clockwork.Clock
+}
+
+// DefaultConfig returns the config of DefaultDecorators.
+func DefaultConfig() Config {
+	return Config{
+		Decorators:      strings.Split(DefaultDecorators, ","),
+		Timeout:         5 * time.Second,
+		Attempts:        3,
+		Backoff:         50 * time.Millisecond,
+		MaxBackoff:      time.Second,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+		Clock:           clockwork.NewRealClock(),
+	}
+}
+
+// Decorate returns d wrapped in the decorators c names.
+func Decorate(d DatastoreWrapper, c Config) (DatastoreWrapper, error) {
+	seen := map[string]bool{}
+	for i := len(c.Decorators) - 1; i >= 0; i-- {
+		name := strings.TrimSpace(c.Decorators[i])
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return nil, errors.Errorf("datastore decorator %q is listed twice", name)
+		}
+		seen[name] = true
+		switch name {
+		case "metrics":
+			d = Instrument(d)
+		case "tracing":
+			d = &Traced{D: d}
+		case "breaker":
+			d = NewBreaker(d, c.BreakerFailures, c.BreakerCooldown, c.Clock)
+		case "retry":
+			d = &Retrying{D: d, Attempts: c.Attempts, Backoff: c.Backoff, MaxBackoff: c.MaxBackoff}
+		case "timeout":
+			d = &TimeLimited{D: d, Limit: c.Timeout}
+		case "faults":
+			if c.Faults == nil {
+				return nil, errors.New("no fault injector configured")
+			}
+			c.Faults.D = d
+			d = c.Faults
+		default:
+			return nil, errors.Errorf("unknown datastore decorator %q, want metrics, tracing, breaker, retry, timeout or faults", name)
+		}
+	}
+	return d, nil
+}
+
+// Operation names, as they appear in metrics and spans
+const (
+	opGet    = "get"
+	opGetAll = "get_all"
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+func keyKind(k *datastore.Key) string {
+	if k == nil {
+		return ""
+	}
+	return k.Kind
+}
+
+// queryKind returns the kind q runs over, which Query doesn't export
+func queryKind(q *datastore.Query) string {
+	if q == nil {
+		return ""
+	}
+	f := reflect.ValueOf(q).Elem().FieldByName("kind")
+	if f.Kind() != reflect.String {
+		return "unknown"
+	}
+	return f.String()
+}
+
+// transient reports whether err is a failure of the datastore that may not
+// happen again, rather than a problem with the call
+func transient(err error) bool {
+	switch err {
+	case nil, ErrCircuitOpen:
+		return false
+	case context.DeadlineExceeded:
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// Traced records every call to the wrapped datastore in a span, labelled with
+// the kind of the entities
+type Traced struct {
+	D DatastoreWrapper
+}
+
+func (t *Traced) Get(ctx context.Context, k *datastore.Key, v interface{}) error {
+	span := startSpan(ctx, opGet, keyKind(k))
+	err := t.D.Get(ctx, k, v)
+	finishSpan(span, err)
+	return err
+}
+
+func (t *Traced) GetAll(ctx context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	span := startSpan(ctx, opGetAll, queryKind(q))
+	keys, err := t.D.GetAll(ctx, q, v)
+	finishSpan(span, err)
+	return keys, err
+}
+
+func (t *Traced) Put(ctx context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	span := startSpan(ctx, opPut, keyKind(k))
+	key, err := t.D.Put(ctx, k, v)
+	finishSpan(span, err)
+	return key, err
+}
+
+func (t *Traced) Delete(ctx context.Context, k *datastore.Key) error {
+	span := startSpan(ctx, opDelete, keyKind(k))
+	err := t.D.Delete(ctx, k)
+	finishSpan(span, err)
+	return err
+}
+
+func startSpan(ctx context.Context, op, kind string) *tracing.Span {
+	span := tracing.FromContext(ctx).NewChild("datastore/" + op)
+	span.SetLabel("datastore/kind", kind)
+	return span
+}
+
+func finishSpan(span *tracing.Span, err error) {
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		span.SetLabel("error", err.Error())
+	}
+	span.Finish()
+}
+
+// TimeLimited gives every call to the wrapped datastore at most Limit to
+// finish, or the caller's deadline if that is sooner
+type TimeLimited struct {
+	D     DatastoreWrapper
+	Limit time.Duration
+}
+
+func (t *TimeLimited) Get(ctx context.Context, k *datastore.Key, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, t.Limit)
+	defer cancel()
+	return t.D.Get(ctx, k, v)
+}
+
+func (t *TimeLimited) GetAll(ctx context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Limit)
+	defer cancel()
+	return t.D.GetAll(ctx, q, v)
+}
+
+func (t *TimeLimited) Put(ctx context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Limit)
+	defer cancel()
+	return t.D.Put(ctx, k, v)
+}
+
+func (t *TimeLimited) Delete(ctx context.Context, k *datastore.Key) error {
+	ctx, cancel := context.WithTimeout(ctx, t.Limit)
+	defer cancel()
+	return t.D.Delete(ctx, k)
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "datastore is down")
+
+func TestRetrying(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	r := &dw.Retrying{D: m, Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ctx := context.Background()
+	k := datastore.NameKey("User", "1", nil)
+
+	gomock.InOrder(
+		m.EXPECT().Get(ctx, k, nil).Return(errUnavailable),
+		m.EXPECT().Get(ctx, k, nil).Return(nil),
+	)
+	if err := r.Get(ctx, k, nil); err != nil {
+		t.Errorf("expected the retry to succeed, got %v", err)
+	}
+
+	m.EXPECT().Get(ctx, k, nil).Return(errUnavailable).Times(3)
+	if err := r.Get(ctx, k, nil); err != errUnavailable {
+		t.Errorf("expected the last error after 3 attempts, got %v", err)
+	}
+
+	m.EXPECT().Get(ctx, k, nil).Return(datastore.ErrNoSuchEntity)
+	if err := r.Get(ctx, k, nil); err != datastore.ErrNoSuchEntity {
+		t.Errorf("expected errors that aren't transient to be returned at once, got %v", err)
+	}
+
+	ik := datastore.IncompleteKey("Transaction", nil)
+	m.EXPECT().Put(ctx, ik, nil).Return(nil, errUnavailable)
+	if _, err := r.Put(ctx, ik, nil); err != errUnavailable {
+		t.Errorf("expected puts of incomplete keys not to be retried, got %v", err)
+	}
+}
+
+func TestRetryingGetAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	r := &dw.Retrying{D: m, Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ctx := context.Background()
+	q := datastore.NewQuery("Product")
+
+	// like the datastore, the first attempt appends what it got before failing
+	appending := func(names ...string) func(context.Context, *datastore.Query, interface{}) ([]*datastore.Key, error) {
+		return func(_ context.Context, _ *datastore.Query, dst interface{}) ([]*datastore.Key, error) {
+			p := dst.(*[]string)
+			*p = append(*p, names...)
+			if len(names) < 2 {
+				return nil, errUnavailable
+			}
+			return make([]*datastore.Key, len(names)), nil
+		}
+	}
+	gomock.InOrder(
+		m.EXPECT().GetAll(ctx, q, gomock.Any()).DoAndReturn(appending("a")),
+		m.EXPECT().GetAll(ctx, q, gomock.Any()).DoAndReturn(appending("a", "b")),
+	)
+	got := []string{"before"}
+	if _, err := r.GetAll(ctx, q, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"before", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v after the retry, got %v", want, got)
+	}
+
+	m.EXPECT().GetAll(ctx, q, nil).Return(nil, nil)
+	if _, err := r.GetAll(ctx, q, nil); err != nil {
+		t.Errorf("expected keys-only queries to work, got %v", err)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	clock := clockwork.NewFakeClock()
+	b := dw.NewBreaker(m, 2, time.Minute, clock)
+	ctx := context.Background()
+	k := datastore.NameKey("User", "1", nil)
+
+	m.EXPECT().Get(ctx, k, nil).Return(errUnavailable).Times(2)
+	b.Get(ctx, k, nil)
+	b.Get(ctx, k, nil)
+	if err := b.Get(ctx, k, nil); err != dw.ErrCircuitOpen {
+		t.Fatalf("expected the breaker to open, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	m.EXPECT().Get(ctx, k, nil).Return(errUnavailable)
+	b.Get(ctx, k, nil)
+	if err := b.Get(ctx, k, nil); err != dw.ErrCircuitOpen {
+		t.Fatalf("expected a failed probe to keep the breaker open, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	m.EXPECT().Get(ctx, k, nil).Return(datastore.ErrNoSuchEntity).Times(2)
+	b.Get(ctx, k, nil)
+	if err := b.Get(ctx, k, nil); err != datastore.ErrNoSuchEntity {
+		t.Errorf("expected a successful probe to close the breaker, got %v", err)
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	clock := clockwork.NewFakeClock()
+	b := dw.NewBreaker(m, 1, time.Minute, clock)
+	ctx := context.Background()
+	slow, probe, other := datastore.NameKey("User", "slow", nil), datastore.NameKey("User", "probe", nil), datastore.NameKey("User", "1", nil)
+
+	// blocking starts a call that waits to be released
+	blocking := func(k *datastore.Key) (release, done chan error) {
+		started := make(chan struct{})
+		release, done = make(chan error), make(chan error)
+		m.EXPECT().Get(ctx, k, nil).DoAndReturn(func(context.Context, *datastore.Key, interface{}) error {
+			close(started)
+			return <-release
+		})
+		go func() { done <- b.Get(ctx, k, nil) }()
+		<-started
+		return
+	}
+
+	// a call made while closed is still in flight when the breaker opens
+	releaseSlow, slowDone := blocking(slow)
+	m.EXPECT().Get(ctx, other, nil).Return(errUnavailable)
+	b.Get(ctx, other, nil)
+
+	clock.Advance(time.Minute)
+	releaseProbe, probeDone := blocking(probe)
+
+	// it finishing during the probe neither closes the breaker nor lets
+	// another probe through
+	releaseSlow <- nil
+	<-slowDone
+	if err := b.Get(ctx, other, nil); err != dw.ErrCircuitOpen {
+		t.Fatalf("expected only the probe to close the breaker, got %v", err)
+	}
+
+	releaseProbe <- nil
+	<-probeDone
+	m.EXPECT().Get(ctx, other, nil).Return(nil)
+	if err := b.Get(ctx, other, nil); err != nil {
+		t.Errorf("expected a successful probe to close the breaker, got %v", err)
+	}
+}
+
+func TestTimeLimited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	tl := &dw.TimeLimited{D: m, Limit: time.Second}
+	k := datastore.NameKey("User", "1", nil)
+
+	m.EXPECT().Delete(gomock.Any(), k).DoAndReturn(func(ctx context.Context, _ *datastore.Key) error {
+		if d, ok := ctx.Deadline(); !ok || time.Until(d) > time.Second {
+			t.Errorf("expected a deadline within a second, got %v", d)
+		}
+		return nil
+	})
+	tl.Delete(context.Background(), k)
+}
+
+func TestDecorate(t *testing.T) {
+	c := dw.DefaultConfig()
+	d, err := dw.Decorate(&dw.CloudDatastore{}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.(*dw.Instrumented); !ok {
+		t.Errorf("expected metrics outermost, got %T", d)
+	}
+
+	c.Decorators = []string{""}
+	if d, _ := dw.Decorate(&dw.CloudDatastore{}, c); d == nil {
+		t.Error("expected no decorators to leave the datastore as it is")
+	}
+	for _, bad := range [][]string{{"cache"}, {"retry", "retry"}} {
+		c.Decorators = bad
+		if _, err := dw.Decorate(&dw.CloudDatastore{}, c); err == nil {
+			t.Errorf("expected %v to be rejected", bad)
+		}
+	}
+}
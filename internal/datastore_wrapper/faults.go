@@ -0,0 +1,193 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/jonboulle/clockwork"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The errors a FaultRule can inject
+const (
+	FaultUnavailable      = "unavailable"
+	FaultDeadlineExceeded = "deadline_exceeded"
+	FaultNotFound         = "not_found"
+	FaultContention       = "contention"
+)
+
+var faultErrors = map[string]error{
+	FaultUnavailable:      status.Error(codes.Unavailable, "injected fault: datastore unavailable"),
+	FaultDeadlineExceeded: status.Error(codes.DeadlineExceeded, "injected fault: datastore deadline exceeded"),
+	FaultNotFound:         datastore.ErrNoSuchEntity,
+	FaultContention:       status.Error(codes.Aborted, "injected fault: too much contention on these datastore entities"),
+}
+
+// ValidFaultError reports whether e names an error a FaultRule can inject.
+func ValidFaultError(e string) bool {
+	_, ok := faultErrors[e]
+	return ok
+}
+
+// ValidOp reports whether op names a datastore operation: get, get_all, put
+// or delete.
+func ValidOp(op string) bool {
+	switch op {
+	case opGet, opGetAll, opPut, opDelete:
+		return true
+	}
+	return false
+}
+
+// FaultRule injects faults into some of the datastore calls it matches.
+type FaultRule struct {
+	// Kind and Op restrict the rule to calls on entities of a kind, and to
+	// an operation. Empty values match every call.
+	Kind, Op string
+	// Rate is the share of the calls matched that the rule affects, from 0
+	// to 1.
+	Rate float64
+	// Latency delays the calls affected.
+	Latency time.Duration
+	// Error fails the calls affected with one of the Fault errors, if set.
+	Error string
+	// Partial makes the calls affected before failing them, like a write
+	// that was applied but whose response was lost. Failed reads leave
+	// nothing behind either way.
+	Partial bool
+}
+
+func (r FaultRule) matches(op, kind string) bool {
+	return (r.Kind == "" || r.Kind == kind) && (r.Op == "" || r.Op == op)
+}
+
+// Faulty injects faults into calls to the wrapped datastore, following rules
+// that can be changed at any time. Which calls are affected is drawn from a
+// seeded source, so the same rules, seed and calls give the same faults.
+type Faulty struct {
+	D     DatastoreWrapper
+	clock clockwork.Clock
+
+	mu    sync.Mutex
+	rules []FaultRule
+	seed  int64
+	rand  *rand.Rand
+}
+
+// NewFaulty returns a Faulty with no rules, drawing from seed. Its D must be
+// set before use, which Decorate does.
+func NewFaulty(seed int64, clock clockwork.Clock) *Faulty {
+	return &Faulty{clock: clock, seed: seed, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Rules returns the rules in force and the seed they draw from.
+func (f *Faulty) Rules() ([]FaultRule, int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FaultRule(nil), f.rules...), f.seed
+}
+
+// SetRules replaces the rules, and restarts drawing from seed.
+func (f *Faulty) SetRules(rules []FaultRule, seed int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append([]FaultRule(nil), rules...)
+	f.seed = seed
+	f.rand = rand.New(rand.NewSource(seed))
+}
+
+func (f *Faulty) Get(ctx context.Context, k *datastore.Key, v interface{}) error {
+	reset := restorer(v)
+	err := f.do(ctx, opGet, keyKind(k), func() error {
+		return f.D.Get(ctx, k, v)
+	})
+	if err != nil {
+		reset()
+	}
+	return err
+}
+
+func (f *Faulty) GetAll(ctx context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	var keys []*datastore.Key
+	reset := truncater(v)
+	err := f.do(ctx, opGetAll, queryKind(q), func() error {
+		var err error
+		keys, err = f.D.GetAll(ctx, q, v)
+		return err
+	})
+	if err != nil {
+		reset()
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (f *Faulty) Put(ctx context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	var key *datastore.Key
+	err := f.do(ctx, opPut, keyKind(k), func() error {
+		var err error
+		key, err = f.D.Put(ctx, k, v)
+		return err
+	})
+	return key, err
+}
+
+func (f *Faulty) Delete(ctx context.Context, k *datastore.Key) error {
+	return f.do(ctx, opDelete, keyKind(k), func() error {
+		return f.D.Delete(ctx, k)
+	})
+}
+
+// do makes call with the faults drawn for op and kind
+func (f *Faulty) do(ctx context.Context, op, kind string, call func() error) error {
+	delay, err, partial := f.draw(op, kind)
+	if delay > 0 {
+		select {
+		case <-f.clock.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err == nil {
+		return call()
+	}
+	if partial {
+		call()
+	}
+	return err
+}
+
+// draw decides which of the rules matching op and kind affect a call. Their
+// latencies add up, and the first error wins.
+func (f *Faulty) draw(op, kind string) (delay time.Duration, err error, partial bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.rules {
+		if !r.matches(op, kind) || f.rand.Float64() >= r.Rate {
+			continue
+		}
+		delay += r.Latency
+		if err == nil && r.Error != "" {
+			err, partial = faultErrors[r.Error], r.Partial
+		}
+	}
+	return delay, err, partial
+}
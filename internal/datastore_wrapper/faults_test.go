@@ -0,0 +1,141 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	"github.com/jonboulle/clockwork"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+)
+
+func TestFaultyDraws(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	m.EXPECT().Get(gomock.Any(), gomock.Any(), nil).Return(nil).AnyTimes()
+	f := dw.NewFaulty(42, clockwork.NewFakeClock())
+	f.D = m
+	ctx := context.Background()
+	k := datastore.NameKey("User", "1", nil)
+	rules := []dw.FaultRule{{Kind: "User", Op: "get", Rate: 0.5, Error: dw.FaultNotFound}}
+
+	run := func() []bool {
+		f.SetRules(rules, 42)
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			failed = append(failed, f.Get(ctx, k, nil) == datastore.ErrNoSuchEntity)
+		}
+		return failed
+	}
+	first, second := run(), run()
+	n := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected the same faults from the same seed, got %v and %v", first, second)
+		}
+		if first[i] {
+			n++
+		}
+	}
+	if n == 0 || n == len(first) {
+		t.Errorf("expected about half the calls to fail, got %v", first)
+	}
+
+	// other kinds are left alone
+	if err := f.Get(ctx, datastore.NameKey("Product", "1", nil), nil); err != nil {
+		t.Errorf("expected no fault, got %v", err)
+	}
+}
+
+func TestFaultyPartial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	f := dw.NewFaulty(1, clockwork.NewFakeClock())
+	f.D = m
+	ctx := context.Background()
+	k := datastore.IDKey("User", 1, nil)
+
+	f.SetRules([]dw.FaultRule{{Op: "put", Rate: 1, Error: dw.FaultUnavailable, Partial: true}}, 1)
+	m.EXPECT().Put(ctx, k, nil).Return(k, nil)
+	if _, err := f.Put(ctx, k, nil); err == nil {
+		t.Error("expected the put to be made and fail anyway")
+	}
+
+	// a failed read leaves no results behind, so retries don't duplicate them
+	q := datastore.NewQuery("User")
+	f.SetRules([]dw.FaultRule{{Op: "get_all", Rate: 1, Error: dw.FaultUnavailable, Partial: true}}, 1)
+	m.EXPECT().GetAll(ctx, q, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ *datastore.Query, dst interface{}) ([]*datastore.Key, error) {
+			*dst.(*[]string) = append(*dst.(*[]string), "ghost")
+			return []*datastore.Key{k}, nil
+		})
+	var got []string
+	if _, err := f.GetAll(ctx, q, &got); err == nil || len(got) != 0 {
+		t.Errorf("expected the query to be made and fail without results, got %v, %v", got, err)
+	}
+
+	type user struct{ Name string }
+	f.SetRules([]dw.FaultRule{{Op: "get", Rate: 1, Error: dw.FaultUnavailable, Partial: true}}, 1)
+	m.EXPECT().Get(ctx, k, gomock.Any()).SetArg(2, user{Name: "ghost"}).Return(nil)
+	u := user{Name: "before"}
+	if err := f.Get(ctx, k, &u); err == nil || u.Name != "before" {
+		t.Errorf("expected the get to be made and fail without loading anything, got %v, %v", u, err)
+	}
+
+	f.SetRules([]dw.FaultRule{{Op: "delete", Rate: 1, Error: dw.FaultContention}}, 1)
+	if err := f.Delete(ctx, k); err == nil {
+		t.Error("expected the delete to fail without being made")
+	}
+}
+
+func TestFaultyLatency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	clock := clockwork.NewFakeClock()
+	f := dw.NewFaulty(1, clock)
+	f.D = m
+	k := datastore.IDKey("User", 1, nil)
+	f.SetRules([]dw.FaultRule{{Rate: 1, Latency: time.Second}}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- f.Delete(ctx, k) }()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected the caller to stop waiting, got %v", err)
+	}
+
+	// the cancelled call's timer stays on the fake clock, so start afresh
+	clock = clockwork.NewFakeClock()
+	f = dw.NewFaulty(1, clock)
+	f.D = m
+	f.SetRules([]dw.FaultRule{{Rate: 1, Latency: time.Second}}, 1)
+	m.EXPECT().Delete(gomock.Any(), k).Return(nil)
+	go func() { done <- f.Delete(context.Background(), k) }()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+)
+
+// Instrumented records the latency of every call to the wrapped datastore, by
+// operation and kind
+type Instrumented struct {
+	D DatastoreWrapper
+}
+
+// Instrument returns d, recording the latency of its calls
+func Instrument(d DatastoreWrapper) *Instrumented {
+	return &Instrumented{D: d}
+}
+
+func (i *Instrumented) Get(ctx context.Context, k *datastore.Key, v interface{}) error {
+	start := time.Now()
+	err := i.D.Get(ctx, k, v)
+	observe(opGet, keyKind(k), start, err)
+	return err
+}
+
+func (i *Instrumented) GetAll(ctx context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	start := time.Now()
+	keys, err := i.D.GetAll(ctx, q, v)
+	observe(opGetAll, queryKind(q), start, err)
+	return keys, err
+}
+
+func (i *Instrumented) Put(ctx context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	start := time.Now()
+	key, err := i.D.Put(ctx, k, v)
+	observe(opPut, keyKind(k), start, err)
+	return key, err
+}
+
+func (i *Instrumented) Delete(ctx context.Context, k *datastore.Key) error {
+	start := time.Now()
+	err := i.D.Delete(ctx, k)
+	observe(opDelete, keyKind(k), start, err)
+	return err
+}
+
+func observe(op, kind string, start time.Time, err error) {
+	result := "ok"
+	if err == datastore.ErrNoSuchEntity {
+		result = "not_found"
+	} else if err != nil {
+		result = "error"
+	}
+	metrics.ObserveDatastore(op, kind, result, time.Since(start))
+}
@@ -71,3 +71,15 @@ func (m *MockDatastoreWrapper) Put(arg0 context.Context, arg1 *datastore.Key, ar
 func (mr *MockDatastoreWrapperMockRecorder) Put(arg0, arg1, arg2 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockDatastoreWrapper)(nil).Put), arg0, arg1, arg2)
 }
+
+// Delete mocks base method
+func (m *MockDatastoreWrapper) Delete(arg0 context.Context, arg1 *datastore.Key) error {
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockDatastoreWrapperMockRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockDatastoreWrapper)(nil).Delete), arg0, arg1)
+}
@@ -0,0 +1,403 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/datastore"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Call is a datastore call as recorded: what was asked, and what came back.
+// Entities are kept as the JSON of the Go values they were loaded into or
+// saved from, so replaying a call needs the same types.
+type Call struct {
+	Op    string         `json:"op"`
+	Key   *datastore.Key `json:"key,omitempty"`
+	Query string         `json:"query,omitempty"`
+	// Value is the entity put, or the entities got
+	Value json.RawMessage `json:"value,omitempty"`
+	// Keys are the keys GetAll returned, or the key Put did
+	Keys  []*datastore.Key `json:"keys,omitempty"`
+	Error *CallError       `json:"error,omitempty"`
+}
+
+// CallError is an error a recorded call returned.
+type CallError struct {
+	// Code is the gRPC status code of the error, if it had one
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+func toCallError(err error) *CallError {
+	if err == nil {
+		return nil
+	}
+	if s, ok := status.FromError(err); ok {
+		return &CallError{Code: s.Code().String(), Message: s.Message()}
+	}
+	return &CallError{Message: err.Error()}
+}
+
+func (e *CallError) err() error {
+	switch {
+	case e == nil:
+		return nil
+	case e.Message == datastore.ErrNoSuchEntity.Error():
+		return datastore.ErrNoSuchEntity
+	case e.Message == datastore.ErrConcurrentTransaction.Error():
+		return datastore.ErrConcurrentTransaction
+	case e.Code != "":
+		for c := codes.OK; c <= codes.Unauthenticated; c++ {
+			if c.String() == e.Code {
+				return status.Error(c, e.Message)
+			}
+		}
+	}
+	return errors.New(e.Message)
+}
+
+// secretFields name the entity fields holding credentials: password hashes,
+// and the hashes of tokens emailed to users or handed out for the API.
+// Recordings leave them out, so they may be shared, and replays leave them
+// out when comparing puts, as the recording has none to compare with.
+var secretFields = map[string]bool{
+	"PasswordHash": true,
+	"VerifyToken":  true,
+	"ResetToken":   true,
+	"Hash":         true,
+}
+
+// Recorder writes every call to the wrapped datastore to a writer, as one
+// JSON Call per line. Entity fields holding credentials are left out.
+type Recorder struct {
+	D DatastoreWrapper
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns d, recording its calls to w.
+func NewRecorder(d DatastoreWrapper, w io.Writer) *Recorder {
+	return &Recorder{D: d, enc: json.NewEncoder(w)}
+}
+
+// Err returns the first error writing calls, after which no more are written.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) Get(ctx context.Context, k *datastore.Key, v interface{}) error {
+	err := r.D.Get(ctx, k, v)
+	c := Call{Op: opGet, Key: k, Error: toCallError(err)}
+	if err == nil {
+		c.Value = r.marshal(v)
+	}
+	r.record(c)
+	return err
+}
+
+func (r *Recorder) GetAll(ctx context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	keys, err := r.D.GetAll(ctx, q, v)
+	c := Call{Op: opGetAll, Query: describeQuery(q), Keys: keys, Error: toCallError(err)}
+	if err == nil && v != nil {
+		c.Value = r.marshal(v)
+	}
+	r.record(c)
+	return keys, err
+}
+
+func (r *Recorder) Put(ctx context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	key, err := r.D.Put(ctx, k, v)
+	c := Call{Op: opPut, Key: k, Value: r.marshal(v), Error: toCallError(err)}
+	if key != nil {
+		c.Keys = []*datastore.Key{key}
+	}
+	r.record(c)
+	return key, err
+}
+
+func (r *Recorder) Delete(ctx context.Context, k *datastore.Key) error {
+	err := r.D.Delete(ctx, k)
+	r.record(Call{Op: opDelete, Key: k, Error: toCallError(err)})
+	return err
+}
+
+func (r *Recorder) marshal(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err == nil {
+		b, err = stripFields(b, secretFields)
+	}
+	if err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = errors.Wrap(err, "failed to encode entity")
+		}
+		r.mu.Unlock()
+	}
+	return b
+}
+
+func (r *Recorder) record(c Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	if err := r.enc.Encode(c); err != nil {
+		r.err = errors.Wrap(err, "failed to record call")
+	}
+}
+
+// ErrNotRecorded is returned by a Replayer for calls it has no recording of.
+var ErrNotRecorded = errors.New("datastore call was not recorded")
+
+// Replayer answers calls with the results of recorded ones. Each recorded
+// call answers once, in the order it was made, so a session made of several
+// requests may be replayed as they interleave differently. Calls that were
+// not recorded, and puts of entities that differ from the recording, fail.
+type Replayer struct {
+	mu     sync.Mutex
+	calls  []Call
+	used   []bool
+	ignore map[string]bool
+}
+
+// NewReplayer returns a Replayer of calls.
+func NewReplayer(calls []Call) *Replayer {
+	ignore := map[string]bool{}
+	for n := range secretFields {
+		ignore[n] = true
+	}
+	return &Replayer{calls: calls, used: make([]bool, len(calls)), ignore: ignore}
+}
+
+// LoadReplayer returns a Replayer of the calls recorded in a file.
+func LoadReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open recording")
+	}
+	defer f.Close()
+	var calls []Call
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 16<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var c Call
+		if err := json.Unmarshal(s.Bytes(), &c); err != nil {
+			return nil, errors.Wrapf(err, "%s:%d: invalid call", path, line)
+		}
+		calls = append(calls, c)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read recording")
+	}
+	return NewReplayer(calls), nil
+}
+
+// IgnoreFields leaves fields with these names out when comparing the
+// entities put with the recording, such as timestamps. Fields holding
+// credentials, which are not recorded, are always left out.
+func (r *Replayer) IgnoreFields(names ...string) *Replayer {
+	for _, n := range names {
+		r.ignore[n] = true
+	}
+	return r
+}
+
+// Unused returns the recorded calls that were not replayed.
+func (r *Replayer) Unused() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Call
+	for i, c := range r.calls {
+		if !r.used[i] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (r *Replayer) Get(_ context.Context, k *datastore.Key, v interface{}) error {
+	c, err := r.next(opGet, k, "")
+	if err != nil {
+		return err
+	}
+	if len(c.Value) > 0 {
+		if err := json.Unmarshal(c.Value, v); err != nil {
+			return errors.Wrap(err, "failed to decode recorded entity")
+		}
+	}
+	return c.Error.err()
+}
+
+func (r *Replayer) GetAll(_ context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	c, err := r.next(opGetAll, nil, describeQuery(q))
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Value) > 0 && v != nil {
+		if err := json.Unmarshal(c.Value, v); err != nil {
+			return nil, errors.Wrap(err, "failed to decode recorded entities")
+		}
+	}
+	return c.Keys, c.Error.err()
+}
+
+func (r *Replayer) Put(_ context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	c, err := r.next(opPut, k, "")
+	if err != nil {
+		return nil, err
+	}
+	got, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode entity")
+	}
+	if want, got := r.normalize(c.Value), r.normalize(got); want != got {
+		return nil, errors.Errorf("put of %v differs from the recording:\n got: %s\nwant: %s", k, got, want)
+	}
+	var key *datastore.Key
+	if len(c.Keys) > 0 {
+		key = c.Keys[0]
+	}
+	return key, c.Error.err()
+}
+
+func (r *Replayer) Delete(_ context.Context, k *datastore.Key) error {
+	c, err := r.next(opDelete, k, "")
+	if err != nil {
+		return err
+	}
+	return c.Error.err()
+}
+
+// next marks the first unused recorded call matching op, k and query as used,
+// and returns it
+func (r *Replayer) next(op string, k *datastore.Key, query string) (Call, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range r.calls {
+		if !r.used[i] && c.Op == op && c.Key.Equal(k) && c.Query == query {
+			r.used[i] = true
+			return c, nil
+		}
+	}
+	what := query
+	if k != nil {
+		what = k.String()
+	}
+	return Call{}, errors.Wrapf(ErrNotRecorded, "%s %s", op, what)
+}
+
+// normalize returns the JSON b without the ignored fields, with keys in
+// order and no spaces, or b itself if it is not JSON
+func (r *Replayer) normalize(b []byte) string {
+	out, err := stripFields(b, r.ignore)
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
+
+// stripFields returns the JSON b without the object fields named in names,
+// at any depth, with keys in order and no spaces
+func stripFields(b []byte, names map[string]bool) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(strip(v, names))
+}
+
+func strip(v interface{}, names map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if names[k] {
+				delete(v, k)
+			} else {
+				v[k] = strip(e, names)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = strip(e, names)
+		}
+	}
+	return v
+}
+
+// describeQuery returns what q asks for, so recorded queries can be matched
+// with the ones replayed. Query keeps all of it unexported.
+func describeQuery(q *datastore.Query) string {
+	if q == nil {
+		return ""
+	}
+	v := reflect.ValueOf(q).Elem()
+	var parts []string
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if f := v.Field(i); name != "trans" && !f.IsZero() {
+			parts = append(parts, name+"="+describe(f))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func describe(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		return describe(v.Elem())
+	case reflect.Struct:
+		var fields []string
+		for i := 0; i < v.NumField(); i++ {
+			fields = append(fields, describe(v.Field(i)))
+		}
+		return "{" + strings.Join(fields, " ") + "}"
+	case reflect.Slice:
+		var elems []string
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, describe(v.Index(i)))
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	default:
+		return fmt.Sprint(v)
+	}
+}
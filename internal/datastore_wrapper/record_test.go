@@ -0,0 +1,174 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/mock/gomock"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	dwmock "github.com/m-okeefe/spookystore/internal/datastore_wrapper/mock"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type entity struct {
+	Name  string
+	Count int
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	var buf bytes.Buffer
+	rec := dw.NewRecorder(m, &buf)
+	ctx := context.Background()
+	k := datastore.IDKey("Thing", 1, nil)
+	missing := datastore.IDKey("Thing", 2, nil)
+	q := datastore.NewQuery("Thing").Filter("Count >", 1).Ancestor(datastore.NameKey("Shelf", "top", nil))
+
+	m.EXPECT().Get(ctx, k, &entity{}).SetArg(2, entity{Name: "bat", Count: 3}).Return(nil)
+	m.EXPECT().Get(ctx, missing, &entity{}).Return(datastore.ErrNoSuchEntity)
+	m.EXPECT().GetAll(ctx, q, &[]entity{}).SetArg(2, []entity{{Name: "bat", Count: 3}}).Return([]*datastore.Key{k}, nil)
+	m.EXPECT().Put(ctx, k, &entity{Name: "bat", Count: 4}).Return(k, nil)
+	m.EXPECT().Delete(ctx, k).Return(status.Error(codes.Unavailable, "down"))
+	rec.Get(ctx, k, &entity{})
+	rec.Get(ctx, missing, &entity{})
+	rec.GetAll(ctx, q, &[]entity{})
+	rec.Put(ctx, k, &entity{Name: "bat", Count: 4})
+	rec.Delete(ctx, k)
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []dw.Call
+	for d := json.NewDecoder(&buf); d.More(); {
+		var c dw.Call
+		if err := d.Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		calls = append(calls, c)
+	}
+	r := dw.NewReplayer(calls)
+
+	// calls of the same op needn't come in the order they were recorded
+	if err := r.Get(ctx, missing, &entity{}); err != datastore.ErrNoSuchEntity {
+		t.Errorf("expected ErrNoSuchEntity, got %v", err)
+	}
+	var e entity
+	if err := r.Get(ctx, k, &e); err != nil || e.Count != 3 {
+		t.Errorf("expected the recorded entity, got %v, %v", e, err)
+	}
+	var es []entity
+	if _, err := r.GetAll(ctx, datastore.NewQuery("Thing").Filter("Count >", 2), &es); errors.Cause(err) != dw.ErrNotRecorded {
+		t.Errorf("expected another query not to match, got %v", err)
+	}
+	keys, err := r.GetAll(ctx, datastore.NewQuery("Thing").Filter("Count >", 1).Ancestor(datastore.NameKey("Shelf", "top", nil)), &es)
+	if err != nil || len(es) != 1 || len(keys) != 1 || !keys[0].Equal(k) {
+		t.Errorf("expected the recorded query results, got %v, %v, %v", es, keys, err)
+	}
+	if _, err := r.Put(ctx, k, &entity{Name: "bat", Count: 4}); err != nil {
+		t.Error(err)
+	}
+	if err := r.Delete(ctx, k); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+	if err := r.Get(ctx, k, &e); errors.Cause(err) != dw.ErrNotRecorded {
+		t.Errorf("expected ErrNotRecorded once the recording is used up, got %v", err)
+	}
+	if u := r.Unused(); len(u) != 0 {
+		t.Errorf("expected every call to be replayed, got %v", u)
+	}
+}
+
+type account struct {
+	Email        string
+	PasswordHash []byte
+	VerifyToken  string
+	ResetToken   string
+	Tokens       []struct{ Name, Hash string }
+}
+
+func TestRecordRedactsCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := dwmock.NewMockDatastoreWrapper(ctrl)
+	var buf bytes.Buffer
+	rec := dw.NewRecorder(m, &buf)
+	ctx := context.Background()
+	k := datastore.IDKey("Account", 1, nil)
+	a := account{
+		Email:        "sam@example.com",
+		PasswordHash: []byte("hunter2-hash"),
+		VerifyToken:  "verify-secret",
+		ResetToken:   "reset-secret",
+		Tokens:       []struct{ Name, Hash string }{{"cli", "api-secret"}},
+	}
+
+	m.EXPECT().Get(ctx, k, &account{}).SetArg(2, a).Return(nil)
+	m.EXPECT().GetAll(ctx, datastore.NewQuery("Account"), &[]account{}).SetArg(2, []account{a}).Return([]*datastore.Key{k}, nil)
+	m.EXPECT().Put(ctx, k, &a).Return(k, nil)
+	rec.Get(ctx, k, &account{})
+	rec.GetAll(ctx, datastore.NewQuery("Account"), &[]account{})
+	rec.Put(ctx, k, &a)
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2-hash", "aHVudGVyMi1oYXNo", "verify-secret", "reset-secret", "api-secret",
+		"PasswordHash", "VerifyToken", "ResetToken", `"Hash"`} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %s to be left out of the recording:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "sam@example.com") {
+		t.Errorf("expected the other fields to be recorded:\n%s", out)
+	}
+
+	// puts still match their recording, whatever the credentials are
+	var calls []dw.Call
+	for d := json.NewDecoder(&buf); d.More(); {
+		var c dw.Call
+		if err := d.Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		calls = append(calls, c)
+	}
+	if _, err := dw.NewReplayer(calls).Put(ctx, k, &a); err != nil {
+		t.Errorf("expected the put to replay, got %v", err)
+	}
+}
+
+func TestReplayPuts(t *testing.T) {
+	ctx := context.Background()
+	k := datastore.IDKey("Thing", 1, nil)
+	calls := []dw.Call{{Op: "put", Key: k, Value: json.RawMessage(`{"Name": "bat", "Count": 4}`)}}
+
+	if _, err := dw.NewReplayer(calls).Put(ctx, k, &entity{Name: "bat", Count: 5}); err == nil {
+		t.Error("expected a put that differs from the recording to fail")
+	}
+	r := dw.NewReplayer(calls).IgnoreFields("Count")
+	if _, err := r.Put(ctx, k, &entity{Name: "bat", Count: 5}); err != nil {
+		t.Errorf("expected ignored fields to be left out, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore_wrapper
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/m-okeefe/spookystore/internal/metrics"
+)
+
+// Retrying retries calls to the wrapped datastore that fail with transient
+// errors, after a random wait that grows with every retry. Puts of incomplete
+// keys are not retried, since a put that failed may still have created an
+// entity.
+type Retrying struct {
+	D DatastoreWrapper
+	// Attempts is how many times a call is tried in all
+	Attempts int
+	// Backoff is the longest wait before the first retry. It doubles with
+	// every retry, up to MaxBackoff.
+	Backoff, MaxBackoff time.Duration
+}
+
+func (r *Retrying) Get(ctx context.Context, k *datastore.Key, v interface{}) error {
+	return r.do(ctx, opGet, keyKind(k), true, func() error {
+		return r.D.Get(ctx, k, v)
+	})
+}
+
+func (r *Retrying) GetAll(ctx context.Context, q *datastore.Query, v interface{}) ([]*datastore.Key, error) {
+	var keys []*datastore.Key
+	reset := truncater(v)
+	err := r.do(ctx, opGetAll, queryKind(q), true, func() error {
+		// a failed attempt may have appended some results already
+		reset()
+		var err error
+		keys, err = r.D.GetAll(ctx, q, v)
+		return err
+	})
+	return keys, err
+}
+
+// truncater returns a func that cuts the slice dst of GetAll back to its
+// current length, dropping what was appended since
+func truncater(dst interface{}) func() {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return func() {}
+	}
+	s := rv.Elem()
+	n := s.Len()
+	return func() { s.SetLen(n) }
+}
+
+// restorer returns a func that sets the entity dst of Get back to its current
+// value, undoing what was loaded into it since
+func restorer(dst interface{}) func() {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return func() {}
+	}
+	saved := reflect.New(rv.Elem().Type()).Elem()
+	saved.Set(rv.Elem())
+	return func() { rv.Elem().Set(saved) }
+}
+
+func (r *Retrying) Put(ctx context.Context, k *datastore.Key, v interface{}) (*datastore.Key, error) {
+	var key *datastore.Key
+	err := r.do(ctx, opPut, keyKind(k), k == nil || !k.Incomplete(), func() error {
+		var err error
+		key, err = r.D.Put(ctx, k, v)
+		return err
+	})
+	return key, err
+}
+
+func (r *Retrying) Delete(ctx context.Context, k *datastore.Key) error {
+	return r.do(ctx, opDelete, keyKind(k), true, func() error {
+		return r.D.Delete(ctx, k)
+	})
+}
+
+// do calls call until it succeeds, fails for good, runs out of attempts or
+// ctx is done, and returns its last error
+func (r *Retrying) do(ctx context.Context, op, kind string, idempotent bool, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if !idempotent || attempt >= r.Attempts || ctx.Err() != nil || !transient(err) {
+			return err
+		}
+		metrics.DatastoreRetried(op, kind)
+		t := time.NewTimer(r.backoff(attempt))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+	}
+}
+
+// backoff returns a random wait before retrying after the given attempt
+func (r *Retrying) backoff(attempt int) time.Duration {
+	max := r.MaxBackoff
+	if d := r.Backoff << uint(attempt-1); d > 0 && d < max {
+		max = d
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hub is an in-process publish/subscribe hub, which the spookystore
+// server uses to push changes to streaming RPCs. Messages only reach
+// subscribers in the same process.
+package hub
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrOverflow is the error of a subscription that was dropped because its
+// subscriber fell behind.
+var ErrOverflow = errors.New("hub: subscriber fell behind")
+
+// ErrClosed is the error of a subscription that ended because the hub was
+// closed.
+var ErrClosed = errors.New("hub: closed")
+
+// Hub passes messages published to a topic on to the topic's subscribers.
+// Publishing never blocks: each subscription buffers a number of messages, and
+// a subscriber that lets its buffer fill up is dropped rather than holding up
+// the publisher or missing messages silently.
+type Hub struct {
+	buffer int
+
+	mu     sync.Mutex
+	topics map[string]map[*Subscription]bool
+	closed bool
+}
+
+// New returns a Hub that buffers up to buffer messages per subscription.
+func New(buffer int) *Hub {
+	if buffer < 1 {
+		buffer = 1
+	}
+	return &Hub{buffer: buffer, topics: map[string]map[*Subscription]bool{}}
+}
+
+// Subscription receives the messages published to a topic after it was
+// created.
+type Subscription struct {
+	h     *Hub
+	topic string
+	c     chan interface{}
+	err   error // guarded by h.mu
+}
+
+// Subscribe starts receiving the messages published to topic. Subscriptions
+// to a closed hub have already ended.
+func (h *Hub) Subscribe(topic string) *Subscription {
+	s := &Subscription{h: h, topic: topic, c: make(chan interface{}, h.buffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		s.err = ErrClosed
+		close(s.c)
+		return s
+	}
+	if h.topics[topic] == nil {
+		h.topics[topic] = map[*Subscription]bool{}
+	}
+	h.topics[topic][s] = true
+	return s
+}
+
+// Publish sends msg to the subscribers of topic, dropping the subscriptions
+// whose buffer is full.
+func (h *Hub) Publish(topic string, msg interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.topics[topic] {
+		select {
+		case s.c <- msg:
+		default:
+			s.err = ErrOverflow
+			h.remove(s)
+		}
+	}
+}
+
+// Close ends every subscription with ErrClosed, so their subscribers can
+// finish up. Messages published afterwards go nowhere.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subs := range h.topics {
+		for s := range subs {
+			s.err = ErrClosed
+			h.remove(s)
+		}
+	}
+}
+
+// Subscribers returns the number of subscriptions to topic.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.topics[topic])
+}
+
+// remove closes and forgets a subscription; h.mu must be held
+func (h *Hub) remove(s *Subscription) {
+	subs := h.topics[s.topic]
+	if !subs[s] {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.topics, s.topic)
+	}
+	close(s.c)
+}
+
+// C returns the channel messages are delivered on. It is closed when the
+// subscription ends.
+func (s *Subscription) C() <-chan interface{} { return s.c }
+
+// Err returns why the subscription ended: ErrOverflow if it was dropped,
+// ErrClosed if the hub was closed, or nil.
+func (s *Subscription) Err() error {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	s.h.remove(s)
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hub
+
+import "testing"
+
+func TestPublish(t *testing.T) {
+	h := New(4)
+	a, b := h.Subscribe("cart/1"), h.Subscribe("cart/1")
+	other := h.Subscribe("cart/2")
+	h.Publish("cart/1", "boo")
+	h.Publish("products", "ignored")
+
+	for _, s := range []*Subscription{a, b} {
+		if got := <-s.C(); got != "boo" {
+			t.Errorf("expected boo, got %v", got)
+		}
+	}
+	select {
+	case m := <-other.C():
+		t.Errorf("expected nothing on another topic, got %v", m)
+	default:
+	}
+	if n := h.Subscribers("cart/1"); n != 2 {
+		t.Errorf("expected 2 subscribers, got %d", n)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	h := New(2)
+	slow, fast := h.Subscribe("products"), h.Subscribe("products")
+	for i := 0; i < 3; i++ {
+		h.Publish("products", i)
+		<-fast.C()
+	}
+
+	var got []interface{}
+	for m := range slow.C() {
+		got = append(got, m)
+	}
+	if len(got) != 2 || slow.Err() != ErrOverflow {
+		t.Errorf("expected 2 messages then ErrOverflow, got %v, %v", got, slow.Err())
+	}
+	if fast.Err() != nil || h.Subscribers("products") != 1 {
+		t.Errorf("expected the fast subscriber to stay subscribed")
+	}
+}
+
+func TestClose(t *testing.T) {
+	h := New(1)
+	s := h.Subscribe("products")
+	s.Close()
+	s.Close()
+	h.Publish("products", "boo")
+	if _, ok := <-s.C(); ok {
+		t.Error("expected the channel to be closed")
+	}
+	if s.Err() != nil || h.Subscribers("products") != 0 {
+		t.Errorf("expected a clean unsubscribe, got %v", s.Err())
+	}
+}
+
+func TestCloseHub(t *testing.T) {
+	h := New(1)
+	before := h.Subscribe("products")
+	h.Close()
+	after := h.Subscribe("cart/1")
+	for _, s := range []*Subscription{before, after} {
+		if _, ok := <-s.C(); ok || s.Err() != ErrClosed {
+			t.Errorf("expected the subscription to end with ErrClosed, got %v", s.Err())
+		}
+		s.Close()
+	}
+	h.Publish("products", "boo")
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// GitHub logs users in with GitHub, which speaks OAuth2 but not OpenID
+// Connect, so users are looked up with the REST API instead of an ID token.
+type GitHub struct {
+	name   string
+	cfg    oauth2.Config
+	apiURL string
+}
+
+// NewGitHub returns a GitHub provider, named "github" unless c says otherwise.
+func NewGitHub(c Config) *GitHub {
+	name := c.Name
+	if name == "" {
+		name = "github"
+	}
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+	return &GitHub{
+		name: name,
+		cfg: oauth2.Config{
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  "https://github.com/login/oauth/authorize",
+				TokenURL: "https://github.com/login/oauth/access_token",
+			},
+			Scopes: scopes,
+		},
+		apiURL: "https://api.github.com",
+	}
+}
+
+func (g *GitHub) Name() string { return g.name }
+
+func (g *GitHub) OAuth2Config() *oauth2.Config {
+	cfg := g.cfg
+	return &cfg
+}
+
+// Identity looks up the token's user and their primary email. The nonce is
+// not used, as GitHub does not issue ID tokens.
+func (g *GitHub) Identity(ctx context.Context, tok *oauth2.Token, nonce string) (*Identity, error) {
+	var u struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := getJSON(ctx, g.apiURL+"/user", tok, &u); err != nil {
+		return nil, errors.Wrap(err, "failed to get github user")
+	}
+	if u.ID == 0 {
+		return nil, errors.New("github user has no id")
+	}
+	id := &Identity{
+		Provider: g.name,
+		Subject:  strconv.FormatInt(u.ID, 10),
+		Name:     u.Name,
+		Picture:  u.AvatarURL,
+	}
+	if id.Name == "" {
+		id.Name = u.Login
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, g.apiURL+"/user/emails", tok, &emails); err != nil {
+		return nil, errors.Wrap(err, "failed to get github user emails")
+	}
+	for _, e := range emails {
+		if e.Primary {
+			id.Email, id.EmailVerified = e.Email, e.Verified
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package identity logs users in with external identity providers: any
+// OpenID Connect issuer, and GitHub.
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// Identity is a user as known to an identity provider.
+type Identity struct {
+	Provider      string
+	Subject       string // stable user ID at the provider
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// Provider is an identity provider users can log in with.
+type Provider interface {
+	// Name identifies the provider in login URLs and linked accounts.
+	Name() string
+
+	// OAuth2Config returns a copy of the provider's oauth2 client config,
+	// without a RedirectURL.
+	OAuth2Config() *oauth2.Config
+
+	// Identity returns the user a token was issued to. nonce is the value
+	// sent as the nonce parameter of the authorization request.
+	Identity(ctx context.Context, tok *oauth2.Token, nonce string) (*Identity, error)
+}
+
+// Config configures a Provider.
+type Config struct {
+	Name string `json:"name"`
+
+	// Type is "oidc" (the default) or "github".
+	Type string `json:"type"`
+
+	// Issuer is the OpenID Connect issuer URL, e.g. https://accounts.google.com
+	Issuer string `json:"issuer"`
+
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+
+	// Scopes replaces the default scopes of the provider type.
+	Scopes []string `json:"scopes"`
+}
+
+// LoadConfig reads a JSON list of provider configs.
+func LoadConfig(path string) ([]Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read identity provider config")
+	}
+	var c []Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errors.Wrap(err, "failed to parse identity provider config")
+	}
+	return c, nil
+}
+
+// New returns the Provider described by c. OpenID Connect providers are
+// discovered from their issuer, so this makes network requests.
+func New(ctx context.Context, c Config) (Provider, error) {
+	if c.ClientID == "" {
+		return nil, errors.Errorf("identity provider %q: missing client_id", c.Name)
+	}
+	switch c.Type {
+	case "", "oidc":
+		return NewOIDC(ctx, c)
+	case "github":
+		return NewGitHub(c), nil
+	default:
+		return nil, errors.Errorf("identity provider %q: unknown type %q", c.Name, c.Type)
+	}
+}
+
+// getJSON fetches url into v, authenticating with tok if it is not nil.
+func getJSON(ctx context.Context, url string, tok *oauth2.Token, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if tok != nil {
+		tok.SetAuthHeader(req)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to get %s: %s", url, resp.Status)
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(v), "failed to decode %s", url)
+}
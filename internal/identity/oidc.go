@@ -0,0 +1,312 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jws"
+)
+
+const (
+	// clockSkew is how far ID token expiry may be off from our clock
+	clockSkew = time.Minute
+
+	// minKeyRefresh limits how often unknown key IDs make us refetch the JWKS
+	minKeyRefresh = time.Minute
+)
+
+// OIDC is an OpenID Connect provider. Users are identified by the ID token
+// the provider returns with the access token; the userinfo endpoint fills in
+// profile claims the ID token leaves out.
+type OIDC struct {
+	name     string
+	issuer   string
+	cfg      oauth2.Config
+	userinfo string
+	jwks     string
+	now      func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	keysFetch time.Time
+}
+
+// discovery is the subset of the OpenID provider metadata we use
+type discovery struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	UserInfo string `json:"userinfo_endpoint"`
+	JWKS     string `json:"jwks_uri"`
+}
+
+// NewOIDC discovers the OpenID Connect provider at c.Issuer.
+func NewOIDC(ctx context.Context, c Config) (*OIDC, error) {
+	if c.Name == "" || c.Issuer == "" {
+		return nil, errors.New("oidc provider needs a name and an issuer")
+	}
+	var d discovery
+	if err := getJSON(ctx, strings.TrimSuffix(c.Issuer, "/")+"/.well-known/openid-configuration", nil, &d); err != nil {
+		return nil, errors.Wrapf(err, "failed to discover %s", c.Issuer)
+	}
+	if d.Issuer != c.Issuer {
+		return nil, errors.Errorf("issuer %q does not match discovered issuer %q", c.Issuer, d.Issuer)
+	}
+	if d.AuthURL == "" || d.TokenURL == "" || d.JWKS == "" {
+		return nil, errors.Errorf("incomplete discovery document for %s", c.Issuer)
+	}
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDC{
+		name:   c.Name,
+		issuer: d.Issuer,
+		cfg: oauth2.Config{
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			Endpoint:     oauth2.Endpoint{AuthURL: d.AuthURL, TokenURL: d.TokenURL},
+			Scopes:       scopes,
+		},
+		userinfo: d.UserInfo,
+		jwks:     d.JWKS,
+		now:      time.Now,
+	}, nil
+}
+
+func (o *OIDC) Name() string { return o.name }
+
+func (o *OIDC) OAuth2Config() *oauth2.Config {
+	cfg := o.cfg
+	return &cfg
+}
+
+// Identity verifies the ID token in tok and returns the user it identifies.
+func (o *OIDC) Identity(ctx context.Context, tok *oauth2.Token, nonce string) (*Identity, error) {
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	c, err := o.verify(ctx, raw, nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid id token")
+	}
+
+	if (c.Email == "" || c.Name == "" || c.Picture == "") && o.userinfo != "" {
+		var u claims
+		if err := getJSON(ctx, o.userinfo, tok, &u); err != nil {
+			return nil, errors.Wrap(err, "failed to get userinfo")
+		}
+		if u.Subject != c.Subject {
+			return nil, errors.New("userinfo is for a different user than the id token")
+		}
+		if c.Email == "" {
+			c.Email, c.EmailVerified = u.Email, u.EmailVerified
+		}
+		if c.Name == "" {
+			c.Name = u.Name
+		}
+		if c.Picture == "" {
+			c.Picture = u.Picture
+		}
+	}
+
+	return &Identity{
+		Provider:      o.name,
+		Subject:       c.Subject,
+		Email:         c.Email,
+		EmailVerified: bool(c.EmailVerified),
+		Name:          c.Name,
+		Picture:       c.Picture,
+	}, nil
+}
+
+// claims are the ID token and userinfo claims we use
+type claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	AuthorizedBy  string   `json:"azp"`
+	Expiry        int64    `json:"exp"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	Picture       string   `json:"picture"`
+}
+
+// audience is a JWT aud claim, which is either a string or a list of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*a = l
+	return nil
+}
+
+// flexBool accepts both true and "true", as some providers send the latter
+type flexBool bool
+
+func (f *flexBool) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*f = flexBool(v)
+	case string:
+		*f = v == "true"
+	}
+	return nil
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of an ID
+// token and returns its claims.
+func (o *OIDC) verify(ctx context.Context, raw, nonce string) (*claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed header")
+	}
+	var h jws.Header
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, errors.Wrap(err, "malformed header")
+	}
+	if h.Algorithm != "RS256" {
+		return nil, errors.Errorf("unsupported signing algorithm %q", h.Algorithm)
+	}
+	key, err := o.key(ctx, h.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := jws.Verify(raw, key); err != nil {
+		return nil, errors.Wrap(err, "bad signature")
+	}
+
+	b, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed payload")
+	}
+	var c claims
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errors.Wrap(err, "malformed payload")
+	}
+	if c.Issuer != o.issuer {
+		return nil, errors.Errorf("issued by %q, want %q", c.Issuer, o.issuer)
+	}
+	if !c.Audience.contains(o.cfg.ClientID) {
+		return nil, errors.New("not issued to this client")
+	}
+	if len(c.Audience) > 1 && c.AuthorizedBy != o.cfg.ClientID {
+		return nil, errors.New("not authorized for this client")
+	}
+	if o.now().Add(-clockSkew).Unix() > c.Expiry {
+		return nil, errors.New("expired")
+	}
+	if c.Nonce != nonce {
+		return nil, errors.New("wrong nonce")
+	}
+	if c.Subject == "" {
+		return nil, errors.New("no subject")
+	}
+	return &c, nil
+}
+
+func (a audience) contains(v string) bool {
+	for _, s := range a {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the provider's signing key with the given ID, refetching the
+// key set if the key is not known, as providers rotate their keys.
+func (o *OIDC) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if k, ok := o.keys[kid]; ok {
+		return k, nil
+	}
+	if o.now().Sub(o.keysFetch) < minKeyRefresh {
+		return nil, errors.Errorf("unknown signing key %q", kid)
+	}
+	keys, err := fetchKeys(ctx, o.jwks)
+	if err != nil {
+		return nil, err
+	}
+	o.keys, o.keysFetch = keys, o.now()
+	if k, ok := o.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, errors.Errorf("unknown signing key %q", kid)
+}
+
+// fetchKeys returns the RSA keys in a JSON Web Key Set, by key ID
+func fetchKeys(ctx context.Context, url string) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, url, nil, &set); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch signing keys")
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad modulus in key %q", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad exponent in key %q", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
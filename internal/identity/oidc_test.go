@@ -0,0 +1,173 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// testIssuer is a fake OpenID Connect provider
+type testIssuer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	userinfo map[string]interface{}
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 ti.URL,
+			"authorization_endpoint": ti.URL + "/auth",
+			"token_endpoint":         ti.URL + "/token",
+			"userinfo_endpoint":      ti.URL + "/userinfo",
+			"jwks_uri":               ti.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(ti.userinfo)
+	})
+	ti.Server = httptest.NewServer(mux)
+	return ti
+}
+
+// token returns a token response carrying an ID token with the given claims
+func (ti *testIssuer) token(t *testing.T, key *rsa.PrivateKey, c map[string]interface{}) *oauth2.Token {
+	enc := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := enc(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + enc(c)
+	h := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok := &oauth2.Token{AccessToken: "access"}
+	return tok.WithExtra(map[string]interface{}{
+		"id_token": signed + "." + base64.RawURLEncoding.EncodeToString(sig),
+	})
+}
+
+func TestOIDCIdentity(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	ctx := context.Background()
+
+	p, err := New(ctx, Config{Name: "test", Issuer: ti.URL, ClientID: "spooky"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := p.(*OIDC)
+	now := time.Unix(1500000000, 0)
+	o.now = func() time.Time { return now }
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":            ti.URL,
+			"sub":            "42",
+			"aud":            "spooky",
+			"exp":            now.Add(time.Hour).Unix(),
+			"nonce":          "n0nce",
+			"email":          "sam@example.com",
+			"email_verified": "true",
+			"name":           "Sam",
+			"picture":        "https://example.com/sam.png",
+		}
+	}
+
+	id, err := o.Identity(ctx, ti.token(t, ti.key, valid()), "n0nce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Provider: "test", Subject: "42", Email: "sam@example.com", EmailVerified: true, Name: "Sam", Picture: "https://example.com/sam.png"}
+	if *id != want {
+		t.Errorf("got %+v, want %+v", *id, want)
+	}
+
+	// missing profile claims come from userinfo
+	c := valid()
+	delete(c, "email")
+	delete(c, "picture")
+	ti.userinfo = map[string]interface{}{"sub": "42", "email": "sam@example.com", "email_verified": true, "picture": "p.png"}
+	if id, err := o.Identity(ctx, ti.token(t, ti.key, c), "n0nce"); err != nil {
+		t.Error(err)
+	} else if id.Email != "sam@example.com" || !id.EmailVerified || id.Picture != "p.png" {
+		t.Errorf("expected userinfo claims to be used, got %+v", id)
+	}
+	ti.userinfo["sub"] = "43"
+	if _, err := o.Identity(ctx, ti.token(t, ti.key, c), "n0nce"); err == nil {
+		t.Errorf("expected userinfo for another user to be rejected")
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := map[string]func(map[string]interface{}){
+		"wrong issuer":   func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"wrong audience": func(c map[string]interface{}) { c["aud"] = []string{"other"} },
+		"no azp":         func(c map[string]interface{}) { c["aud"] = []string{"spooky", "other"} },
+		"expired":        func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"wrong nonce":    func(c map[string]interface{}) { c["nonce"] = "replayed" },
+		"no subject":     func(c map[string]interface{}) { delete(c, "sub") },
+	}
+	for name, f := range bad {
+		c := valid()
+		f(c)
+		if _, err := o.Identity(ctx, ti.token(t, ti.key, c), "n0nce"); err == nil {
+			t.Errorf("%s: expected id token to be rejected", name)
+		}
+	}
+	if _, err := o.Identity(ctx, ti.token(t, other, valid()), "n0nce"); err == nil {
+		t.Errorf("expected id token signed with another key to be rejected")
+	}
+	if _, err := o.Identity(ctx, &oauth2.Token{AccessToken: "access"}, "n0nce"); err == nil {
+		t.Errorf("expected token without id_token to be rejected")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics defines the Prometheus metrics of both services, and the
+// interceptors that record them. Labels only ever take values from small
+// fixed sets, such as method names, route templates and status codes, so
+// requests can't blow up the number of series.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	serverHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "gRPC calls handled, by method and status code.",
+	}, []string{"method", "code"})
+	serverDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "grpc_server_handling_seconds",
+		Help: "Time taken to handle gRPC calls, by method.",
+	}, []string{"method"})
+
+	clientHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_client_handled_total",
+		Help: "gRPC calls made, by method and status code.",
+	}, []string{"method", "code"})
+	clientDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "grpc_client_handling_seconds",
+		Help: "Time taken by gRPC calls made, by method.",
+	}, []string{"method"})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "http_request_duration_seconds",
+		Help: "Time taken to serve HTTP requests, by route and method.",
+	}, []string{"route", "method"})
+
+	datastoreDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "datastore_call_duration_seconds",
+		Help: "Time taken by datastore calls, by operation, kind and result.",
+	}, []string{"op", "kind", "result"})
+	datastoreRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "datastore_retries_total",
+		Help: "Datastore calls retried after a transient error, by operation and kind.",
+	}, []string{"op", "kind"})
+	datastoreBreakerOpened = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "datastore_breaker_opened_total",
+		Help: "Times the datastore circuit breaker opened.",
+	})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Cache lookups, by cache and result: hit, miss or error.",
+	}, []string{"cache", "result"})
+
+	// CartsCreated counts carts that got their first item.
+	CartsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "spookystore_carts_created_total",
+		Help: "Carts that got their first item.",
+	})
+	// ItemsAdded counts the items added to carts, by quantity.
+	ItemsAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "spookystore_cart_items_added_total",
+		Help: "Items added to carts, counting each unit.",
+	})
+	// Checkouts counts completed checkouts.
+	Checkouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "spookystore_checkouts_total",
+		Help: "Completed checkouts.",
+	})
+	// Revenue adds up the total cost of the carts checked out.
+	Revenue = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "spookystore_revenue_total",
+		Help: "Total cost of the carts checked out.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		serverHandled, serverDuration,
+		clientHandled, clientDuration,
+		httpRequests, httpDuration,
+		datastoreDuration, datastoreRetries, datastoreBreakerOpened,
+		cacheLookups,
+		CartsCreated, ItemsAdded, Checkouts, Revenue,
+	)
+}
+
+// Handler serves the metrics to Prometheus on /metrics.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return mux
+}
+
+// UnaryServerInterceptor records the rate, errors and duration of calls.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeCall(serverHandled, serverDuration, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records streams like UnaryServerInterceptor does
+// calls, once they end.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeCall(serverHandled, serverDuration, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor records the rate, errors and duration of the calls
+// a client makes.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		observeCall(clientHandled, clientDuration, method, start, err)
+		return err
+	}
+}
+
+func observeCall(handled *prometheus.CounterVec, duration *prometheus.HistogramVec, method string, start time.Time, err error) {
+	handled.WithLabelValues(method, status.Code(err).String()).Inc()
+	duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveHTTP records a request served by route, the template the request
+// matched rather than its path.
+func ObserveHTTP(route, method string, code int, elapsed time.Duration) {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		method = "other"
+	}
+	httpRequests.WithLabelValues(route, method, codeLabel(code)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(elapsed.Seconds())
+}
+
+func codeLabel(code int) string {
+	if code < 100 || code > 599 {
+		return "other"
+	}
+	return strconv.Itoa(code)
+}
+
+// ObserveDatastore records a datastore call on entities of kind. result is
+// "ok", "not_found" or "error".
+func ObserveDatastore(op, kind, result string, elapsed time.Duration) {
+	datastoreDuration.WithLabelValues(op, kind, result).Observe(elapsed.Seconds())
+}
+
+// DatastoreRetried records the retry of a datastore call.
+func DatastoreRetried(op, kind string) {
+	datastoreRetries.WithLabelValues(op, kind).Inc()
+}
+
+// DatastoreBreakerOpened records the datastore circuit breaker opening.
+func DatastoreBreakerOpened() {
+	datastoreBreakerOpened.Inc()
+}
+
+// ObserveCache records a lookup in cache. result is "hit", "miss" or "error".
+func ObserveCache(cache, result string) {
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/SpookyStore/GetUser"}
+	ic := UnaryServerInterceptor()
+	ic(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	ic(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "no such user")
+	})
+
+	if n := testutil.ToFloat64(serverHandled.WithLabelValues(info.FullMethod, "OK")); n != 1 {
+		t.Errorf("expected 1 OK call, got %v", n)
+	}
+	if n := testutil.ToFloat64(serverHandled.WithLabelValues(info.FullMethod, "NotFound")); n != 1 {
+		t.Errorf("expected 1 NotFound call, got %v", n)
+	}
+}
+
+func TestObserveHTTP(t *testing.T) {
+	ObserveHTTP("/u/{id:[0-9]+}", "BREW", 418, time.Millisecond)
+	ObserveHTTP("/u/{id:[0-9]+}", http.MethodGet, 1000, time.Millisecond)
+	if n := testutil.ToFloat64(httpRequests.WithLabelValues("/u/{id:[0-9]+}", "other", "418")); n != 1 {
+		t.Errorf("expected unknown methods to be labelled other, got %v", n)
+	}
+	if n := testutil.ToFloat64(httpRequests.WithLabelValues("/u/{id:[0-9]+}", http.MethodGet, "other")); n != 1 {
+		t.Errorf("expected invalid codes to be labelled other, got %v", n)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	Checkouts.Inc()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "spookystore_checkouts_total") {
+		t.Errorf("expected the metrics, got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/m-okeefe/spookystore/internal/auth"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the gRPC metadata key and HTTP header carrying the ID that
+// ties together the log lines of one request across services.
+const RequestIDKey = "x-request-id"
+
+// healthMethodPrefix is the method name prefix of the gRPC health service
+const healthMethodPrefix = "/grpc.health.v1.Health/"
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a context carrying request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID ctx carries, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Logger returns the logger of the call ctx belongs to, which is fallback with
+// the method and request ID added, or fallback outside of a call.
+func Logger(ctx context.Context, fallback *logrus.Entry) *logrus.Entry {
+	if l, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return l
+	}
+	return fallback
+}
+
+// incomingRequestID returns the request ID sent by the caller, or a new one
+func incomingRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(RequestIDKey); len(v) > 0 && v[0] != "" && len(v[0]) <= 64 {
+		return v[0]
+	}
+	return NewRequestID()
+}
+
+// AccessLog logs every call with its method, caller, status code and how long
+// it took. Handlers can log with the same fields through Logger. The request
+// ID is taken from the caller's metadata, or made up, and sent back as a
+// header.
+func AccessLog(log *logrus.Entry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, l := startCall(ctx, log, info.FullMethod)
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, RequestID(ctx)))
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		endCall(l, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamAccessLog is AccessLog for streaming calls, which are logged when
+// they end.
+func StreamAccessLog(log *logrus.Entry) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, l := startCall(ss.Context(), log, info.FullMethod)
+		ss.SetHeader(metadata.Pairs(RequestIDKey, RequestID(ctx)))
+		start := time.Now()
+		err := handler(srv, WithStreamContext(ss, ctx))
+		endCall(l, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// startCall returns the context and logger of an incoming call
+func startCall(ctx context.Context, log *logrus.Entry, method string) (context.Context, *logrus.Entry) {
+	id := incomingRequestID(ctx)
+	l := log.WithFields(logrus.Fields{
+		"grpc.method": method,
+		"request.id":  id,
+	})
+	if uid, ok := auth.UserIDFromContext(ctx); ok {
+		l = l.WithField("user.id", uid)
+	} else if _, ok := auth.TokenFromContext(ctx); ok {
+		l = l.WithField("auth", "token")
+	}
+	return context.WithValue(WithRequestID(ctx, id), loggerKey{}, l), l
+}
+
+// endCall logs the outcome of a call. Health checks, which come every few
+// seconds, are only logged at debug level.
+func endCall(l *logrus.Entry, method string, start time.Time, err error) {
+	code := status.Code(err)
+	e := l.WithFields(logrus.Fields{
+		"grpc.code": code.String(),
+		"elapsed":   time.Since(start).String(),
+	})
+	if serverFault(code) {
+		e.WithField("error", err).Warn("call failed")
+	} else if strings.HasPrefix(method, healthMethodPrefix) {
+		e.Debug("call completed")
+	} else {
+		e.Info("call completed")
+	}
+}
+
+// serverFault reports whether code c means the server, not the caller, is at
+// fault
+func serverFault(c codes.Code) bool {
+	switch c {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+// ClientRequestID sends the request ID of the context to the server, so the
+// server's logs can be matched up with the client's.
+func ClientRequestID() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// ClientStreamRequestID is ClientRequestID for streaming calls.
+func ClientStreamRequestID() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// ClientLog logs every outgoing call with its method, status code and how long
+// it took, at debug level, or as a warning if the server was at fault.
+func ClientLog(log *logrus.Entry) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		code := status.Code(err)
+		e := log.WithFields(logrus.Fields{
+			"grpc.method": method,
+			"grpc.code":   code.String(),
+			"request.id":  RequestID(ctx),
+			"elapsed":     time.Since(start).String(),
+		})
+		if serverFault(code) {
+			e.WithField("error", err).Warn("backend call failed")
+		} else {
+			e.Debug("backend call completed")
+		}
+		return err
+	}
+}
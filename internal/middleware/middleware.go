@@ -0,0 +1,94 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package middleware holds the gRPC interceptors shared by the spookystore
+// server and the web tier's client connection: panic recovery, access logging
+// with request IDs, and default deadlines.
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ChainUnaryServer runs interceptors in order, the first one outermost.
+func ChainUnaryServer(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, h := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return ic(ctx, req, info, h)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// ChainUnaryClient runs interceptors in order, the first one outermost.
+func ChainUnaryClient(interceptors ...grpc.UnaryClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		next := invoker
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, inv := interceptors[i], next
+			next = func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return ic(ctx, method, req, reply, cc, inv, opts...)
+			}
+		}
+		return next(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// ChainStreamServer runs interceptors in order, the first one outermost.
+func ChainStreamServer(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, h := interceptors[i], next
+			next = func(srv interface{}, ss grpc.ServerStream) error {
+				return ic(srv, ss, info, h)
+			}
+		}
+		return next(srv, ss)
+	}
+}
+
+// ChainStreamClient runs interceptors in order, the first one outermost.
+func ChainStreamClient(interceptors ...grpc.StreamClientInterceptor) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		next := streamer
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, st := interceptors[i], next
+			next = func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return ic(ctx, desc, cc, method, st, opts...)
+			}
+		}
+		return next(ctx, desc, cc, method, opts...)
+	}
+}
+
+// serverStream is a grpc.ServerStream with a replaced context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// WithStreamContext returns ss with its context replaced by ctx, for stream
+// interceptors that add values to the context.
+func WithStreamContext(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	return &serverStream{ServerStream: ss, ctx: ctx}
+}
@@ -0,0 +1,272 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-okeefe/spookystore/internal/auth"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var info = &grpc.UnaryServerInfo{FullMethod: "/SpookyStore/GetUser"}
+
+// testLog returns a logger writing JSON lines to buf
+func testLog(buf *bytes.Buffer) *logrus.Entry {
+	l := logrus.New()
+	l.Out = buf
+	l.Formatter = &logrus.JSONFormatter{}
+	l.Level = logrus.DebugLevel
+	return logrus.NewEntry(l)
+}
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &v); err != nil {
+			t.Fatalf("invalid log line %q", l)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func TestChainUnaryServer(t *testing.T) {
+	var order []string
+	ic := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			order = append(order, name)
+			return handler(ctx, req)
+		}
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		order = append(order, "handler")
+		return req, nil
+	}
+	resp, err := ChainUnaryServer(ic("a"), ic("b"))(context.Background(), "req", info, handler)
+	if err != nil || resp != "req" {
+		t.Errorf("got %v, %v", resp, err)
+	}
+	if want := []string{"a", "b", "handler"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("ran %v, want %v", order, want)
+	}
+}
+
+func TestChainUnaryClient(t *testing.T) {
+	var order []string
+	ic := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			order = append(order, name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		order = append(order, "invoker")
+		return nil
+	}
+	if err := ChainUnaryClient(ic("a"), ic("b"))(context.Background(), "/SpookyStore/GetUser", nil, nil, nil, invoker); err != nil {
+		t.Error(err)
+	}
+	if want := []string{"a", "b", "invoker"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("ran %v, want %v", order, want)
+	}
+}
+
+// fakeStream is a server stream that only has a context
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (f *fakeStream) Context() context.Context    { return f.ctx }
+func (f *fakeStream) SetHeader(metadata.MD) error { return nil }
+
+var streamInfo = &grpc.StreamServerInfo{FullMethod: "/SpookyStore/WatchCart", IsServerStream: true}
+
+func TestChainStreamServer(t *testing.T) {
+	type key struct{}
+	var order []string
+	ic := func(name string) grpc.StreamServerInterceptor {
+		return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			order = append(order, name)
+			return handler(srv, WithStreamContext(ss, context.WithValue(ss.Context(), key{}, name)))
+		}
+	}
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		order = append(order, "handler")
+		if v := ss.Context().Value(key{}); v != "b" {
+			t.Errorf("expected the innermost context, got %v", v)
+		}
+		return nil
+	}
+	if err := ChainStreamServer(ic("a"), ic("b"))(nil, &fakeStream{ctx: context.Background()}, streamInfo, handler); err != nil {
+		t.Error(err)
+	}
+	if want := []string{"a", "b", "handler"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("ran %v, want %v", order, want)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		var m map[string]int
+		m["boo"]++
+		return nil, nil
+	}
+	_, err := Recovery(testLog(&buf))(context.Background(), nil, info, handler)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected Internal, got %v", err)
+	}
+	if l := lines(t, &buf); len(l) != 1 || l[0]["grpc.method"] != info.FullMethod || !strings.Contains(l[0]["stack"].(string), "TestRecovery") {
+		t.Errorf("expected the panic to be logged with its stack, got %v", l)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	var got string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		got = RequestID(ctx)
+		Logger(ctx, nil).Info("in handler")
+		return nil, status.Error(codes.NotFound, "user 5 not found")
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "abc123", auth.MetadataKey, "5"))
+	AccessLog(testLog(&buf))(ctx, nil, info, handler)
+	if got != "abc123" {
+		t.Errorf("expected the caller's request ID, got %q", got)
+	}
+	l := lines(t, &buf)
+	if len(l) != 2 {
+		t.Fatalf("expected 2 log lines, got %v", l)
+	}
+	for _, e := range l {
+		if e["request.id"] != "abc123" || e["grpc.method"] != info.FullMethod || e["user.id"] != "5" {
+			t.Errorf("expected request fields on every line, got %v", e)
+		}
+	}
+	if l[1]["grpc.code"] != "NotFound" || l[1]["elapsed"] == nil || l[1]["level"] != "info" {
+		t.Errorf("expected the access log line, got %v", l[1])
+	}
+
+	buf.Reset()
+	handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+		got = RequestID(ctx)
+		return nil, status.Error(codes.Unavailable, "datastore down")
+	}
+	AccessLog(testLog(&buf))(context.Background(), nil, info, handler)
+	if len(got) != 16 {
+		t.Errorf("expected a new request ID, got %q", got)
+	}
+	if l := lines(t, &buf); l[0]["level"] != "warning" {
+		t.Errorf("expected server faults to be logged as warnings, got %v", l)
+	}
+}
+
+func TestAccessLogHealthChecks(t *testing.T) {
+	var buf bytes.Buffer
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	AccessLog(testLog(&buf))(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	if l := lines(t, &buf); l[0]["level"] != "debug" {
+		t.Errorf("expected health checks to be logged at debug level, got %v", l)
+	}
+}
+
+func TestStreamAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		if RequestID(ss.Context()) != "abc123" {
+			t.Errorf("expected the caller's request ID, got %q", RequestID(ss.Context()))
+		}
+		panic("boo")
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "abc123"))
+	err := ChainStreamServer(StreamAccessLog(testLog(&buf)), StreamRecovery(testLog(&buf)))(nil, &fakeStream{ctx: ctx}, streamInfo, handler)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected Internal, got %v", err)
+	}
+	l := lines(t, &buf)
+	if len(l) != 2 || l[0]["msg"] != "recovered from panic" || l[1]["grpc.code"] != "Internal" {
+		t.Fatalf("expected the panic and the failed call to be logged, got %v", l)
+	}
+	for _, e := range l {
+		if e["request.id"] != "abc123" || e["grpc.method"] != streamInfo.FullMethod {
+			t.Errorf("expected request fields on every line, got %v", e)
+		}
+	}
+}
+
+func TestClientRequestID(t *testing.T) {
+	var got []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		got = md.Get(RequestIDKey)
+		return nil
+	}
+	ClientRequestID()(WithRequestID(context.Background(), "abc123"), "/SpookyStore/GetUser", nil, nil, nil, invoker)
+	if len(got) != 1 || got[0] != "abc123" {
+		t.Errorf("expected the request ID to be sent, got %v", got)
+	}
+	ClientRequestID()(context.Background(), "/SpookyStore/GetUser", nil, nil, nil, invoker)
+	if len(got) != 0 {
+		t.Errorf("expected no request ID without one in the context, got %v", got)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	var left time.Duration
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		d, ok := ctx.Deadline()
+		if !ok {
+			t.Fatal("expected a deadline")
+		}
+		left = time.Until(d)
+		return nil, nil
+	}
+	Deadline(time.Minute)(context.Background(), nil, info, handler)
+	if left <= 50*time.Second || left > time.Minute {
+		t.Errorf("expected the default deadline, got %s left", left)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	Deadline(time.Minute)(ctx, nil, info, handler)
+	if left <= time.Minute {
+		t.Errorf("expected the caller's deadline to be kept, got %s left", left)
+	}
+}
+
+func TestClientDeadline(t *testing.T) {
+	var ok bool
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		_, ok = ctx.Deadline()
+		return nil
+	}
+	ClientDeadline(time.Second)(context.Background(), "/SpookyStore/GetUser", nil, nil, nil, invoker)
+	if !ok {
+		t.Error("expected outgoing calls to get a deadline")
+	}
+}
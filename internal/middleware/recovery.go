@@ -0,0 +1,55 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recovery turns a panicking handler into an Internal error, logging the
+// panic and its stack, so one bad request can't take the server down.
+func Recovery(log *logrus.Entry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer recoverPanic(ctx, log, info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecovery is Recovery for streaming calls.
+func StreamRecovery(log *logrus.Entry) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverPanic(ss.Context(), log, info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+// recoverPanic must be deferred; it logs a panic and sets *err to Internal
+func recoverPanic(ctx context.Context, log *logrus.Entry, method string, err *error) {
+	if p := recover(); p != nil {
+		Logger(ctx, log).WithFields(logrus.Fields{
+			"grpc.method": method,
+			"panic":       fmt.Sprint(p),
+			"stack":       string(debug.Stack()),
+		}).Error("recovered from panic")
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
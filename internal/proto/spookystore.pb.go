@@ -6,6 +6,7 @@ package spookystore
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import _ "google.golang.org/genproto/googleapis/api/annotations"
 import timestamp "github.com/golang/protobuf/ptypes/timestamp"
 
 import (
@@ -24,6 +25,31 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type ProductEvent_EventType int32
+
+const (
+	ProductEvent_UNKNOWN ProductEvent_EventType = 0
+	ProductEvent_CREATED ProductEvent_EventType = 1
+)
+
+var ProductEvent_EventType_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "CREATED",
+}
+
+var ProductEvent_EventType_value = map[string]int32{
+	"UNKNOWN": 0,
+	"CREATED": 1,
+}
+
+func (x ProductEvent_EventType) String() string {
+	return proto.EnumName(ProductEvent_EventType_name, int32(x))
+}
+
+func (ProductEvent_EventType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{36, 0}
+}
+
 type User struct {
 	GoogleID             string         `protobuf:"bytes,1,opt,name=GoogleID,proto3" json:"GoogleID,omitempty"`
 	ID                   string         `protobuf:"bytes,2,opt,name=ID,proto3" json:"ID,omitempty"`
@@ -32,6 +58,8 @@ type User struct {
 	Cart                 *Cart          `protobuf:"bytes,5,opt,name=Cart,proto3" json:"Cart,omitempty"`
 	Transactions         []*Transaction `protobuf:"bytes,6,rep,name=Transactions,proto3" json:"Transactions,omitempty"`
 	Email                string         `protobuf:"bytes,7,opt,name=Email,proto3" json:"Email,omitempty"`
+	Roles                []string       `protobuf:"bytes,8,rep,name=Roles,proto3" json:"Roles,omitempty"`
+	Identities           []string       `protobuf:"bytes,9,rep,name=Identities,proto3" json:"Identities,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
 	XXX_unrecognized     []byte         `json:"-"`
 	XXX_sizecache        int32          `json:"-"`
@@ -110,6 +138,20 @@ func (m *User) GetEmail() string {
 	return ""
 }
 
+func (m *User) GetRoles() []string {
+	if m != nil {
+		return m.Roles
+	}
+	return nil
+}
+
+func (m *User) GetIdentities() []string {
+	if m != nil {
+		return m.Identities
+	}
+	return nil
+}
+
 type Product struct {
 	ID                   string   `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
 	DisplayName          string   `protobuf:"bytes,2,opt,name=DisplayName,proto3" json:"DisplayName,omitempty"`
@@ -624,8 +666,9 @@ func (m *AddProductRequest) GetQuantity() int32 {
 	return 0
 }
 
+// Failures are reported as gRPC status errors, so the Success field is gone.
 type AddProductResponse struct {
-	Success              bool     `protobuf:"varint,1,opt,name=Success,proto3" json:"Success,omitempty"`
+	Cart                 *Cart    `protobuf:"bytes,2,opt,name=Cart,proto3" json:"Cart,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -655,11 +698,11 @@ func (m *AddProductResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_AddProductResponse proto.InternalMessageInfo
 
-func (m *AddProductResponse) GetSuccess() bool {
+func (m *AddProductResponse) GetCart() *Cart {
 	if m != nil {
-		return m.Success
+		return m.Cart
 	}
-	return false
+	return nil
 }
 
 type GetNumTransactionsRequest struct {
@@ -731,7 +774,6 @@ func (m *NumTransactionsResponse) GetNumTransactions() int32 {
 }
 
 type ClearCartResponse struct {
-	Success              bool     `protobuf:"varint,1,opt,name=Success,proto3" json:"Success,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -761,18 +803,11 @@ func (m *ClearCartResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_ClearCartResponse proto.InternalMessageInfo
 
-func (m *ClearCartResponse) GetSuccess() bool {
-	if m != nil {
-		return m.Success
-	}
-	return false
-}
-
 type CheckoutResponse struct {
-	Success              bool     `protobuf:"varint,1,opt,name=Success,proto3" json:"Success,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Transaction          *Transaction `protobuf:"bytes,2,opt,name=Transaction,proto3" json:"Transaction,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
 }
 
 func (m *CheckoutResponse) Reset()         { *m = CheckoutResponse{} }
@@ -799,176 +834,1644 @@ func (m *CheckoutResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_CheckoutResponse proto.InternalMessageInfo
 
-func (m *CheckoutResponse) GetSuccess() bool {
+func (m *CheckoutResponse) GetTransaction() *Transaction {
 	if m != nil {
-		return m.Success
+		return m.Transaction
 	}
-	return false
+	return nil
 }
 
-func init() {
-	proto.RegisterType((*User)(nil), "User")
-	proto.RegisterType((*Product)(nil), "Product")
-	proto.RegisterType((*Cart)(nil), "Cart")
-	proto.RegisterType((*CartItem)(nil), "CartItem")
-	proto.RegisterType((*Transaction)(nil), "Transaction")
-	proto.RegisterType((*TransactionCounter)(nil), "TransactionCounter")
-	proto.RegisterType((*UserRequest)(nil), "UserRequest")
-	proto.RegisterType((*UserResponse)(nil), "UserResponse")
-	proto.RegisterType((*GetProductRequest)(nil), "GetProductRequest")
-	proto.RegisterType((*GetAllProductsRequest)(nil), "GetAllProductsRequest")
-	proto.RegisterType((*GetAllProductsResponse)(nil), "GetAllProductsResponse")
-	proto.RegisterType((*AddProductRequest)(nil), "AddProductRequest")
-	proto.RegisterType((*AddProductResponse)(nil), "AddProductResponse")
-	proto.RegisterType((*GetNumTransactionsRequest)(nil), "GetNumTransactionsRequest")
-	proto.RegisterType((*NumTransactionsResponse)(nil), "NumTransactionsResponse")
-	proto.RegisterType((*ClearCartResponse)(nil), "ClearCartResponse")
-	proto.RegisterType((*CheckoutResponse)(nil), "CheckoutResponse")
+type ListUsersRequest struct {
+	Limit                int32    `protobuf:"varint,1,opt,name=Limit,proto3" json:"Limit,omitempty"`
+	Offset               int32    `protobuf:"varint,2,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *ListUsersRequest) Reset()         { *m = ListUsersRequest{} }
+func (m *ListUsersRequest) String() string { return proto.CompactTextString(m) }
+func (*ListUsersRequest) ProtoMessage()    {}
+func (*ListUsersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{17}
+}
+func (m *ListUsersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListUsersRequest.Unmarshal(m, b)
+}
+func (m *ListUsersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListUsersRequest.Marshal(b, m, deterministic)
+}
+func (m *ListUsersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListUsersRequest.Merge(m, src)
+}
+func (m *ListUsersRequest) XXX_Size() int {
+	return xxx_messageInfo_ListUsersRequest.Size(m)
+}
+func (m *ListUsersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListUsersRequest.DiscardUnknown(m)
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+var xxx_messageInfo_ListUsersRequest proto.InternalMessageInfo
 
-// SpookyStoreClient is the client API for SpookyStore service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type SpookyStoreClient interface {
-	AuthorizeGoogle(ctx context.Context, in *User, opts ...grpc.CallOption) (*User, error)
-	GetUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error)
-	GetAllProducts(ctx context.Context, in *GetAllProductsRequest, opts ...grpc.CallOption) (*GetAllProductsResponse, error)
-	GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error)
-	AddProductToCart(ctx context.Context, in *AddProductRequest, opts ...grpc.CallOption) (*AddProductResponse, error)
-	ClearCart(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*ClearCartResponse, error)
-	Checkout(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*CheckoutResponse, error)
-	GetNumTransactions(ctx context.Context, in *GetNumTransactionsRequest, opts ...grpc.CallOption) (*NumTransactionsResponse, error)
+func (m *ListUsersRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
 }
 
-type spookyStoreClient struct {
-	cc *grpc.ClientConn
+func (m *ListUsersRequest) GetOffset() int32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
 }
 
-func NewSpookyStoreClient(cc *grpc.ClientConn) SpookyStoreClient {
-	return &spookyStoreClient{cc}
+type ListUsersResponse struct {
+	Users                []*User  `protobuf:"bytes,1,rep,name=Users,proto3" json:"Users,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (c *spookyStoreClient) AuthorizeGoogle(ctx context.Context, in *User, opts ...grpc.CallOption) (*User, error) {
-	out := new(User)
-	err := c.cc.Invoke(ctx, "/SpookyStore/AuthorizeGoogle", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *ListUsersResponse) Reset()         { *m = ListUsersResponse{} }
+func (m *ListUsersResponse) String() string { return proto.CompactTextString(m) }
+func (*ListUsersResponse) ProtoMessage()    {}
+func (*ListUsersResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{18}
+}
+func (m *ListUsersResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListUsersResponse.Unmarshal(m, b)
+}
+func (m *ListUsersResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListUsersResponse.Marshal(b, m, deterministic)
+}
+func (m *ListUsersResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListUsersResponse.Merge(m, src)
+}
+func (m *ListUsersResponse) XXX_Size() int {
+	return xxx_messageInfo_ListUsersResponse.Size(m)
+}
+func (m *ListUsersResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListUsersResponse.DiscardUnknown(m)
 }
 
-func (c *spookyStoreClient) GetUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error) {
-	out := new(UserResponse)
-	err := c.cc.Invoke(ctx, "/SpookyStore/GetUser", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_ListUsersResponse proto.InternalMessageInfo
+
+func (m *ListUsersResponse) GetUsers() []*User {
+	if m != nil {
+		return m.Users
 	}
-	return out, nil
+	return nil
 }
 
-func (c *spookyStoreClient) GetAllProducts(ctx context.Context, in *GetAllProductsRequest, opts ...grpc.CallOption) (*GetAllProductsResponse, error) {
-	out := new(GetAllProductsResponse)
-	err := c.cc.Invoke(ctx, "/SpookyStore/GetAllProducts", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+type ImportProductsRequest struct {
+	Products             []*Product `protobuf:"bytes,1,rep,name=Products,proto3" json:"Products,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
 }
 
-func (c *spookyStoreClient) GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error) {
-	out := new(Product)
-	err := c.cc.Invoke(ctx, "/SpookyStore/GetProduct", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *ImportProductsRequest) Reset()         { *m = ImportProductsRequest{} }
+func (m *ImportProductsRequest) String() string { return proto.CompactTextString(m) }
+func (*ImportProductsRequest) ProtoMessage()    {}
+func (*ImportProductsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{19}
+}
+func (m *ImportProductsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportProductsRequest.Unmarshal(m, b)
+}
+func (m *ImportProductsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportProductsRequest.Marshal(b, m, deterministic)
+}
+func (m *ImportProductsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportProductsRequest.Merge(m, src)
+}
+func (m *ImportProductsRequest) XXX_Size() int {
+	return xxx_messageInfo_ImportProductsRequest.Size(m)
+}
+func (m *ImportProductsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportProductsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportProductsRequest proto.InternalMessageInfo
+
+func (m *ImportProductsRequest) GetProducts() []*Product {
+	if m != nil {
+		return m.Products
 	}
-	return out, nil
+	return nil
 }
 
-func (c *spookyStoreClient) AddProductToCart(ctx context.Context, in *AddProductRequest, opts ...grpc.CallOption) (*AddProductResponse, error) {
-	out := new(AddProductResponse)
-	err := c.cc.Invoke(ctx, "/SpookyStore/AddProductToCart", in, out, opts...)
-	if err != nil {
-		return nil, err
+type ImportProductsResponse struct {
+	Created              int32    `protobuf:"varint,1,opt,name=Created,proto3" json:"Created,omitempty"`
+	Existing             int32    `protobuf:"varint,2,opt,name=Existing,proto3" json:"Existing,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportProductsResponse) Reset()         { *m = ImportProductsResponse{} }
+func (m *ImportProductsResponse) String() string { return proto.CompactTextString(m) }
+func (*ImportProductsResponse) ProtoMessage()    {}
+func (*ImportProductsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{20}
+}
+func (m *ImportProductsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportProductsResponse.Unmarshal(m, b)
+}
+func (m *ImportProductsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportProductsResponse.Marshal(b, m, deterministic)
+}
+func (m *ImportProductsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportProductsResponse.Merge(m, src)
+}
+func (m *ImportProductsResponse) XXX_Size() int {
+	return xxx_messageInfo_ImportProductsResponse.Size(m)
+}
+func (m *ImportProductsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportProductsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportProductsResponse proto.InternalMessageInfo
+
+func (m *ImportProductsResponse) GetCreated() int32 {
+	if m != nil {
+		return m.Created
 	}
-	return out, nil
+	return 0
 }
 
-func (c *spookyStoreClient) ClearCart(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*ClearCartResponse, error) {
-	out := new(ClearCartResponse)
-	err := c.cc.Invoke(ctx, "/SpookyStore/ClearCart", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *ImportProductsResponse) GetExisting() int32 {
+	if m != nil {
+		return m.Existing
 	}
-	return out, nil
+	return 0
 }
 
-func (c *spookyStoreClient) Checkout(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*CheckoutResponse, error) {
-	out := new(CheckoutResponse)
-	err := c.cc.Invoke(ctx, "/SpookyStore/Checkout", in, out, opts...)
-	if err != nil {
-		return nil, err
+type SetUserRolesRequest struct {
+	UserID               string   `protobuf:"bytes,1,opt,name=UserID,proto3" json:"UserID,omitempty"`
+	Roles                []string `protobuf:"bytes,2,rep,name=Roles,proto3" json:"Roles,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetUserRolesRequest) Reset()         { *m = SetUserRolesRequest{} }
+func (m *SetUserRolesRequest) String() string { return proto.CompactTextString(m) }
+func (*SetUserRolesRequest) ProtoMessage()    {}
+func (*SetUserRolesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{21}
+}
+func (m *SetUserRolesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetUserRolesRequest.Unmarshal(m, b)
+}
+func (m *SetUserRolesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetUserRolesRequest.Marshal(b, m, deterministic)
+}
+func (m *SetUserRolesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetUserRolesRequest.Merge(m, src)
+}
+func (m *SetUserRolesRequest) XXX_Size() int {
+	return xxx_messageInfo_SetUserRolesRequest.Size(m)
+}
+func (m *SetUserRolesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetUserRolesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetUserRolesRequest proto.InternalMessageInfo
+
+func (m *SetUserRolesRequest) GetUserID() string {
+	if m != nil {
+		return m.UserID
 	}
-	return out, nil
+	return ""
 }
 
-func (c *spookyStoreClient) GetNumTransactions(ctx context.Context, in *GetNumTransactionsRequest, opts ...grpc.CallOption) (*NumTransactionsResponse, error) {
-	out := new(NumTransactionsResponse)
-	err := c.cc.Invoke(ctx, "/SpookyStore/GetNumTransactions", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *SetUserRolesRequest) GetRoles() []string {
+	if m != nil {
+		return m.Roles
 	}
-	return out, nil
+	return nil
 }
 
-// SpookyStoreServer is the server API for SpookyStore service.
-type SpookyStoreServer interface {
-	AuthorizeGoogle(context.Context, *User) (*User, error)
-	GetUser(context.Context, *UserRequest) (*UserResponse, error)
-	GetAllProducts(context.Context, *GetAllProductsRequest) (*GetAllProductsResponse, error)
-	GetProduct(context.Context, *GetProductRequest) (*Product, error)
-	AddProductToCart(context.Context, *AddProductRequest) (*AddProductResponse, error)
-	ClearCart(context.Context, *UserRequest) (*ClearCartResponse, error)
-	Checkout(context.Context, *UserRequest) (*CheckoutResponse, error)
-	GetNumTransactions(context.Context, *GetNumTransactionsRequest) (*NumTransactionsResponse, error)
+type LinkAccountRequest struct {
+	Provider             string   `protobuf:"bytes,1,opt,name=Provider,proto3" json:"Provider,omitempty"`
+	Subject              string   `protobuf:"bytes,2,opt,name=Subject,proto3" json:"Subject,omitempty"`
+	Email                string   `protobuf:"bytes,3,opt,name=Email,proto3" json:"Email,omitempty"`
+	EmailVerified        bool     `protobuf:"varint,4,opt,name=EmailVerified,proto3" json:"EmailVerified,omitempty"`
+	DisplayName          string   `protobuf:"bytes,5,opt,name=DisplayName,proto3" json:"DisplayName,omitempty"`
+	Picture              string   `protobuf:"bytes,6,opt,name=Picture,proto3" json:"Picture,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func RegisterSpookyStoreServer(s *grpc.Server, srv SpookyStoreServer) {
-	s.RegisterService(&_SpookyStore_serviceDesc, srv)
+func (m *LinkAccountRequest) Reset()         { *m = LinkAccountRequest{} }
+func (m *LinkAccountRequest) String() string { return proto.CompactTextString(m) }
+func (*LinkAccountRequest) ProtoMessage()    {}
+func (*LinkAccountRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{22}
+}
+func (m *LinkAccountRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LinkAccountRequest.Unmarshal(m, b)
+}
+func (m *LinkAccountRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LinkAccountRequest.Marshal(b, m, deterministic)
+}
+func (m *LinkAccountRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LinkAccountRequest.Merge(m, src)
+}
+func (m *LinkAccountRequest) XXX_Size() int {
+	return xxx_messageInfo_LinkAccountRequest.Size(m)
+}
+func (m *LinkAccountRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LinkAccountRequest.DiscardUnknown(m)
 }
 
-func _SpookyStore_AuthorizeGoogle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(User)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(SpookyStoreServer).AuthorizeGoogle(ctx, in)
+var xxx_messageInfo_LinkAccountRequest proto.InternalMessageInfo
+
+func (m *LinkAccountRequest) GetProvider() string {
+	if m != nil {
+		return m.Provider
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/SpookyStore/AuthorizeGoogle",
+	return ""
+}
+
+func (m *LinkAccountRequest) GetSubject() string {
+	if m != nil {
+		return m.Subject
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SpookyStoreServer).AuthorizeGoogle(ctx, req.(*User))
+	return ""
+}
+
+func (m *LinkAccountRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-func _SpookyStore_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(UserRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *LinkAccountRequest) GetEmailVerified() bool {
+	if m != nil {
+		return m.EmailVerified
 	}
-	if interceptor == nil {
-		return srv.(SpookyStoreServer).GetUser(ctx, in)
+	return false
+}
+
+func (m *LinkAccountRequest) GetDisplayName() string {
+	if m != nil {
+		return m.DisplayName
+	}
+	return ""
+}
+
+func (m *LinkAccountRequest) GetPicture() string {
+	if m != nil {
+		return m.Picture
+	}
+	return ""
+}
+
+type RegisterRequest struct {
+	Email                string   `protobuf:"bytes,1,opt,name=Email,proto3" json:"Email,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=Password,proto3" json:"Password,omitempty"`
+	DisplayName          string   `protobuf:"bytes,3,opt,name=DisplayName,proto3" json:"DisplayName,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterRequest) Reset()         { *m = RegisterRequest{} }
+func (m *RegisterRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterRequest) ProtoMessage()    {}
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{23}
+}
+func (m *RegisterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterRequest.Unmarshal(m, b)
+}
+func (m *RegisterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterRequest.Marshal(b, m, deterministic)
+}
+func (m *RegisterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterRequest.Merge(m, src)
+}
+func (m *RegisterRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterRequest.Size(m)
+}
+func (m *RegisterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterRequest proto.InternalMessageInfo
+
+func (m *RegisterRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetDisplayName() string {
+	if m != nil {
+		return m.DisplayName
+	}
+	return ""
+}
+
+type RegisterResponse struct {
+	UserID               string   `protobuf:"bytes,1,opt,name=UserID,proto3" json:"UserID,omitempty"`
+	VerificationToken    string   `protobuf:"bytes,2,opt,name=VerificationToken,proto3" json:"VerificationToken,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterResponse) Reset()         { *m = RegisterResponse{} }
+func (m *RegisterResponse) String() string { return proto.CompactTextString(m) }
+func (*RegisterResponse) ProtoMessage()    {}
+func (*RegisterResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{24}
+}
+func (m *RegisterResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterResponse.Unmarshal(m, b)
+}
+func (m *RegisterResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterResponse.Marshal(b, m, deterministic)
+}
+func (m *RegisterResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterResponse.Merge(m, src)
+}
+func (m *RegisterResponse) XXX_Size() int {
+	return xxx_messageInfo_RegisterResponse.Size(m)
+}
+func (m *RegisterResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterResponse proto.InternalMessageInfo
+
+func (m *RegisterResponse) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *RegisterResponse) GetVerificationToken() string {
+	if m != nil {
+		return m.VerificationToken
+	}
+	return ""
+}
+
+type VerifyEmailRequest struct {
+	Token                string   `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *VerifyEmailRequest) Reset()         { *m = VerifyEmailRequest{} }
+func (m *VerifyEmailRequest) String() string { return proto.CompactTextString(m) }
+func (*VerifyEmailRequest) ProtoMessage()    {}
+func (*VerifyEmailRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{25}
+}
+func (m *VerifyEmailRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VerifyEmailRequest.Unmarshal(m, b)
+}
+func (m *VerifyEmailRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VerifyEmailRequest.Marshal(b, m, deterministic)
+}
+func (m *VerifyEmailRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VerifyEmailRequest.Merge(m, src)
+}
+func (m *VerifyEmailRequest) XXX_Size() int {
+	return xxx_messageInfo_VerifyEmailRequest.Size(m)
+}
+func (m *VerifyEmailRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_VerifyEmailRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VerifyEmailRequest proto.InternalMessageInfo
+
+func (m *VerifyEmailRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type LoginLocalRequest struct {
+	Email                string   `protobuf:"bytes,1,opt,name=Email,proto3" json:"Email,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=Password,proto3" json:"Password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LoginLocalRequest) Reset()         { *m = LoginLocalRequest{} }
+func (m *LoginLocalRequest) String() string { return proto.CompactTextString(m) }
+func (*LoginLocalRequest) ProtoMessage()    {}
+func (*LoginLocalRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{26}
+}
+func (m *LoginLocalRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoginLocalRequest.Unmarshal(m, b)
+}
+func (m *LoginLocalRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoginLocalRequest.Marshal(b, m, deterministic)
+}
+func (m *LoginLocalRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoginLocalRequest.Merge(m, src)
+}
+func (m *LoginLocalRequest) XXX_Size() int {
+	return xxx_messageInfo_LoginLocalRequest.Size(m)
+}
+func (m *LoginLocalRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoginLocalRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoginLocalRequest proto.InternalMessageInfo
+
+func (m *LoginLocalRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *LoginLocalRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+type PasswordResetRequest struct {
+	Email                string   `protobuf:"bytes,1,opt,name=Email,proto3" json:"Email,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PasswordResetRequest) Reset()         { *m = PasswordResetRequest{} }
+func (m *PasswordResetRequest) String() string { return proto.CompactTextString(m) }
+func (*PasswordResetRequest) ProtoMessage()    {}
+func (*PasswordResetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{27}
+}
+func (m *PasswordResetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PasswordResetRequest.Unmarshal(m, b)
+}
+func (m *PasswordResetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PasswordResetRequest.Marshal(b, m, deterministic)
+}
+func (m *PasswordResetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PasswordResetRequest.Merge(m, src)
+}
+func (m *PasswordResetRequest) XXX_Size() int {
+	return xxx_messageInfo_PasswordResetRequest.Size(m)
+}
+func (m *PasswordResetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PasswordResetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PasswordResetRequest proto.InternalMessageInfo
+
+func (m *PasswordResetRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+type PasswordResetResponse struct {
+	// Token is empty if there is no user with the email
+	Token                string   `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PasswordResetResponse) Reset()         { *m = PasswordResetResponse{} }
+func (m *PasswordResetResponse) String() string { return proto.CompactTextString(m) }
+func (*PasswordResetResponse) ProtoMessage()    {}
+func (*PasswordResetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{28}
+}
+func (m *PasswordResetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PasswordResetResponse.Unmarshal(m, b)
+}
+func (m *PasswordResetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PasswordResetResponse.Marshal(b, m, deterministic)
+}
+func (m *PasswordResetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PasswordResetResponse.Merge(m, src)
+}
+func (m *PasswordResetResponse) XXX_Size() int {
+	return xxx_messageInfo_PasswordResetResponse.Size(m)
+}
+func (m *PasswordResetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_PasswordResetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PasswordResetResponse proto.InternalMessageInfo
+
+func (m *PasswordResetResponse) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type ResetPasswordRequest struct {
+	Token                string   `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=Password,proto3" json:"Password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResetPasswordRequest) Reset()         { *m = ResetPasswordRequest{} }
+func (m *ResetPasswordRequest) String() string { return proto.CompactTextString(m) }
+func (*ResetPasswordRequest) ProtoMessage()    {}
+func (*ResetPasswordRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{29}
+}
+func (m *ResetPasswordRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResetPasswordRequest.Unmarshal(m, b)
+}
+func (m *ResetPasswordRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResetPasswordRequest.Marshal(b, m, deterministic)
+}
+func (m *ResetPasswordRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResetPasswordRequest.Merge(m, src)
+}
+func (m *ResetPasswordRequest) XXX_Size() int {
+	return xxx_messageInfo_ResetPasswordRequest.Size(m)
+}
+func (m *ResetPasswordRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResetPasswordRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResetPasswordRequest proto.InternalMessageInfo
+
+func (m *ResetPasswordRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *ResetPasswordRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+type APIToken struct {
+	ID                   string               `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	UserID               string               `protobuf:"bytes,2,opt,name=UserID,proto3" json:"UserID,omitempty"`
+	Name                 string               `protobuf:"bytes,3,opt,name=Name,proto3" json:"Name,omitempty"`
+	Scopes               []string             `protobuf:"bytes,4,rep,name=Scopes,proto3" json:"Scopes,omitempty"`
+	Created              *timestamp.Timestamp `protobuf:"bytes,5,opt,name=Created,proto3" json:"Created,omitempty"`
+	Expires              *timestamp.Timestamp `protobuf:"bytes,6,opt,name=Expires,proto3" json:"Expires,omitempty"`
+	Revoked              bool                 `protobuf:"varint,7,opt,name=Revoked,proto3" json:"Revoked,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *APIToken) Reset()         { *m = APIToken{} }
+func (m *APIToken) String() string { return proto.CompactTextString(m) }
+func (*APIToken) ProtoMessage()    {}
+func (*APIToken) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{30}
+}
+func (m *APIToken) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_APIToken.Unmarshal(m, b)
+}
+func (m *APIToken) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_APIToken.Marshal(b, m, deterministic)
+}
+func (m *APIToken) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_APIToken.Merge(m, src)
+}
+func (m *APIToken) XXX_Size() int {
+	return xxx_messageInfo_APIToken.Size(m)
+}
+func (m *APIToken) XXX_DiscardUnknown() {
+	xxx_messageInfo_APIToken.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_APIToken proto.InternalMessageInfo
+
+func (m *APIToken) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *APIToken) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *APIToken) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *APIToken) GetScopes() []string {
+	if m != nil {
+		return m.Scopes
+	}
+	return nil
+}
+
+func (m *APIToken) GetCreated() *timestamp.Timestamp {
+	if m != nil {
+		return m.Created
+	}
+	return nil
+}
+
+func (m *APIToken) GetExpires() *timestamp.Timestamp {
+	if m != nil {
+		return m.Expires
+	}
+	return nil
+}
+
+func (m *APIToken) GetRevoked() bool {
+	if m != nil {
+		return m.Revoked
+	}
+	return false
+}
+
+type CreateAPITokenRequest struct {
+	UserID string   `protobuf:"bytes,1,opt,name=UserID,proto3" json:"UserID,omitempty"`
+	Name   string   `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
+	Scopes []string `protobuf:"bytes,3,rep,name=Scopes,proto3" json:"Scopes,omitempty"`
+	// TTLSeconds is how long the token is valid, 0 for the default of 30 days
+	TTLSeconds           int64    `protobuf:"varint,4,opt,name=TTLSeconds,proto3" json:"TTLSeconds,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateAPITokenRequest) Reset()         { *m = CreateAPITokenRequest{} }
+func (m *CreateAPITokenRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateAPITokenRequest) ProtoMessage()    {}
+func (*CreateAPITokenRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{31}
+}
+func (m *CreateAPITokenRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateAPITokenRequest.Unmarshal(m, b)
+}
+func (m *CreateAPITokenRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateAPITokenRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateAPITokenRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateAPITokenRequest.Merge(m, src)
+}
+func (m *CreateAPITokenRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateAPITokenRequest.Size(m)
+}
+func (m *CreateAPITokenRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateAPITokenRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateAPITokenRequest proto.InternalMessageInfo
+
+func (m *CreateAPITokenRequest) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *CreateAPITokenRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CreateAPITokenRequest) GetScopes() []string {
+	if m != nil {
+		return m.Scopes
+	}
+	return nil
+}
+
+func (m *CreateAPITokenRequest) GetTTLSeconds() int64 {
+	if m != nil {
+		return m.TTLSeconds
+	}
+	return 0
+}
+
+type CreateAPITokenResponse struct {
+	// Token is only ever returned here; only its hash is stored
+	Token                string    `protobuf:"bytes,1,opt,name=Token,proto3" json:"Token,omitempty"`
+	Info                 *APIToken `protobuf:"bytes,2,opt,name=Info,proto3" json:"Info,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *CreateAPITokenResponse) Reset()         { *m = CreateAPITokenResponse{} }
+func (m *CreateAPITokenResponse) String() string { return proto.CompactTextString(m) }
+func (*CreateAPITokenResponse) ProtoMessage()    {}
+func (*CreateAPITokenResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{32}
+}
+func (m *CreateAPITokenResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateAPITokenResponse.Unmarshal(m, b)
+}
+func (m *CreateAPITokenResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateAPITokenResponse.Marshal(b, m, deterministic)
+}
+func (m *CreateAPITokenResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateAPITokenResponse.Merge(m, src)
+}
+func (m *CreateAPITokenResponse) XXX_Size() int {
+	return xxx_messageInfo_CreateAPITokenResponse.Size(m)
+}
+func (m *CreateAPITokenResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateAPITokenResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateAPITokenResponse proto.InternalMessageInfo
+
+func (m *CreateAPITokenResponse) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *CreateAPITokenResponse) GetInfo() *APIToken {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+type ListAPITokensResponse struct {
+	Tokens               []*APIToken `protobuf:"bytes,1,rep,name=Tokens,proto3" json:"Tokens,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *ListAPITokensResponse) Reset()         { *m = ListAPITokensResponse{} }
+func (m *ListAPITokensResponse) String() string { return proto.CompactTextString(m) }
+func (*ListAPITokensResponse) ProtoMessage()    {}
+func (*ListAPITokensResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{33}
+}
+func (m *ListAPITokensResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListAPITokensResponse.Unmarshal(m, b)
+}
+func (m *ListAPITokensResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListAPITokensResponse.Marshal(b, m, deterministic)
+}
+func (m *ListAPITokensResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListAPITokensResponse.Merge(m, src)
+}
+func (m *ListAPITokensResponse) XXX_Size() int {
+	return xxx_messageInfo_ListAPITokensResponse.Size(m)
+}
+func (m *ListAPITokensResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListAPITokensResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListAPITokensResponse proto.InternalMessageInfo
+
+func (m *ListAPITokensResponse) GetTokens() []*APIToken {
+	if m != nil {
+		return m.Tokens
+	}
+	return nil
+}
+
+type RevokeAPITokenRequest struct {
+	UserID               string   `protobuf:"bytes,1,opt,name=UserID,proto3" json:"UserID,omitempty"`
+	TokenID              string   `protobuf:"bytes,2,opt,name=TokenID,proto3" json:"TokenID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeAPITokenRequest) Reset()         { *m = RevokeAPITokenRequest{} }
+func (m *RevokeAPITokenRequest) String() string { return proto.CompactTextString(m) }
+func (*RevokeAPITokenRequest) ProtoMessage()    {}
+func (*RevokeAPITokenRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{34}
+}
+func (m *RevokeAPITokenRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeAPITokenRequest.Unmarshal(m, b)
+}
+func (m *RevokeAPITokenRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeAPITokenRequest.Marshal(b, m, deterministic)
+}
+func (m *RevokeAPITokenRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeAPITokenRequest.Merge(m, src)
+}
+func (m *RevokeAPITokenRequest) XXX_Size() int {
+	return xxx_messageInfo_RevokeAPITokenRequest.Size(m)
+}
+func (m *RevokeAPITokenRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeAPITokenRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeAPITokenRequest proto.InternalMessageInfo
+
+func (m *RevokeAPITokenRequest) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *RevokeAPITokenRequest) GetTokenID() string {
+	if m != nil {
+		return m.TokenID
+	}
+	return ""
+}
+
+type WatchProductsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchProductsRequest) Reset()         { *m = WatchProductsRequest{} }
+func (m *WatchProductsRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchProductsRequest) ProtoMessage()    {}
+func (*WatchProductsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{35}
+}
+func (m *WatchProductsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchProductsRequest.Unmarshal(m, b)
+}
+func (m *WatchProductsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchProductsRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchProductsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchProductsRequest.Merge(m, src)
+}
+func (m *WatchProductsRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchProductsRequest.Size(m)
+}
+func (m *WatchProductsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchProductsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchProductsRequest proto.InternalMessageInfo
+
+type ProductEvent struct {
+	Type                 ProductEvent_EventType `protobuf:"varint,1,opt,name=Type,proto3,enum=ProductEvent_EventType" json:"Type,omitempty"`
+	Product              *Product               `protobuf:"bytes,2,opt,name=Product,proto3" json:"Product,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *ProductEvent) Reset()         { *m = ProductEvent{} }
+func (m *ProductEvent) String() string { return proto.CompactTextString(m) }
+func (*ProductEvent) ProtoMessage()    {}
+func (*ProductEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{36}
+}
+func (m *ProductEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProductEvent.Unmarshal(m, b)
+}
+func (m *ProductEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProductEvent.Marshal(b, m, deterministic)
+}
+func (m *ProductEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProductEvent.Merge(m, src)
+}
+func (m *ProductEvent) XXX_Size() int {
+	return xxx_messageInfo_ProductEvent.Size(m)
+}
+func (m *ProductEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProductEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProductEvent proto.InternalMessageInfo
+
+func (m *ProductEvent) GetType() ProductEvent_EventType {
+	if m != nil {
+		return m.Type
+	}
+	return ProductEvent_UNKNOWN
+}
+
+func (m *ProductEvent) GetProduct() *Product {
+	if m != nil {
+		return m.Product
+	}
+	return nil
+}
+
+type DatastoreFault struct {
+	// Kind and Op restrict the fault to calls on entities of a kind, and to
+	// an operation: get, get_all, put or delete. Empty values match every call.
+	Kind string `protobuf:"bytes,1,opt,name=Kind,proto3" json:"Kind,omitempty"`
+	Op   string `protobuf:"bytes,2,opt,name=Op,proto3" json:"Op,omitempty"`
+	// Rate is the share of the calls matched that are affected, from 0 to 1
+	Rate          float64 `protobuf:"fixed64,3,opt,name=Rate,proto3" json:"Rate,omitempty"`
+	LatencyMillis int64   `protobuf:"varint,4,opt,name=LatencyMillis,proto3" json:"LatencyMillis,omitempty"`
+	// Error is unavailable, deadline_exceeded, not_found, contention, or
+	// empty to only add latency
+	Error string `protobuf:"bytes,5,opt,name=Error,proto3" json:"Error,omitempty"`
+	// Partial makes the call before failing it, like a write that was applied
+	// but whose response was lost
+	Partial              bool     `protobuf:"varint,6,opt,name=Partial,proto3" json:"Partial,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DatastoreFault) Reset()         { *m = DatastoreFault{} }
+func (m *DatastoreFault) String() string { return proto.CompactTextString(m) }
+func (*DatastoreFault) ProtoMessage()    {}
+func (*DatastoreFault) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{37}
+}
+func (m *DatastoreFault) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DatastoreFault.Unmarshal(m, b)
+}
+func (m *DatastoreFault) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DatastoreFault.Marshal(b, m, deterministic)
+}
+func (m *DatastoreFault) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DatastoreFault.Merge(m, src)
+}
+func (m *DatastoreFault) XXX_Size() int {
+	return xxx_messageInfo_DatastoreFault.Size(m)
+}
+func (m *DatastoreFault) XXX_DiscardUnknown() {
+	xxx_messageInfo_DatastoreFault.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DatastoreFault proto.InternalMessageInfo
+
+func (m *DatastoreFault) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *DatastoreFault) GetOp() string {
+	if m != nil {
+		return m.Op
+	}
+	return ""
+}
+
+func (m *DatastoreFault) GetRate() float64 {
+	if m != nil {
+		return m.Rate
+	}
+	return 0
+}
+
+func (m *DatastoreFault) GetLatencyMillis() int64 {
+	if m != nil {
+		return m.LatencyMillis
+	}
+	return 0
+}
+
+func (m *DatastoreFault) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *DatastoreFault) GetPartial() bool {
+	if m != nil {
+		return m.Partial
+	}
+	return false
+}
+
+type GetDatastoreFaultsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetDatastoreFaultsRequest) Reset()         { *m = GetDatastoreFaultsRequest{} }
+func (m *GetDatastoreFaultsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetDatastoreFaultsRequest) ProtoMessage()    {}
+func (*GetDatastoreFaultsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{38}
+}
+func (m *GetDatastoreFaultsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetDatastoreFaultsRequest.Unmarshal(m, b)
+}
+func (m *GetDatastoreFaultsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetDatastoreFaultsRequest.Marshal(b, m, deterministic)
+}
+func (m *GetDatastoreFaultsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetDatastoreFaultsRequest.Merge(m, src)
+}
+func (m *GetDatastoreFaultsRequest) XXX_Size() int {
+	return xxx_messageInfo_GetDatastoreFaultsRequest.Size(m)
+}
+func (m *GetDatastoreFaultsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetDatastoreFaultsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetDatastoreFaultsRequest proto.InternalMessageInfo
+
+type SetDatastoreFaultsRequest struct {
+	Faults []*DatastoreFault `protobuf:"bytes,1,rep,name=Faults,proto3" json:"Faults,omitempty"`
+	// Seed restarts the draws of which calls are affected, 0 keeps the seed
+	// in use
+	Seed                 int64    `protobuf:"varint,2,opt,name=Seed,proto3" json:"Seed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetDatastoreFaultsRequest) Reset()         { *m = SetDatastoreFaultsRequest{} }
+func (m *SetDatastoreFaultsRequest) String() string { return proto.CompactTextString(m) }
+func (*SetDatastoreFaultsRequest) ProtoMessage()    {}
+func (*SetDatastoreFaultsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{39}
+}
+func (m *SetDatastoreFaultsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetDatastoreFaultsRequest.Unmarshal(m, b)
+}
+func (m *SetDatastoreFaultsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetDatastoreFaultsRequest.Marshal(b, m, deterministic)
+}
+func (m *SetDatastoreFaultsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetDatastoreFaultsRequest.Merge(m, src)
+}
+func (m *SetDatastoreFaultsRequest) XXX_Size() int {
+	return xxx_messageInfo_SetDatastoreFaultsRequest.Size(m)
+}
+func (m *SetDatastoreFaultsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetDatastoreFaultsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetDatastoreFaultsRequest proto.InternalMessageInfo
+
+func (m *SetDatastoreFaultsRequest) GetFaults() []*DatastoreFault {
+	if m != nil {
+		return m.Faults
+	}
+	return nil
+}
+
+func (m *SetDatastoreFaultsRequest) GetSeed() int64 {
+	if m != nil {
+		return m.Seed
+	}
+	return 0
+}
+
+type DatastoreFaults struct {
+	// Enabled is false unless the backend runs with fault injection
+	Enabled              bool              `protobuf:"varint,1,opt,name=Enabled,proto3" json:"Enabled,omitempty"`
+	Faults               []*DatastoreFault `protobuf:"bytes,2,rep,name=Faults,proto3" json:"Faults,omitempty"`
+	Seed                 int64             `protobuf:"varint,3,opt,name=Seed,proto3" json:"Seed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *DatastoreFaults) Reset()         { *m = DatastoreFaults{} }
+func (m *DatastoreFaults) String() string { return proto.CompactTextString(m) }
+func (*DatastoreFaults) ProtoMessage()    {}
+func (*DatastoreFaults) Descriptor() ([]byte, []int) {
+	return fileDescriptor_213487394ea54d54, []int{40}
+}
+func (m *DatastoreFaults) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DatastoreFaults.Unmarshal(m, b)
+}
+func (m *DatastoreFaults) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DatastoreFaults.Marshal(b, m, deterministic)
+}
+func (m *DatastoreFaults) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DatastoreFaults.Merge(m, src)
+}
+func (m *DatastoreFaults) XXX_Size() int {
+	return xxx_messageInfo_DatastoreFaults.Size(m)
+}
+func (m *DatastoreFaults) XXX_DiscardUnknown() {
+	xxx_messageInfo_DatastoreFaults.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DatastoreFaults proto.InternalMessageInfo
+
+func (m *DatastoreFaults) GetEnabled() bool {
+	if m != nil {
+		return m.Enabled
+	}
+	return false
+}
+
+func (m *DatastoreFaults) GetFaults() []*DatastoreFault {
+	if m != nil {
+		return m.Faults
+	}
+	return nil
+}
+
+func (m *DatastoreFaults) GetSeed() int64 {
+	if m != nil {
+		return m.Seed
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterEnum("ProductEvent_EventType", ProductEvent_EventType_name, ProductEvent_EventType_value)
+	proto.RegisterType((*User)(nil), "User")
+	proto.RegisterType((*Product)(nil), "Product")
+	proto.RegisterType((*Cart)(nil), "Cart")
+	proto.RegisterType((*CartItem)(nil), "CartItem")
+	proto.RegisterType((*Transaction)(nil), "Transaction")
+	proto.RegisterType((*TransactionCounter)(nil), "TransactionCounter")
+	proto.RegisterType((*UserRequest)(nil), "UserRequest")
+	proto.RegisterType((*UserResponse)(nil), "UserResponse")
+	proto.RegisterType((*GetProductRequest)(nil), "GetProductRequest")
+	proto.RegisterType((*GetAllProductsRequest)(nil), "GetAllProductsRequest")
+	proto.RegisterType((*GetAllProductsResponse)(nil), "GetAllProductsResponse")
+	proto.RegisterType((*AddProductRequest)(nil), "AddProductRequest")
+	proto.RegisterType((*AddProductResponse)(nil), "AddProductResponse")
+	proto.RegisterType((*GetNumTransactionsRequest)(nil), "GetNumTransactionsRequest")
+	proto.RegisterType((*NumTransactionsResponse)(nil), "NumTransactionsResponse")
+	proto.RegisterType((*ClearCartResponse)(nil), "ClearCartResponse")
+	proto.RegisterType((*CheckoutResponse)(nil), "CheckoutResponse")
+	proto.RegisterType((*ListUsersRequest)(nil), "ListUsersRequest")
+	proto.RegisterType((*ListUsersResponse)(nil), "ListUsersResponse")
+	proto.RegisterType((*ImportProductsRequest)(nil), "ImportProductsRequest")
+	proto.RegisterType((*ImportProductsResponse)(nil), "ImportProductsResponse")
+	proto.RegisterType((*SetUserRolesRequest)(nil), "SetUserRolesRequest")
+	proto.RegisterType((*LinkAccountRequest)(nil), "LinkAccountRequest")
+	proto.RegisterType((*RegisterRequest)(nil), "RegisterRequest")
+	proto.RegisterType((*RegisterResponse)(nil), "RegisterResponse")
+	proto.RegisterType((*VerifyEmailRequest)(nil), "VerifyEmailRequest")
+	proto.RegisterType((*LoginLocalRequest)(nil), "LoginLocalRequest")
+	proto.RegisterType((*PasswordResetRequest)(nil), "PasswordResetRequest")
+	proto.RegisterType((*PasswordResetResponse)(nil), "PasswordResetResponse")
+	proto.RegisterType((*ResetPasswordRequest)(nil), "ResetPasswordRequest")
+	proto.RegisterType((*APIToken)(nil), "APIToken")
+	proto.RegisterType((*CreateAPITokenRequest)(nil), "CreateAPITokenRequest")
+	proto.RegisterType((*CreateAPITokenResponse)(nil), "CreateAPITokenResponse")
+	proto.RegisterType((*ListAPITokensResponse)(nil), "ListAPITokensResponse")
+	proto.RegisterType((*RevokeAPITokenRequest)(nil), "RevokeAPITokenRequest")
+	proto.RegisterType((*WatchProductsRequest)(nil), "WatchProductsRequest")
+	proto.RegisterType((*ProductEvent)(nil), "ProductEvent")
+	proto.RegisterType((*DatastoreFault)(nil), "DatastoreFault")
+	proto.RegisterType((*GetDatastoreFaultsRequest)(nil), "GetDatastoreFaultsRequest")
+	proto.RegisterType((*SetDatastoreFaultsRequest)(nil), "SetDatastoreFaultsRequest")
+	proto.RegisterType((*DatastoreFaults)(nil), "DatastoreFaults")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// SpookyStoreClient is the client API for SpookyStore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type SpookyStoreClient interface {
+	AuthorizeGoogle(ctx context.Context, in *User, opts ...grpc.CallOption) (*User, error)
+	GetUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error)
+	GetAllProducts(ctx context.Context, in *GetAllProductsRequest, opts ...grpc.CallOption) (*GetAllProductsResponse, error)
+	GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error)
+	AddProductToCart(ctx context.Context, in *AddProductRequest, opts ...grpc.CallOption) (*AddProductResponse, error)
+	ClearCart(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*ClearCartResponse, error)
+	Checkout(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*CheckoutResponse, error)
+	GetNumTransactions(ctx context.Context, in *GetNumTransactionsRequest, opts ...grpc.CallOption) (*NumTransactionsResponse, error)
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error)
+	ImportProducts(ctx context.Context, in *ImportProductsRequest, opts ...grpc.CallOption) (*ImportProductsResponse, error)
+	SetUserRoles(ctx context.Context, in *SetUserRolesRequest, opts ...grpc.CallOption) (*User, error)
+	LinkAccount(ctx context.Context, in *LinkAccountRequest, opts ...grpc.CallOption) (*User, error)
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	VerifyEmail(ctx context.Context, in *VerifyEmailRequest, opts ...grpc.CallOption) (*User, error)
+	LoginLocal(ctx context.Context, in *LoginLocalRequest, opts ...grpc.CallOption) (*User, error)
+	RequestPasswordReset(ctx context.Context, in *PasswordResetRequest, opts ...grpc.CallOption) (*PasswordResetResponse, error)
+	ResetPassword(ctx context.Context, in *ResetPasswordRequest, opts ...grpc.CallOption) (*User, error)
+	CreateAPIToken(ctx context.Context, in *CreateAPITokenRequest, opts ...grpc.CallOption) (*CreateAPITokenResponse, error)
+	ListAPITokens(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*ListAPITokensResponse, error)
+	RevokeAPIToken(ctx context.Context, in *RevokeAPITokenRequest, opts ...grpc.CallOption) (*APIToken, error)
+	// GetDatastoreFaults returns the faults injected into datastore calls.
+	GetDatastoreFaults(ctx context.Context, in *GetDatastoreFaultsRequest, opts ...grpc.CallOption) (*DatastoreFaults, error)
+	// SetDatastoreFaults replaces the faults injected into datastore calls,
+	// when the backend runs with the faults datastore decorator.
+	SetDatastoreFaults(ctx context.Context, in *SetDatastoreFaultsRequest, opts ...grpc.CallOption) (*DatastoreFaults, error)
+	// WatchCart sends the user's cart, then the cart again every time it changes.
+	WatchCart(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (SpookyStore_WatchCartClient, error)
+	// WatchProducts sends an event for every change to the catalog.
+	WatchProducts(ctx context.Context, in *WatchProductsRequest, opts ...grpc.CallOption) (SpookyStore_WatchProductsClient, error)
+	// WatchNumTransactions sends the number of transactions, then the new
+	// number after every checkout.
+	WatchNumTransactions(ctx context.Context, in *GetNumTransactionsRequest, opts ...grpc.CallOption) (SpookyStore_WatchNumTransactionsClient, error)
+}
+
+type spookyStoreClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSpookyStoreClient(cc *grpc.ClientConn) SpookyStoreClient {
+	return &spookyStoreClient{cc}
+}
+
+func (c *spookyStoreClient) AuthorizeGoogle(ctx context.Context, in *User, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/SpookyStore/AuthorizeGoogle", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) GetUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error) {
+	out := new(UserResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/GetUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) GetAllProducts(ctx context.Context, in *GetAllProductsRequest, opts ...grpc.CallOption) (*GetAllProductsResponse, error) {
+	out := new(GetAllProductsResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/GetAllProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/SpookyStore/GetProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) AddProductToCart(ctx context.Context, in *AddProductRequest, opts ...grpc.CallOption) (*AddProductResponse, error) {
+	out := new(AddProductResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/AddProductToCart", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) ClearCart(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*ClearCartResponse, error) {
+	out := new(ClearCartResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/ClearCart", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) Checkout(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*CheckoutResponse, error) {
+	out := new(CheckoutResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/Checkout", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) GetNumTransactions(ctx context.Context, in *GetNumTransactionsRequest, opts ...grpc.CallOption) (*NumTransactionsResponse, error) {
+	out := new(NumTransactionsResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/GetNumTransactions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error) {
+	out := new(ListUsersResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/ListUsers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) ImportProducts(ctx context.Context, in *ImportProductsRequest, opts ...grpc.CallOption) (*ImportProductsResponse, error) {
+	out := new(ImportProductsResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/ImportProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) SetUserRoles(ctx context.Context, in *SetUserRolesRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/SpookyStore/SetUserRoles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) LinkAccount(ctx context.Context, in *LinkAccountRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/SpookyStore/LinkAccount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) VerifyEmail(ctx context.Context, in *VerifyEmailRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/SpookyStore/VerifyEmail", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) LoginLocal(ctx context.Context, in *LoginLocalRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/SpookyStore/LoginLocal", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) RequestPasswordReset(ctx context.Context, in *PasswordResetRequest, opts ...grpc.CallOption) (*PasswordResetResponse, error) {
+	out := new(PasswordResetResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/RequestPasswordReset", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) ResetPassword(ctx context.Context, in *ResetPasswordRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/SpookyStore/ResetPassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) CreateAPIToken(ctx context.Context, in *CreateAPITokenRequest, opts ...grpc.CallOption) (*CreateAPITokenResponse, error) {
+	out := new(CreateAPITokenResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/CreateAPIToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) ListAPITokens(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*ListAPITokensResponse, error) {
+	out := new(ListAPITokensResponse)
+	err := c.cc.Invoke(ctx, "/SpookyStore/ListAPITokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) RevokeAPIToken(ctx context.Context, in *RevokeAPITokenRequest, opts ...grpc.CallOption) (*APIToken, error) {
+	out := new(APIToken)
+	err := c.cc.Invoke(ctx, "/SpookyStore/RevokeAPIToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) GetDatastoreFaults(ctx context.Context, in *GetDatastoreFaultsRequest, opts ...grpc.CallOption) (*DatastoreFaults, error) {
+	out := new(DatastoreFaults)
+	err := c.cc.Invoke(ctx, "/SpookyStore/GetDatastoreFaults", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) SetDatastoreFaults(ctx context.Context, in *SetDatastoreFaultsRequest, opts ...grpc.CallOption) (*DatastoreFaults, error) {
+	out := new(DatastoreFaults)
+	err := c.cc.Invoke(ctx, "/SpookyStore/SetDatastoreFaults", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spookyStoreClient) WatchCart(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (SpookyStore_WatchCartClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_SpookyStore_serviceDesc.Streams[0], "/SpookyStore/WatchCart", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &spookyStoreWatchCartClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SpookyStore_WatchCartClient interface {
+	Recv() (*Cart, error)
+	grpc.ClientStream
+}
+
+type spookyStoreWatchCartClient struct {
+	grpc.ClientStream
+}
+
+func (x *spookyStoreWatchCartClient) Recv() (*Cart, error) {
+	m := new(Cart)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *spookyStoreClient) WatchProducts(ctx context.Context, in *WatchProductsRequest, opts ...grpc.CallOption) (SpookyStore_WatchProductsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_SpookyStore_serviceDesc.Streams[1], "/SpookyStore/WatchProducts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &spookyStoreWatchProductsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SpookyStore_WatchProductsClient interface {
+	Recv() (*ProductEvent, error)
+	grpc.ClientStream
+}
+
+type spookyStoreWatchProductsClient struct {
+	grpc.ClientStream
+}
+
+func (x *spookyStoreWatchProductsClient) Recv() (*ProductEvent, error) {
+	m := new(ProductEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *spookyStoreClient) WatchNumTransactions(ctx context.Context, in *GetNumTransactionsRequest, opts ...grpc.CallOption) (SpookyStore_WatchNumTransactionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_SpookyStore_serviceDesc.Streams[2], "/SpookyStore/WatchNumTransactions", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &spookyStoreWatchNumTransactionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SpookyStore_WatchNumTransactionsClient interface {
+	Recv() (*NumTransactionsResponse, error)
+	grpc.ClientStream
+}
+
+type spookyStoreWatchNumTransactionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *spookyStoreWatchNumTransactionsClient) Recv() (*NumTransactionsResponse, error) {
+	m := new(NumTransactionsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SpookyStoreServer is the server API for SpookyStore service.
+type SpookyStoreServer interface {
+	AuthorizeGoogle(context.Context, *User) (*User, error)
+	GetUser(context.Context, *UserRequest) (*UserResponse, error)
+	GetAllProducts(context.Context, *GetAllProductsRequest) (*GetAllProductsResponse, error)
+	GetProduct(context.Context, *GetProductRequest) (*Product, error)
+	AddProductToCart(context.Context, *AddProductRequest) (*AddProductResponse, error)
+	ClearCart(context.Context, *UserRequest) (*ClearCartResponse, error)
+	Checkout(context.Context, *UserRequest) (*CheckoutResponse, error)
+	GetNumTransactions(context.Context, *GetNumTransactionsRequest) (*NumTransactionsResponse, error)
+	ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error)
+	ImportProducts(context.Context, *ImportProductsRequest) (*ImportProductsResponse, error)
+	SetUserRoles(context.Context, *SetUserRolesRequest) (*User, error)
+	LinkAccount(context.Context, *LinkAccountRequest) (*User, error)
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	VerifyEmail(context.Context, *VerifyEmailRequest) (*User, error)
+	LoginLocal(context.Context, *LoginLocalRequest) (*User, error)
+	RequestPasswordReset(context.Context, *PasswordResetRequest) (*PasswordResetResponse, error)
+	ResetPassword(context.Context, *ResetPasswordRequest) (*User, error)
+	CreateAPIToken(context.Context, *CreateAPITokenRequest) (*CreateAPITokenResponse, error)
+	ListAPITokens(context.Context, *UserRequest) (*ListAPITokensResponse, error)
+	RevokeAPIToken(context.Context, *RevokeAPITokenRequest) (*APIToken, error)
+	// GetDatastoreFaults returns the faults injected into datastore calls.
+	GetDatastoreFaults(context.Context, *GetDatastoreFaultsRequest) (*DatastoreFaults, error)
+	// SetDatastoreFaults replaces the faults injected into datastore calls,
+	// when the backend runs with the faults datastore decorator.
+	SetDatastoreFaults(context.Context, *SetDatastoreFaultsRequest) (*DatastoreFaults, error)
+	// WatchCart sends the user's cart, then the cart again every time it changes.
+	WatchCart(*UserRequest, SpookyStore_WatchCartServer) error
+	// WatchProducts sends an event for every change to the catalog.
+	WatchProducts(*WatchProductsRequest, SpookyStore_WatchProductsServer) error
+	// WatchNumTransactions sends the number of transactions, then the new
+	// number after every checkout.
+	WatchNumTransactions(*GetNumTransactionsRequest, SpookyStore_WatchNumTransactionsServer) error
+}
+
+func RegisterSpookyStoreServer(s *grpc.Server, srv SpookyStoreServer) {
+	s.RegisterService(&_SpookyStore_serviceDesc, srv)
+}
+
+func _SpookyStore_AuthorizeGoogle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(User)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).AuthorizeGoogle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/AuthorizeGoogle",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).AuthorizeGoogle(ctx, req.(*User))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).GetUser(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
@@ -1088,6 +2591,321 @@ func _SpookyStore_GetNumTransactions_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SpookyStore_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/ListUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_ImportProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).ImportProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/ImportProducts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).ImportProducts(ctx, req.(*ImportProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_SetUserRoles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserRolesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).SetUserRoles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/SetUserRoles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).SetUserRoles(ctx, req.(*SetUserRolesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_LinkAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LinkAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).LinkAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/LinkAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).LinkAccount(ctx, req.(*LinkAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_VerifyEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyEmailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).VerifyEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/VerifyEmail",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).VerifyEmail(ctx, req.(*VerifyEmailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_LoginLocal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginLocalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).LoginLocal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/LoginLocal",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).LoginLocal(ctx, req.(*LoginLocalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_RequestPasswordReset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PasswordResetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).RequestPasswordReset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/RequestPasswordReset",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).RequestPasswordReset(ctx, req.(*PasswordResetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_ResetPassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetPasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).ResetPassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/ResetPassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).ResetPassword(ctx, req.(*ResetPasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_CreateAPIToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAPITokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).CreateAPIToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/CreateAPIToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).CreateAPIToken(ctx, req.(*CreateAPITokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_ListAPITokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).ListAPITokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/ListAPITokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).ListAPITokens(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_RevokeAPIToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAPITokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).RevokeAPIToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/RevokeAPIToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).RevokeAPIToken(ctx, req.(*RevokeAPITokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_GetDatastoreFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDatastoreFaultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).GetDatastoreFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/GetDatastoreFaults",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).GetDatastoreFaults(ctx, req.(*GetDatastoreFaultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_SetDatastoreFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetDatastoreFaultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpookyStoreServer).SetDatastoreFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/SpookyStore/SetDatastoreFaults",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpookyStoreServer).SetDatastoreFaults(ctx, req.(*SetDatastoreFaultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpookyStore_WatchCart_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UserRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SpookyStoreServer).WatchCart(m, &spookyStoreWatchCartServer{stream})
+}
+
+type SpookyStore_WatchCartServer interface {
+	Send(*Cart) error
+	grpc.ServerStream
+}
+
+type spookyStoreWatchCartServer struct {
+	grpc.ServerStream
+}
+
+func (x *spookyStoreWatchCartServer) Send(m *Cart) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SpookyStore_WatchProducts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchProductsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SpookyStoreServer).WatchProducts(m, &spookyStoreWatchProductsServer{stream})
+}
+
+type SpookyStore_WatchProductsServer interface {
+	Send(*ProductEvent) error
+	grpc.ServerStream
+}
+
+type spookyStoreWatchProductsServer struct {
+	grpc.ServerStream
+}
+
+func (x *spookyStoreWatchProductsServer) Send(m *ProductEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SpookyStore_WatchNumTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetNumTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SpookyStoreServer).WatchNumTransactions(m, &spookyStoreWatchNumTransactionsServer{stream})
+}
+
+type SpookyStore_WatchNumTransactionsServer interface {
+	Send(*NumTransactionsResponse) error
+	grpc.ServerStream
+}
+
+type spookyStoreWatchNumTransactionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *spookyStoreWatchNumTransactionsServer) Send(m *NumTransactionsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _SpookyStore_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "SpookyStore",
 	HandlerType: (*SpookyStoreServer)(nil),
@@ -1124,59 +2942,203 @@ var _SpookyStore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetNumTransactions",
 			Handler:    _SpookyStore_GetNumTransactions_Handler,
 		},
+		{
+			MethodName: "ListUsers",
+			Handler:    _SpookyStore_ListUsers_Handler,
+		},
+		{
+			MethodName: "ImportProducts",
+			Handler:    _SpookyStore_ImportProducts_Handler,
+		},
+		{
+			MethodName: "SetUserRoles",
+			Handler:    _SpookyStore_SetUserRoles_Handler,
+		},
+		{
+			MethodName: "LinkAccount",
+			Handler:    _SpookyStore_LinkAccount_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _SpookyStore_Register_Handler,
+		},
+		{
+			MethodName: "VerifyEmail",
+			Handler:    _SpookyStore_VerifyEmail_Handler,
+		},
+		{
+			MethodName: "LoginLocal",
+			Handler:    _SpookyStore_LoginLocal_Handler,
+		},
+		{
+			MethodName: "RequestPasswordReset",
+			Handler:    _SpookyStore_RequestPasswordReset_Handler,
+		},
+		{
+			MethodName: "ResetPassword",
+			Handler:    _SpookyStore_ResetPassword_Handler,
+		},
+		{
+			MethodName: "CreateAPIToken",
+			Handler:    _SpookyStore_CreateAPIToken_Handler,
+		},
+		{
+			MethodName: "ListAPITokens",
+			Handler:    _SpookyStore_ListAPITokens_Handler,
+		},
+		{
+			MethodName: "RevokeAPIToken",
+			Handler:    _SpookyStore_RevokeAPIToken_Handler,
+		},
+		{
+			MethodName: "GetDatastoreFaults",
+			Handler:    _SpookyStore_GetDatastoreFaults_Handler,
+		},
+		{
+			MethodName: "SetDatastoreFaults",
+			Handler:    _SpookyStore_SetDatastoreFaults_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCart",
+			Handler:       _SpookyStore_WatchCart_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchProducts",
+			Handler:       _SpookyStore_WatchProducts_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchNumTransactions",
+			Handler:       _SpookyStore_WatchNumTransactions_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "spookystore.proto",
 }
 
 func init() { proto.RegisterFile("spookystore.proto", fileDescriptor_213487394ea54d54) }
 
 var fileDescriptor_213487394ea54d54 = []byte{
-	// 723 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x94, 0xdd, 0x4e, 0xdb, 0x48,
-	0x14, 0xc7, 0xed, 0x7c, 0x90, 0xe4, 0x38, 0x7c, 0xe4, 0xec, 0x2e, 0x18, 0xb3, 0xbb, 0x64, 0x67,
-	0x6f, 0xa2, 0x15, 0x0c, 0xab, 0xf4, 0xba, 0x1f, 0xc8, 0xa1, 0x51, 0x24, 0x84, 0xa8, 0x09, 0x0f,
-	0x60, 0x92, 0x29, 0x58, 0x38, 0x99, 0xd4, 0x33, 0xbe, 0xa0, 0xea, 0x4b, 0xf4, 0x75, 0xfa, 0x16,
-	0x7d, 0xa3, 0xca, 0xe3, 0x71, 0xe2, 0xd8, 0x44, 0x45, 0xbd, 0x82, 0xf3, 0x9f, 0xe3, 0x39, 0xff,
-	0x33, 0xe7, 0x97, 0x03, 0x1d, 0xb1, 0xe0, 0xfc, 0xf1, 0x49, 0x48, 0x1e, 0x31, 0xba, 0x88, 0xb8,
-	0xe4, 0xce, 0xf1, 0x3d, 0xe7, 0xf7, 0x21, 0x3b, 0x53, 0xd1, 0x5d, 0xfc, 0xf1, 0x4c, 0x06, 0x33,
-	0x26, 0xa4, 0x3f, 0x5b, 0xa4, 0x09, 0xe4, 0xbb, 0x09, 0xb5, 0x5b, 0xc1, 0x22, 0x74, 0xa0, 0x39,
-	0x54, 0xb9, 0xa3, 0x81, 0x6d, 0x76, 0xcd, 0x5e, 0xcb, 0x5b, 0xc6, 0xb8, 0x03, 0x95, 0xd1, 0xc0,
-	0xae, 0x28, 0xb5, 0x32, 0x1a, 0x60, 0x17, 0xac, 0x41, 0x20, 0x16, 0xa1, 0xff, 0x74, 0xe5, 0xcf,
-	0x98, 0x5d, 0x55, 0x07, 0x79, 0x09, 0x6d, 0x68, 0x5c, 0x07, 0x13, 0x19, 0x47, 0xcc, 0xae, 0xa9,
-	0xd3, 0x2c, 0xc4, 0x43, 0xa8, 0xb9, 0x7e, 0x24, 0xed, 0x7a, 0xd7, 0xec, 0x59, 0xfd, 0x3a, 0x4d,
-	0x02, 0x4f, 0x49, 0xf8, 0x3f, 0xb4, 0xc7, 0x91, 0x3f, 0x17, 0xfe, 0x44, 0x06, 0x7c, 0x2e, 0xec,
-	0xad, 0x6e, 0xb5, 0x67, 0xf5, 0xdb, 0x34, 0x27, 0x7a, 0x6b, 0x19, 0xf8, 0x3b, 0xd4, 0x2f, 0x66,
-	0x7e, 0x10, 0xda, 0x0d, 0x55, 0x24, 0x0d, 0xc8, 0x57, 0x13, 0x1a, 0xd7, 0x11, 0x9f, 0xc6, 0x13,
-	0xa9, 0xad, 0x9b, 0x9b, 0xac, 0x57, 0xca, 0xd6, 0xff, 0x06, 0xd0, 0x5e, 0x6f, 0xbd, 0x4b, 0xdd,
-	0x5b, 0x4e, 0x41, 0x84, 0x9a, 0xcb, 0x85, 0x54, 0x7d, 0x55, 0x3c, 0xf5, 0xbf, 0xba, 0x95, 0x89,
-	0x49, 0x14, 0x2c, 0x12, 0x5f, 0xaa, 0xb7, 0xe4, 0xd6, 0x95, 0x44, 0x2e, 0xd2, 0xb6, 0xf1, 0x18,
-	0xea, 0x23, 0xc9, 0x66, 0xc2, 0x36, 0x55, 0x73, 0x2d, 0xd5, 0x7f, 0xa2, 0x78, 0xa9, 0x8e, 0x7f,
-	0x42, 0x6b, 0xcc, 0xa5, 0x1f, 0xaa, 0x1a, 0x15, 0x55, 0x63, 0x25, 0x90, 0x10, 0x9a, 0xd9, 0x07,
-	0xbf, 0xd0, 0xda, 0x73, 0xd6, 0x1d, 0x68, 0x7e, 0x88, 0xfd, 0xb9, 0x0c, 0xe4, 0x93, 0xf2, 0x5d,
-	0xf7, 0x96, 0x31, 0xf9, 0x02, 0x56, 0xee, 0xb9, 0x4b, 0x05, 0xdf, 0xc1, 0xb6, 0xcb, 0x67, 0x8b,
-	0x90, 0x49, 0x36, 0x1d, 0x07, 0xba, 0xa4, 0xd5, 0x77, 0x68, 0x0a, 0x1d, 0xcd, 0xa0, 0xa3, 0xe3,
-	0x0c, 0x3a, 0x6f, 0xfd, 0x03, 0x3c, 0xca, 0x5e, 0xa3, 0x9a, 0xa7, 0x21, 0xd5, 0xc8, 0x1b, 0xc0,
-	0x5c, 0x75, 0x97, 0xc7, 0x73, 0xc9, 0x22, 0xec, 0xc1, 0xee, 0x55, 0x3c, 0x5b, 0xe3, 0xc4, 0x54,
-	0xb6, 0x8b, 0x32, 0xf9, 0x0b, 0xac, 0x84, 0x6c, 0x8f, 0x7d, 0x8a, 0x99, 0x28, 0x91, 0x40, 0xde,
-	0x42, 0x3b, 0x3d, 0x16, 0x0b, 0x3e, 0x17, 0x2c, 0x61, 0xe9, 0x3d, 0x8f, 0xe7, 0x53, 0x95, 0xd2,
-	0xf4, 0xd2, 0x20, 0xc1, 0x35, 0xc9, 0xd2, 0xad, 0xd5, 0xa9, 0xfa, 0x44, 0x49, 0xe4, 0x5f, 0xe8,
-	0x0c, 0x99, 0xd4, 0xa0, 0x6d, 0xaa, 0x72, 0x00, 0x7f, 0x0c, 0x99, 0x3c, 0x0f, 0x43, 0x9d, 0x27,
-	0x74, 0x22, 0x19, 0xc0, 0x7e, 0xf1, 0x40, 0x1b, 0xf9, 0x0f, 0x2c, 0xad, 0x5d, 0x06, 0x42, 0x6a,
-	0x50, 0x9a, 0x34, 0x2b, 0x94, 0x3f, 0x24, 0x0c, 0x3a, 0xe7, 0xd3, 0x69, 0xc1, 0xc3, 0x3e, 0x6c,
-	0x25, 0x06, 0x97, 0x3e, 0x74, 0x94, 0xa0, 0xa5, 0x33, 0x97, 0xbf, 0xe6, 0x95, 0xb0, 0x06, 0x42,
-	0xb5, 0x00, 0x02, 0x05, 0xcc, 0x97, 0xd1, 0x46, 0x6d, 0x68, 0xdc, 0xc4, 0x93, 0x09, 0x13, 0x42,
-	0xbf, 0x59, 0x16, 0x92, 0x23, 0x38, 0x1c, 0x32, 0x59, 0x18, 0x48, 0xd6, 0xb9, 0x0b, 0x07, 0xa5,
-	0x13, 0x7d, 0xe3, 0xcb, 0x87, 0x7b, 0x0a, 0x1d, 0x37, 0x64, 0x7e, 0xa4, 0x80, 0xf9, 0xb9, 0xa1,
-	0x13, 0xd8, 0x73, 0x1f, 0xd8, 0xe4, 0x91, 0xc7, 0x2f, 0xc8, 0xee, 0x7f, 0xab, 0x82, 0x75, 0xa3,
-	0x76, 0xe9, 0x4d, 0xb2, 0x4b, 0xf1, 0x1f, 0xd8, 0x3d, 0x8f, 0xe5, 0x03, 0x8f, 0x82, 0xcf, 0x2c,
-	0x5d, 0x8a, 0x98, 0x92, 0xe0, 0xa4, 0x7f, 0x88, 0x81, 0x3d, 0x68, 0x0c, 0x99, 0x54, 0x9b, 0xb4,
-	0x4d, 0x73, 0xd8, 0x39, 0xdb, 0x34, 0x4f, 0x19, 0x31, 0xd0, 0x85, 0x9d, 0xf5, 0xc1, 0xe3, 0x3e,
-	0x7d, 0x16, 0x11, 0xe7, 0x80, 0x3e, 0x4f, 0x08, 0x31, 0xf0, 0x04, 0x60, 0xc5, 0x1e, 0x22, 0x2d,
-	0x81, 0xe8, 0x2c, 0x81, 0x21, 0x06, 0xbe, 0x86, 0xbd, 0xd5, 0xf8, 0xc6, 0x5c, 0x2d, 0x22, 0xa4,
-	0x25, 0x70, 0x9c, 0xdf, 0x68, 0x79, 0xca, 0xc4, 0xc0, 0x33, 0x68, 0x2d, 0xdf, 0xba, 0xd0, 0x1d,
-	0xd2, 0xd2, 0x14, 0x88, 0x81, 0xa7, 0xd0, 0xcc, 0x5e, 0xbb, 0x90, 0xdf, 0xa1, 0xc5, 0x31, 0x10,
-	0x03, 0x2f, 0x01, 0xcb, 0xb4, 0xa0, 0x43, 0x37, 0x22, 0xe4, 0xd8, 0x74, 0x03, 0x41, 0xc4, 0xb8,
-	0xdb, 0x52, 0x6b, 0xe7, 0xd5, 0x8f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xe9, 0xd0, 0x11, 0x43, 0x0e,
-	0x07, 0x00, 0x00,
+	// 1886 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0x4f, 0x57, 0x1b, 0xc9,
+	0x11, 0x47, 0x12, 0x02, 0xa9, 0x04, 0x42, 0x6a, 0x90, 0x18, 0xc6, 0x36, 0x66, 0xdb, 0xf6, 0x8b,
+	0x83, 0x77, 0x5b, 0x5e, 0xb2, 0x97, 0xf8, 0xbd, 0x38, 0xcb, 0x13, 0x5a, 0xa2, 0x5d, 0x16, 0xb3,
+	0x23, 0x39, 0xde, 0x5b, 0x32, 0x96, 0x1a, 0x3c, 0x8b, 0x34, 0xa3, 0xcc, 0xb4, 0x9c, 0x25, 0x8e,
+	0x2f, 0x39, 0x24, 0xe7, 0xbc, 0xdc, 0xf3, 0x61, 0x72, 0xce, 0x2d, 0x5f, 0x61, 0x3f, 0x48, 0x5e,
+	0xd7, 0x74, 0xcf, 0x3f, 0x0d, 0xe0, 0xf8, 0xe5, 0x02, 0x53, 0xd5, 0xd5, 0xf5, 0xbf, 0xab, 0x7e,
+	0x82, 0x66, 0x30, 0xf3, 0xbc, 0xcb, 0xab, 0x40, 0x78, 0x3e, 0x67, 0x33, 0xdf, 0x13, 0x9e, 0x79,
+	0xf7, 0xc2, 0xf3, 0x2e, 0x26, 0xbc, 0x63, 0xcf, 0x9c, 0x8e, 0xed, 0xba, 0x9e, 0xb0, 0x85, 0xe3,
+	0xb9, 0x81, 0x3a, 0xbd, 0xaf, 0x4e, 0x91, 0x7a, 0x3d, 0x3f, 0xef, 0x08, 0x67, 0xca, 0x03, 0x61,
+	0x4f, 0x67, 0xa1, 0x00, 0xfd, 0x5b, 0x11, 0x96, 0x5f, 0x06, 0xdc, 0x27, 0x26, 0x54, 0x8e, 0x51,
+	0xb6, 0x7f, 0x64, 0x14, 0xf6, 0x0a, 0x8f, 0xab, 0x56, 0x44, 0x93, 0x3a, 0x14, 0xfb, 0x47, 0x46,
+	0x11, 0xb9, 0xc5, 0xfe, 0x11, 0xd9, 0x83, 0xda, 0x91, 0x13, 0xcc, 0x26, 0xf6, 0xd5, 0xa9, 0x3d,
+	0xe5, 0x46, 0x09, 0x0f, 0x92, 0x2c, 0x62, 0xc0, 0xea, 0x99, 0x33, 0x12, 0x73, 0x9f, 0x1b, 0xcb,
+	0x78, 0xaa, 0x49, 0xb2, 0x03, 0xcb, 0x5d, 0xdb, 0x17, 0x46, 0x79, 0xaf, 0xf0, 0xb8, 0x76, 0x50,
+	0x66, 0x92, 0xb0, 0x90, 0x45, 0x9e, 0xc2, 0xda, 0xd0, 0xb7, 0xdd, 0xc0, 0x1e, 0x61, 0x08, 0xc6,
+	0xca, 0x5e, 0xe9, 0x71, 0xed, 0x60, 0x8d, 0x25, 0x98, 0x56, 0x4a, 0x82, 0x6c, 0x41, 0xb9, 0x37,
+	0xb5, 0x9d, 0x89, 0xb1, 0x8a, 0x46, 0x42, 0x42, 0x72, 0x2d, 0x6f, 0xc2, 0x03, 0xa3, 0xb2, 0x57,
+	0x92, 0x5c, 0x24, 0xc8, 0x2e, 0x40, 0x7f, 0xcc, 0x5d, 0xe1, 0x08, 0x87, 0x07, 0x46, 0x15, 0x8f,
+	0x12, 0x1c, 0xfa, 0xf7, 0x02, 0xac, 0x9e, 0xf9, 0xde, 0x78, 0x3e, 0x12, 0x2a, 0xe0, 0xc2, 0x75,
+	0x01, 0x17, 0x17, 0x03, 0xde, 0x05, 0x50, 0x11, 0xbe, 0xb4, 0x4e, 0x54, 0x46, 0x12, 0x1c, 0x42,
+	0x60, 0xb9, 0xeb, 0x05, 0x02, 0xb3, 0x51, 0xb4, 0xf0, 0x1b, 0xb5, 0xf2, 0x60, 0xe4, 0x3b, 0x33,
+	0x19, 0x8d, 0x51, 0x56, 0x5a, 0x63, 0x16, 0xed, 0x85, 0xc9, 0x22, 0xf7, 0xa1, 0xdc, 0x17, 0x7c,
+	0x1a, 0x18, 0x05, 0x4c, 0x49, 0x15, 0xb3, 0x26, 0x39, 0x56, 0xc8, 0x27, 0x77, 0xa1, 0x3a, 0xf4,
+	0x84, 0x3d, 0x41, 0x1b, 0x45, 0xb4, 0x11, 0x33, 0xe8, 0x04, 0x2a, 0xfa, 0xc2, 0x47, 0x84, 0x96,
+	0xe7, 0xba, 0x09, 0x95, 0xef, 0xe6, 0xb6, 0x4c, 0xdd, 0x15, 0xfa, 0x5d, 0xb6, 0x22, 0x9a, 0xfe,
+	0x19, 0x6a, 0x89, 0x22, 0x2d, 0x18, 0xfc, 0x12, 0xd6, 0xbb, 0xde, 0x74, 0x36, 0xe1, 0x82, 0x8f,
+	0x87, 0x8e, 0x32, 0x59, 0x3b, 0x30, 0x59, 0xd8, 0xaa, 0x4c, 0xb7, 0x2a, 0x1b, 0xea, 0x56, 0xb5,
+	0xd2, 0x17, 0xc8, 0x1d, 0x9d, 0x8d, 0x52, 0xb2, 0x87, 0x42, 0x1e, 0x7d, 0x0e, 0x24, 0x61, 0xbd,
+	0xeb, 0xcd, 0x5d, 0xc1, 0x7d, 0xf2, 0x18, 0x36, 0x4e, 0xe7, 0xd3, 0x54, 0x77, 0x15, 0xd0, 0xed,
+	0x2c, 0x9b, 0xde, 0x83, 0x9a, 0x7c, 0x0f, 0x16, 0xff, 0xc3, 0x9c, 0x07, 0x0b, 0x9d, 0x40, 0x7f,
+	0x0d, 0x6b, 0xe1, 0x71, 0x30, 0xf3, 0xdc, 0x80, 0xcb, 0x5e, 0xfb, 0xca, 0x9b, 0xbb, 0x63, 0x14,
+	0xa9, 0x58, 0x21, 0x21, 0x9b, 0x5c, 0x4a, 0xa9, 0xd0, 0xca, 0x0c, 0xaf, 0x20, 0x8b, 0x3e, 0x80,
+	0xe6, 0x31, 0x17, 0xaa, 0xd1, 0xae, 0xb3, 0xb2, 0x0d, 0xad, 0x63, 0x2e, 0x0e, 0x27, 0x13, 0x25,
+	0x17, 0x28, 0x41, 0x7a, 0x04, 0xed, 0xec, 0x81, 0x72, 0x64, 0x1f, 0x6a, 0x8a, 0x77, 0xe2, 0x04,
+	0x42, 0x35, 0x4a, 0x85, 0x69, 0x43, 0xc9, 0x43, 0xca, 0xa1, 0x79, 0x38, 0x1e, 0x67, 0x7c, 0x68,
+	0xc3, 0x8a, 0x74, 0x30, 0xf2, 0x43, 0x51, 0xb2, 0xb5, 0x94, 0x64, 0x34, 0x03, 0x62, 0x46, 0xaa,
+	0x11, 0x4a, 0x99, 0x46, 0x78, 0x0e, 0x24, 0x69, 0x46, 0x39, 0xaa, 0x07, 0x40, 0x71, 0x61, 0x00,
+	0x7c, 0xbd, 0x5c, 0x29, 0x34, 0x8a, 0xd6, 0xea, 0x60, 0x3e, 0x1a, 0xf1, 0x20, 0xa0, 0x77, 0x60,
+	0xe7, 0x98, 0x8b, 0x4c, 0x81, 0x74, 0x26, 0xba, 0xb0, 0xbd, 0x70, 0xa2, 0x2c, 0x7c, 0x78, 0xb1,
+	0x29, 0x34, 0xbb, 0x13, 0x6e, 0xfb, 0xe8, 0x83, 0xba, 0x9e, 0xf5, 0xe2, 0x3b, 0x68, 0x74, 0xdf,
+	0xf0, 0xd1, 0xa5, 0x37, 0x8f, 0x63, 0x60, 0xa9, 0x16, 0x57, 0xa1, 0xa4, 0x07, 0x55, 0x52, 0x20,
+	0xab, 0xf2, 0x4b, 0x68, 0xc8, 0x3a, 0xc8, 0x04, 0xeb, 0x78, 0x64, 0x23, 0x9d, 0x38, 0x53, 0x47,
+	0x28, 0x57, 0x43, 0x42, 0x16, 0xe5, 0xc5, 0xf9, 0x79, 0xc0, 0xc3, 0x74, 0x95, 0x2d, 0x45, 0xd1,
+	0xa7, 0xd0, 0x4c, 0x68, 0x50, 0x5e, 0xdd, 0x81, 0x32, 0x32, 0x54, 0xf1, 0x55, 0xdb, 0x85, 0x3c,
+	0xfa, 0x2b, 0x68, 0xf5, 0xa7, 0x33, 0xcf, 0x17, 0x99, 0x96, 0x22, 0x0f, 0xa1, 0xa2, 0x59, 0x0b,
+	0x5d, 0x13, 0x9d, 0xd0, 0x53, 0x68, 0x67, 0xaf, 0x2b, 0xab, 0x06, 0xac, 0x76, 0x7d, 0x6e, 0x0b,
+	0x3e, 0x56, 0xae, 0x6b, 0x52, 0xf6, 0x46, 0xef, 0x47, 0x27, 0x10, 0x8e, 0x7b, 0xa1, 0xdc, 0x8f,
+	0x68, 0xda, 0x85, 0xcd, 0x01, 0x47, 0xff, 0x71, 0x3a, 0xdf, 0xd6, 0x84, 0xd1, 0x48, 0x2f, 0x26,
+	0x46, 0x3a, 0xfd, 0x57, 0x01, 0xc8, 0x89, 0xe3, 0x5e, 0x1e, 0x8e, 0x46, 0xf2, 0x9d, 0x6b, 0x25,
+	0x26, 0x46, 0xf4, 0xd6, 0x19, 0x73, 0x5f, 0xaf, 0x32, 0x4d, 0x4b, 0x6f, 0x07, 0xf3, 0xd7, 0x3f,
+	0xf0, 0x91, 0x50, 0xbd, 0xac, 0xc9, 0x78, 0x97, 0x94, 0x92, 0xbb, 0xe4, 0x21, 0xac, 0xe3, 0xc7,
+	0x6f, 0xb9, 0xef, 0x9c, 0x3b, 0x7c, 0x8c, 0x53, 0xb0, 0x62, 0xa5, 0x99, 0xd9, 0x21, 0x5a, 0xbe,
+	0x71, 0x21, 0xae, 0xa4, 0x16, 0x22, 0xe5, 0xb0, 0x61, 0xf1, 0x0b, 0x27, 0x10, 0xf1, 0xd0, 0x89,
+	0x5c, 0x29, 0x24, 0x5d, 0x91, 0x61, 0xd9, 0x41, 0xf0, 0x47, 0xcf, 0x1f, 0x2b, 0xdf, 0x23, 0xfa,
+	0xf6, 0x8d, 0x4c, 0xbf, 0x87, 0x46, 0x6c, 0x46, 0x95, 0xee, 0xba, 0x6c, 0x7f, 0x0a, 0xcd, 0x30,
+	0xb4, 0x11, 0x82, 0x89, 0xa1, 0x77, 0xc9, 0x5d, 0x65, 0x72, 0xf1, 0x80, 0xee, 0x03, 0x41, 0xe6,
+	0x15, 0xba, 0x99, 0x88, 0x21, 0xbc, 0xa7, 0x62, 0x08, 0x65, 0x7b, 0xd0, 0x3c, 0xf1, 0x2e, 0x1c,
+	0xf7, 0xc4, 0x1b, 0xd9, 0x93, 0x8f, 0x0e, 0x97, 0x7e, 0x0a, 0x5b, 0xfa, 0xdb, 0xe2, 0x01, 0x17,
+	0x37, 0x6a, 0xa2, 0x9f, 0x41, 0x2b, 0x23, 0x1d, 0x0f, 0xef, 0x1c, 0x1f, 0x7f, 0x03, 0x5b, 0x28,
+	0x16, 0xdf, 0xb9, 0x21, 0xa2, 0x1b, 0xdd, 0xfc, 0xa9, 0x00, 0x95, 0xc3, 0xb3, 0x7e, 0x28, 0x98,
+	0xdd, 0x83, 0x71, 0xf2, 0x8b, 0xa9, 0xe4, 0x13, 0x58, 0x4e, 0xd4, 0x10, 0xbf, 0xa5, 0xec, 0x60,
+	0xe4, 0xcd, 0x78, 0x60, 0x2c, 0x63, 0xff, 0x2b, 0x8a, 0x7c, 0x11, 0xbf, 0xbd, 0xf2, 0xad, 0x5b,
+	0x34, 0x7a, 0x97, 0x5f, 0xc0, 0x6a, 0xef, 0xc7, 0x99, 0xe3, 0xf3, 0xc0, 0x58, 0xb9, 0xfd, 0x96,
+	0x12, 0x95, 0x1d, 0x6c, 0xf1, 0xb7, 0xde, 0x25, 0x1f, 0x23, 0xda, 0xaa, 0x58, 0x9a, 0xa4, 0xef,
+	0xa0, 0x15, 0xaa, 0xd6, 0xb1, 0xde, 0xf6, 0x9a, 0x75, 0x88, 0xc5, 0xdc, 0x10, 0x4b, 0xa9, 0x10,
+	0x77, 0x01, 0x86, 0xc3, 0x93, 0x01, 0x1f, 0x79, 0xee, 0x38, 0xc0, 0xd7, 0x57, 0xb2, 0x12, 0x1c,
+	0xfa, 0x2d, 0xb4, 0xb3, 0xc6, 0x6f, 0xaa, 0x2e, 0xb9, 0x07, 0xcb, 0x7d, 0xf7, 0xdc, 0x53, 0x33,
+	0xbb, 0xca, 0xa2, 0x6b, 0xc8, 0xa6, 0xcf, 0xa0, 0x25, 0x07, 0xab, 0xe6, 0xc6, 0x63, 0xee, 0x13,
+	0x58, 0x09, 0x39, 0x11, 0x06, 0x8b, 0x6e, 0xaa, 0x03, 0xda, 0x87, 0x56, 0x98, 0x92, 0x0f, 0xcd,
+	0x83, 0x01, 0xab, 0x28, 0x17, 0xf5, 0x80, 0x26, 0x69, 0x1b, 0xb6, 0x5e, 0xd9, 0x62, 0xf4, 0x26,
+	0xbb, 0xff, 0xff, 0x5a, 0x80, 0x35, 0xc5, 0xeb, 0xbd, 0xe5, 0xae, 0x20, 0x4f, 0x60, 0x79, 0x78,
+	0x35, 0xe3, 0xa8, 0xb8, 0x7e, 0xb0, 0xcd, 0x92, 0x87, 0x0c, 0xff, 0xca, 0x63, 0x0b, 0x85, 0x08,
+	0x8d, 0x10, 0xae, 0x0a, 0x3f, 0x9e, 0xf4, 0xfa, 0x80, 0x3e, 0x82, 0x6a, 0x74, 0x8d, 0xd4, 0x60,
+	0xf5, 0xe5, 0xe9, 0x37, 0xa7, 0x2f, 0x5e, 0x9d, 0x36, 0x96, 0x24, 0xd1, 0xb5, 0x7a, 0x87, 0xc3,
+	0xde, 0x51, 0xa3, 0x40, 0xff, 0x59, 0x80, 0xfa, 0x91, 0x2d, 0x6c, 0xfc, 0x29, 0xf2, 0x95, 0x3d,
+	0x9f, 0x08, 0x59, 0xd5, 0x6f, 0x1c, 0x85, 0x84, 0xaa, 0x16, 0x7e, 0xcb, 0xa6, 0x7f, 0x31, 0xd3,
+	0xbf, 0x1c, 0x5e, 0xcc, 0xa4, 0x8c, 0x65, 0x8b, 0xb0, 0xb9, 0x0b, 0x16, 0x7e, 0xcb, 0x11, 0x7b,
+	0x62, 0x0b, 0xee, 0x8e, 0xae, 0xbe, 0x75, 0x26, 0x13, 0x47, 0x17, 0x39, 0xcd, 0xc4, 0xa7, 0xed,
+	0xfb, 0x9e, 0xaf, 0x86, 0x6b, 0x48, 0xe0, 0x58, 0xb5, 0x7d, 0xe1, 0xd8, 0x13, 0x6c, 0xe5, 0x8a,
+	0xa5, 0x49, 0x05, 0x1e, 0xd2, 0x2e, 0x46, 0x69, 0xfc, 0x1e, 0x76, 0x06, 0xd7, 0x1d, 0x92, 0x9f,
+	0xc1, 0x4a, 0xc8, 0x50, 0x95, 0xde, 0x60, 0x69, 0x41, 0x4b, 0x1d, 0xcb, 0x60, 0x06, 0x9c, 0x87,
+	0xcf, 0xbe, 0x64, 0xe1, 0x37, 0x7d, 0x03, 0x1b, 0x19, 0xb5, 0xd2, 0xc7, 0x9e, 0x6b, 0xbf, 0x9e,
+	0x70, 0x0d, 0x12, 0x35, 0x99, 0xb0, 0x54, 0xfc, 0x30, 0x4b, 0xa5, 0xd8, 0xd2, 0xc1, 0xbf, 0xeb,
+	0x50, 0x1b, 0xe0, 0xcf, 0xc1, 0x81, 0xbc, 0x40, 0x3e, 0x81, 0x8d, 0xc3, 0xb9, 0x78, 0xe3, 0xf9,
+	0xce, 0x9f, 0x78, 0xf8, 0xcb, 0x8d, 0x84, 0x08, 0xc0, 0x0c, 0xff, 0xd1, 0x25, 0xf2, 0x1c, 0x56,
+	0x8f, 0xc3, 0xa5, 0x4b, 0xd6, 0x58, 0x02, 0xe5, 0x9a, 0xeb, 0x2c, 0x09, 0x6a, 0x69, 0xfb, 0x2f,
+	0xff, 0xf9, 0xe9, 0x1f, 0xc5, 0x06, 0xa9, 0x77, 0xde, 0x7e, 0xde, 0x99, 0x07, 0xdc, 0x0f, 0x3a,
+	0xef, 0xfa, 0x47, 0xef, 0xc9, 0x2b, 0xa8, 0xa7, 0xd1, 0x27, 0x69, 0xb3, 0x5c, 0x9c, 0x6a, 0x6e,
+	0xb3, 0x7c, 0x98, 0x4a, 0xb7, 0x50, 0x75, 0x9d, 0xac, 0x49, 0xd5, 0x33, 0xad, 0xa6, 0x07, 0x10,
+	0x83, 0x62, 0x42, 0xd8, 0x02, 0x42, 0x36, 0xa3, 0x4e, 0xa5, 0x3b, 0xa8, 0x61, 0x93, 0x34, 0x93,
+	0x1a, 0x42, 0xff, 0x7e, 0x07, 0x8d, 0x18, 0x70, 0x0e, 0x3d, 0xfc, 0xe9, 0x44, 0xd8, 0x02, 0xd4,
+	0x35, 0x37, 0xd9, 0x22, 0x2e, 0xa5, 0x14, 0xf5, 0xde, 0xa5, 0xdb, 0x89, 0xa0, 0xc3, 0x77, 0xfa,
+	0xbe, 0x33, 0xb2, 0x7d, 0xf1, 0xac, 0xb0, 0x4f, 0xbe, 0x86, 0x6a, 0x84, 0x17, 0x33, 0x29, 0x24,
+	0x6c, 0x01, 0x49, 0xd2, 0x3b, 0xa8, 0xb2, 0xb5, 0xbf, 0x99, 0xce, 0x23, 0xaa, 0x23, 0x27, 0x50,
+	0xd1, 0xb8, 0x32, 0xa3, 0xaa, 0xc9, 0xb2, 0x80, 0x93, 0xde, 0x47, 0x4d, 0x3b, 0x74, 0x3b, 0xab,
+	0x49, 0x6b, 0xf8, 0x01, 0xc8, 0x22, 0x56, 0x26, 0x26, 0xbb, 0x16, 0x40, 0x9b, 0x06, 0xbb, 0x06,
+	0x3f, 0xd3, 0x5d, 0x34, 0x66, 0x90, 0xb6, 0x34, 0x26, 0x12, 0x12, 0x1d, 0x84, 0x59, 0xa4, 0x07,
+	0xd5, 0x08, 0x7c, 0x92, 0x26, 0xcb, 0x42, 0x59, 0x93, 0xb0, 0x05, 0x6c, 0x4a, 0x9b, 0xa8, 0xb3,
+	0x46, 0xaa, 0x51, 0x00, 0xc4, 0x86, 0x7a, 0x1a, 0x52, 0x92, 0x36, 0xcb, 0x85, 0xa8, 0xe6, 0x36,
+	0xcb, 0xc7, 0x9e, 0xda, 0x53, 0xba, 0x99, 0xec, 0x85, 0x67, 0x0e, 0x0a, 0xcb, 0x7a, 0x9d, 0xc1,
+	0x5a, 0x12, 0x65, 0x92, 0x2d, 0x96, 0x03, 0x3a, 0xf5, 0xfb, 0x78, 0x80, 0xca, 0xee, 0x99, 0x46,
+	0x4e, 0x03, 0xf8, 0x52, 0x5e, 0x6a, 0x7c, 0x02, 0xb5, 0x04, 0xe2, 0x24, 0x9b, 0x6c, 0x11, 0x7f,
+	0xc6, 0xef, 0xed, 0x73, 0xa8, 0x68, 0xcc, 0x45, 0x1a, 0x2c, 0x83, 0xf2, 0xcc, 0x26, 0xcb, 0x02,
+	0x32, 0xba, 0x24, 0xf5, 0x27, 0xc0, 0x14, 0xd9, 0x64, 0x8b, 0xd0, 0x2a, 0xd6, 0xff, 0x73, 0x80,
+	0x18, 0x4d, 0x11, 0xc2, 0x16, 0xa0, 0x55, 0x2c, 0x7a, 0x0c, 0x5b, 0x8a, 0x97, 0x82, 0x42, 0xa4,
+	0xc5, 0xf2, 0x80, 0x94, 0xd9, 0x66, 0xb9, 0x88, 0x89, 0x2e, 0x91, 0x0e, 0xac, 0xa7, 0xd0, 0x11,
+	0x69, 0xb1, 0x3c, 0xb4, 0x14, 0x5b, 0xbe, 0x80, 0x7a, 0x7a, 0x41, 0x93, 0x36, 0xcb, 0x85, 0x0b,
+	0xe6, 0x36, 0xcb, 0xdf, 0xe4, 0xf4, 0x21, 0x56, 0x66, 0x97, 0xee, 0xe4, 0x54, 0x46, 0x48, 0x49,
+	0x2c, 0xcd, 0x10, 0xd6, 0x53, 0xab, 0x3b, 0xf3, 0xaa, 0xda, 0x2c, 0x77, 0xb1, 0xd3, 0x7b, 0xa8,
+	0x7c, 0x9b, 0xb4, 0x32, 0x4f, 0x2b, 0x54, 0x4c, 0x7e, 0x0f, 0xf5, 0xf4, 0x52, 0x27, 0x6d, 0x96,
+	0xbb, 0xe5, 0xcd, 0x18, 0x11, 0xd0, 0x27, 0xa8, 0xf3, 0xd1, 0xfe, 0x83, 0x6b, 0x1d, 0xee, 0xbc,
+	0x53, 0xab, 0xfe, 0x3d, 0x39, 0xc7, 0xa7, 0x9b, 0xdd, 0x1a, 0x26, 0x5b, 0x64, 0x6a, 0x4b, 0x8d,
+	0xcc, 0x9e, 0x08, 0xf4, 0xf0, 0x22, 0xa6, 0x34, 0x68, 0x8f, 0xa7, 0x8e, 0xdb, 0x19, 0x6b, 0x91,
+	0xcf, 0xce, 0x43, 0x8d, 0x0e, 0x90, 0x41, 0x9e, 0x9d, 0xc1, 0xff, 0x60, 0xe7, 0x11, 0xda, 0xb9,
+	0x6f, 0xde, 0x60, 0x47, 0x96, 0xe2, 0x21, 0x54, 0x11, 0xbe, 0xe4, 0xcc, 0xc9, 0xf0, 0x07, 0x3f,
+	0x5d, 0x7a, 0x5a, 0x20, 0xbf, 0x84, 0xf5, 0x14, 0xc8, 0x21, 0x2d, 0x96, 0x07, 0x7a, 0xcc, 0xf5,
+	0x14, 0xaa, 0xc1, 0xab, 0x67, 0x0a, 0x1f, 0xfd, 0x7f, 0x06, 0xde, 0xd2, 0xd3, 0xc2, 0xeb, 0x15,
+	0xc4, 0xbe, 0xbf, 0xf8, 0xef, 0x00, 0xdc, 0x41, 0xc1, 0x62, 0x63, 0x15, 0x00, 0x00,
 }
@@ -0,0 +1,73 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"context"
+
+	"cloud.google.com/go/datastore"
+	dw "github.com/m-okeefe/spookystore/internal/datastore_wrapper"
+	"github.com/pkg/errors"
+)
+
+const sessionKind = "Session"
+
+// DatastoreStore keeps sessions as Session entities in Cloud Datastore, keyed
+// by session ID, so they are shared between web replicas.
+type DatastoreStore struct {
+	ds dw.DatastoreWrapper
+}
+
+func NewDatastoreStore(ds dw.DatastoreWrapper) *DatastoreStore {
+	return &DatastoreStore{ds: ds}
+}
+
+func (d *DatastoreStore) Get(ctx context.Context, id string) (*Session, error) {
+	var s Session
+	err := d.ds.Get(ctx, datastore.NameKey(sessionKind, id, nil), &s)
+	if err == datastore.ErrNoSuchEntity {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get session")
+	}
+	return &s, nil
+}
+
+func (d *DatastoreStore) Save(ctx context.Context, s *Session) error {
+	_, err := d.ds.Put(ctx, datastore.NameKey(sessionKind, s.ID, nil), s)
+	return errors.Wrap(err, "failed to save session")
+}
+
+func (d *DatastoreStore) Delete(ctx context.Context, id string) error {
+	err := d.ds.Delete(ctx, datastore.NameKey(sessionKind, id, nil))
+	if err == datastore.ErrNoSuchEntity {
+		return nil
+	}
+	return errors.Wrap(err, "failed to delete session")
+}
+
+func (d *DatastoreStore) DeleteUser(ctx context.Context, userID string) error {
+	q := datastore.NewQuery(sessionKind).Filter("UserID =", userID).KeysOnly()
+	keys, err := d.ds.GetAll(ctx, q, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to query sessions")
+	}
+	for _, k := range keys {
+		if err := d.ds.Delete(ctx, k); err != nil && err != datastore.ErrNoSuchEntity {
+			return errors.Wrap(err, "failed to delete session")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+	"github.com/pkg/errors"
+)
+
+// Keys protect session cookies. The first pair signs and encrypts new
+// cookies; the rest are only used to read cookies issued before a rotation.
+type Keys struct {
+	pairs [][]byte // hash key, block key, hash key, block key, ...
+}
+
+// ParseKeys parses a comma-separated list of hashKey:blockKey pairs, newest
+// first. Each key is base64 encoded. Hash keys must be 32 or 64 bytes and
+// block keys 16, 24 or 32 bytes.
+//
+// To rotate keys, put a new pair at the front of the list, and drop the
+// oldest pair once every cookie it issued has expired.
+func ParseKeys(s string) (*Keys, error) {
+	k := &Keys{}
+	for i, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("key pair %d: want hashKey:blockKey", i)
+		}
+		hash, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "key pair %d: failed to decode hash key", i)
+		}
+		block, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "key pair %d: failed to decode block key", i)
+		}
+		if l := len(hash); l != 32 && l != 64 {
+			return nil, errors.Errorf("key pair %d: hash key is %d bytes, want 32 or 64", i, l)
+		}
+		if l := len(block); l != 16 && l != 24 && l != 32 {
+			return nil, errors.Errorf("key pair %d: block key is %d bytes, want 16, 24 or 32", i, l)
+		}
+		k.pairs = append(k.pairs, hash, block)
+	}
+	if len(k.pairs) == 0 {
+		return nil, errors.New("no session keys given")
+	}
+	return k, nil
+}
+
+// RandomKeys returns a freshly generated key pair. Cookies protected by it do
+// not survive a restart, and are not readable by other replicas.
+func RandomKeys() *Keys {
+	return &Keys{pairs: [][]byte{
+		securecookie.GenerateRandomKey(64),
+		securecookie.GenerateRandomKey(32),
+	}}
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/jonboulle/clockwork"
+	"github.com/pkg/errors"
+)
+
+const (
+	cookieName = "session"
+
+	// touchInterval limits how often LastSeen is written back to the store
+	touchInterval = time.Minute
+)
+
+// Manager ties sessions in a Store to browser cookies.
+type Manager struct {
+	store  Store
+	codecs []securecookie.Codec
+	clock  clockwork.Clock
+
+	maxAge      time.Duration
+	idleTimeout time.Duration
+	secure      bool
+}
+
+// Options configures a Manager.
+type Options struct {
+	// MaxAge ends a session this long after login, however active it is.
+	MaxAge time.Duration
+	// IdleTimeout ends a session that has not been used for this long.
+	IdleTimeout time.Duration
+	// Secure restricts the cookie to HTTPS.
+	Secure bool
+}
+
+// NewManager returns a Manager that stores sessions in store and protects the
+// cookie with keys, as returned by ParseKeys.
+func NewManager(store Store, keys *Keys, clock clockwork.Clock, opts Options) *Manager {
+	codecs := make([]securecookie.Codec, 0, len(keys.pairs)/2)
+	for i := 0; i+1 < len(keys.pairs); i += 2 {
+		codecs = append(codecs, securecookie.New(keys.pairs[i], keys.pairs[i+1]).
+			MaxAge(int(opts.MaxAge.Seconds())).
+			SetSerializer(securecookie.JSONEncoder{}))
+	}
+	return &Manager{
+		store:       store,
+		codecs:      codecs,
+		clock:       clock,
+		maxAge:      opts.MaxAge,
+		idleTimeout: opts.IdleTimeout,
+		secure:      opts.Secure,
+	}
+}
+
+// Encode signs and encrypts a value for a cookie with the current key.
+func (m *Manager) Encode(name string, value interface{}) (string, error) {
+	return securecookie.EncodeMulti(name, value, m.codecs...)
+}
+
+// Decode verifies and decrypts a cookie value, trying every configured key.
+func (m *Manager) Decode(name, value string, dst interface{}) error {
+	return securecookie.DecodeMulti(name, value, dst, m.codecs...)
+}
+
+// Load returns the live session of the request. It returns a nil session and
+// no error if the request has no valid session cookie, or its session has
+// expired or been revoked.
+func (m *Manager) Load(ctx context.Context, r *http.Request) (*Session, error) {
+	c, err := r.Cookie(cookieName)
+	if err == http.ErrNoCookie {
+		return nil, nil
+	}
+	var id string
+	if err := m.Decode(cookieName, c.Value, &id); err != nil {
+		return nil, nil
+	}
+
+	s, err := m.store.Get(ctx, id)
+	if err == ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := m.clock.Now()
+	if m.expired(s, now) {
+		return nil, m.store.Delete(ctx, s.ID)
+	}
+	if now.Sub(s.LastSeen) > touchInterval {
+		s.LastSeen = now
+		if err := m.store.Save(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (m *Manager) expired(s *Session, now time.Time) bool {
+	if m.maxAge > 0 && now.Sub(s.Created) > m.maxAge {
+		return true
+	}
+	if m.idleTimeout > 0 && now.Sub(s.LastSeen) > m.idleTimeout {
+		return true
+	}
+	return false
+}
+
+// Login starts a new session for userID and sets its cookie. Any session the
+// request already had is ended, so the session ID always changes on login.
+func (m *Manager) Login(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string) (*Session, error) {
+	if old, err := m.Load(ctx, r); err != nil {
+		return nil, err
+	} else if old != nil {
+		if err := m.store.Delete(ctx, old.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate session id")
+	}
+	csrf, err := newID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate csrf token")
+	}
+	now := m.clock.Now()
+	s := &Session{
+		ID:        id,
+		UserID:    userID,
+		Created:   now,
+		LastSeen:  now,
+		CSRFToken: csrf,
+	}
+	if err := m.store.Save(ctx, s); err != nil {
+		return nil, err
+	}
+
+	v, err := m.Encode(cookieName, s.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode session cookie")
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    v,
+		Path:     "/",
+		MaxAge:   int(m.maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return s, nil
+}
+
+// Logout ends the request's session, if any, and clears its cookie.
+func (m *Manager) Logout(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	defer m.clearCookie(w)
+	s, err := m.Load(ctx, r)
+	if err != nil || s == nil {
+		return err
+	}
+	return m.store.Delete(ctx, s.ID)
+}
+
+// LogoutAll ends every session of userID, on every device, and clears the
+// cookie of the current request.
+func (m *Manager) LogoutAll(ctx context.Context, w http.ResponseWriter, userID string) error {
+	defer m.clearCookie(w)
+	return m.store.DeleteUser(ctx, userID)
+}
+
+func (m *Manager) clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
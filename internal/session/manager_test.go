@@ -0,0 +1,190 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+var testOpts = Options{MaxAge: 24 * time.Hour, IdleTimeout: time.Hour}
+
+func keyPair(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32))) + ":" +
+		base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 16)))
+}
+
+func mustKeys(t *testing.T, s string) *Keys {
+	k, err := ParseKeys(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// login runs Login and returns a request carrying the resulting cookie
+func login(t *testing.T, m *Manager, r *http.Request, userID string) (*Session, *http.Request) {
+	w := httptest.NewRecorder()
+	s, err := m.Login(context.Background(), w, r, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		next.AddCookie(c)
+	}
+	return s, next
+}
+
+func TestLoadExpiry(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	m := NewManager(NewMemoryStore(), mustKeys(t, keyPair('a')), clock, testOpts)
+	ctx := context.Background()
+
+	_, r := login(t, m, httptest.NewRequest(http.MethodGet, "/", nil), "555")
+	if s, err := m.Load(ctx, r); err != nil || s == nil || s.UserID != "555" {
+		t.Fatalf("expected live session for 555, got %+v (err=%v)", s, err)
+	}
+
+	// activity keeps the session alive past the idle timeout...
+	for i := 0; i < 4; i++ {
+		clock.Advance(50 * time.Minute)
+		if s, _ := m.Load(ctx, r); s == nil {
+			t.Fatalf("session expired while active after %d checks", i)
+		}
+	}
+	// ...but not an idle gap longer than it
+	clock.Advance(61 * time.Minute)
+	if s, _ := m.Load(ctx, r); s != nil {
+		t.Errorf("expected idle session to expire")
+	}
+
+	_, r = login(t, m, httptest.NewRequest(http.MethodGet, "/", nil), "555")
+	for i := 0; i < 30; i++ {
+		clock.Advance(50 * time.Minute)
+		m.Load(ctx, r)
+	}
+	if s, _ := m.Load(ctx, r); s != nil {
+		t.Errorf("expected session to expire after its max age")
+	}
+}
+
+func TestLoginRotatesSession(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(store, mustKeys(t, keyPair('a')), clockwork.NewFakeClock(), testOpts)
+	ctx := context.Background()
+
+	first, r := login(t, m, httptest.NewRequest(http.MethodGet, "/", nil), "555")
+	second, _ := login(t, m, r, "555")
+	if first.ID == second.ID {
+		t.Fatalf("expected a new session ID on login")
+	}
+	if _, err := store.Get(ctx, first.ID); err != ErrNotFound {
+		t.Errorf("expected previous session to be deleted, got %v", err)
+	}
+}
+
+func TestLoginCookieAndCSRF(t *testing.T) {
+	m := NewManager(NewMemoryStore(), mustKeys(t, keyPair('a')), clockwork.NewFakeClock(), testOpts)
+	w := httptest.NewRecorder()
+	s, err := m.Login(context.Background(), w, httptest.NewRequest(http.MethodGet, "/", nil), "555")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].SameSite != http.SameSiteLaxMode || !cookies[0].HttpOnly {
+		t.Errorf("expected one HttpOnly, SameSite=Lax cookie, got %+v", cookies)
+	}
+
+	if !s.ValidCSRF(s.CSRFToken) {
+		t.Errorf("expected the session's CSRF token to be valid")
+	}
+	for _, bad := range []string{"", s.ID, s.CSRFToken + "x"} {
+		if s.ValidCSRF(bad) {
+			t.Errorf("expected CSRF token %q to be invalid", bad)
+		}
+	}
+	if (&Session{ID: "old"}).ValidCSRF("") {
+		t.Errorf("expected a session without a CSRF token to accept none")
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	m := NewManager(NewMemoryStore(), mustKeys(t, keyPair('a')), clockwork.NewFakeClock(), testOpts)
+	ctx := context.Background()
+
+	_, laptop := login(t, m, httptest.NewRequest(http.MethodGet, "/", nil), "555")
+	_, phone := login(t, m, httptest.NewRequest(http.MethodGet, "/", nil), "555")
+	_, other := login(t, m, httptest.NewRequest(http.MethodGet, "/", nil), "777")
+
+	if err := m.LogoutAll(ctx, httptest.NewRecorder(), "555"); err != nil {
+		t.Fatal(err)
+	}
+	for name, r := range map[string]*http.Request{"laptop": laptop, "phone": phone} {
+		if s, _ := m.Load(ctx, r); s != nil {
+			t.Errorf("%s: expected session to be revoked", name)
+		}
+	}
+	if s, _ := m.Load(ctx, other); s == nil {
+		t.Errorf("expected other user's session to survive")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	store := NewMemoryStore()
+	clock := clockwork.NewFakeClock()
+	ctx := context.Background()
+
+	old := NewManager(store, mustKeys(t, keyPair('a')), clock, testOpts)
+	_, r := login(t, old, httptest.NewRequest(http.MethodGet, "/", nil), "555")
+
+	rotated := NewManager(store, mustKeys(t, keyPair('b')+","+keyPair('a')), clock, testOpts)
+	if s, _ := rotated.Load(ctx, r); s == nil {
+		t.Errorf("expected cookie from previous key to be accepted")
+	}
+
+	dropped := NewManager(store, mustKeys(t, keyPair('b')), clock, testOpts)
+	if s, _ := dropped.Load(ctx, r); s != nil {
+		t.Errorf("expected cookie from removed key to be rejected")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in         string
+		shouldPass bool
+	}{
+		{in: keyPair('a'), shouldPass: true},
+		{in: keyPair('a') + "," + keyPair('b'), shouldPass: true},
+		{in: "", shouldPass: false},
+		{in: "not-a-pair", shouldPass: false},
+		{in: "c2hvcnQ=:c2hvcnQ=", shouldPass: false},
+	}
+	for _, test := range tests {
+		_, err := ParseKeys(test.in)
+		if test.shouldPass && err != nil {
+			t.Errorf("ParseKeys(%q): %v", test.in, err)
+		} else if !test.shouldPass && err == nil {
+			t.Errorf("ParseKeys(%q): expected to fail", test.in)
+		}
+	}
+}
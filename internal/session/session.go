@@ -0,0 +1,68 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package session keeps web logins on the server. The browser only holds a
+// signed and encrypted session ID; the session itself lives in a Store, so it
+// can expire and be revoked independently of the cookie.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by a Store when no session has the requested ID.
+var ErrNotFound = errors.New("session not found")
+
+// Session is a logged-in browser.
+type Session struct {
+	ID       string    `datastore:"ID"`
+	UserID   string    `datastore:"UserID"`
+	Created  time.Time `datastore:"Created"`
+	LastSeen time.Time `datastore:"LastSeen"`
+	// CSRFToken must come with the requests of the session that change
+	// anything, so other sites cannot make them with its cookie.
+	CSRFToken string `datastore:"CSRFToken,noindex"`
+}
+
+// ValidCSRF reports whether token is the session's CSRF token. Sessions
+// started before they had one accept none.
+func (s *Session) ValidCSRF(token string) bool {
+	return s.CSRFToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.CSRFToken)) == 1
+}
+
+// Store persists sessions.
+type Store interface {
+	// Get returns the session with this ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Session, error)
+	// Save creates or replaces a session.
+	Save(ctx context.Context, s *Session) error
+	// Delete removes a session. Deleting a missing session is not an error.
+	Delete(ctx context.Context, id string) error
+	// DeleteUser removes every session belonging to a user.
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// newID returns a random, URL-safe session ID.
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// DefaultOTLPEndpoint is where a collector running next to the process takes
+// OTLP over HTTP.
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// Exporter sends finished spans somewhere.
+type Exporter interface {
+	// ExportSpans sends a batch of spans. It is not called concurrently.
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	// Shutdown releases the exporter, after the last batch.
+	Shutdown(ctx context.Context) error
+}
+
+// NewExporter returns the exporter called name: "none", "stdout", or "otlp",
+// which sends spans to the OTLP/HTTP endpoint.
+func NewExporter(name, endpoint string) (Exporter, error) {
+	switch name {
+	case "none", "":
+		return NoopExporter{}, nil
+	case "stdout":
+		return NewJSONExporter(os.Stdout), nil
+	case "otlp":
+		return NewOTLPExporter(endpoint), nil
+	default:
+		return nil, errors.Errorf("unknown trace exporter %q, want none, stdout or otlp", name)
+	}
+}
+
+// NoopExporter drops spans.
+type NoopExporter struct{}
+
+// ExportSpans does nothing.
+func (NoopExporter) ExportSpans(context.Context, []SpanData) error { return nil }
+
+// Shutdown does nothing.
+func (NoopExporter) Shutdown(context.Context) error { return nil }
+
+// JSONExporter writes spans to a writer, one JSON object per line.
+type JSONExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONExporter returns an exporter writing to w.
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{enc: json.NewEncoder(w)}
+}
+
+// ExportSpans writes spans.
+func (e *JSONExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range spans {
+		if err := e.enc.Encode(s); err != nil {
+			return errors.Wrap(err, "failed to write span")
+		}
+	}
+	return nil
+}
+
+// Shutdown does nothing.
+func (e *JSONExporter) Shutdown(context.Context) error { return nil }
+
+// OTLPExporter posts spans to an OpenTelemetry collector, in the JSON encoding
+// of OTLP over HTTP.
+type OTLPExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewOTLPExporter returns an exporter posting to endpoint, such as
+// DefaultOTLPEndpoint.
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	return &OTLPExporter{endpoint: endpoint, client: &http.Client{}}
+}
+
+// ExportSpans posts spans, grouped by service.
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	b, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return errors.Wrap(err, "failed to encode spans")
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "invalid otlp endpoint")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ctxhttp.Do(ctx, e.client, req)
+	if err != nil {
+		return errors.Wrap(err, "failed to post spans")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("collector answered %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Shutdown does nothing.
+func (e *OTLPExporter) Shutdown(context.Context) error { return nil }
+
+// The OTLP JSON encoding, for the fields spookystore uses
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+var otlpKinds = map[Kind]int{KindInternal: 1, KindServer: 2, KindClient: 3}
+
+func otlpRequest(spans []SpanData) otlpTraces {
+	var req otlpTraces
+	byService := map[string]int{}
+	for _, s := range spans {
+		i, ok := byService[s.Service]
+		if !ok {
+			i = len(req.ResourceSpans)
+			byService[s.Service] = i
+			rs := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{{}}}
+			rs.Resource.Attributes = []otlpKeyValue{{Key: "service.name", Value: otlpValue{s.Service}}}
+			rs.ScopeSpans[0].Scope.Name = "github.com/m-okeefe/spookystore/internal/tracing"
+			req.ResourceSpans = append(req.ResourceSpans, rs)
+		}
+		scope := &req.ResourceSpans[i].ScopeSpans[0]
+		scope.Spans = append(scope.Spans, toOTLP(s))
+	}
+	return req
+}
+
+func toOTLP(s SpanData) otlpSpan {
+	o := otlpSpan{
+		TraceID:           s.TraceID,
+		SpanID:            s.SpanID,
+		ParentSpanID:      s.ParentSpanID,
+		Name:              s.Name,
+		Kind:              otlpKinds[s.Kind],
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+	}
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		o.Attributes = append(o.Attributes, otlpKeyValue{Key: k, Value: otlpValue{s.Labels[k]}})
+	}
+	if msg, failed := s.Labels["error"]; failed {
+		o.Status = otlpStatus{Code: 2, Message: msg}
+	}
+	return o
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/m-okeefe/spookystore/internal/middleware"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TraceparentHeader is the W3C Trace Context header, and the gRPC metadata key
+// spans are propagated in.
+const TraceparentHeader = "traceparent"
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header value. It reports false
+// for values that don't identify a span.
+func ParseTraceparent(h string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// HTTPHandler traces the requests h serves, continuing the caller's trace if
+// the request has a traceparent header. The span is named after the path.
+func (t *Tracer) HTTPHandler(h http.Handler) http.Handler {
+	if t == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent, _ := ParseTraceparent(r.Header.Get(TraceparentHeader))
+		span := t.StartSpan(r.URL.Path, KindServer, parent)
+		defer span.Finish()
+		span.SetLabel("http/method", r.Method)
+		span.SetLabel("http/url", r.URL.String())
+		h.ServeHTTP(w, r.WithContext(NewContext(r.Context(), span)))
+	})
+}
+
+// GRPCClientInterceptor traces outgoing calls in a span named after the method,
+// and passes it on to the server.
+func (t *Tracer) GRPCClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span := t.StartSpan(method, KindClient, FromContext(ctx).Context())
+		if span == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		defer span.Finish()
+		err := invoker(withOutgoingSpan(ctx, span), method, req, reply, cc, opts...)
+		if err != nil {
+			span.SetLabel("error", err.Error())
+		}
+		return err
+	}
+}
+
+// GRPCStreamClientInterceptor passes the caller's span on with streaming
+// calls. Streams can outlive the caller, so they get no span of their own.
+func (t *Tracer) GRPCStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if span := FromContext(ctx); span != nil {
+			ctx = withOutgoingSpan(ctx, span)
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// GRPCServerInterceptor traces incoming calls in a span named after the full
+// method, continuing the caller's trace.
+func (t *Tracer) GRPCServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		span := t.StartSpan(info.FullMethod, KindServer, incomingSpan(ctx))
+		if span == nil {
+			return handler(ctx, req)
+		}
+		defer span.Finish()
+		resp, err := handler(NewContext(ctx, span), req)
+		if err != nil {
+			span.SetLabel("error", status.Code(err).String())
+		}
+		return resp, err
+	}
+}
+
+// GRPCStreamServerInterceptor traces incoming streams like
+// GRPCServerInterceptor does calls.
+func (t *Tracer) GRPCStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span := t.StartSpan(info.FullMethod, KindServer, incomingSpan(ss.Context()))
+		if span == nil {
+			return handler(srv, ss)
+		}
+		defer span.Finish()
+		err := handler(srv, middleware.WithStreamContext(ss, NewContext(ss.Context(), span)))
+		if err != nil {
+			span.SetLabel("error", status.Code(err).String())
+		}
+		return err
+	}
+}
+
+func withOutgoingSpan(ctx context.Context, span *Span) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, TraceparentHeader, span.Context().Traceparent())
+}
+
+func incomingSpan(ctx context.Context) SpanContext {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(TraceparentHeader); len(v) > 0 {
+		sc, _ := ParseTraceparent(v[0])
+		return sc
+	}
+	return SpanContext{}
+}
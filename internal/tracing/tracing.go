@@ -0,0 +1,355 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records spans of work and hands them to a pluggable
+// Exporter, in the spirit of OpenTelemetry. Traces cross process boundaries in
+// W3C traceparent headers, over HTTP and gRPC metadata alike.
+//
+// Spans live in the context:
+//
+//	span := tracing.FromContext(ctx).NewChild("usersvc/GetUser")
+//	defer span.Finish()
+//
+// A nil *Span and a nil *Tracer are valid and do nothing, so code can be
+// traced without checking whether tracing is on.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	// exportInterval is how often finished spans are exported
+	exportInterval = 5 * time.Second
+	// exportTimeout bounds a single export
+	exportTimeout = 10 * time.Second
+	// batchSize is how many finished spans trigger an export early
+	batchSize = 256
+	// maxQueue is how many finished spans are kept waiting for an export;
+	// more are dropped
+	maxQueue = 4096
+)
+
+// Kind says what a span represents.
+type Kind string
+
+const (
+	// KindInternal is work within a process.
+	KindInternal Kind = "internal"
+	// KindServer is the handling of an incoming request.
+	KindServer Kind = "server"
+	// KindClient is an outgoing request.
+	KindClient Kind = "client"
+)
+
+// SpanContext identifies a span across processes.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether sc identifies a span.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// SpanData is a finished span, as it is exported.
+type SpanData struct {
+	Service      string            `json:"service"`
+	Name         string            `json:"name"`
+	Kind         Kind              `json:"kind"`
+	TraceID      string            `json:"traceId"`
+	SpanID       string            `json:"spanId"`
+	ParentSpanID string            `json:"parentSpanId,omitempty"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// Sampler decides whether a new trace is recorded. Traces continued from
+// another process follow the caller's decision instead.
+type Sampler interface {
+	Sample() bool
+}
+
+type samplerFunc func() bool
+
+func (f samplerFunc) Sample() bool { return f() }
+
+// AlwaysSample records every trace.
+func AlwaysSample() Sampler { return samplerFunc(func() bool { return true }) }
+
+// NeverSample records no new traces.
+func NeverSample() Sampler { return samplerFunc(func() bool { return false }) }
+
+// LimitedSampler records at most qps new traces a second.
+func LimitedSampler(qps float64) Sampler {
+	if qps <= 0 {
+		return NeverSample()
+	}
+	return &limitedSampler{interval: time.Duration(float64(time.Second) / qps)}
+}
+
+type limitedSampler struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (l *limitedSampler) Sample() bool {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Before(l.next) {
+		return false
+	}
+	l.next = now.Add(l.interval)
+	return true
+}
+
+// Tracer starts spans and exports the sampled ones in batches, in the
+// background.
+type Tracer struct {
+	service string
+	exp     Exporter
+	sampler Sampler
+	log     *logrus.Entry
+
+	mu      sync.Mutex
+	pending []SpanData
+	dropped int
+
+	flush    chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTracer returns a Tracer that names spans as coming from service, and
+// exports them with exp. Export failures are logged to log.
+func NewTracer(service string, exp Exporter, sampler Sampler, log *logrus.Entry) *Tracer {
+	t := &Tracer{
+		service: service,
+		exp:     exp,
+		sampler: sampler,
+		log:     log,
+		flush:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// StartSpan starts a span, as a child of parent if it is valid, or else as
+// the root of a new trace.
+func (t *Tracer) StartSpan(name string, kind Kind, parent SpanContext) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{t: t, name: name, kind: kind, start: time.Now()}
+	if parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.parent = parent.SpanID
+	} else {
+		rand.Read(s.sc.TraceID[:])
+		s.sc.Sampled = t.sampler.Sample()
+	}
+	rand.Read(s.sc.SpanID[:])
+	return s
+}
+
+// Shutdown exports the spans finished so far and shuts down the exporter.
+// Spans finished afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.stopOnce.Do(func() { close(t.done) })
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exp.Shutdown(ctx)
+}
+
+// run exports finished spans every exportInterval, or sooner when a batch is
+// full, until the tracer shuts down
+func (t *Tracer) run() {
+	defer close(t.stopped)
+	tick := time.NewTicker(exportInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-t.flush:
+		case <-t.done:
+			t.export()
+			return
+		}
+		t.export()
+	}
+}
+
+func (t *Tracer) export() {
+	t.mu.Lock()
+	spans, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		t.log.WithField("spans", dropped).Warn("dropped spans, exports can't keep up")
+	}
+	if len(spans) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	if err := t.exp.ExportSpans(ctx, spans); err != nil {
+		t.log.WithFields(logrus.Fields{"error": err, "spans": len(spans)}).Warn("failed to export spans")
+	}
+}
+
+// finished queues a sampled span for export
+func (t *Tracer) finished(d SpanData) {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	t.mu.Lock()
+	if len(t.pending) >= maxQueue {
+		t.dropped++
+	} else {
+		t.pending = append(t.pending, d)
+	}
+	full := len(t.pending) >= batchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Span is a timed piece of work in a trace. Its methods are safe to call on a
+// nil *Span.
+type Span struct {
+	t      *Tracer
+	sc     SpanContext
+	parent [8]byte
+	name   string
+	kind   Kind
+	start  time.Time
+
+	mu       sync.Mutex
+	labels   map[string]string
+	finished bool
+}
+
+// NewChild starts a span for part of the work of s.
+func (s *Span) NewChild(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.t.StartSpan(name, KindInternal, s.sc)
+}
+
+// SetLabel annotates s with a key and value, until s is finished.
+func (s *Span) SetLabel(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return
+	}
+	if s.labels == nil {
+		s.labels = map[string]string{}
+	}
+	s.labels[key] = value
+}
+
+// Finish ends s and, if its trace is sampled, queues it for export. Calls after
+// the first do nothing.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	labels := s.labels
+	s.mu.Unlock()
+	if !s.sc.Sampled {
+		return
+	}
+	d := SpanData{
+		Service: s.t.service,
+		Name:    s.name,
+		Kind:    s.kind,
+		TraceID: hex.EncodeToString(s.sc.TraceID[:]),
+		SpanID:  hex.EncodeToString(s.sc.SpanID[:]),
+		Start:   s.start,
+		End:     time.Now(),
+		Labels:  labels,
+	}
+	if s.parent != [8]byte{} {
+		d.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	s.t.finished(d)
+}
+
+// Context returns the identity of s, to pass on to other processes.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// TraceID returns the hex ID of the trace s belongs to, or "" for a nil span.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.sc.TraceID[:])
+}
+
+type spanKey struct{}
+
+// NewContext returns a context carrying s.
+func NewContext(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// FromContext returns the span ctx carries, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
@@ -0,0 +1,210 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// recorder keeps the spans exported to it
+type recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *recorder) ExportSpans(_ context.Context, spans []SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *recorder) Shutdown(context.Context) error { return nil }
+
+func newTestTracer(sampler Sampler) (*Tracer, *recorder) {
+	rec := &recorder{}
+	return NewTracer("test", rec, sampler, logrus.NewEntry(logrus.New())), rec
+}
+
+func TestTraceparent(t *testing.T) {
+	const h = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(h)
+	if !ok || !sc.Sampled {
+		t.Fatalf("failed to parse %q: %+v", h, sc)
+	}
+	if got := sc.Traceparent(); got != h {
+		t.Errorf("got %q, want %q", got, h)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("expected %q to be invalid", bad)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	tr, rec := newTestTracer(AlwaysSample())
+	root := tr.StartSpan("/u/1", KindServer, SpanContext{})
+	child := root.NewChild("get_user")
+	child.SetLabel("user/id", "1")
+	child.Finish()
+	child.SetLabel("late", "ignored")
+	root.Finish()
+	root.Finish()
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("expected 2 spans, got %+v", rec.spans)
+	}
+	c, r := rec.spans[0], rec.spans[1]
+	if c.Name != "get_user" || c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID || r.ParentSpanID != "" {
+		t.Errorf("expected get_user to be a child of /u/1, got %+v and %+v", c, r)
+	}
+	if len(c.Labels) != 1 || c.Labels["user/id"] != "1" || c.Service != "test" {
+		t.Errorf("unexpected span %+v", c)
+	}
+
+	var nilSpan *Span
+	nilSpan.NewChild("x").SetLabel("k", "v")
+	nilSpan.Finish()
+	if FromContext(context.Background()) != nil || nilSpan.TraceID() != "" {
+		t.Error("expected no span")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	tr, rec := newTestTracer(NeverSample())
+	tr.StartSpan("unsampled", KindServer, SpanContext{}).Finish()
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	tr.StartSpan("continued", KindServer, parent).Finish()
+	tr.Shutdown(context.Background())
+	if len(rec.spans) != 1 || rec.spans[0].Name != "continued" {
+		t.Errorf("expected only the caller's sampled trace, got %+v", rec.spans)
+	}
+
+	s := LimitedSampler(1)
+	if !s.Sample() || s.Sample() {
+		t.Error("expected one trace a second")
+	}
+}
+
+func TestGRPCPropagation(t *testing.T) {
+	tr, rec := newTestTracer(AlwaysSample())
+	root := tr.StartSpan("/cart/u/1", KindServer, SpanContext{})
+	ctx := NewContext(context.Background(), root)
+
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	if err := tr.GRPCClientInterceptor()(ctx, "/SpookyStore/GetUser", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+
+	var server *Span
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		server = FromContext(ctx)
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/SpookyStore/GetUser"}
+	tr.GRPCServerInterceptor()(metadata.NewIncomingContext(context.Background(), md), nil, info, handler)
+	root.Finish()
+	tr.Shutdown(context.Background())
+
+	if len(rec.spans) != 3 {
+		t.Fatalf("expected client, server and root spans, got %+v", rec.spans)
+	}
+	client := rec.spans[0]
+	if server.TraceID() != root.TraceID() || rec.spans[1].ParentSpanID != client.SpanID || client.Kind != KindClient {
+		t.Errorf("expected the server span to continue the client's, got %+v", rec.spans)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	tr, rec := newTestTracer(NeverSample())
+	var traceID string
+	h := tr.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = FromContext(r.Context()).TraceID()
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/u/1", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	tr.Shutdown(context.Background())
+
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected to continue the caller's trace, got %q", traceID)
+	}
+	if len(rec.spans) != 1 || rec.spans[0].Name != "/u/1" || rec.spans[0].Labels["http/method"] != http.MethodGet {
+		t.Errorf("unexpected spans %+v", rec.spans)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got otlpTraces
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	start := time.Unix(1540000000, 0)
+	err := NewOTLPExporter(srv.URL+"/v1/traces").ExportSpans(context.Background(), []SpanData{{
+		Service: "web", Name: "get_user", Kind: KindClient,
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7",
+		Start: start, End: start.Add(time.Millisecond),
+		Labels: map[string]string{"error": "NotFound"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.ResourceSpans) != 1 {
+		t.Fatalf("expected one resource, got %+v", got)
+	}
+	rs := got.ResourceSpans[0]
+	if rs.Resource.Attributes[0].Value.StringValue != "web" {
+		t.Errorf("expected the service name, got %+v", rs.Resource)
+	}
+	s := rs.ScopeSpans[0].Spans[0]
+	if s.Kind != 3 || s.StartTimeUnixNano != "1540000000000000000" || s.Status.Code != 2 {
+		t.Errorf("unexpected span %+v", s)
+	}
+
+	if err := NewOTLPExporter(srv.URL+"/elsewhere").ExportSpans(context.Background(), nil); err == nil {
+		t.Error("expected collector errors to be returned")
+	}
+}
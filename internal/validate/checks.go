@@ -0,0 +1,128 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Check returns why a field value is invalid, or "" if it is valid. The
+// description reads after the field name, e.g. "must be positive".
+type Check func(v reflect.Value) string
+
+// Required rejects empty strings, zero numbers and empty lists.
+func Required(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return "is required"
+		}
+	default:
+		if v.Interface() == reflect.Zero(v.Type()).Interface() {
+			return "is required"
+		}
+	}
+	return ""
+}
+
+// ID rejects strings that are not a datastore ID, a positive integer. Empty
+// strings pass; combine with Required.
+func ID(v reflect.Value) string {
+	if s := v.String(); s != "" {
+		if id, err := strconv.ParseInt(s, 10, 64); err != nil || id <= 0 {
+			return "must be a positive integer ID"
+		}
+	}
+	return ""
+}
+
+// Email rejects strings without an @. Empty strings pass.
+func Email(v reflect.Value) string {
+	if s := v.String(); s != "" && !strings.Contains(s, "@") {
+		return "must be an email address"
+	}
+	return ""
+}
+
+// Positive rejects numbers below 1.
+func Positive(v reflect.Value) string {
+	if number(v) <= 0 {
+		return "must be positive"
+	}
+	return ""
+}
+
+// Min rejects numbers below n.
+func Min(n float64) Check {
+	return func(v reflect.Value) string {
+		if number(v) < n {
+			return fmt.Sprintf("must be at least %v", n)
+		}
+		return ""
+	}
+}
+
+// Max rejects numbers above n.
+func Max(n float64) Check {
+	return func(v reflect.Value) string {
+		if number(v) > n {
+			return fmt.Sprintf("must be at most %v", n)
+		}
+		return ""
+	}
+}
+
+// MaxLen rejects strings longer than n characters and lists longer than n
+// elements.
+func MaxLen(n int) Check {
+	return func(v reflect.Value) string {
+		if v.Kind() == reflect.String {
+			if utf8.RuneCountInString(v.String()) > n {
+				return fmt.Sprintf("must be at most %d characters", n)
+			}
+		} else if v.Len() > n {
+			return fmt.Sprintf("must have at most %d elements", n)
+		}
+		return ""
+	}
+}
+
+// Is rejects strings for which ok returns false, saying they must be desc.
+// Empty strings pass.
+func Is(ok func(string) bool, desc string) Check {
+	return func(v reflect.Value) string {
+		if s := v.String(); s != "" && !ok(s) {
+			return fmt.Sprintf("must be %s, not %q", desc, s)
+		}
+		return ""
+	}
+}
+
+// number returns the value of an integer or floating point field
+func number(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	panic(fmt.Sprintf("validate: %s is not a number", v.Type()))
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validate checks request messages against declarative rules, so
+// malformed requests are rejected before they reach a handler.
+package validate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Rules maps the name of each message, as returned by proto.MessageName, to
+// the rules of its fields. Fields holding messages that have rules of their
+// own are checked too.
+type Rules map[string][]Field
+
+// Field holds the checks of one field of a message.
+type Field struct {
+	Name   string
+	Checks []Check
+	// Elem checks each element of a repeated field
+	Elem []Check
+}
+
+// F returns the rules of field name.
+func F(name string, checks ...Check) Field {
+	return Field{Name: name, Checks: checks}
+}
+
+// Each returns f, also checking each element of the repeated field.
+func (f Field) Each(checks ...Check) Field {
+	f.Elem = append(f.Elem, checks...)
+	return f
+}
+
+// Validate returns an InvalidArgument error, with a BadRequest detail listing
+// every violation, if msg breaks its rules. Messages without rules are valid.
+func (r Rules) Validate(msg proto.Message) error {
+	v := r.Violations(msg)
+	if len(v) == 0 {
+		return nil
+	}
+	descs := make([]string, len(v))
+	for i, fv := range v {
+		descs[i] = fv.GetField() + " " + fv.GetDescription()
+	}
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid %s: %s", proto.MessageName(msg), strings.Join(descs, "; ")))
+	if d, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: v}); err == nil {
+		st = d
+	}
+	return st.Err()
+}
+
+// Violations returns the rules msg breaks.
+func (r Rules) Violations(msg proto.Message) []*errdetails.BadRequest_FieldViolation {
+	var out []*errdetails.BadRequest_FieldViolation
+	r.check(reflect.ValueOf(msg), "", &out)
+	return out
+}
+
+func (r Rules) check(m reflect.Value, prefix string, out *[]*errdetails.BadRequest_FieldViolation) {
+	if m.Kind() != reflect.Ptr || m.IsNil() {
+		return
+	}
+	msg, ok := m.Interface().(proto.Message)
+	if !ok {
+		return
+	}
+	name := proto.MessageName(msg)
+	for _, f := range r[name] {
+		fv := m.Elem().FieldByName(f.Name)
+		if !fv.IsValid() {
+			panic(fmt.Sprintf("validate: %s has no field %s", name, f.Name))
+		}
+		path := prefix + f.Name
+		add := func(path, desc string) {
+			*out = append(*out, &errdetails.BadRequest_FieldViolation{Field: path, Description: desc})
+		}
+		for _, c := range f.Checks {
+			if desc := c(fv); desc != "" {
+				add(path, desc)
+				break
+			}
+		}
+		if len(f.Elem) > 0 && fv.Kind() == reflect.Slice {
+			for i := 0; i < fv.Len(); i++ {
+				for _, c := range f.Elem {
+					if desc := c(fv.Index(i)); desc != "" {
+						add(fmt.Sprintf("%s[%d]", path, i), desc)
+						break
+					}
+				}
+			}
+		}
+	}
+	// nested messages
+	for i := 0; i < m.Elem().NumField(); i++ {
+		sf := m.Elem().Type().Field(i)
+		if strings.HasPrefix(sf.Name, "XXX_") {
+			continue
+		}
+		fv := m.Elem().Field(i)
+		switch {
+		case fv.Kind() == reflect.Ptr:
+			r.check(fv, prefix+sf.Name+".", out)
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Ptr:
+			for j := 0; j < fv.Len(); j++ {
+				r.check(fv.Index(j), fmt.Sprintf("%s%s[%d].", prefix, sf.Name, j), out)
+			}
+		}
+	}
+}
+
+// UnaryServerInterceptor rejects calls whose request breaks the rules.
+func (r Rules) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if msg, ok := req.(proto.Message); ok {
+			if err := r.Validate(msg); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streaming calls whose requests break the
+// rules.
+func (r Rules) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: ss, rules: r})
+	}
+}
+
+// validatingStream checks every message it receives
+type validatingStream struct {
+	grpc.ServerStream
+	rules Rules
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if msg, ok := m.(proto.Message); ok {
+		return s.rules.Validate(msg)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/m-okeefe/spookystore/internal/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		check Check
+		value interface{}
+		want  string
+	}{
+		{"required string", Required, "x", ""},
+		{"required empty string", Required, "", "is required"},
+		{"required number", Required, int32(3), ""},
+		{"required zero", Required, int32(0), "is required"},
+		{"required list", Required, []string{"a"}, ""},
+		{"required empty list", Required, []string(nil), "is required"},
+		{"id", ID, "5629499534213120", ""},
+		{"id empty", ID, "", ""},
+		{"id word", ID, "casper", "must be a positive integer ID"},
+		{"id zero", ID, "0", "must be a positive integer ID"},
+		{"id negative", ID, "-4", "must be a positive integer ID"},
+		{"email", Email, "casper@example.com", ""},
+		{"email empty", Email, "", ""},
+		{"email no at", Email, "casper", "must be an email address"},
+		{"positive", Positive, int32(1), ""},
+		{"positive zero", Positive, int32(0), "must be positive"},
+		{"positive negative", Positive, int32(-2), "must be positive"},
+		{"min", Min(0), float32(0), ""},
+		{"min below", Min(0), float32(-0.5), "must be at least 0"},
+		{"max", Max(60), int64(60), ""},
+		{"max above", Max(60), int64(61), "must be at most 60"},
+		{"max length", MaxLen(5), "boo!!", ""},
+		{"max length counts characters", MaxLen(5), "👻👻👻👻👻", ""},
+		{"max length exceeded", MaxLen(5), "boo!!!", "must be at most 5 characters"},
+		{"max length list", MaxLen(1), []string{"a", "b"}, "must have at most 1 elements"},
+		{"is", Is(func(s string) bool { return s == "ghost" }, "a ghost"), "ghost", ""},
+		{"is empty", Is(func(s string) bool { return false }, "a ghost"), "", ""},
+		{"is not", Is(func(s string) bool { return s == "ghost" }, "a ghost"), "bat", `must be a ghost, not "bat"`},
+	}
+	for _, test := range tests {
+		if got := test.check(reflect.ValueOf(test.value)); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+var testRules = Rules{
+	"AddProductRequest": {
+		F("UserID", Required, ID),
+		F("Quantity", Positive),
+	},
+	"ImportProductsRequest": {
+		F("Products", Required),
+	},
+	"Product": {
+		F("DisplayName", Required),
+	},
+	"SetUserRolesRequest": {
+		F("Roles").Each(Is(func(s string) bool { return s == "admin" }, "admin")),
+	},
+}
+
+func fields(v []*errdetails.BadRequest_FieldViolation) []string {
+	var out []string
+	for _, fv := range v {
+		out = append(out, fv.GetField())
+	}
+	return out
+}
+
+func TestViolations(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		want []string
+	}{
+		{"valid", &pb.AddProductRequest{UserID: "1", Quantity: 1}, nil},
+		{"first failing check per field", &pb.AddProductRequest{Quantity: -1}, []string{"UserID", "Quantity"}},
+		{"no rules", &pb.GetAllProductsRequest{}, nil},
+		{"nested", &pb.ImportProductsRequest{Products: []*pb.Product{{DisplayName: "candle"}, {}}}, []string{"Products[1].DisplayName"}},
+		{"each", &pb.SetUserRolesRequest{Roles: []string{"admin", "ghost"}}, []string{"Roles[1]"}},
+	}
+	for _, test := range tests {
+		got := fields(testRules.Violations(test.msg))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got violations %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := testRules.Validate(&pb.AddProductRequest{UserID: "casper", Quantity: 0})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if want := "invalid AddProductRequest: UserID must be a positive integer ID; Quantity must be positive"; st.Message() != want {
+		t.Errorf("got message %q, want %q", st.Message(), want)
+	}
+	br, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok || !reflect.DeepEqual(fields(br.GetFieldViolations()), []string{"UserID", "Quantity"}) {
+		t.Errorf("expected field violations for UserID and Quantity, got %v", st.Details())
+	}
+}
+
+func TestUnknownField(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a rule for a missing field to panic")
+		}
+	}()
+	Rules{"UserRequest": {F("Name", Required)}}.Violations(&pb.UserRequest{})
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	intercept := testRules.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/SpookyStore/AddProductToCart"}
+
+	if _, err := intercept(context.Background(), &pb.AddProductRequest{UserID: "1"}, info, handler); status.Code(err) != codes.InvalidArgument || called {
+		t.Errorf("expected an invalid request to be rejected, got %v", err)
+	}
+	if _, err := intercept(context.Background(), &pb.AddProductRequest{UserID: "1", Quantity: 2}, info, handler); err != nil || !called {
+		t.Errorf("expected a valid request to be handled, got %v", err)
+	}
+}
+
+// recvStream is a server stream whose only message is req
+type recvStream struct {
+	grpc.ServerStream
+	req *pb.AddProductRequest
+}
+
+func (s *recvStream) RecvMsg(m interface{}) error {
+	*m.(*pb.AddProductRequest) = *s.req
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		return ss.RecvMsg(&pb.AddProductRequest{})
+	}
+	intercept := testRules.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/SpookyStore/WatchCart", IsServerStream: true}
+
+	if err := intercept(nil, &recvStream{req: &pb.AddProductRequest{UserID: "1"}}, info, handler); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an invalid request to be rejected, got %v", err)
+	}
+	if err := intercept(nil, &recvStream{req: &pb.AddProductRequest{UserID: "1", Quantity: 2}}, info, handler); err != nil {
+		t.Errorf("expected a valid request to be received, got %v", err)
+	}
+}